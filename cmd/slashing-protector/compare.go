@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type compareCmd struct {
+	Network string   `required:"" help:"Network of the keys to compare"`
+	PubKeys []string `help:"Public keys to compare, in addition to the ones found in data directories"`
+
+	A string `arg:"" help:"URL of an instance or path to a data directory"`
+	B string `arg:"" help:"URL of an instance or path to a data directory"`
+
+	JWTSecretFile string `env:"JWT_SECRET_FILE" help:"JWT secret of the instances, if they require JWTs"`
+}
+
+func (cmd *compareCmd) Run() (err error) {
+	ctx := context.Background()
+
+	opts, err := clientOptions(cmd.JWTSecretFile)
+	if err != nil {
+		return err
+	}
+	a, err := openCompareSource(cmd.A, opts...)
+	if err != nil {
+		return err
+	}
+	b, err := openCompareSource(cmd.B, opts...)
+	if err != nil {
+		return multierr.Append(err, a.Close())
+	}
+	defer func() {
+		err = multierr.Combine(err, a.Close(), b.Close())
+	}()
+
+	// Collect the public keys to compare.
+	pubKeys, err := parsePubKeys(cmd.PubKeys)
+	if err != nil {
+		return err
+	}
+	keys := map[phase0.BLSPubKey]struct{}{}
+	for _, pubKey := range pubKeys {
+		keys[pubKey] = struct{}{}
+	}
+	for _, source := range []compareSource{a, b} {
+		pubKeys, listed, err := source.PubKeys(cmd.Network)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list public keys of %s", source)
+		}
+		if listed {
+			for pubKey := range pubKeys {
+				keys[pubKey] = struct{}{}
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("no public keys to compare, specify them with --pub-keys")
+	}
+	sorted := make([]phase0.BLSPubKey, 0, len(keys))
+	for pubKey := range keys {
+		sorted = append(sorted, pubKey)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+
+	// Compare the digests of every key.
+	var diverged int
+	for _, pubKey := range sorted {
+		digestA, err := compareDigest(ctx, a, cmd.Network, pubKey)
+		if err != nil {
+			return err
+		}
+		digestB, err := compareDigest(ctx, b, cmd.Network, pubKey)
+		if err != nil {
+			return err
+		}
+		if digestA != nil && digestB != nil && *digestA == *digestB {
+			continue
+		}
+		diverged++
+		fmt.Printf("%#x\n  A: %s\n  B: %s\n", pubKey, formatDigest(digestA), formatDigest(digestB))
+	}
+	fmt.Printf("Compared %d keys, %d diverged\n", len(sorted), diverged)
+	if diverged > 0 {
+		return errors.Errorf("%d keys diverged", diverged)
+	}
+	return nil
+}
+
+// compareDigest returns the digest of a key, or nil if it's missing from the source.
+func compareDigest(ctx context.Context, source compareSource, network string, pubKey phase0.BLSPubKey) (*kv.Digest, error) {
+	pubKeys, listed, err := source.PubKeys(network)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := pubKeys[pubKey]; listed && !ok {
+		return nil, nil
+	}
+	digest, err := source.Digest(ctx, network, pubKey)
+	if errors.Is(err, protector.ErrUnknownKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get digest of %#x from %s", pubKey, source)
+	}
+	return digest, nil
+}
+
+func formatDigest(digest *kv.Digest) string {
+	if digest == nil {
+		return "missing"
+	}
+	return fmt.Sprintf(
+		"%#x (%d attestations, %d proposals)",
+		digest.Root,
+		digest.Attestations,
+		digest.Proposals,
+	)
+}
+
+// compareSource is an instance or a data directory to compare.
+type compareSource interface {
+	fmt.Stringer
+
+	// PubKeys returns the public keys in the source,
+	// or false if the source can't list them.
+	PubKeys(network string) (map[phase0.BLSPubKey]struct{}, bool, error)
+
+	// Digest returns the digest of a key, or protector.ErrUnknownKey if the
+	// source has no history for it, without creating one.
+	Digest(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Digest, error)
+	Close() error
+}
+
+func openCompareSource(location string, opts ...protectorhttp.ClientOption) (compareSource, error) {
+	if isURL(location) {
+		return &urlSource{
+			url:    location,
+			client: protectorhttp.NewClient(&http.Client{Timeout: 30 * time.Second}, location, opts...),
+		}, nil
+	}
+	if _, err := os.Stat(location); err != nil {
+		return nil, errors.Wrap(err, "failed to open data directory")
+	}
+	return &dirSource{dir: location, pool: kvpool.New(location)}, nil
+}
+
+// urlSource is a running instance.
+type urlSource struct {
+	url    string
+	client *protectorhttp.Client
+}
+
+func (s *urlSource) String() string {
+	return s.url
+}
+
+func (s *urlSource) PubKeys(string) (map[phase0.BLSPubKey]struct{}, bool, error) {
+	return nil, false, nil
+}
+
+func (s *urlSource) Digest(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Digest, error) {
+	return s.client.Digest(ctx, network, pubKey)
+}
+
+func (s *urlSource) Close() error {
+	return nil
+}
+
+// dirSource is a data directory, which must not be in use by an instance.
+type dirSource struct {
+	dir     string
+	pool    *kvpool.Pool
+	pubKeys map[phase0.BLSPubKey]struct{}
+}
+
+func (s *dirSource) String() string {
+	return s.dir
+}
+
+func (s *dirSource) PubKeys(network string) (map[phase0.BLSPubKey]struct{}, bool, error) {
+	if s.pubKeys == nil {
+		pubKeys, err := s.pool.PubKeys(network)
+		if err != nil {
+			return nil, false, err
+		}
+		s.pubKeys = make(map[phase0.BLSPubKey]struct{}, len(pubKeys))
+		for _, pubKey := range pubKeys {
+			s.pubKeys[pubKey] = struct{}{}
+		}
+	}
+	return s.pubKeys, true, nil
+}
+
+func (s *dirSource) Digest(ctx context.Context, network string, pubKey phase0.BLSPubKey) (digest *kv.Digest, err error) {
+	if !s.pool.Exists(network, pubKey) {
+		return nil, protector.ErrUnknownKey
+	}
+	conn, err := s.pool.Acquire(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = multierr.Append(err, conn.Release())
+	}()
+	return conn.Digest()
+}
+
+func (s *dirSource) Close() error {
+	return s.pool.Close()
+}
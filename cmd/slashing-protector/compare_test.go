@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCompareDigest(t *testing.T) {
+	ctx := context.Background()
+	known, unknown := phase0.BLSPubKey{0x1}, phase0.BLSPubKey{0x2}
+
+	// Sign with the known key on an instance.
+	dir := t.TempDir()
+	prtc := protector.New(dir)
+	check, err := prtc.CheckProposal(ctx, "mainnet", known, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	server := httptest.NewServer(protectorhttp.NewServer(zap.NewNop(), prtc))
+	instance, err := openCompareSource(server.URL)
+	require.NoError(t, err)
+
+	// Expect the known key to have a digest, and the unknown key to be
+	// missing without being created on the instance.
+	digest, err := compareDigest(ctx, instance, "mainnet", known)
+	require.NoError(t, err)
+	require.Equal(t, 1, digest.Proposals)
+	digest, err = compareDigest(ctx, instance, "mainnet", unknown)
+	require.NoError(t, err)
+	require.Nil(t, digest)
+	pubKeys, err := prtc.(protector.ProtectorPooler).Pool().PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Equal(t, []phase0.BLSPubKey{known}, pubKeys)
+
+	// Expect the same digests from the data directory once the instance is
+	// stopped, and the unknown key to be missing from it too.
+	server.Close()
+	require.NoError(t, prtc.Close())
+	dirSource, err := openCompareSource(dir)
+	require.NoError(t, err)
+	defer dirSource.Close()
+	fromDir, err := compareDigest(ctx, dirSource, "mainnet", known)
+	require.NoError(t, err)
+	require.Equal(t, 1, fromDir.Proposals)
+	fromDir, err = compareDigest(ctx, dirSource, "mainnet", unknown)
+	require.NoError(t, err)
+	require.Nil(t, fromDir)
+}
+
+func TestCompareCmd(t *testing.T) {
+	ctx := context.Background()
+	pubKey := phase0.BLSPubKey{0x1}
+	sign := func(slots ...phase0.Slot) string {
+		dir := t.TempDir()
+		prtc := protector.New(dir)
+		defer prtc.Close()
+		for _, slot := range slots {
+			check, err := prtc.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, slot)
+			require.NoError(t, err)
+			require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+		}
+		return dir
+	}
+	a, b, c := sign(1), sign(1), sign(1, 2)
+
+	// Expect equal histories to match, and diverged or missing ones not to.
+	require.NoError(t, (&compareCmd{Network: "mainnet", A: a, B: b}).Run())
+	require.ErrorContains(t, (&compareCmd{Network: "mainnet", A: a, B: c}).Run(), "1 keys diverged")
+	require.ErrorContains(t, (&compareCmd{Network: "mainnet", A: a, B: t.TempDir()}).Run(), "1 keys diverged")
+}
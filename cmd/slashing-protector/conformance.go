@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bloxapp/slashing-protector/protector/conformance"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type conformanceCmd struct {
+	Paths []string `arg:"" help:"Test vector files, or directories to search for them"`
+}
+
+func (cmd *conformanceCmd) Run() error {
+	ctx := context.Background()
+
+	var fileNames []string
+	for _, path := range cmd.Paths {
+		err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && filepath.Ext(path) == ".json" {
+				fileNames = append(fileNames, path)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(fileNames) == 0 {
+		return errors.New("no test vectors found")
+	}
+	sort.Strings(fileNames)
+
+	var failed int
+	for _, fileName := range fileNames {
+		test, err := conformance.Load(fileName)
+		if err != nil {
+			return err
+		}
+		mismatches, err := runConformance(ctx, test)
+		if err != nil {
+			return errors.Wrapf(err, "failed to run %s", fileName)
+		}
+		if len(mismatches) == 0 {
+			fmt.Printf("PASS %s\n", test.Name)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", test.Name)
+		for _, m := range mismatches {
+			fmt.Printf("  %s\n", m)
+		}
+	}
+	fmt.Printf("Ran %d tests, %d failed\n", len(fileNames), failed)
+	if failed > 0 {
+		return errors.Errorf("%d tests failed", failed)
+	}
+	return nil
+}
+
+// runConformance runs a test against a protector in a temporary directory.
+func runConformance(ctx context.Context, test *conformance.Test) (mismatches []*conformance.Mismatch, err error) {
+	dir, err := os.MkdirTemp("", "slashing-protector-conformance")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = multierr.Append(err, os.RemoveAll(dir))
+	}()
+	return conformance.Run(ctx, dir, test)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type convertCmd struct {
+	To     string `required:"" enum:"minimal,complete" help:"Format to convert to: 'minimal' keeps only the watermarks of each key, and 'complete' merges and orders the records of each key"`
+	Output string `short:"o" help:"File to write the converted interchange to (defaults to stdout)"`
+
+	File string `arg:"" type:"existingfile" help:"Interchange file to convert"`
+}
+
+func (cmd *convertCmd) Run() (err error) {
+	b, err := os.ReadFile(cmd.File)
+	if err != nil {
+		return err
+	}
+	var in interchange.Interchange
+	if err := json.Unmarshal(b, &in); err != nil {
+		return errors.Wrap(err, "failed to decode interchange")
+	}
+
+	var converted *interchange.Interchange
+	if cmd.To == "minimal" {
+		converted, err = interchange.Minimize(&in)
+	} else {
+		converted, err = interchange.Complete(&in)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to convert")
+	}
+
+	out := os.Stdout
+	if cmd.Output != "" {
+		out, err = os.Create(cmd.Output)
+		if err != nil {
+			return errors.Wrap(err, "failed to create output file")
+		}
+		defer func() {
+			err = multierr.Append(err, out.Close())
+		}()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(converted)
+}
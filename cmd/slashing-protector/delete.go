@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type deleteCmd struct {
+	Network     string            `required:"" help:"Network of the keys to delete"`
+	PubKeys     []string          `required:"" help:"Public keys to delete"`
+	Archive     bool              `help:"Archive the histories instead of removing them"`
+	Tombstones  bool              `help:"Keep the watermarks of the histories when deleting from a data directory (instances keep them with TOMBSTONES)"`
+	AdminToken  string            `env:"ADMIN_TOKEN" help:"Admin token of the instance"`
+	NetworkDirs map[string]string `env:"NETWORK_DIRS" help:"Directories of networks whose databases are kept outside of the data directory, as given to instances"`
+
+	Target string `arg:"" help:"URL of an instance, or path to a data directory which isn't in use"`
+}
+
+func (cmd *deleteCmd) Run() (err error) {
+	pubKeys, err := parsePubKeys(cmd.PubKeys)
+	if err != nil {
+		return err
+	}
+
+	var archiveDir string
+	if isURL(cmd.Target) {
+		client := protectorhttp.NewClient(
+			&http.Client{Timeout: 5 * time.Minute},
+			cmd.Target,
+			protectorhttp.WithClientAdminToken(cmd.AdminToken),
+		)
+		archiveDir, err = client.Delete(context.Background(), cmd.Network, pubKeys, cmd.Archive)
+	} else {
+		opts := []kvpool.Option{kvpool.WithNetworkDirs(cmd.NetworkDirs)}
+		if cmd.Tombstones {
+			opts = append(opts, kvpool.WithTombstones())
+		}
+		pool := kvpool.New(cmd.Target, opts...)
+		defer func() {
+			err = multierr.Append(err, pool.Close())
+		}()
+		archiveDir, err = pool.Delete(context.Background(), cmd.Network, pubKeys, cmd.Archive)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to delete")
+	}
+
+	fmt.Printf("Deleted %d keys\n", len(pubKeys))
+	if archiveDir != "" {
+		fmt.Printf("Archived into %s\n", archiveDir)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/carlmjohnson/requests"
+	"go.uber.org/zap"
+)
+
+// duplicateKeyEvent is the body of the webhook of a duplicate key.
+type duplicateKeyEvent struct {
+	PubKey   string    `json:"pub_key"`
+	Networks []string  `json:"networks"`
+	Time     time.Time `json:"time"`
+}
+
+// reportDuplicateKey returns a function which logs duplicate keys,
+// and posts them to webhookURL unless it's empty.
+func reportDuplicateKey(logger *zap.Logger, webhookURL string) func(*protector.DuplicateKey) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(d *protector.DuplicateKey) {
+		event := &duplicateKeyEvent{
+			PubKey:   fmt.Sprintf("%#x", d.PubKey),
+			Networks: d.Networks,
+			Time:     d.Time,
+		}
+		logger.Warn("public key is checked on more than one network, which likely means a validator client is misconfigured",
+			zap.String("pub_key", event.PubKey),
+			zap.Strings("networks", event.Networks),
+		)
+		if webhookURL == "" {
+			return
+		}
+		// Checks mustn't wait for the webhook.
+		go func() {
+			err := requests.
+				URL(webhookURL).
+				Client(client).
+				BodyJSON(event).
+				Fetch(context.Background())
+			if err != nil {
+				logger.Error("failed to post duplicate key to webhook", zap.Error(err))
+			}
+		}()
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector/nats"
+	"go.uber.org/zap"
+)
+
+// publishDecisions returns a function which publishes decisions to publisher
+// under "<prefix>.<network>.<outcome>", so that subscribers can select all
+// of them with "<prefix>.>" or only the slashable attempts with
+// "<prefix>.*.slashable".
+func publishDecisions(logger *zap.Logger, publisher *nats.Publisher, prefix string) func(*protectorhttp.DecisionEvent) {
+	return func(event *protectorhttp.DecisionEvent) {
+		data, err := json.Marshal(event)
+		if err != nil {
+			logger.Error("failed to marshal decision event", zap.Error(err))
+			return
+		}
+		publisher.Publish(prefix+"."+event.Network+"."+event.Outcome(), data)
+	}
+}
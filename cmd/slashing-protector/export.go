@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type exportCmd struct {
+	Network               string            `required:"" help:"Network of the keys to export"`
+	PubKeys               []string          `help:"Public keys to export (defaults to all keys)"`
+	PubKeysFile           string            `type:"existingfile" help:"File with public keys to export, one per line"`
+	Output                string            `short:"o" help:"File to write the export to (defaults to stdout, and is required by the prysm format)"`
+	Format                string            `enum:"json,csv,prysm" default:"json" help:"Format of the export: the EIP-3076 interchange (json), a row per signed block or attestation (csv), or a Prysm validator database (prysm)"`
+	Minimal               bool              `help:"Export only the watermarks of each key (the EIP-3076 minimal format): a block at the highest signed slot, and an attestation at the highest signed source and target epochs"`
+	GenesisValidatorsRoot string            `help:"Genesis validators root of the network when exporting from a data directory, for networks which aren't known (instances use their own)"`
+	NetworkDirs           map[string]string `env:"NETWORK_DIRS" help:"Directories of networks whose databases are kept outside of the data directory, as given to instances"`
+	JWTSecretFile         string            `env:"JWT_SECRET_FILE" help:"JWT secret of the instance, if it requires JWTs"`
+
+	Source string `arg:"" help:"URL of an instance, or path to a data directory which isn't in use"`
+}
+
+func (cmd *exportCmd) Run() (err error) {
+	ctx := context.Background()
+	pubKeys, err := cmd.pubKeys()
+	if err != nil {
+		return err
+	}
+	if cmd.Format == "prysm" && cmd.Output == "" {
+		return errors.New("--output is required by the prysm format")
+	}
+
+	var exported *interchange.Interchange
+	if isURL(cmd.Source) {
+		opts, optsErr := clientOptions(cmd.JWTSecretFile)
+		if optsErr != nil {
+			return optsErr
+		}
+		client := protectorhttp.NewClient(&http.Client{Timeout: 5 * time.Minute}, cmd.Source, opts...)
+		if cmd.Minimal {
+			exported, err = client.ExportMinimal(ctx, cmd.Network, pubKeys)
+		} else {
+			exported, err = client.Export(ctx, cmd.Network, pubKeys)
+		}
+	} else {
+		root, rootErr := genesisValidatorsRoot(cmd.Network, cmd.GenesisValidatorsRoot)
+		if rootErr != nil {
+			return rootErr
+		}
+		prtc := protector.New(cmd.Source, protector.WithPoolOptions(kvpool.WithNetworkDirs(cmd.NetworkDirs)))
+		defer func() {
+			err = multierr.Append(err, prtc.Close())
+		}()
+		if len(pubKeys) == 0 {
+			pubKeys, err = prtc.(protector.ProtectorPooler).Pool().PubKeys(cmd.Network)
+			if err != nil {
+				return errors.Wrap(err, "failed to list public keys")
+			}
+		}
+		export := interchange.Export
+		if cmd.Minimal {
+			export = interchange.ExportMinimal
+		}
+		exported, err = export(ctx, prtc, cmd.Network, root, pubKeys)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to export")
+	}
+
+	// Prysm's database is created by bolt rather than written to.
+	if cmd.Format == "prysm" {
+		return interchange.WritePrysm(cmd.Output, exported)
+	}
+
+	out := os.Stdout
+	if cmd.Output != "" {
+		out, err = os.Create(cmd.Output)
+		if err != nil {
+			return errors.Wrap(err, "failed to create output file")
+		}
+		defer func() {
+			err = multierr.Append(err, out.Close())
+		}()
+	}
+	if cmd.Format == "csv" {
+		return interchange.WriteCSV(out, exported)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(exported)
+}
+
+// pubKeys returns the public keys given with --pub-keys and --pub-keys-file.
+func (cmd *exportCmd) pubKeys() ([]phase0.BLSPubKey, error) {
+	values := cmd.PubKeys
+	if cmd.PubKeysFile != "" {
+		f, err := os.Open(cmd.PubKeysFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open public keys file")
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				values = append(values, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "failed to read public keys file")
+		}
+	}
+	return parsePubKeys(values)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type importCmd struct {
+	Network               string            `required:"" help:"Network to import the interchange into"`
+	GenesisValidatorsRoot string            `help:"Genesis validators root of the network, for networks which aren't known (instances use their own)"`
+	AdminToken            string            `env:"ADMIN_TOKEN" help:"Admin token of the instance"`
+	NetworkDirs           map[string]string `env:"NETWORK_DIRS" help:"Directories of networks whose databases are kept outside of the data directory, as given to instances"`
+
+	File   string `arg:"" type:"existingfile" help:"Interchange file to import"`
+	Target string `arg:"" help:"URL of an instance, or path to a data directory which isn't in use"`
+}
+
+func (cmd *importCmd) Run() (err error) {
+	b, err := os.ReadFile(cmd.File)
+	if err != nil {
+		return err
+	}
+	var exported interchange.Interchange
+	if err := json.Unmarshal(b, &exported); err != nil {
+		return errors.Wrap(err, "failed to decode interchange")
+	}
+
+	if isURL(cmd.Target) {
+		client := protectorhttp.NewClient(
+			&http.Client{Timeout: 5 * time.Minute},
+			cmd.Target,
+			protectorhttp.WithClientAdminToken(cmd.AdminToken),
+		)
+		_, err = client.Import(context.Background(), cmd.Network, &exported)
+	} else {
+		root, rootErr := genesisValidatorsRoot(cmd.Network, cmd.GenesisValidatorsRoot)
+		if rootErr != nil {
+			return rootErr
+		}
+		pool := kvpool.New(cmd.Target, kvpool.WithNetworkDirs(cmd.NetworkDirs))
+		defer func() {
+			err = multierr.Append(err, pool.Close())
+		}()
+		// Interrupted imports resume when they're run again.
+		err = interchange.Import(context.Background(), pool, cmd.Network, root, &exported,
+			interchange.WithResume(),
+			interchange.WithProgress(func(progress interchange.ImportProgress) {
+				fmt.Fprintf(os.Stderr, "Imported %d of %d keys\n", progress.Imported, progress.Total)
+			}),
+		)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to import")
+	}
+
+	fmt.Printf("Imported %d keys\n", len(exported.Data))
+	return nil
+}
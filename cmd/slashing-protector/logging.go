@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// backupTimeFormat is the format of the timestamp which
+// rotated log files are suffixed with, which sorts by time.
+const backupTimeFormat = "20060102T150405.000"
+
+// buildLogger builds the logger of the CLI, which logs to stderr,
+// to a rotated file, or to both.
+func buildLogger() (*zap.Logger, error) {
+	config := zap.NewDevelopmentConfig()
+	if err := config.Level.UnmarshalText([]byte(CLI.LogLevel)); err != nil {
+		return nil, err
+	}
+	if CLI.LogFile == "" {
+		return config.Build()
+	}
+	file, err := openRotatingFile(CLI.LogFile, CLI.LogMaxSize<<20, CLI.LogMaxAge, CLI.LogMaxBackups)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open log file")
+	}
+	return config.Build(zap.WrapCore(func(console zapcore.Core) zapcore.Core {
+		core := zapcore.NewCore(zapcore.NewConsoleEncoder(config.EncoderConfig), file, config.Level)
+		if !CLI.LogConsole {
+			return core
+		}
+		return zapcore.NewTee(console, core)
+	}))
+}
+
+// rotatingFile is a log file which is rotated once it reaches a maximum
+// size, keeping a bounded number of rotated files for a bounded time.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens the log file at path for appending, which is
+// rotated once it reaches maxSize bytes (or never if zero). Rotated files
+// older than maxAge or beyond the latest maxBackups are removed, unless
+// they're zero.
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, f.removeBackups()
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync()
+}
+
+// open opens the log file for appending. Must be called with mu held.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the log file after the current time, opens a new one
+// and removes the expired backups. Must be called with mu held.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	backup, err := f.backupName(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.removeBackups()
+}
+
+// backupName returns the name of a backup rotated at now which doesn't exist
+// yet. Backups rotated within the same millisecond are suffixed with
+// a sequence number, so that they don't overwrite each other.
+func (f *rotatingFile) backupName(now time.Time) (string, error) {
+	name := f.path + "." + now.Format(backupTimeFormat)
+	for seq := 1; ; seq++ {
+		_, err := os.Lstat(name)
+		if os.IsNotExist(err) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s.%s-%d", f.path, now.Format(backupTimeFormat), seq)
+	}
+}
+
+// logBackup is a rotated log file.
+type logBackup struct {
+	name    string
+	rotated time.Time
+	seq     int
+}
+
+// parseBackup parses the time and sequence number of a rotated
+// log file, and reports whether name is one.
+func (f *rotatingFile) parseBackup(name string) (logBackup, bool) {
+	suffix := strings.TrimPrefix(name, f.path+".")
+	backup := logBackup{name: name}
+	if i := strings.LastIndexByte(suffix, '-'); i >= 0 {
+		seq, err := strconv.Atoi(suffix[i+1:])
+		if err != nil || seq <= 0 {
+			return logBackup{}, false
+		}
+		suffix, backup.seq = suffix[:i], seq
+	}
+	rotated, err := time.Parse(backupTimeFormat, suffix)
+	if err != nil {
+		return logBackup{}, false
+	}
+	backup.rotated = rotated
+	return backup, true
+}
+
+// removeBackups removes the rotated files which are older than maxAge
+// or beyond the latest maxBackups. Other files matching the name of
+// the log file are left alone, and don't count towards maxBackups.
+func (f *rotatingFile) removeBackups() error {
+	names, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return err
+	}
+	var backups []logBackup
+	for _, name := range names {
+		if backup, ok := f.parseBackup(name); ok {
+			backups = append(backups, backup)
+		}
+	}
+	// Sort backups by the time they were rotated at, newest first.
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].rotated.Equal(backups[j].rotated) {
+			return backups[i].rotated.After(backups[j].rotated)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	for i, backup := range backups {
+		expired := f.maxAge > 0 && time.Since(backup.rotated) > f.maxAge
+		if expired || (f.maxBackups > 0 && i >= f.maxBackups) {
+			if err := os.Remove(backup.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protector.log")
+	f, err := openRotatingFile(path, 4, 0, 0)
+	require.NoError(t, err)
+	defer f.file.Close()
+
+	// Rotate several times within the same millisecond, and expect
+	// every line to be kept in it's own file.
+	lines := []string{"aaa\n", "bbb\n", "ccc\n", "ddd\n"}
+	for _, line := range lines {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	names, err := filepath.Glob(path + "*")
+	require.NoError(t, err)
+	require.Len(t, names, len(lines))
+	var got []string
+	for _, name := range names {
+		b, err := os.ReadFile(name)
+		require.NoError(t, err)
+		got = append(got, string(b))
+	}
+	require.ElementsMatch(t, lines, got)
+}
+
+func TestRotatingFile_BackupName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protector.log")
+	f := &rotatingFile{path: path}
+	now := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Expect names rotated at the same time to be suffixed with a
+	// sequence number, and to sort by it.
+	var backups []logBackup
+	for i := 0; i < 11; i++ {
+		name, err := f.backupName(now)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(name, nil, 0600))
+		backup, ok := f.parseBackup(name)
+		require.True(t, ok, name)
+		require.True(t, now.Equal(backup.rotated))
+		require.Equal(t, i, backup.seq)
+		backups = append(backups, backup)
+	}
+	require.Equal(t, path+".20220102T030405.000", backups[0].name)
+	require.Equal(t, path+".20220102T030405.000-10", backups[10].name)
+}
+
+func TestRotatingFile_RemoveBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "protector.log")
+	now := time.Now().UTC()
+	backup := func(age time.Duration, seq string) string {
+		return path + "." + now.Add(-age).Format(backupTimeFormat) + seq
+	}
+	for name, tt := range map[string]struct {
+		maxAge     time.Duration
+		maxBackups int
+		files      []string
+		expected   []string
+	}{
+		"latest backups": {
+			maxBackups: 2,
+			files:      []string{backup(3*time.Hour, ""), backup(2*time.Hour, ""), backup(time.Hour, ""), backup(time.Hour, "-1")},
+			expected:   []string{backup(time.Hour, ""), backup(time.Hour, "-1")},
+		},
+		"sequence numbers": {
+			maxBackups: 1,
+			files:      []string{backup(time.Hour, "-9"), backup(time.Hour, "-10")},
+			expected:   []string{backup(time.Hour, "-10")},
+		},
+		"unparsed files": {
+			maxBackups: 1,
+			files:      []string{path + ".bak", path + ".zzz", backup(2*time.Hour, ""), backup(time.Hour, "")},
+			expected:   []string{path + ".bak", path + ".zzz", backup(time.Hour, "")},
+		},
+		"expired backups": {
+			maxAge:   90 * time.Minute,
+			files:    []string{backup(2*time.Hour, ""), backup(time.Hour, ""), backup(time.Hour, "-x")},
+			expected: []string{backup(time.Hour, ""), backup(time.Hour, "-x")},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			names, err := filepath.Glob(path + ".*")
+			require.NoError(t, err)
+			for _, name := range names {
+				require.NoError(t, os.Remove(name))
+			}
+			for _, name := range tt.files {
+				require.NoError(t, os.WriteFile(name, nil, 0600))
+			}
+
+			f := &rotatingFile{path: path, maxAge: tt.maxAge, maxBackups: tt.maxBackups}
+			require.NoError(t, f.removeBackups())
+			names, err = filepath.Glob(path + ".*")
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.expected, names, strings.Join(names, ", "))
+		})
+	}
+}
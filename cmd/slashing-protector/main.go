@@ -1,38 +1,101 @@
 package main
 
 import (
+	"encoding/hex"
 	"log"
-	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
 	protectorhttp "github.com/bloxapp/slashing-protector/http"
-	"github.com/bloxapp/slashing-protector/protector"
-	"go.uber.org/zap"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/pkg/errors"
 )
 
 var CLI struct {
-	DbPath string `env:"DB_PATH" description:"Path to the database directory" default:"/slashing-protector-data"`
-	Addr   string `env:"ADDR" description:"Address to listen on" default:":9369"`
+	LogLevel      string        `env:"LOG_LEVEL" help:"Minimum level of logs ('info' leaves out the logs of passing checks, while slashable checks and errors are logged at 'info')" enum:"debug,info,warn,error" default:"debug"`
+	LogFile       string        `env:"LOG_FILE" help:"Path to a file to write logs to, instead of stderr unless LOG_CONSOLE is set (empty to log to stderr only)"`
+	LogConsole    bool          `env:"LOG_CONSOLE" help:"Log to stderr as well as to LOG_FILE"`
+	LogMaxSize    int64         `env:"LOG_MAX_SIZE" help:"Size in megabytes at which LOG_FILE is rotated, suffixing it with the time (0 to never rotate)" default:"100"`
+	LogMaxAge     time.Duration `env:"LOG_MAX_AGE" help:"Age after which rotated log files are removed (0 to keep them regardless of age)" default:"0"`
+	LogMaxBackups int           `env:"LOG_MAX_BACKUPS" help:"Number of rotated log files to keep (0 to keep them all)" default:"10"`
+
+	Serve       serveCmd       `cmd:"" default:"withargs" help:"Run the server (default)"`
+	Compare     compareCmd     `cmd:"" help:"Compare the histories of two instances or data directories"`
+	Convert     convertCmd     `cmd:"" help:"Convert an interchange file between the minimal and complete formats"`
+	Conformance conformanceCmd `cmd:"" help:"Replay slashing protection interchange test vectors"`
+	Delete      deleteCmd      `cmd:"" help:"Delete the histories of public keys"`
+	Export      exportCmd      `cmd:"" help:"Export the histories of public keys"`
+	Import      importCmd      `cmd:"" help:"Import an interchange file, refusing those of another chain"`
+	Migrate     migrateCmd     `cmd:"" help:"Move the databases of a data directory into another layout"`
+	Restore     restoreCmd     `cmd:"" help:"Restore a backup archive after verifying its manifest"`
+	Validate    validateCmd    `cmd:"" help:"Validate an interchange file before importing it"`
 }
 
 func main() {
-	kong.Parse(&CLI)
+	ctx := kong.Parse(&CLI)
 
-	logger, err := zap.NewDevelopment()
+	logger, err := buildLogger()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer logger.Sync()
 
-	// Display the configuration. Don't expose sensitive attributes!
-	logger.Debug("Starting slashing-protector",
-		zap.String("db_path", CLI.DbPath),
-		zap.String("addr", CLI.Addr),
-	)
-
-	// Create the server and start it.
-	prtc := protector.New(CLI.DbPath)
-	srv := protectorhttp.NewServer(logger, prtc)
-	err = http.ListenAndServe(CLI.Addr, srv)
-	logger.Fatal("ListenAndServe", zap.Error(err))
+	ctx.FatalIfErrorf(ctx.Run(logger))
+}
+
+// parsePubKeys decodes hex-encoded public keys.
+func parsePubKeys(values []string) ([]phase0.BLSPubKey, error) {
+	pubKeys := make([]phase0.BLSPubKey, len(values))
+	for i, s := range values {
+		v, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil || len(v) != phase0.PublicKeyLength {
+			return nil, errors.Errorf("invalid public key %q", s)
+		}
+		copy(pubKeys[i][:], v)
+	}
+	return pubKeys, nil
+}
+
+// isURL reports whether location is the URL of an instance
+// rather than the path of a data directory.
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// genesisValidatorsRoot returns the genesis validators root given with
+// --genesis-validators-root, or else the known one of the network,
+// or a zero root if the network isn't known.
+func genesisValidatorsRoot(network, root string) (phase0.Root, error) {
+	known, ok := interchange.KnownGenesisValidatorsRoot(network)
+	if root == "" {
+		return known, nil
+	}
+	parsed, err := interchange.ParseRoot(root)
+	if err != nil {
+		return parsed, errors.Wrap(err, "invalid --genesis-validators-root")
+	}
+	if ok && parsed != known {
+		return parsed, errors.Errorf("--genesis-validators-root doesn't match the known one of %s", network)
+	}
+	return parsed, nil
+}
+
+// clientOptions returns the options of clients of instances which
+// require JWTs signed with the secret in jwtSecretFile, if it's given.
+func clientOptions(jwtSecretFile string) ([]protectorhttp.ClientOption, error) {
+	if jwtSecretFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(jwtSecretFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read JWT secret")
+	}
+	secret, err := protectorhttp.ParseJWTSecret(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid JWT secret")
+	}
+	return []protectorhttp.ClientOption{protectorhttp.WithClientJWTSecret(secret)}, nil
 }
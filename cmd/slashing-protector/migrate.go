@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+)
+
+type migrateCmd struct {
+	Layout string `required:"" enum:"flat,sharded" help:"Layout to move the databases into ('flat' or 'sharded')"`
+
+	Dir string `arg:"" type:"existingdir" help:"Path to a data directory which isn't in use"`
+}
+
+func (cmd *migrateCmd) Run() error {
+	layout, _ := kvpool.LayoutByName(cmd.Layout)
+	moved, err := kvpool.Migrate(cmd.Dir, layout)
+	if err != nil {
+		return errors.Wrapf(err, "failed to migrate after moving %d databases", moved)
+	}
+	fmt.Printf("Moved %d databases into the %s layout\n", moved, layout.Name)
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bloxapp/slashing-protector/protector/backup"
+	"github.com/pkg/errors"
+)
+
+type restoreCmd struct {
+	Archive string `arg:"" type:"existingfile" help:"Backup archive to restore (compressed with zstd or not)"`
+	DbPath  string `arg:"" help:"Data directory to restore into, which mustn't be in use"`
+}
+
+func (cmd *restoreCmd) Run() error {
+	f, err := os.Open(cmd.Archive)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer f.Close()
+
+	manifest, err := backup.Restore(f, cmd.DbPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to restore")
+	}
+	fmt.Printf("Restored %d files from a backup created at %s\n", len(manifest.Files), manifest.Created)
+	return nil
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	protectorhttp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/archiver"
+	"github.com/bloxapp/slashing-protector/protector/beacon"
+	"github.com/bloxapp/slashing-protector/protector/compactor"
+	"github.com/bloxapp/slashing-protector/protector/forensics"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/keystore"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/bloxapp/slashing-protector/protector/nats"
+	"github.com/bloxapp/slashing-protector/protector/pruner"
+	"github.com/bloxapp/slashing-protector/protector/replica"
+	"github.com/bloxapp/slashing-protector/protector/shard"
+	"github.com/bloxapp/slashing-protector/protector/snapshotter"
+	"github.com/bloxapp/slashing-protector/protector/verifier"
+	"github.com/bloxapp/slashing-protector/protector/wal"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+type serveCmd struct {
+	DbPath string `env:"DB_PATH" help:"Path to the database directory" default:"/slashing-protector-data"`
+	Addr   string `env:"ADDR" help:"Address to listen on" default:":9369"`
+
+	TLSCertFile       string        `env:"TLS_CERT_FILE" help:"Path to a PEM certificate (chain) to serve HTTPS with, instead of HTTP (requires TLS_KEY_FILE)"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE" help:"Path to the PEM private key of TLS_CERT_FILE"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" help:"Interval to reload TLS_CERT_FILE and TLS_KEY_FILE if they were modified" default:"1m"`
+
+	TrustedProxies []string `env:"TRUSTED_PROXIES" help:"Addresses or CIDR ranges of proxies (such as load balancers) whose X-Forwarded-For header is trusted for the client's address, such as '10.0.0.0/8,192.168.1.1'"`
+
+	NetworkDirs map[string]string `env:"NETWORK_DIRS" help:"Directories of networks whose databases are kept outside of DB_PATH, such as 'mainnet=/mnt/nvme/mainnet'"`
+
+	AdminToken string `env:"ADMIN_TOKEN" help:"Bearer token required by the admin endpoints (empty to disable them)"`
+
+	JWTSecretFile string `env:"JWT_SECRET_FILE" help:"Path to a hex-encoded 32-byte secret, as with the Engine API's jwtsecret, to require the other endpoints to carry JWTs signed with (empty to disable)"`
+
+	ForensicsPath       string        `env:"FORENSICS_PATH" help:"Path to a directory to record the evidence of slashable checks in (defaults to 'forensics' in DB_PATH)"`
+	ForensicsMaxBundles int           `env:"FORENSICS_MAX_BUNDLES" help:"Number of the latest forensics bundles to keep (0 to keep all)" default:"10000"`
+	ForensicsMaxAge     time.Duration `env:"FORENSICS_MAX_AGE" help:"Age of forensics bundles to remove, such as '720h' (0 to keep them)" default:"0"`
+
+	InactiveStatus int `env:"INACTIVE_STATUS" help:"Status code of checks of public keys which were deactivated" default:"410"`
+
+	VerifySignatures bool `env:"VERIFY_SIGNATURES" help:"Require checks to carry the signature of their signing root, and refuse them unless it's valid for their public key"`
+
+	KeystoresPath         string `env:"KEYSTORES_PATH" help:"Path to a directory of EIP-2335 keystores to sign passing checks with, returning the signatures in their responses (empty to disable)"`
+	KeystoresPasswordFile string `env:"KEYSTORES_PASSWORD_FILE" help:"Path to a file with the password of the keystores in KEYSTORES_PATH"`
+
+	Durability   string        `env:"DURABILITY" help:"How writes are made durable: 'always' fsyncs every write, 'group' fsyncs every SYNC_INTERVAL, and 'none' never fsyncs (for tests only)" enum:"always,group,none" default:"always"`
+	SyncInterval time.Duration `env:"SYNC_INTERVAL" help:"Interval to fsync writes with DURABILITY=group" default:"10ms"`
+
+	BoltFreelistType    string        `env:"BOLT_FREELIST_TYPE" help:"Type of bolt's freelist ('array' or 'hashmap', which is faster for large databases)" enum:"array,hashmap" default:"array"`
+	BoltInitialMmapSize int           `env:"BOLT_INITIAL_MMAP_SIZE" help:"Initial size in bytes of the memory map of each database (0 for bolt's default)" default:"0"`
+	BoltTimeout         time.Duration `env:"BOLT_TIMEOUT" help:"Time to wait for the lock of a database, which may need raising on network storage" default:"1s"`
+	MemoryBudget        int64         `env:"MEMORY_BUDGET" help:"Budget in bytes for the memory maps of the databases open at once, above which checks wait for others to finish (0 to disable)" default:"0"`
+
+	MaxKeysPerNetwork int `env:"MAX_KEYS_PER_NETWORK" help:"Maximum number of public keys with a database in each network, beyond which checks of new keys are rejected with 403 (0 for no limit)" default:"0"`
+
+	WitnessPath    string        `env:"WITNESS_PATH" help:"Path to a database (preferably on a different volume) used to detect rollbacks of the data directory (empty to disable)"`
+	Layout         string        `env:"LAYOUT" help:"Layout to create new databases in ('flat', or 'sharded' under two levels of directories named after their public key's prefix), instead of the one recorded by the migrate command" enum:",flat,sharded" default:""`
+	Tombstones     bool          `env:"TOMBSTONES" help:"Keep the watermarks of deleted histories, which refuse signing at or below them if the keys are ever checked again"`
+	WatermarksOnly bool          `env:"WATERMARKS_ONLY" help:"Keep only the latest attestation and proposal of every key instead of it's full history, refusing anything at or below them, for deployments with little disk"`
+	CommitInterval time.Duration `env:"COMMIT_INTERVAL" help:"Interval to batch attestation saves of the same key into a single transaction (0 to disable)" default:"0"`
+
+	MaxConcurrentChecks int `env:"MAX_CONCURRENT_CHECKS" help:"Maximum number of checks to run concurrently, admitting proposals before attestations when reached (0 for no limit)" default:"0"`
+	MaxQueuedChecks     int `env:"MAX_QUEUED_CHECKS" help:"Maximum number of checks waiting for MAX_CONCURRENT_CHECKS, above which checks are rejected with 429 (0 for no limit)" default:"0"`
+
+	ElectraForkEpochs map[string]uint64 `env:"ELECTRA_FORK_EPOCHS" help:"Epochs of the Electra fork in networks which aren't known (or to override known ones), such as 'devnet=10;other=20'"`
+
+	GenesisValidatorsRoots map[string]string `env:"GENESIS_VALIDATORS_ROOTS" help:"Genesis validators roots of networks which aren't known (or to override known ones), which are written into exports and enforced on imports, such as 'devnet=0x...'. Otherwise they're learned from BEACON_NODES"`
+
+	BeaconNodes          map[string]string `env:"BEACON_NODES" help:"URLs of beacon nodes by network, which enable addressing validators by index, such as 'mainnet=http://localhost:5052'"`
+	IndexRefreshInterval time.Duration     `env:"INDEX_REFRESH_INTERVAL" help:"Interval to refresh the indices of known validators from the beacon nodes" default:"10m"`
+
+	ArchiveExitedAfter    time.Duration `env:"ARCHIVE_EXITED_AFTER" help:"Retention period after which the histories of exited validators are archived and their databases removed, which requires BEACON_NODES (0 to disable)" default:"0"`
+	ArchiveExitedInterval time.Duration `env:"ARCHIVE_EXITED_INTERVAL" help:"Interval to look for exited validators to archive" default:"1h"`
+
+	CompactInterval  time.Duration `env:"COMPACT_INTERVAL" help:"Interval to compact the databases of keys which weren't signed with for COMPACT_COLD_AFTER (0 to disable)" default:"0"`
+	CompactColdAfter time.Duration `env:"COMPACT_COLD_AFTER" help:"Duration without signing after which a key's database may be compacted" default:"24h"`
+	FreezeColdAfter  time.Duration `env:"FREEZE_COLD_AFTER" help:"Duration without signing after which a key's database is compressed on COMPACT_INTERVAL until it's next checked, such as '720h' (0 to disable)" default:"0"`
+
+	PruneKeepEpochs uint64        `env:"PRUNE_KEEP_EPOCHS" help:"Number of epochs before each key's latest records to keep, below which it's history is pruned in the background, refusing anything at or below what was pruned (0 to disable)" default:"0"`
+	PruneInterval   time.Duration `env:"PRUNE_INTERVAL" help:"Interval to prune the next PRUNE_BATCH keys, which is an epoch by default" default:"6m24s"`
+	PruneBatch      int           `env:"PRUNE_BATCH" help:"Number of keys to prune every PRUNE_INTERVAL" default:"100"`
+
+	BackupInterval time.Duration `env:"BACKUP_INTERVAL" help:"Interval to back up every database into BACKUP_DIR, verifying each backup by restoring it in the background (0 to disable)" default:"0"`
+	BackupDir      string        `env:"BACKUP_DIR" help:"Directory to write scheduled backups into, which is 'backups' in DB_PATH by default"`
+	BackupKeep     int           `env:"BACKUP_KEEP" help:"Number of the most recent scheduled backups to keep" default:"7"`
+
+	VerifyInterval time.Duration `env:"VERIFY_INTERVAL" help:"Interval to verify the invariants of every database in the background, one at a time (0 to disable)" default:"0"`
+
+	DuplicateKeyWindow  time.Duration `env:"DUPLICATE_KEY_WINDOW" help:"Window within which a public key checked on more than one network is reported as a duplicate, at most once per window (0 to disable)" default:"1h"`
+	DuplicateKeyWebhook string        `env:"DUPLICATE_KEY_WEBHOOK" help:"URL to post duplicate keys to as JSON, besides logging them (empty to disable)"`
+
+	CheckLogSample int `env:"CHECK_LOG_SAMPLE" help:"Log only the first N passing checks of each kind per second and every Nth after that, while slashable checks and errors are always logged (0 to log all)" default:"0"`
+
+	SlowThreshold time.Duration `env:"SLOW_THRESHOLD" help:"Duration above which checks, and the store operations they're made of, are logged as slow (0 to disable)" default:"0"`
+
+	NATSURL     string `env:"NATS_URL" help:"URL of a NATS server to publish the decision of every check to as JSON, such as 'nats://token@localhost:4222' (empty to disable)"`
+	NATSSubject string `env:"NATS_SUBJECT" help:"Prefix of the subjects decisions are published to, followed by the network and 'signable', 'slashable' or 'error'" default:"slashing-protector"`
+
+	CapturePath string `env:"CAPTURE_PATH" help:"Path to a file to append every check request to as JSON lines, without signatures, for replaying (empty to disable)"`
+
+	Faults map[string]string `env:"FAULTS" help:"Faults to inject into the checks of each endpoint for testing clients, such as 'attestation=latency:200ms,error:0.1,slashable:0.05'. Never enable it in production!"`
+
+	CheckTimeout  time.Duration `env:"CHECK_TIMEOUT" help:"Time budget of checks, beyond which they're useless for duties" default:"5s"`
+	ReadTimeout   time.Duration `env:"READ_TIMEOUT" help:"Time budget of history, digest, stats and watermark reads" default:"30s"`
+	ExportTimeout time.Duration `env:"EXPORT_TIMEOUT" help:"Time budget of exports" default:"5m"`
+	ImportTimeout time.Duration `env:"IMPORT_TIMEOUT" help:"Time budget of imports" default:"10m"`
+	AdminTimeout  time.Duration `env:"ADMIN_TIMEOUT" help:"Time budget of other admin requests, except snapshots which aren't bounded" default:"1m"`
+
+	LatencySLO time.Duration `env:"LATENCY_SLO" help:"Objective for the p99 latency of checks, above which history reads and admin requests are rejected with 503 (0 to disable)" default:"0"`
+
+	AsyncWalPath       string        `env:"ASYNC_WAL_PATH" help:"Path to a directory for the write-ahead log of asynchronous writes, which acknowledge attestations before saving them (empty to disable)"`
+	AsyncFlushInterval time.Duration `env:"ASYNC_FLUSH_INTERVAL" help:"Interval to save acknowledged attestations of asynchronous writes to their databases" default:"1s"`
+
+	ReplicaOf              string            `env:"REPLICA_OF" help:"Path to a primary's data directory to serve reads from, while rejecting checks (empty to disable)"`
+	ReplicaRefreshInterval time.Duration     `env:"REPLICA_REFRESH_INTERVAL" help:"Interval to refresh the snapshots of the primary's data" default:"1m"`
+	ReplicaNetworkDirs     map[string]string `env:"REPLICA_NETWORK_DIRS" help:"Directories of the primary's networks whose databases are kept outside of REPLICA_OF, as given to the primary by NETWORK_DIRS"`
+	LeaderURL              string            `env:"LEADER_URL" help:"URL of the primary to forward checks to, instead of rejecting them (requires REPLICA_OF)"`
+
+	Shards     []string `env:"SHARDS" help:"URLs of every shard of a sharded deployment, which own the keys assigned to them by consistent hashing, such as 'http://shard-0:9369,http://shard-1:9369' (empty to disable)"`
+	ShardURL   string   `env:"SHARD_URL" help:"URL of this instance among SHARDS"`
+	ShardProxy bool     `env:"SHARD_PROXY" help:"Forward requests of keys owned by other shards to their owner, instead of refusing them with 421 Misdirected Request"`
+}
+
+func (cmd *serveCmd) Run(logger *zap.Logger) error {
+	// Display the configuration. Don't expose sensitive attributes!
+	logger.Debug("Starting slashing-protector",
+		zap.String("db_path", cmd.DbPath),
+		zap.Any("network_dirs", cmd.NetworkDirs),
+		zap.String("addr", cmd.Addr),
+		zap.String("tls_cert_file", cmd.TLSCertFile),
+		zap.Duration("tls_reload_interval", cmd.TLSReloadInterval),
+		zap.Strings("trusted_proxies", cmd.TrustedProxies),
+		zap.Duration("commit_interval", cmd.CommitInterval),
+		zap.Bool("admin_enabled", cmd.AdminToken != ""),
+		zap.String("jwt_secret_file", cmd.JWTSecretFile),
+		zap.String("forensics_path", cmd.ForensicsPath),
+		zap.Int("forensics_max_bundles", cmd.ForensicsMaxBundles),
+		zap.Duration("forensics_max_age", cmd.ForensicsMaxAge),
+		zap.Int("inactive_status", cmd.InactiveStatus),
+		zap.Bool("verify_signatures", cmd.VerifySignatures),
+		zap.String("keystores_path", cmd.KeystoresPath),
+		zap.String("durability", cmd.Durability),
+		zap.Duration("sync_interval", cmd.SyncInterval),
+		zap.String("bolt_freelist_type", cmd.BoltFreelistType),
+		zap.Int("bolt_initial_mmap_size", cmd.BoltInitialMmapSize),
+		zap.Duration("bolt_timeout", cmd.BoltTimeout),
+		zap.Int64("memory_budget", cmd.MemoryBudget),
+		zap.Int("max_keys_per_network", cmd.MaxKeysPerNetwork),
+		zap.String("witness_path", cmd.WitnessPath),
+		zap.String("layout", cmd.Layout),
+		zap.Bool("tombstones", cmd.Tombstones),
+		zap.Bool("watermarks_only", cmd.WatermarksOnly),
+		zap.Int("max_concurrent_checks", cmd.MaxConcurrentChecks),
+		zap.Int("max_queued_checks", cmd.MaxQueuedChecks),
+		zap.Any("electra_fork_epochs", cmd.ElectraForkEpochs),
+		zap.Any("genesis_validators_roots", cmd.GenesisValidatorsRoots),
+		zap.Any("beacon_nodes", cmd.BeaconNodes),
+		zap.Duration("index_refresh_interval", cmd.IndexRefreshInterval),
+		zap.Duration("archive_exited_after", cmd.ArchiveExitedAfter),
+		zap.Duration("archive_exited_interval", cmd.ArchiveExitedInterval),
+		zap.Duration("compact_interval", cmd.CompactInterval),
+		zap.Duration("compact_cold_after", cmd.CompactColdAfter),
+		zap.Duration("freeze_cold_after", cmd.FreezeColdAfter),
+		zap.Uint64("prune_keep_epochs", cmd.PruneKeepEpochs),
+		zap.Duration("prune_interval", cmd.PruneInterval),
+		zap.Int("prune_batch", cmd.PruneBatch),
+		zap.Duration("backup_interval", cmd.BackupInterval),
+		zap.String("backup_dir", cmd.BackupDir),
+		zap.Int("backup_keep", cmd.BackupKeep),
+		zap.Duration("verify_interval", cmd.VerifyInterval),
+		zap.Duration("duplicate_key_window", cmd.DuplicateKeyWindow),
+		zap.Bool("duplicate_key_webhook", cmd.DuplicateKeyWebhook != ""),
+		zap.Int("check_log_sample", cmd.CheckLogSample),
+		zap.Duration("slow_threshold", cmd.SlowThreshold),
+		zap.Bool("nats_enabled", cmd.NATSURL != ""),
+		zap.String("nats_subject", cmd.NATSSubject),
+		zap.String("capture_path", cmd.CapturePath),
+		zap.Any("faults", cmd.Faults),
+		zap.Duration("check_timeout", cmd.CheckTimeout),
+		zap.Duration("read_timeout", cmd.ReadTimeout),
+		zap.Duration("export_timeout", cmd.ExportTimeout),
+		zap.Duration("import_timeout", cmd.ImportTimeout),
+		zap.Duration("admin_timeout", cmd.AdminTimeout),
+		zap.Duration("latency_slo", cmd.LatencySLO),
+		zap.String("async_wal_path", cmd.AsyncWalPath),
+		zap.Duration("async_flush_interval", cmd.AsyncFlushInterval),
+		zap.String("replica_of", cmd.ReplicaOf),
+		zap.Duration("replica_refresh_interval", cmd.ReplicaRefreshInterval),
+		zap.Any("replica_network_dirs", cmd.ReplicaNetworkDirs),
+		zap.String("leader_url", cmd.LeaderURL),
+		zap.Strings("shards", cmd.Shards),
+		zap.String("shard_url", cmd.ShardURL),
+		zap.Bool("shard_proxy", cmd.ShardProxy),
+	)
+
+	poolOpts := []kvpool.Option{
+		kvpool.WithDurability(kv.Durability(cmd.Durability), cmd.SyncInterval),
+		kvpool.WithTuning(kv.Tuning{
+			FreelistType:    bolt.FreelistType(cmd.BoltFreelistType),
+			InitialMmapSize: cmd.BoltInitialMmapSize,
+			Timeout:         cmd.BoltTimeout,
+		}),
+	}
+	if cmd.WitnessPath != "" {
+		witness, err := kvpool.OpenWitness(cmd.WitnessPath)
+		if err != nil {
+			logger.Fatal("failed to open witness", zap.Error(err))
+		}
+		defer witness.Close()
+		poolOpts = append(poolOpts, kvpool.WithWitness(witness))
+	}
+	if len(cmd.NetworkDirs) > 0 {
+		poolOpts = append(poolOpts, kvpool.WithNetworkDirs(cmd.NetworkDirs))
+	}
+	if cmd.Layout != "" {
+		layout, _ := kvpool.LayoutByName(cmd.Layout)
+		poolOpts = append(poolOpts, kvpool.WithLayout(layout))
+	}
+	if cmd.Tombstones {
+		poolOpts = append(poolOpts, kvpool.WithTombstones())
+	}
+	if cmd.WatermarksOnly {
+		poolOpts = append(poolOpts, kvpool.WithWatermarksOnly())
+	}
+	if cmd.MemoryBudget > 0 {
+		poolOpts = append(poolOpts, kvpool.WithMemoryBudget(cmd.MemoryBudget))
+	}
+	if cmd.MaxKeysPerNetwork > 0 {
+		poolOpts = append(poolOpts, kvpool.WithMaxKeys(cmd.MaxKeysPerNetwork))
+	}
+
+	forensicsPath := cmd.ForensicsPath
+	if forensicsPath == "" {
+		forensicsPath = filepath.Join(cmd.DbPath, "forensics")
+	}
+	recorder := forensics.New(logger, forensicsPath,
+		forensics.WithMaxBundles(cmd.ForensicsMaxBundles),
+		forensics.WithMaxAge(cmd.ForensicsMaxAge),
+	)
+
+	opts := []protector.Option{
+		protector.WithForensics(recorder),
+		protector.WithCommitInterval(cmd.CommitInterval),
+		protector.WithPoolOptions(poolOpts...),
+		protector.WithMaxConcurrentChecks(cmd.MaxConcurrentChecks),
+		protector.WithMaxQueuedChecks(cmd.MaxQueuedChecks),
+	}
+	if cmd.AsyncWalPath != "" {
+		w, err := wal.Open(cmd.AsyncWalPath)
+		if err != nil {
+			logger.Fatal("failed to open WAL", zap.Error(err))
+		}
+		opts = append(opts, protector.WithAsyncWrites(w, cmd.AsyncFlushInterval))
+	}
+	if cmd.DuplicateKeyWindow > 0 {
+		opts = append(opts, protector.WithDuplicateKeyDetection(
+			cmd.DuplicateKeyWindow,
+			reportDuplicateKey(logger, cmd.DuplicateKeyWebhook),
+		))
+	}
+	if cmd.SlowThreshold > 0 {
+		opts = append(opts, protector.WithSlowOperations(cmd.SlowThreshold, func(op *protector.SlowOperation) {
+			logger.Warn("slow operation",
+				zap.String("operation", op.Operation),
+				zap.String("network", op.Network),
+				zap.String("pub_key", fmt.Sprintf("%#x", op.PubKey)),
+				zap.Duration("took", op.Took),
+			)
+		}))
+	}
+	var rep *replica.Replica
+	if cmd.ReplicaOf != "" {
+		// Take the initial snapshots before serving.
+		rep = replica.New(logger, cmd.ReplicaOf, cmd.DbPath, replica.WithNetworkDirs(cmd.ReplicaNetworkDirs))
+		if err := rep.Refresh(); err != nil {
+			logger.Fatal("failed to refresh replica", zap.Error(err))
+		}
+		go rep.Run(context.Background(), cmd.ReplicaRefreshInterval)
+		opts = append(opts, protector.WithReadOnly())
+	}
+
+	// Create the server and start it.
+	prtc := protector.New(cmd.DbPath, opts...)
+	srvOpts := []protectorhttp.Option{
+		protectorhttp.WithInactiveStatus(cmd.InactiveStatus),
+		protectorhttp.WithBatchConcurrency(cmd.MaxConcurrentChecks),
+		protectorhttp.WithForensics(recorder),
+		protectorhttp.WithTimeouts(protectorhttp.Timeouts{
+			Check:  cmd.CheckTimeout,
+			Read:   cmd.ReadTimeout,
+			Export: cmd.ExportTimeout,
+			Import: cmd.ImportTimeout,
+			Admin:  cmd.AdminTimeout,
+		}),
+	}
+	if rep != nil {
+		srvOpts = append(srvOpts, protectorhttp.WithReplica(rep))
+	}
+	if cmd.LeaderURL != "" {
+		if rep == nil {
+			logger.Fatal("LEADER_URL requires REPLICA_OF")
+		}
+		leader, err := url.Parse(cmd.LeaderURL)
+		if err != nil || leader.Scheme == "" || leader.Host == "" {
+			logger.Fatal("invalid leader URL", zap.String("leader_url", cmd.LeaderURL))
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithLeader(leader))
+	}
+	if len(cmd.Shards) > 0 {
+		ring, err := shard.New(cmd.Shards)
+		if err != nil {
+			logger.Fatal("invalid shards", zap.Error(err))
+		}
+		if !ring.Has(cmd.ShardURL) {
+			logger.Fatal("SHARD_URL must be one of SHARDS", zap.String("shard_url", cmd.ShardURL))
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithShard(ring, cmd.ShardURL))
+		if cmd.ShardProxy {
+			srvOpts = append(srvOpts, protectorhttp.WithShardProxy())
+		}
+	}
+	if cmd.AdminToken != "" {
+		srvOpts = append(srvOpts, protectorhttp.WithAdminToken(cmd.AdminToken))
+	}
+	if len(cmd.TrustedProxies) > 0 {
+		prefixes := make([]netip.Prefix, len(cmd.TrustedProxies))
+		for i, proxy := range cmd.TrustedProxies {
+			prefix, err := parsePrefix(proxy)
+			if err != nil {
+				logger.Fatal("invalid trusted proxy", zap.String("proxy", proxy), zap.Error(err))
+			}
+			prefixes[i] = prefix
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithTrustedProxies(prefixes))
+	}
+	if cmd.JWTSecretFile != "" {
+		data, err := os.ReadFile(cmd.JWTSecretFile)
+		if err != nil {
+			logger.Fatal("failed to read JWT secret", zap.Error(err))
+		}
+		secret, err := protectorhttp.ParseJWTSecret(data)
+		if err != nil {
+			logger.Fatal("invalid JWT secret", zap.Error(err))
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithJWTSecret(secret))
+	}
+	if cmd.VerifySignatures {
+		srvOpts = append(srvOpts, protectorhttp.WithSignatureVerification())
+	}
+	if cmd.KeystoresPath != "" {
+		signer, err := loadSigner(cmd.KeystoresPath, cmd.KeystoresPasswordFile)
+		if err != nil {
+			logger.Fatal("failed to load keystores", zap.Error(err))
+		}
+		logger.Info("loaded keystores", zap.Int("keys", signer.Len()))
+		srvOpts = append(srvOpts, protectorhttp.WithSigner(signer))
+	}
+	if len(cmd.ElectraForkEpochs) > 0 {
+		epochs := make(map[string]phase0.Epoch, len(cmd.ElectraForkEpochs))
+		for network, epoch := range cmd.ElectraForkEpochs {
+			epochs[network] = phase0.Epoch(epoch)
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithElectraForkEpochs(epochs))
+	}
+	if len(cmd.GenesisValidatorsRoots) > 0 {
+		roots := make(map[string]phase0.Root, len(cmd.GenesisValidatorsRoots))
+		for network, root := range cmd.GenesisValidatorsRoots {
+			parsed, err := interchange.ParseRoot(root)
+			if err != nil || parsed == (phase0.Root{}) {
+				logger.Fatal("invalid genesis validators root", zap.String("network", network), zap.String("root", root))
+			}
+			roots[network] = parsed
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithGenesisValidatorsRoots(roots))
+	}
+	var nodes map[string]*beacon.Client
+	if pooler, ok := prtc.(protector.ProtectorPooler); ok && len(cmd.BeaconNodes) > 0 {
+		nodes = make(map[string]*beacon.Client, len(cmd.BeaconNodes))
+		for network, url := range cmd.BeaconNodes {
+			network := network
+			node := beacon.New(logger, &http.Client{Timeout: 10 * time.Second}, url)
+			go node.Run(context.Background(), cmd.IndexRefreshInterval, func() ([]phase0.BLSPubKey, error) {
+				return pooler.Pool().PubKeys(network)
+			})
+			nodes[network] = node
+		}
+		srvOpts = append(srvOpts, protectorhttp.WithBeaconNodes(nodes))
+
+	}
+	if cmd.CheckLogSample > 0 {
+		srvOpts = append(srvOpts, protectorhttp.WithCheckLogSampling(cmd.CheckLogSample))
+	}
+	if cmd.NATSURL != "" {
+		publisher, err := nats.New(logger, cmd.NATSURL)
+		if err != nil {
+			logger.Fatal("invalid NATS_URL", zap.Error(err))
+		}
+		go publisher.Run(context.Background())
+		srvOpts = append(srvOpts, protectorhttp.WithDecisionEvents(publishDecisions(logger, publisher, cmd.NATSSubject)))
+	}
+	if cmd.CapturePath != "" {
+		f, err := os.OpenFile(cmd.CapturePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			logger.Fatal("failed to open capture", zap.Error(err))
+		}
+		defer f.Close()
+		srvOpts = append(srvOpts, protectorhttp.WithCapture(protectorhttp.NewCapture(f)))
+	}
+	if len(cmd.Faults) > 0 {
+		faults := make(map[string]protectorhttp.Fault, len(cmd.Faults))
+		for endpoint, spec := range cmd.Faults {
+			fault, err := protectorhttp.ParseFault(endpoint, spec)
+			if err != nil {
+				logger.Fatal("invalid fault", zap.String("endpoint", endpoint), zap.Error(err))
+			}
+			faults[endpoint] = fault
+		}
+		logger.Warn("injecting faults into checks, which must never be enabled in production", zap.Any("faults", cmd.Faults))
+		srvOpts = append(srvOpts, protectorhttp.WithFaults(faults))
+	}
+	if cmd.LatencySLO > 0 {
+		srvOpts = append(srvOpts, protectorhttp.WithLatencySLO(cmd.LatencySLO))
+	}
+	// Verification is deferred while the server is degraded, like the rest of
+	// background maintenance, but the server serves it's status.
+	var srv *protectorhttp.Server
+	var ver *verifier.Verifier
+	if pooler, ok := prtc.(protector.ProtectorPooler); ok && cmd.VerifyInterval > 0 {
+		ver = verifier.New(logger, pooler, verifier.WithDeferral(func() bool {
+			return srv.Degraded()
+		}))
+		srvOpts = append(srvOpts, protectorhttp.WithVerifier(ver))
+	}
+	var snap *snapshotter.Snapshotter
+	if pooler, ok := prtc.(protector.ProtectorPooler); ok && cmd.BackupInterval > 0 {
+		dir := cmd.BackupDir
+		if dir == "" {
+			dir = filepath.Join(cmd.DbPath, "backups")
+		}
+		snap = snapshotter.New(logger, pooler, dir, cmd.BackupKeep, snapshotter.WithDeferral(func() bool {
+			return srv.Degraded()
+		}))
+		srvOpts = append(srvOpts, protectorhttp.WithSnapshotter(snap))
+	}
+	srv = protectorhttp.NewServer(logger, prtc, srvOpts...)
+	if ver != nil {
+		go ver.Run(context.Background(), cmd.VerifyInterval)
+	}
+	if snap != nil {
+		go snap.Run(context.Background(), cmd.BackupInterval)
+	}
+
+	// Background maintenance waits while the server is degraded.
+	// Replicas are refreshed from their primary, which compacts them itself.
+	if pooler, ok := prtc.(protector.ProtectorPooler); ok && cmd.CompactInterval > 0 && cmd.ReplicaOf == "" {
+		compactorOpts := []compactor.Option{compactor.WithDeferral(srv.Degraded)}
+		if cmd.FreezeColdAfter > 0 {
+			compactorOpts = append(compactorOpts, compactor.WithFreezing(cmd.FreezeColdAfter))
+		}
+		c := compactor.New(logger, pooler.Pool(), cmd.CompactColdAfter, compactorOpts...)
+		go c.Run(context.Background(), cmd.CompactInterval)
+	}
+	if prunable, ok := prtc.(protector.ProtectorPruner); ok && cmd.PruneKeepEpochs > 0 && cmd.ReplicaOf == "" {
+		p := pruner.New(logger, prunable, phase0.Epoch(cmd.PruneKeepEpochs),
+			pruner.WithBatchSize(cmd.PruneBatch), pruner.WithDeferral(srv.Degraded))
+		go p.Run(context.Background(), cmd.PruneInterval)
+	}
+	// Archives are written alongside those of deleted histories.
+	if cmd.ArchiveExitedAfter > 0 && len(nodes) > 0 {
+		pooler := prtc.(protector.ProtectorPooler)
+		arch := archiver.New(logger, pooler, nodes, filepath.Join(cmd.DbPath, "archive"), cmd.ArchiveExitedAfter,
+			archiver.WithDeferral(srv.Degraded))
+		go arch.Run(context.Background(), cmd.ArchiveExitedInterval)
+	}
+	if cmd.TLSCertFile == "" && cmd.TLSKeyFile == "" {
+		err := http.ListenAndServe(cmd.Addr, srv)
+		logger.Fatal("ListenAndServe", zap.Error(err))
+	}
+	if cmd.TLSCertFile == "" || cmd.TLSKeyFile == "" {
+		logger.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be given together")
+	}
+	certs, err := protectorhttp.NewCertReloader(logger, cmd.TLSCertFile, cmd.TLSKeyFile)
+	if err != nil {
+		logger.Fatal("failed to load certificate", zap.Error(err))
+	}
+	go certs.Run(context.Background(), cmd.TLSReloadInterval)
+	server := &http.Server{
+		Addr:    cmd.Addr,
+		Handler: srv,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		},
+	}
+	err = server.ListenAndServeTLS("", "")
+	logger.Fatal("ListenAndServeTLS", zap.Error(err))
+	return nil
+}
+
+// loadSigner decrypts the keystores in dir with the password in passwordFile.
+func loadSigner(dir, passwordFile string) (*protectorhttp.Signer, error) {
+	if passwordFile == "" {
+		return nil, errors.New("KEYSTORES_PASSWORD_FILE is required")
+	}
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read password")
+	}
+	keys, err := keystore.LoadDir(dir, strings.TrimRight(string(password), "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	return protectorhttp.NewSigner(keys)
+}
+
+// parsePrefix parses a CIDR range, or a single address as a range of itself.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/pkg/errors"
+)
+
+type validateCmd struct {
+	Network               string `help:"Network the interchange is expected to be of, whose genesis validators root is known (mainnet, sepolia, holesky or hoodi)"`
+	GenesisValidatorsRoot string `help:"Genesis validators root the interchange is expected to be of, for networks which aren't known"`
+
+	File string `arg:"" type:"existingfile" help:"Interchange file to validate"`
+}
+
+func (cmd *validateCmd) Run() error {
+	gvr := cmd.GenesisValidatorsRoot
+	if cmd.Network != "" {
+		known, ok := interchange.GenesisValidatorsRoots[cmd.Network]
+		if !ok {
+			return errors.Errorf("unknown network %q, specify --genesis-validators-root instead", cmd.Network)
+		}
+		if gvr != "" && gvr != known {
+			return errors.New("--genesis-validators-root doesn't match --network")
+		}
+		gvr = known
+	}
+
+	b, err := os.ReadFile(cmd.File)
+	if err != nil {
+		return err
+	}
+	var exported interchange.Interchange
+	if err := json.Unmarshal(b, &exported); err != nil {
+		return errors.Wrap(err, "failed to decode interchange")
+	}
+
+	issues := interchange.Validate(&exported, gvr)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	fmt.Printf("Validated %d keys, %d issues\n", len(exported.Data), len(issues))
+	if len(issues) > 0 {
+		return errors.Errorf("%d issues", len(issues))
+	}
+	return nil
+}
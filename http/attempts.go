@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type attemptsResponse struct {
+	PubKey   jsonPubKey         `json:"pub_key"`
+	Attempts []*attemptResponse `json:"attempts"`
+}
+
+type attemptResponse struct {
+	Time        time.Time     `json:"time"`
+	Kind        string        `json:"kind"`
+	SigningRoot jsonRoot      `json:"signing_root"`
+	Slot        *phase0.Slot  `json:"slot,omitempty"`
+	SourceEpoch *phase0.Epoch `json:"source_epoch,omitempty"`
+	TargetEpoch *phase0.Epoch `json:"target_epoch,omitempty"`
+	Reason      string        `json:"reason"`
+}
+
+// handleAttempts responds with the slashable attempts of a key which were
+// refused, from oldest to newest.
+func (s *Server) handleAttempts(w http.ResponseWriter, r *http.Request) {
+	recorder, ok := s.protector.(protector.ProtectorAttemptRecorder)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+
+	attempts, err := recorder.Attempts(r.Context(), getNetwork(r.Context()), pubKey)
+	if err != nil {
+		s.logger.Error("failed to read attempts", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	resp := &attemptsResponse{
+		PubKey:   jsonPubKey(pubKey),
+		Attempts: make([]*attemptResponse, len(attempts)),
+	}
+	for i, a := range attempts {
+		a := a
+		resp.Attempts[i] = &attemptResponse{
+			Time:        a.Time.UTC(),
+			Kind:        a.Kind.String(),
+			SigningRoot: jsonRoot(a.SigningRoot),
+			Reason:      a.Reason,
+		}
+		switch a.Kind {
+		case kv.AttemptProposal:
+			resp.Attempts[i].Slot = &a.Slot
+		case kv.AttemptAttestation:
+			resp.Attempts[i].SourceEpoch = &a.Source
+			resp.Attempts[i].TargetEpoch = &a.Target
+		}
+	}
+	render.JSON(w, r, resp)
+}
+
+// Attempts returns the slashable attempts of a public key
+// which were refused, from oldest to newest.
+func (c *Client) Attempts(ctx context.Context, network string, pubKey phase0.BLSPubKey) ([]*kv.Attempt, error) {
+	var resp attemptsResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/attempts/%#x", network, pubKey).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	attempts := make([]*kv.Attempt, len(resp.Attempts))
+	for i, a := range resp.Attempts {
+		attempts[i] = &kv.Attempt{
+			Time:        a.Time,
+			SigningRoot: phase0.Root(a.SigningRoot),
+			Reason:      a.Reason,
+		}
+		switch a.Kind {
+		case kv.AttemptProposal.String():
+			attempts[i].Kind = kv.AttemptProposal
+		case kv.AttemptAttestation.String():
+			attempts[i].Kind = kv.AttemptAttestation
+		}
+		if a.Slot != nil {
+			attempts[i].Slot = *a.Slot
+		}
+		if a.SourceEpoch != nil && a.TargetEpoch != nil {
+			attempts[i].Source, attempts[i].Target = *a.SourceEpoch, *a.TargetEpoch
+		}
+	}
+	return attempts, nil
+}
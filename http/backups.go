@@ -0,0 +1,23 @@
+package http
+
+import (
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector/snapshotter"
+)
+
+// WithSnapshotter serves the status of the scheduled backups
+// taken by sn through /metrics.
+func WithSnapshotter(sn *snapshotter.Snapshotter) Option {
+	return func(s *Server) {
+		s.snapshotter = sn
+	}
+}
+
+// unixSeconds returns a timestamp as seconds since the epoch, or zero if it's zero.
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
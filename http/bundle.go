@@ -0,0 +1,210 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/signing"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type bundledProposal struct {
+	SigningRoot jsonRoot       `json:"signing_root"`
+	Slot        phase0.Slot    `json:"block"`
+	Signature   *jsonSignature `json:"signature,omitempty"`
+}
+
+type bundledAttestation struct {
+	SigningRoot jsonRoot                `json:"signing_root"`
+	Data        *phase0.AttestationData `json:"attestation"`
+	Signature   *jsonSignature          `json:"signature,omitempty"`
+}
+
+type checkBundleRequest struct {
+	Timestamp    int64                 `json:"timestamp"`
+	PubKey       jsonPubKey            `json:"pub_key"`
+	Proposal     *bundledProposal      `json:"proposal,omitempty"`
+	Attestations []*bundledAttestation `json:"attestations,omitempty"`
+
+	// ValidatorIndex may be given instead of PubKey.
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index,omitempty"`
+
+	// signingParams compute the signing roots of the attestations.
+	signingParams
+}
+
+type checkBundleResponse struct {
+	Timestamp  int64                  `json:"timestamp"`
+	Check      *protector.BundleCheck `json:"check"`
+	StatusCode int                    `json:"status_code"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// handleCheckBundle checks a block proposal of a key along with the attestations
+// accompanying it, recording either all of them or none of them.
+func (s *Server) handleCheckBundle(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	bundler, ok := s.protector.(protector.ProtectorBundler)
+	if !ok {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, &checkBundleResponse{StatusCode: http.StatusInternalServerError, Error: "not supported"})
+		return
+	}
+	var request checkBundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		render.JSON(w, r, &checkBundleResponse{
+			StatusCode: http.StatusBadRequest,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	resp := checkBundleResponse{Timestamp: request.Timestamp}
+	defer func() {
+		var check *protector.Check
+		if resp.Check != nil {
+			check = &resp.Check.Check
+		}
+		s.logCheck("CheckBundle", check, resp.Error,
+			zap.String("pub_key", hex.EncodeToString(request.PubKey[:])),
+			zap.Bool("proposal", request.Proposal != nil),
+			zap.Int("attestations", len(request.Attestations)),
+			zap.Any("result", resp.Check),
+			zap.Any("error", resp.Error),
+			zap.Duration("took", time.Since(start)),
+		)
+	}()
+
+	network := getNetwork(r.Context())
+	err := s.resolvePubKey(r.Context(), network, &request.PubKey, request.ValidatorIndex)
+	if err != nil {
+		resp.StatusCode = resolveStatus(err)
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+	bundle, err := s.resolveBundle(r.Context(), network, &request)
+	if err != nil {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+
+	resp.Check, err = bundler.CheckBundle(r.Context(), network, phase0.BLSPubKey(request.PubKey), bundle)
+	var check *protector.Check
+	if resp.Check != nil {
+		check = &resp.Check.Check
+	}
+	s.decisions.add(network, "bundle", phase0.BLSPubKey(request.PubKey), check, err)
+	if err != nil {
+		resp.StatusCode = s.checkErrorStatus(err)
+		resp.Error = err.Error()
+		setCheckStatus(w, r, resp.StatusCode)
+	}
+	render.JSON(w, r, resp)
+}
+
+// resolveBundle validates a bundle request, computing the signing roots of it's
+// attestations if there are signing parameters and verifying it's signatures.
+func (s *Server) resolveBundle(ctx context.Context, network string, request *checkBundleRequest) (*protector.Bundle, error) {
+	bundle := &protector.Bundle{}
+	if p := request.Proposal; p != nil {
+		if p.Slot == 0 {
+			return nil, errors.New("can not propose at genesis slot")
+		}
+		if err := s.checkSignature(request.PubKey, p.SigningRoot, p.Signature); err != nil {
+			return nil, err
+		}
+		bundle.Proposal = &protector.BundledProposal{SigningRoot: phase0.Root(p.SigningRoot), Slot: p.Slot}
+	}
+	for i, a := range request.Attestations {
+		if a == nil || a.Data == nil {
+			return nil, errors.Errorf("attestation %d is required", i)
+		}
+		err := s.validateAttestationData(network, a.Data)
+		if err == nil {
+			a.SigningRoot, err = s.resolveSigningRoot(ctx, network, &request.signingParams, signing.DomainBeaconAttester, a.Data, a.SigningRoot)
+		}
+		if err == nil {
+			err = s.checkSignature(request.PubKey, a.SigningRoot, a.Signature)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "attestation %d", i)
+		}
+		bundle.Attestations = append(bundle.Attestations, &protector.BundledAttestation{
+			SigningRoot: phase0.Root(a.SigningRoot),
+			Data:        a.Data,
+		})
+	}
+	if bundle.Proposal == nil && len(bundle.Attestations) == 0 {
+		return nil, errors.New("proposal or attestations are required")
+	}
+	return bundle, nil
+}
+
+// CheckBundle checks a block proposal of a key along with the attestations
+// accompanying it, which are recorded either all or none of them. Signing
+// parameters given with WithDomain or WithForkData apply to the attestations.
+func (c *Client) CheckBundle(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	bundle *protector.Bundle,
+	opts ...CheckOption,
+) (*protector.BundleCheck, error) {
+	o := newCheckOptions(opts)
+	req := &checkBundleRequest{
+		Timestamp:     time.Now().UnixNano(),
+		PubKey:        jsonPubKey(pubKey),
+		signingParams: o.signingParams,
+	}
+	if bundle.Proposal != nil {
+		req.Proposal = &bundledProposal{
+			SigningRoot: jsonRoot(bundle.Proposal.SigningRoot),
+			Slot:        bundle.Proposal.Slot,
+		}
+	}
+	for _, a := range bundle.Attestations {
+		req.Attestations = append(req.Attestations, &bundledAttestation{
+			SigningRoot: jsonRoot(a.SigningRoot),
+			Data:        a.Data,
+		})
+	}
+	var resp checkBundleResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/slashable/bundle", network).
+		BodyJSON(req).
+		AddValidator(nil). // Don't check http.StatusOK
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	if resp.Error != "" {
+		return nil, errors.Wrap(errors.New(resp.Error), "error from server")
+	}
+	if resp.Timestamp != req.Timestamp {
+		return nil, errors.New("timestamp mismatch")
+	}
+	if c.watermarks != nil && resp.Check != nil && !resp.Check.Slashable {
+		if bundle.Proposal != nil {
+			c.watermarks.proposed(network, pubKey, bundle.Proposal.SigningRoot, bundle.Proposal.Slot)
+		}
+		for _, a := range bundle.Attestations {
+			c.watermarks.attested(network, pubKey, a.SigningRoot, a.Data)
+		}
+	}
+	return resp.Check, nil
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CapturedCheck is a check request captured for replaying, which is
+// written as a line of JSON.
+type CapturedCheck struct {
+	Time     time.Time `json:"time"`
+	Network  string    `json:"network"`
+	Endpoint string    `json:"endpoint"`
+
+	// Request is the body of the request, without signatures.
+	Request json.RawMessage `json:"request"`
+}
+
+// Capture writes the check requests of a server to a writer as JSON lines.
+type Capture struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewCapture returns a Capture which writes to w.
+func NewCapture(w io.Writer) *Capture {
+	return &Capture{enc: json.NewEncoder(w)}
+}
+
+func (c *Capture) write(captured *CapturedCheck) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(captured)
+}
+
+// WithCapture captures every check request into capture, so that production
+// traffic can be replayed for load and regression testing. Signatures are
+// removed from the captured requests.
+func WithCapture(capture *Capture) Option {
+	return func(s *Server) {
+		s.capture = capture
+	}
+}
+
+// captureChecks captures the check requests as they arrive, before they're checked.
+func (s *Server) captureChecks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sanitized, err := withoutSignatures(body)
+		if err != nil {
+			// Requests which aren't JSON are captured as a string.
+			sanitized, _ = json.Marshal(string(body))
+		}
+		err = s.capture.write(&CapturedCheck{
+			Time:     time.Now(),
+			Network:  getNetwork(r.Context()),
+			Endpoint: path.Base(r.URL.Path),
+			Request:  sanitized,
+		})
+		if err != nil {
+			s.logger.Error("failed to capture check", zap.Error(err))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withoutSignatures removes the signatures anywhere in a JSON document.
+func withoutSignatures(body []byte) (json.RawMessage, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(removeSignatures(v))
+}
+
+func removeSignatures(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		delete(v, "signature")
+		for k, e := range v {
+			v[k] = removeSignatures(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = removeSignatures(e)
+		}
+	}
+	return v
+}
@@ -2,25 +2,124 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/shard"
 	"github.com/carlmjohnson/requests"
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
 )
 
 type Client struct {
-	http    *http.Client
-	baseURL string
+	http       *http.Client
+	baseURL    string
+	adminToken string
+	jwtSecret  []byte
+
+	// watermarks refuses regressing checks locally, or is nil if disabled.
+	watermarks *watermarkCache
+
+	// hedging hedges single checks with a replica, or is nil if disabled.
+	hedging *hedging
+
+	// shards routes the requests of keys to their shard, or is nil if disabled.
+	shards *shard.Ring
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithClientAdminToken sets the bearer token of admin requests.
+func WithClientAdminToken(token string) ClientOption {
+	return func(c *Client) {
+		c.adminToken = token
+	}
 }
 
-func NewClient(http *http.Client, addr string) *Client {
-	return &Client{
+func NewClient(http *http.Client, addr string, opts ...ClientOption) *Client {
+	c := &Client{
 		http:    http,
 		baseURL: addr,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.jwtSecret != nil {
+		c.http = withJWT(c.http, c.jwtSecret)
+	}
+	return c
+}
+
+// CheckOption configures a check request.
+type CheckOption func(*checkOptions)
+
+type checkOptions struct {
+	signature *jsonSignature
+	signed    *phase0.BLSSignature
+	signingParams
+}
+
+// WithSignature sends the signature produced for the signing root along
+// with a check, which is required by servers that verify signatures.
+// It's ignored by CheckAttestations, whose signers carry their own signatures.
+func WithSignature(signature phase0.BLSSignature) CheckOption {
+	return func(o *checkOptions) {
+		o.signature = (*jsonSignature)(&signature)
+	}
+}
+
+// WithSignedInto stores the signature produced by a server's embedded signer
+// (see WithSigner) into signed, which is left unchanged if it didn't sign.
+// It's ignored by CheckAttestations, whose signers receive their own.
+func WithSignedInto(signed *phase0.BLSSignature) CheckOption {
+	return func(o *checkOptions) {
+		o.signed = signed
+	}
+}
+
+// WithDomain sends the signing domain along with an attestation or block header
+// check, so that the server computes the signing root itself rather than trusting
+// the given one, which may then be zero.
+//
+// The domain must be of a known fork of the network, since it's refused
+// otherwise; use WithForkData for networks whose forks aren't known.
+func WithDomain(domain phase0.Domain) CheckOption {
+	return func(o *checkOptions) {
+		o.Domain = (*jsonRoot)(&domain)
+	}
+}
+
+// WithForkData is like WithDomain, but has the server compute the domain from
+// the fork version (at the epoch of what's signed) and genesis validators root,
+// which must be the network's.
+func WithForkData(forkVersion phase0.Version, genesisValidatorsRoot phase0.Root) CheckOption {
+	return func(o *checkOptions) {
+		o.ForkVersion = (*jsonVersion)(&forkVersion)
+		o.GenesisValidatorsRoot = (*jsonRoot)(&genesisValidatorsRoot)
+	}
+}
+
+// storeSigned stores the signature of the embedded signer, if requested.
+func (o *checkOptions) storeSigned(signature *jsonSignature) {
+	if o.signed != nil && signature != nil {
+		*o.signed = phase0.BLSSignature(*signature)
+	}
+}
+
+func newCheckOptions(opts []CheckOption) *checkOptions {
+	o := &checkOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
 func (c *Client) CheckAttestation(
@@ -29,22 +128,379 @@ func (c *Client) CheckAttestation(
 	pubKey phase0.BLSPubKey,
 	signingRoot phase0.Root,
 	data *phase0.AttestationData,
+	opts ...CheckOption,
 ) (*protector.Check, error) {
 	if data == nil {
 		return nil, errors.New("data is required")
 	}
 
+	if c.watermarks != nil {
+		if check := c.watermarks.refuseAttestation(network, pubKey, signingRoot, data); check != nil {
+			return check, nil
+		}
+	}
+
+	o := newCheckOptions(opts)
 	req := &checkAttestationRequest{
-		Timestamp:   time.Now().UnixNano(),
+		Timestamp:     time.Now().UnixNano(),
+		PubKey:        jsonPubKey(pubKey),
+		SigningRoot:   jsonRoot(signingRoot),
+		Data:          *data,
+		Signature:     o.signature,
+		signingParams: o.signingParams,
+	}
+	resp, err := c.fetchCheck(ctx, pubKey, fmt.Sprintf("/v1/%s/slashable/attestation", network), req, req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if c.watermarks != nil && resp.Check != nil && !resp.Check.Slashable {
+		c.watermarks.attested(network, pubKey, signingRoot, data)
+	}
+	o.storeSigned(resp.Signature)
+	return resp.Check, nil
+}
+
+func (c *Client) CheckProposal(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	slot phase0.Slot,
+	opts ...CheckOption,
+) (*protector.Check, error) {
+	if c.watermarks != nil {
+		if check := c.watermarks.refuseProposal(network, pubKey, signingRoot, slot); check != nil {
+			return check, nil
+		}
+	}
+
+	req := &checkProposalRequest{
 		PubKey:      jsonPubKey(pubKey),
 		SigningRoot: jsonRoot(signingRoot),
-		Data:        *data,
+		Slot:        slot,
+		Signature:   newCheckOptions(opts).signature,
 	}
-	var resp checkResponse
+	resp, err := c.fetchCheck(ctx, pubKey, fmt.Sprintf("/v1/%s/slashable/proposal", network), req, req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if c.watermarks != nil && resp.Check != nil && !resp.Check.Slashable {
+		c.watermarks.proposed(network, pubKey, signingRoot, slot)
+	}
+	return resp.Check, nil
+}
+
+func (c *Client) Digest(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+) (*kv.Digest, error) {
+	var resp digestResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/digest/%#x", network, pubKey).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if requests.HasStatusErr(err, http.StatusNotFound) {
+		return nil, protector.ErrUnknownKey
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &kv.Digest{
+		Root:         phase0.Root(resp.Digest),
+		Attestations: resp.Attestations,
+		Proposals:    resp.Proposals,
+	}, nil
+}
+
+func (c *Client) Delete(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+	archive bool,
+) (archiveDir string, err error) {
+	req := &deleteRequest{
+		PubKeys: make([]jsonPubKey, len(pubKeys)),
+		Archive: archive,
+	}
+	for i, pubKey := range pubKeys {
+		req.PubKeys[i] = jsonPubKey(pubKey)
+	}
+	var resp deleteResponse
+	err = requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Pathf("/v1/admin/delete/%s", network).
+		Bearer(c.adminToken).
+		BodyJSON(req).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch")
+	}
+	return resp.ArchiveDir, nil
+}
+
+// Deactivate marks the given public keys as inactive, so that their checks
+// are rejected until they're activated again.
+func (c *Client) Deactivate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error {
+	return c.setActivation(ctx, "deactivate", network, pubKeys)
+}
+
+// Activate reverts Deactivate.
+func (c *Client) Activate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error {
+	return c.setActivation(ctx, "activate", network, pubKeys)
+}
+
+func (c *Client) setActivation(ctx context.Context, action, network string, pubKeys []phase0.BLSPubKey) error {
+	req := &activationRequest{PubKeys: make([]jsonPubKey, len(pubKeys))}
+	for i, pubKey := range pubKeys {
+		req.PubKeys[i] = jsonPubKey(pubKey)
+	}
+	err := requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Pathf("/v1/admin/%s/%s", action, network).
+		Bearer(c.adminToken).
+		BodyJSON(req).
+		Fetch(ctx)
+	return errors.Wrap(err, "failed to fetch")
+}
+
+// Export returns the interchange of the given public keys,
+// or of all public keys in the network if none are given.
+func (c *Client) Export(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+) (*interchange.Interchange, error) {
+	return c.export(ctx, network, pubKeys, false)
+}
+
+// ExportMinimal returns the interchange of the given public keys (or of all
+// public keys in the network if none are given) in the minimal format, with
+// only the watermarks of each key.
+func (c *Client) ExportMinimal(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+) (*interchange.Interchange, error) {
+	return c.export(ctx, network, pubKeys, true)
+}
+
+func (c *Client) export(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+	minimal bool,
+) (*interchange.Interchange, error) {
+	req := &exportRequest{PubKeys: make([]jsonPubKey, len(pubKeys))}
+	for i, pubKey := range pubKeys {
+		req.PubKeys[i] = jsonPubKey(pubKey)
+	}
+	var resp interchange.Interchange
 	err := requests.
 		URL(c.baseURL).
 		Client(c.http).
-		Pathf("/v1/%s/slashable/attestation", network).
+		Pathf("/v1/%s/export", network).
+		Param("minimal", strconv.FormatBool(minimal)).
+		BodyJSON(req).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &resp, nil
+}
+
+// Import imports an interchange, which the server refuses
+// if it's of another genesis validators root than the network's.
+func (c *Client) Import(ctx context.Context, network string, exported *interchange.Interchange) (int, error) {
+	var resp importResponse
+	err := requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Pathf("/v1/admin/import/%s", network).
+		Bearer(c.adminToken).
+		BodyJSON(exported).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to fetch")
+	}
+	return resp.Imported, nil
+}
+
+// Stats returns the statistics of a public key.
+func (c *Client) Stats(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*protector.Stats, error) {
+	var resp statsResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/stats/%#x", network, pubKey).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return resp.stats(), nil
+}
+
+// Validators returns the statistics of every public key in the network.
+func (c *Client) Validators(ctx context.Context, network string) (map[phase0.BLSPubKey]*protector.Stats, error) {
+	var resp []*statsResponse
+	err := requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Pathf("/v1/%s/validators", network).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	validators := make(map[phase0.BLSPubKey]*protector.Stats, len(resp))
+	for _, v := range resp {
+		validators[phase0.BLSPubKey(v.PubKey)] = v.stats()
+	}
+	return validators, nil
+}
+
+// LastSigned returns the highest signed data of every public key in the network.
+func (c *Client) LastSigned(ctx context.Context, network string) (map[phase0.BLSPubKey]*kv.LastSigned, error) {
+	var resp []*lastSignedResponse
+	err := requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Pathf("/v1/%s/last-signed", network).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	last := make(map[phase0.BLSPubKey]*kv.LastSigned, len(resp))
+	for _, v := range resp {
+		last[phase0.BLSPubKey(v.PubKey)] = &kv.LastSigned{
+			SourceEpoch: v.SourceEpoch,
+			TargetEpoch: v.TargetEpoch,
+			Slot:        v.Slot,
+		}
+	}
+	return last, nil
+}
+
+// AttestationSigner is a public key signing an attestation, and the signing root it signs.
+type AttestationSigner struct {
+	PubKey      phase0.BLSPubKey
+	SigningRoot phase0.Root
+
+	// Signature is the signature of SigningRoot, which is
+	// required if the server verifies signatures.
+	Signature *phase0.BLSSignature
+
+	// SignedInto, if given, receives the signature of the server's embedded
+	// signer (see WithSignedInto), which is left as is if it didn't sign.
+	SignedInto *phase0.BLSSignature
+}
+
+// CheckAttestations checks the same attestation data for many public keys in a
+// single request. Checks are returned in the order of the signers, where the
+// checks which failed are nil and their errors are combined into err.
+func (c *Client) CheckAttestations(
+	ctx context.Context,
+	network string,
+	data *phase0.AttestationData,
+	signers []AttestationSigner,
+	opts ...CheckOption,
+) (checks []*protector.Check, err error) {
+	if data == nil {
+		return nil, errors.New("data is required")
+	}
+
+	// Only the signers which the watermark cache doesn't refuse are sent,
+	// and sent[i] is the index of the i-th of them in signers.
+	checks = make([]*protector.Check, len(signers))
+	sent := make([]int, 0, len(signers))
+	for i, signer := range signers {
+		if c.watermarks != nil {
+			if checks[i] = c.watermarks.refuseAttestation(network, signer.PubKey, signer.SigningRoot, data); checks[i] != nil {
+				continue
+			}
+		}
+		sent = append(sent, i)
+	}
+	if len(sent) == 0 {
+		return checks, nil
+	}
+
+	if c.shards == nil {
+		failed, err := c.fetchAttestations(ctx, c.baseURL, network, data, signers, sent, checks, opts)
+		if err != nil {
+			return nil, err
+		}
+		return checks, failed
+	}
+
+	// Every shard checks the signers it owns, and the checks of the
+	// signers of a shard which failed are nil.
+	byShard := make(map[string][]int)
+	for _, i := range sent {
+		owner := c.shards.Owner(signers[i].PubKey)
+		byShard[owner] = append(byShard[owner], i)
+	}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for owner, sent := range byShard {
+		wg.Add(1)
+		go func(owner string, sent []int) {
+			defer wg.Done()
+			failed, fetchErr := c.fetchAttestations(ctx, owner, network, data, signers, sent, checks, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			err = multierr.Append(err, failed)
+			if fetchErr != nil {
+				err = multierr.Append(err, errors.Wrapf(fetchErr, "shard %s", owner))
+			}
+		}(owner, sent)
+	}
+	wg.Wait()
+	return checks, err
+}
+
+// fetchAttestations checks the signers at the indices in sent at baseURL,
+// and stores their checks into checks. It returns the combined errors of the
+// signers which failed, or err if the request itself failed.
+func (c *Client) fetchAttestations(
+	ctx context.Context,
+	baseURL string,
+	network string,
+	data *phase0.AttestationData,
+	signers []AttestationSigner,
+	sent []int,
+	checks []*protector.Check,
+	opts []CheckOption,
+) (failed error, err error) {
+	req := &checkAttestationsRequest{
+		Timestamp:     time.Now().UnixNano(),
+		Data:          *data,
+		Signers:       make([]attestationSigner, len(sent)),
+		signingParams: newCheckOptions(opts).signingParams,
+	}
+	for i, j := range sent {
+		req.Signers[i] = attestationSigner{
+			PubKey:      jsonPubKey(signers[j].PubKey),
+			SigningRoot: jsonRoot(signers[j].SigningRoot),
+			Signature:   (*jsonSignature)(signers[j].Signature),
+		}
+	}
+	var resp checkAttestationsResponse
+	err = requests.
+		URL(baseURL).
+		Client(c.http).
+		Pathf("/v1/%s/slashable/attestations", network).
 		BodyJSON(req).
 		AddValidator(nil). // Don't check http.StatusOK
 		ToJSON(&resp).
@@ -58,26 +514,43 @@ func (c *Client) CheckAttestation(
 	if resp.Timestamp != req.Timestamp {
 		return nil, errors.New("timestamp mismatch")
 	}
-	return resp.Check, nil
+	if len(resp.Results) != len(sent) {
+		return nil, errors.Errorf("expected %d results, got %d", len(sent), len(resp.Results))
+	}
+	for i, result := range resp.Results {
+		signer := signers[sent[i]]
+		if result.Error != "" {
+			failed = multierr.Append(failed, errors.Errorf("%#x: %s", signer.PubKey, result.Error))
+			continue
+		}
+		checks[sent[i]] = result.Check
+		if signer.SignedInto != nil && result.Signature != nil {
+			*signer.SignedInto = phase0.BLSSignature(*result.Signature)
+		}
+		if c.watermarks != nil && result.Check != nil && !result.Check.Slashable {
+			c.watermarks.attested(network, signer.PubKey, signer.SigningRoot, data)
+		}
+	}
+	return failed, nil
 }
 
-func (c *Client) CheckProposal(
-	ctx context.Context,
-	network string,
-	pubKey phase0.BLSPubKey,
-	signingRoot phase0.Root,
-	slot phase0.Slot,
-) (*protector.Check, error) {
-	req := &checkProposalRequest{
-		PubKey:      jsonPubKey(pubKey),
-		SigningRoot: jsonRoot(signingRoot),
-		Slot:        slot,
+// CheckDuty checks an SSV consensus duty of any role for a potential slashing.
+func (c *Client) CheckDuty(ctx context.Context, network string, duty *Duty) (*protector.Check, error) {
+	req := &checkDutyRequest{
+		Timestamp:     time.Now().UnixNano(),
+		Role:          duty.Role,
+		Slot:          duty.Slot,
+		PubKey:        jsonPubKey(duty.PubKey),
+		SigningRoot:   jsonRoot(duty.SigningRoot),
+		Attestation:   duty.Attestation,
+		Signature:     (*jsonSignature)(duty.Signature),
+		signingParams: signingParams{Domain: (*jsonRoot)(duty.Domain)},
 	}
 	var resp checkResponse
 	err := requests.
-		URL(c.baseURL).
+		URL(c.shardURL(duty.PubKey)).
 		Client(c.http).
-		Pathf("/v1/%s/slashable/proposal", network).
+		Pathf("/v1/%s/slashable/duty", network).
 		BodyJSON(req).
 		AddValidator(nil). // Don't check http.StatusOK
 		ToJSON(&resp).
@@ -93,3 +566,43 @@ func (c *Client) CheckProposal(
 	}
 	return resp.Check, nil
 }
+
+// CheckBlockHeader checks a proposal by its block header, from which the server
+// derives the slot and the signing root, given the signing domain with WithDomain
+// or WithForkData.
+func (c *Client) CheckBlockHeader(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	header *phase0.BeaconBlockHeader,
+	opts ...CheckOption,
+) (*protector.Check, error) {
+	if header == nil {
+		return nil, errors.New("header is required")
+	}
+
+	// The signing root is derived by the server, so only the slot is compared.
+	if c.watermarks != nil {
+		if check := c.watermarks.refuseProposal(network, pubKey, phase0.Root{}, header.Slot); check != nil {
+			return check, nil
+		}
+	}
+
+	o := newCheckOptions(opts)
+	req := &checkBlockHeaderRequest{
+		Timestamp:     time.Now().UnixNano(),
+		PubKey:        jsonPubKey(pubKey),
+		Signature:     o.signature,
+		Header:        header,
+		signingParams: o.signingParams,
+	}
+	resp, err := c.fetchCheck(ctx, pubKey, fmt.Sprintf("/v1/%s/slashable/block-header", network), req, req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if c.watermarks != nil && resp.Check != nil && !resp.Check.Slashable {
+		c.watermarks.proposed(network, pubKey, phase0.Root{}, header.Slot)
+	}
+	o.storeSigned(resp.Signature)
+	return resp.Check, nil
+}
@@ -1,18 +1,40 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/backup"
+	"github.com/bloxapp/slashing-protector/protector/forensics"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/keystore"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/bloxapp/slashing-protector/protector/protectortest"
+	"github.com/bloxapp/slashing-protector/protector/replica"
+	"github.com/bloxapp/slashing-protector/protector/shard"
+	"github.com/bloxapp/slashing-protector/protector/signing"
+	"github.com/bloxapp/slashing-protector/protector/wal"
+	"github.com/carlmjohnson/requests"
+	"github.com/herumi/bls-eth-go-binary/bls"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestClient_CheckAttestation_Valid(t *testing.T) {
@@ -187,6 +209,140 @@ func TestClient_CheckAttestation_DoubleVote(t *testing.T) {
 	}
 }
 
+// TestClient_CheckAttestation_SurroundVote tests cases where an attestation
+// must be slashed because it surrounds or is surrounded by a previous one.
+func TestClient_CheckAttestation_SurroundVote(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		existing [][2]phase0.Epoch
+		incoming [2]phase0.Epoch
+		reason   string
+	}{
+		{
+			name:     "surrounding a previous attestation",
+			existing: [][2]phase0.Epoch{{1, 2}, {3, 4}},
+			incoming: [2]phase0.Epoch{2, 5},
+			reason:   "surrounding a previous attestation",
+		},
+		{
+			name:     "surrounded by a previous attestation",
+			existing: [][2]phase0.Epoch{{1, 2}, {2, 10}},
+			incoming: [2]phase0.Epoch{3, 9},
+			reason:   "surrounded by a previous attestation",
+		},
+		{
+			name:     "surrounding one of many previous attestations",
+			existing: [][2]phase0.Epoch{{1, 2}, {2, 3}, {5, 6}, {6, 7}},
+			incoming: [2]phase0.Epoch{4, 8},
+			reason:   "surrounding a previous attestation",
+		},
+		{
+			name:     "surrounded after a long gap",
+			existing: [][2]phase0.Epoch{{1, 2}, {2, 100}},
+			incoming: [2]phase0.Epoch{50, 60},
+			reason:   "surrounded by a previous attestation",
+		},
+		{
+			name:     "source greater than target",
+			existing: nil,
+			incoming: [2]phase0.Epoch{5, 4},
+			reason:   "source epoch greater than target epoch",
+		},
+		{
+			name:     "adjacent attestations are safe",
+			existing: [][2]phase0.Epoch{{1, 2}, {2, 3}},
+			incoming: [2]phase0.Epoch{3, 4},
+		},
+		{
+			name:     "same source with higher target is safe",
+			existing: [][2]phase0.Epoch{{1, 2}, {2, 5}},
+			incoming: [2]phase0.Epoch{2, 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := setupClient(t)
+			for _, e := range tt.existing {
+				check, err := client.CheckAttestation(
+					ctx,
+					"mainnet",
+					phase0.BLSPubKey{},
+					phase0.Root{byte(e[1])},
+					createAttestationData(e[0], e[1]),
+				)
+				require.NoError(t, err)
+				require.False(t, check.Slashable, check.Reason)
+			}
+
+			check, err := client.CheckAttestation(
+				ctx,
+				"mainnet",
+				phase0.BLSPubKey{},
+				phase0.Root{0xff},
+				createAttestationData(tt.incoming[0], tt.incoming[1]),
+			)
+			require.NoError(t, err)
+			if tt.reason == "" {
+				require.False(t, check.Slashable, check.Reason)
+				return
+			}
+			require.True(t, check.Slashable, "expected slashing")
+			require.Contains(t, check.Reason, tt.reason)
+		})
+	}
+}
+
+func TestClient_CheckAttestation_GroupCommit(t *testing.T) {
+	client, _ := setupClient(t, protector.WithCommitInterval(50*time.Millisecond))
+
+	// Concurrently check conflicting attestations for the same target,
+	// expecting them to land in the same group commit.
+	var (
+		wg     sync.WaitGroup
+		checks = make([]*protector.Check, 5)
+		errs   = make([]error, 5)
+	)
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			checks[i], errs[i] = client.CheckAttestation(
+				context.Background(),
+				"mainnet",
+				phase0.BLSPubKey{},
+				phase0.Root{byte(i + 1)},
+				createAttestationData(0, 1),
+			)
+		}(i)
+	}
+	wg.Wait()
+
+	// Exactly one of them should pass.
+	var passed int
+	for i, check := range checks {
+		require.NoError(t, errs[i])
+		if !check.Slashable {
+			passed++
+		}
+	}
+	require.Equal(t, 1, passed, "expected exactly one attestation to pass")
+
+	// Non-conflicting attestations should all pass.
+	for epoch := phase0.Epoch(1); epoch < 4; epoch++ {
+		check, err := client.CheckAttestation(
+			context.Background(),
+			"mainnet",
+			phase0.BLSPubKey{},
+			phase0.Root{},
+			createAttestationData(epoch, epoch+1),
+		)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+}
+
 func TestClient_CheckProposal_Valid(t *testing.T) {
 	client, _ := setupClient(t)
 	check, err := client.CheckProposal(
@@ -197,14 +353,1521 @@ func TestClient_CheckProposal_Valid(t *testing.T) {
 		32,
 	)
 	require.NoError(t, err)
-	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+// setupClient creates a test client for testing.
+func TestClient_CheckAttestations(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+	data := createAttestationData(1, 2)
+
+	// Sign a conflicting attestation with the first key.
+	check, err := client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0}, phase0.Root{0xff}, data)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Check the same data for many keys, and expect only the first to be slashable.
+	signers := make([]AttestationSigner, 100)
+	for i := range signers {
+		signers[i] = AttestationSigner{
+			PubKey:      phase0.BLSPubKey{byte(i)},
+			SigningRoot: phase0.Root{byte(i)},
+		}
+	}
+	checks, err := client.CheckAttestations(ctx, "mainnet", data, signers)
+	require.NoError(t, err)
+	require.Len(t, checks, len(signers))
+	require.True(t, checks[0].Slashable)
+	for i := 1; i < len(checks); i++ {
+		require.False(t, checks[i].Slashable, "unexpected slashing: %s", checks[i].Reason)
+	}
+
+	// Expect the attestations to be recorded.
+	for _, signer := range signers[1:3] {
+		check, err := client.CheckAttestation(ctx, "mainnet", signer.PubKey, phase0.Root{0xff}, data)
+		require.NoError(t, err)
+		require.True(t, check.Slashable)
+	}
+}
+
+func TestServer_BatchConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	// Serve a protector which tracks how many checks run at once.
+	var (
+		running, peak int
+		mu            sync.Mutex
+	)
+	mock := &protectortest.Mock{
+		CheckAttestationFunc: func(context.Context, string, phase0.BLSPubKey, phase0.Root, *phase0.AttestationData) (*protector.Check, error) {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return &protector.Check{}, nil
+		},
+	}
+	server := httptest.NewServer(NewServer(zap.NewNop(), mock, WithBatchConcurrency(3)))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Expect every signer to be checked, no more than 3 at once.
+	signers := make([]AttestationSigner, 50)
+	for i := range signers {
+		signers[i] = AttestationSigner{PubKey: phase0.BLSPubKey{byte(i)}, SigningRoot: phase0.Root{0x1}}
+	}
+	checks, err := client.CheckAttestations(ctx, "mainnet", createAttestationData(1, 2), signers)
+	require.NoError(t, err)
+	require.Len(t, checks, len(signers))
+	for _, check := range checks {
+		require.NotNil(t, check)
+	}
+	require.Len(t, mock.Calls(), len(signers))
+	require.LessOrEqual(t, peak, 3)
+	require.Positive(t, peak)
+}
+
+func TestClient_CheckDuty(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+	pubKey := phase0.BLSPubKey{0x1}
+	data := createAttestationData(1, 2)
+	data.Slot = 64
+
+	// Expect attester duties to be checked as attestations.
+	duty := &Duty{Role: RoleAttester, Slot: 64, PubKey: pubKey, SigningRoot: phase0.Root{0x1}, Attestation: data}
+	check, err := client.CheckDuty(ctx, "mainnet", duty)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, data)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect mismatching or missing attestations to be refused.
+	duty.Slot = 65
+	_, err = client.CheckDuty(ctx, "mainnet", duty)
+	require.ErrorContains(t, err, "doesn't match duty slot")
+	duty.Attestation = nil
+	_, err = client.CheckDuty(ctx, "mainnet", duty)
+	require.ErrorContains(t, err, "attestation is required")
+
+	// Expect proposer duties to be checked as proposals.
+	duty = &Duty{Role: RoleProposer, Slot: 100, PubKey: pubKey, SigningRoot: phase0.Root{0x3}}
+	check, err = client.CheckDuty(ctx, "mainnet", duty)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	duty.SigningRoot = phase0.Root{0x4}
+	check, err = client.CheckDuty(ctx, "mainnet", duty)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect duties which can't be slashed to be allowed, even repeatedly.
+	for _, role := range []DutyRole{RoleAggregator, RoleSyncCommittee, RoleVoluntaryExit} {
+		for i := 0; i < 2; i++ {
+			check, err = client.CheckDuty(ctx, "mainnet", &Duty{Role: role, Slot: 100, PubKey: pubKey, SigningRoot: phase0.Root{byte(i)}})
+			require.NoError(t, err)
+			require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+		}
+	}
+
+	_, err = client.CheckDuty(ctx, "mainnet", &Duty{Role: "UNKNOWN", PubKey: pubKey})
+	require.ErrorContains(t, err, "unknown duty role")
+}
+
+func TestClient_SignatureVerification(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithSignatureVerification()))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Create a key and sign a signing root with it.
+	require.NoError(t, initBLS())
+	var sk bls.SecretKey
+	sk.SetByCSPRNG()
+	var pubKey phase0.BLSPubKey
+	copy(pubKey[:], sk.GetPublicKey().Serialize())
+	signingRoot := phase0.Root{0x1}
+	var signature phase0.BLSSignature
+	copy(signature[:], sk.SignByte(signingRoot[:]).Serialize())
+
+	// Expect checks without a signature, or with a signature
+	// of another signing root, to be refused and not recorded.
+	_, err := client.CheckProposal(ctx, "mainnet", pubKey, signingRoot, 1)
+	require.ErrorContains(t, err, "signature is required")
+	_, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 1, WithSignature(signature))
+	require.ErrorContains(t, err, ErrInvalidSignature.Error())
+	_, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, createAttestationData(1, 2), WithSignature(signature))
+	require.ErrorContains(t, err, ErrInvalidSignature.Error())
+	checks, err := client.CheckAttestations(ctx, "mainnet", createAttestationData(1, 2), []AttestationSigner{
+		{PubKey: pubKey, SigningRoot: phase0.Root{0x2}, Signature: &signature},
+	})
+	require.ErrorContains(t, err, ErrInvalidSignature.Error())
+	require.Nil(t, checks[0])
+	history, err := prtc.History(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Empty(t, history.Proposals)
+	require.Empty(t, history.Attestations)
+
+	// Expect checks with a valid signature to be served.
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, signingRoot, 1, WithSignature(signature))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, signingRoot, createAttestationData(1, 2), WithSignature(signature))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckDuty(ctx, "mainnet", &Duty{
+		Role:        RoleSyncCommittee,
+		Slot:        1,
+		PubKey:      pubKey,
+		SigningRoot: signingRoot,
+		Signature:   &signature,
+	})
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestClient_SigningRootComputation(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	forkVersion, genesisValidatorsRoot := phase0.Version{0x1}, mainnetGenesisValidatorsRoot()
+	domain, err := signing.ComputeDomain(signing.DomainBeaconAttester, forkVersion, genesisValidatorsRoot)
+	require.NoError(t, err)
+	data := createAttestationData(1, 2)
+	signingRoot, err := signing.SigningRoot(data, domain)
+	require.NoError(t, err)
+
+	// Expect a signing root which doesn't match the computed one to be refused.
+	_, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0xff}, data, WithDomain(domain))
+	require.ErrorContains(t, err, "doesn't match the computed signing root")
+
+	// Expect a domain of another type to be refused.
+	proposerDomain, err := signing.ComputeDomain(signing.DomainBeaconProposer, forkVersion, genesisValidatorsRoot)
+	require.NoError(t, err)
+	_, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{}, data, WithDomain(proposerDomain))
+	require.ErrorContains(t, err, "doesn't match the expected")
+
+	// Expect the computed signing root to be recorded when none is given.
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{}, data, WithForkData(forkVersion, genesisValidatorsRoot))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	history, err := prtc.History(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Len(t, history.Attestations, 1)
+	require.Equal(t, signingRoot, history.Attestations[0].SigningRoot)
+
+	// Expect the same attestation to be allowed again, with the matching signing root.
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, signingRoot, data, WithDomain(domain))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect batch checks and duties to compute signing roots too.
+	checks, err := client.CheckAttestations(ctx, "mainnet", data, []AttestationSigner{{PubKey: phase0.BLSPubKey{0x2}}}, WithDomain(domain))
+	require.NoError(t, err)
+	require.False(t, checks[0].Slashable, "unexpected slashing: %s", checks[0].Reason)
+	_, err = client.CheckDuty(ctx, "mainnet", &Duty{
+		Role:        RoleAttester,
+		PubKey:      phase0.BLSPubKey{0x3},
+		SigningRoot: phase0.Root{0xff},
+		Attestation: data,
+		Domain:      &domain,
+	})
+	require.ErrorContains(t, err, "doesn't match the computed signing root")
+}
+
+func TestClient_SigningChain(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	devnetRoot := phase0.Root{0x2}
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithGenesisValidatorsRoots(map[string]phase0.Root{"devnet": devnetRoot})))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pubKey := phase0.BLSPubKey{0x1}
+	data := createAttestationData(1, 2)
+	domain := func(forkVersion phase0.Version, root phase0.Root) phase0.Domain {
+		domain, err := signing.ComputeDomain(signing.DomainBeaconAttester, forkVersion, root)
+		require.NoError(t, err)
+		return domain
+	}
+	sepoliaRoot, _ := interchange.KnownGenesisValidatorsRoot("sepolia")
+	electra := phase0.Version{0x05}
+
+	// Expect signing parameters of another chain than the network's to be refused.
+	for name, tt := range map[string]struct {
+		network  string
+		opt      CheckOption
+		expected string
+	}{
+		"unknown network":            {"madeup", WithDomain(domain(electra, mainnetGenesisValidatorsRoot())), "no known genesis validators root"},
+		"unknown network fork data":  {"madeup", WithForkData(electra, devnetRoot), "no known genesis validators root"},
+		"other genesis root":         {"mainnet", WithForkData(electra, devnetRoot), "isn't the network's"},
+		"domain of other chain":      {"mainnet", WithDomain(domain(electra, sepoliaRoot)), "isn't of any known fork"},
+		"domain of unknown fork":     {"mainnet", WithDomain(domain(phase0.Version{0x9}, mainnetGenesisValidatorsRoot())), "isn't of any known fork"},
+		"domain without known forks": {"devnet", WithDomain(domain(electra, devnetRoot)), "isn't of any known fork"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := client.CheckAttestation(ctx, tt.network, pubKey, phase0.Root{}, data, tt.opt)
+			require.ErrorContains(t, err, tt.expected)
+			history, err := prtc.History(ctx, tt.network, pubKey)
+			require.NoError(t, err)
+			require.Empty(t, history.Attestations)
+		})
+	}
+
+	// Expect the fork version alone to compute the domain with the network's
+	// genesis validators root, and to verify a domain given with it.
+	check, err := client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{}, data, func(o *checkOptions) {
+		forkVersion := jsonVersion(phase0.Version{0x9})
+		o.ForkVersion = &forkVersion
+	})
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	devnetDomain := domain(phase0.Version{0x9}, devnetRoot)
+	_, err = client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{}, data, WithDomain(devnetDomain), WithForkData(electra, devnetRoot))
+	require.ErrorContains(t, err, "isn't of fork_version")
+	check, err = client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{}, data, WithDomain(devnetDomain), WithForkData(phase0.Version{0x9}, devnetRoot))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestClient_CheckBlockHeader(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	domain, err := signing.ComputeDomain(signing.DomainBeaconProposer, phase0.Version{0x1}, mainnetGenesisValidatorsRoot())
+	require.NoError(t, err)
+	header := &phase0.BeaconBlockHeader{Slot: 10, ProposerIndex: 1, BodyRoot: phase0.Root{0x3}}
+	signingRoot, err := signing.SigningRoot(header, domain)
+	require.NoError(t, err)
+
+	// Expect the domain to be required.
+	_, err = client.CheckBlockHeader(ctx, "mainnet", pubKey, header)
+	require.ErrorContains(t, err, "is required")
+
+	// Expect the proposal to be recorded at the header's slot with its signing root.
+	check, err := client.CheckBlockHeader(ctx, "mainnet", pubKey, header, WithDomain(domain))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	history, err := prtc.History(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Equal(t, []*protector.ProposalRecord{{Slot: 10, SigningRoot: signingRoot}}, history.Proposals)
+
+	// Expect the same header to be allowed again (also as a proposal with
+	// the same signing root), and a different body to be slashable.
+	check, err = client.CheckBlockHeader(ctx, "mainnet", pubKey, header, WithDomain(domain))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, signingRoot, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	header.BodyRoot = phase0.Root{0x4}
+	check, err = client.CheckBlockHeader(ctx, "mainnet", pubKey, header, WithDomain(domain))
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect headers in SSZ to be accepted.
+	header.Slot = 11
+	headerSSZ, err := header.MarshalSSZ()
+	require.NoError(t, err)
+	var resp checkResponse
+	err = requests.
+		URL(server.URL).
+		Path("/v1/mainnet/slashable/block-header").
+		BodyJSON(&checkBlockHeaderRequest{
+			PubKey:        jsonPubKey(pubKey),
+			HeaderSSZ:     headerSSZ,
+			signingParams: signingParams{Domain: (*jsonRoot)(&domain)},
+		}).
+		ToJSON(&resp).
+		Fetch(ctx)
+	require.NoError(t, err)
+	require.Empty(t, resp.Error)
+	require.False(t, resp.Check.Slashable, "unexpected slashing: %s", resp.Check.Reason)
+}
+
+func TestServer_ElectraAttestations(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithElectraForkEpochs(map[string]phase0.Epoch{"devnet": 10})))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect committee indices before Electra to be allowed.
+	data := createAttestationData(8, 9)
+	data.Slot, data.Index = 9*protector.SlotsPerEpoch, 5
+	check, err := client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{0x1}, data)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect committee indices from Electra onwards to be refused, and zero indices to be allowed.
+	data = createAttestationData(9, 10)
+	data.Slot, data.Index = 10*protector.SlotsPerEpoch, 5
+	_, err = client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{0x2}, data)
+	require.ErrorContains(t, err, "index must be 0 from Electra onwards")
+	_, err = client.CheckAttestations(ctx, "devnet", data, []AttestationSigner{{PubKey: pubKey, SigningRoot: phase0.Root{0x2}}})
+	require.ErrorContains(t, err, "index must be 0 from Electra onwards")
+	data.Index = 0
+	check, err = client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{0x2}, data)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect networks without a known Electra fork to be unaffected.
+	data.Index = 5
+	check, err = client.CheckAttestation(ctx, "other", pubKey, phase0.Root{0x2}, data)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestClient_Digest(t *testing.T) {
+	ctx := context.Background()
+	clientA, _ := setupClient(t)
+	clientB, _ := setupClient(t)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect unknown keys to have no digest.
+	_, err := clientA.Digest(ctx, "mainnet", pubKey)
+	require.ErrorIs(t, err, protector.ErrUnknownKey)
+
+	// Sign the same history on both instances.
+	for _, client := range []*Client{clientA, clientB} {
+		check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(1, 2))
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+		check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	// Expect equal digests.
+	digestA, err := clientA.Digest(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	digestB, err := clientB.Digest(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Equal(t, digestA, digestB)
+	require.Equal(t, 1, digestA.Attestations)
+	require.Equal(t, 1, digestA.Proposals)
+
+	// Diverge and expect different digests.
+	check, err := clientB.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 11)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	digestB, err = clientB.Digest(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.NotEqual(t, digestA.Root, digestB.Root)
+}
+
+func TestClient_Validators(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+
+	// Expect no validators before anything is signed.
+	validators, err := client.Validators(ctx, "mainnet")
+	require.NoError(t, err)
+	require.Empty(t, validators)
+
+	// Sign with two keys.
+	start := time.Now()
+	pubKeyA, pubKeyB := phase0.BLSPubKey{0x1}, phase0.BLSPubKey{0x2}
+	for _, pubKey := range []phase0.BLSPubKey{pubKeyA, pubKeyB} {
+		check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(1, 2))
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+	check, err := client.CheckProposal(ctx, "mainnet", pubKeyB, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect both keys to be listed with their stats, and no keys in other networks.
+	validators, err = client.Validators(ctx, "mainnet")
+	require.NoError(t, err)
+	require.Len(t, validators, 2)
+	require.Equal(t, 1, validators[pubKeyA].Attestations)
+	require.Equal(t, 0, validators[pubKeyA].Proposals)
+	require.Equal(t, 1, validators[pubKeyB].Attestations)
+	require.Equal(t, 1, validators[pubKeyB].Proposals)
+	for _, stats := range validators {
+		require.False(t, stats.LastActivity.Before(start))
+	}
+	validators, err = client.Validators(ctx, "prater")
+	require.NoError(t, err)
+	require.Empty(t, validators)
+}
+
+func TestClient_Stats(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect empty stats for an unknown key.
+	stats, err := client.Stats(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Zero(t, stats.Attestations)
+	require.Nil(t, stats.FirstTargetEpoch)
+	require.Nil(t, stats.LastSlot)
+	require.True(t, stats.LastActivity.IsZero())
+	require.True(t, stats.LastCheck.IsZero())
+
+	// Sign some history, and check a slashable attestation
+	// which counts as a check but isn't written.
+	for _, epochs := range [][2]phase0.Epoch{{1, 2}, {2, 5}} {
+		check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(epochs[0], epochs[1]))
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	stats, err = client.Stats(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	lastActivity := stats.LastActivity
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x3}, createAttestationData(2, 5))
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect the stats to reflect the history.
+	stats, err = client.Stats(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Attestations)
+	require.Equal(t, 1, stats.Proposals)
+	require.Positive(t, stats.Size)
+	require.Equal(t, phase0.Epoch(2), *stats.FirstTargetEpoch)
+	require.Equal(t, phase0.Epoch(5), *stats.LastTargetEpoch)
+	require.Equal(t, phase0.Slot(10), *stats.FirstSlot)
+	require.Equal(t, phase0.Slot(10), *stats.LastSlot)
+	require.True(t, stats.LastActivity.Equal(lastActivity))
+	require.True(t, stats.LastCheck.After(lastActivity))
+}
+
+func TestClient_LastSigned(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+	pubKeyA, pubKeyB := phase0.BLSPubKey{0x1}, phase0.BLSPubKey{0x2}
+
+	for _, epochs := range [][2]phase0.Epoch{{1, 2}, {2, 5}} {
+		check, err := client.CheckAttestation(ctx, "mainnet", pubKeyA, phase0.Root{0x1}, createAttestationData(epochs[0], epochs[1]))
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+	check, err := client.CheckProposal(ctx, "mainnet", pubKeyB, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect the highest signed data of both keys.
+	last, err := client.LastSigned(ctx, "mainnet")
+	require.NoError(t, err)
+	require.Len(t, last, 2)
+	require.Equal(t, phase0.Epoch(2), *last[pubKeyA].SourceEpoch)
+	require.Equal(t, phase0.Epoch(5), *last[pubKeyA].TargetEpoch)
+	require.Nil(t, last[pubKeyA].Slot)
+	require.Nil(t, last[pubKeyB].SourceEpoch)
+	require.Nil(t, last[pubKeyB].TargetEpoch)
+	require.Equal(t, phase0.Slot(10), *last[pubKeyB].Slot)
+}
+
+func TestServer_HistorySinceEpoch(t *testing.T) {
+	ctx := context.Background()
+	client, server := setupClient(t)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	for _, epochs := range [][2]phase0.Epoch{{1, 2}, {2, 3}, {3, 4}} {
+		data := createAttestationData(epochs[0], epochs[1])
+		data.BeaconBlockRoot = phase0.Root{0x3}
+		check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, data)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+	for _, slot := range []phase0.Slot{95, 96} {
+		check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, slot)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	type history struct {
+		Proposals []struct {
+			Slot phase0.Slot `json:"slot"`
+		} `json:"proposals"`
+		Attestations []struct {
+			Target          phase0.Epoch `json:"target"`
+			BeaconBlockRoot string       `json:"beacon_block_root"`
+		} `json:"attestations"`
+	}
+	getHistory := func(query string) (*history, int) {
+		resp, err := http.Get(fmt.Sprintf("%s/v1/mainnet/history/%#x%s", server.URL, pubKey, query))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var h history
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&h))
+		}
+		return &h, resp.StatusCode
+	}
+
+	// Expect the full history without since_epoch.
+	h, status := getHistory("")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, h.Attestations, 3)
+	require.Len(t, h.Proposals, 2)
+	require.Equal(t, fmt.Sprintf("%x", phase0.Root{0x3}), h.Attestations[0].BeaconBlockRoot)
+
+	// Expect only records from epoch 3 onwards, which starts at slot 96.
+	h, status = getHistory("?since_epoch=3")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, h.Attestations, 2)
+	require.Equal(t, phase0.Epoch(3), h.Attestations[0].Target)
+	require.Len(t, h.Proposals, 1)
+	require.Equal(t, phase0.Slot(96), h.Proposals[0].Slot)
+
+	// Expect nothing past the end of the history, even without overflowing.
+	h, status = getHistory(fmt.Sprintf("?since_epoch=%d", uint64(math.MaxUint64)))
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, h.Attestations)
+	require.Empty(t, h.Proposals)
+
+	_, status = getHistory("?since_epoch=abc")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestClient_ReadOnly(t *testing.T) {
+	client, _ := setupClient(t, protector.WithReadOnly())
+
+	// Expect checks to be rejected.
+	_, err := client.CheckAttestation(
+		context.Background(),
+		"mainnet",
+		phase0.BLSPubKey{},
+		phase0.Root{0x1},
+		createAttestationData(0, 1),
+	)
+	require.ErrorContains(t, err, protector.ErrReadOnly.Error())
+	_, err = client.CheckProposal(context.Background(), "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, 1)
+	require.ErrorContains(t, err, protector.ErrReadOnly.Error())
+
+	// Expect reads to be served.
+	_, err = client.Stats(context.Background(), "mainnet", phase0.BLSPubKey{})
+	require.NoError(t, err)
+}
+
+// overloadedProtector is a Protector which rejects every check with ErrOverloaded.
+type overloadedProtector struct {
+	protector.Protector
+}
+
+func (overloadedProtector) CheckProposal(context.Context, string, phase0.BLSPubKey, phase0.Root, phase0.Slot) (*protector.Check, error) {
+	return nil, protector.ErrOverloaded
+}
+
+func TestServer_Overloaded(t *testing.T) {
+	server := httptest.NewServer(NewServer(zap.NewNop(), overloadedProtector{}))
+	defer server.Close()
+
+	// Expect a 429 with a Retry-After header.
+	resp, err := http.Post(
+		server.URL+"/v1/mainnet/slashable/proposal",
+		"application/json",
+		strings.NewReader(`{"pub_key":"0x01","signing_root":"0x01","block":1}`),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, retryAfter, resp.Header.Get("Retry-After"))
+
+	// Expect the client to return the error.
+	client := NewClient(http.DefaultClient, server.URL)
+	_, err = client.CheckProposal(context.Background(), "mainnet", phase0.BLSPubKey{}, phase0.Root{}, 1)
+	require.ErrorContains(t, err, protector.ErrOverloaded.Error())
+}
+
+func TestServer_CheckTimeout(t *testing.T) {
+	// Serve a protector whose checks time out waiting for their key.
+	mock := &protectortest.Mock{
+		CheckProposalFunc: func(context.Context, string, phase0.BLSPubKey, phase0.Root, phase0.Slot) (*protector.Check, error) {
+			return nil, fmt.Errorf("kvpool.Acquire: %w", context.DeadlineExceeded)
+		},
+		CheckAttestationFunc: func(context.Context, string, phase0.BLSPubKey, phase0.Root, *phase0.AttestationData) (*protector.Check, error) {
+			return nil, context.Canceled
+		},
+	}
+	server := httptest.NewServer(NewServer(zap.NewNop(), mock))
+	defer server.Close()
+
+	// Expect a 503 status, and not only in the body.
+	attestation, err := json.Marshal(&checkAttestationRequest{PubKey: jsonPubKey{0x1}, Data: *createAttestationData(1, 2)})
+	require.NoError(t, err)
+	for path, body := range map[string]string{
+		"/v1/mainnet/slashable/proposal":    `{"pub_key":"0x01","signing_root":"0x01","block":1}`,
+		"/v1/mainnet/slashable/attestation": string(attestation),
+	} {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		var check checkResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&check))
+		resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, path)
+		require.Equal(t, http.StatusServiceUnavailable, check.StatusCode, path)
+		require.Empty(t, resp.Header.Get("Retry-After"), path)
+	}
+}
+
+func TestServer_LatencySLO(t *testing.T) {
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	srv := NewServer(zap.NewNop(), prtc, WithLatencySLO(time.Nanosecond))
+	server := httptest.NewServer(srv)
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	historyStatus := func() int {
+		resp, err := http.Get(server.URL + "/v1/mainnet/history/0x01")
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	// Expect history reads to be served before any checks.
+	require.Equal(t, http.StatusOK, historyStatus())
+	require.False(t, srv.Degraded())
+
+	// Expect history reads to be shed once checks exceed the objective.
+	_, err := client.CheckProposal(context.Background(), "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, historyStatus())
+	require.True(t, srv.Degraded())
+
+	// Expect the degradation to be reported once.
+	var metrics struct {
+		Degraded     bool
+		Degradations int
+	}
+	err = requests.URL(server.URL).Path("/metrics").ToJSON(&metrics).Fetch(context.Background())
+	require.NoError(t, err)
+	require.True(t, metrics.Degraded)
+	require.Equal(t, 1, metrics.Degradations)
+}
+
+func TestServer_CheckLogSampling(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	core, logs := observer.New(zap.DebugLevel)
+	srv := NewServer(zap.New(core), prtc, WithCheckLogSampling(2))
+	server := httptest.NewServer(srv)
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Expect only the first two passing checks to be logged within the second.
+	for slot := phase0.Slot(1); slot <= 3; slot++ {
+		check, err := client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, slot)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+	require.Equal(t, 2, logs.FilterMessage("CheckProposal").Len())
+	require.Equal(t, int64(1), srv.checkLog.droppedLogs())
+
+	// Expect slashable checks to be logged regardless.
+	check, err := client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x2}, 3)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	slashable := logs.FilterMessage("CheckProposal").FilterLevelExact(zap.InfoLevel).All()
+	require.Len(t, slashable, 1)
+	require.Equal(t, uint64(3), slashable[0].ContextMap()["slot"])
+}
+
+func TestServer_RecoverPanics(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	srv := NewServer(zap.NewNop(), prtc)
+	srv.router.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	// Expect a panic to respond with a problem, and count it.
+	resp, err := http.Get(server.URL + "/panic")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	var p problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	require.Equal(t, http.StatusInternalServerError, p.Status)
+
+	// Expect the server to keep serving.
+	check, err := NewClient(http.DefaultClient, server.URL).CheckProposal(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	var metrics struct{ Panics int64 }
+	err = requests.URL(server.URL).Path("/metrics").ToJSON(&metrics).Fetch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), metrics.Panics)
+}
+
+func TestServer_Timeouts(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	proposalFault, err := ParseFault("proposal", "latency:1s")
+	require.NoError(t, err)
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc,
+		WithFaults(map[string]Fault{"proposal": proposalFault}),
+		WithTimeouts(Timeouts{Check: 50 * time.Millisecond}),
+	))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Expect checks to time out within their budget.
+	start := time.Now()
+	resp, err := http.Post(server.URL+"/v1/mainnet/slashable/proposal", "application/json", strings.NewReader(`{"block":"1"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	require.Less(t, time.Since(start), time.Second)
+
+	// Expect other routes to keep their default budgets.
+	check, err := client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	_, err = client.Stats(ctx, "mainnet", phase0.BLSPubKey{0x1})
+	require.NoError(t, err)
+}
+
+func TestServer_Faults(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	attestationFault, err := ParseFault("attestation", "error:1")
+	require.NoError(t, err)
+	proposalFault, err := ParseFault("proposal", "latency:10ms,slashable:1")
+	require.NoError(t, err)
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithFaults(map[string]Fault{
+		"attestation": attestationFault,
+		"proposal":    proposalFault,
+	})))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Expect injected errors.
+	_, err = client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	})
+	require.ErrorContains(t, err, "injected fault")
+
+	// Expect injected slashable checks to be delayed, and recorded nothing.
+	start := time.Now()
+	check, err := client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	require.Equal(t, "injected fault", check.Reason)
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	history, err := prtc.History(ctx, "mainnet", phase0.BLSPubKey{})
+	require.NoError(t, err)
+	require.Empty(t, history.Proposals)
+	require.Empty(t, history.Attestations)
+
+	// Expect faults to be validated.
+	_, err = ParseFault("bundle", "slashable:0.5")
+	require.Error(t, err)
+	_, err = ParseFault("history", "error:0.5")
+	require.Error(t, err)
+	_, err = ParseFault("attestation", "error:2")
+	require.Error(t, err)
+}
+
+func TestServer_Capture(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	var captured bytes.Buffer
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithCapture(NewCapture(&captured))))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Expect every check to be captured without it's signature.
+	_, err := client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1,
+		WithSignature(phase0.BLSSignature{0x2}))
+	require.NoError(t, err)
+	_, err = client.CheckProposal(ctx, "holesky", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	dec := json.NewDecoder(&captured)
+	for _, network := range []string{"mainnet", "holesky"} {
+		var check CapturedCheck
+		require.NoError(t, dec.Decode(&check))
+		require.Equal(t, network, check.Network)
+		require.Equal(t, "proposal", check.Endpoint)
+		var request checkProposalRequest
+		require.NoError(t, json.Unmarshal(check.Request, &request))
+		require.Equal(t, phase0.Slot(1), request.Slot)
+		require.Nil(t, request.Signature)
+		require.NotContains(t, string(check.Request), "signature")
+	}
+	require.False(t, dec.More())
+}
+
+func TestClient_GC(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL, WithClientAdminToken("secret"))
+
+	pubKeys := []phase0.BLSPubKey{{0x1}, {0x2}}
+	for _, pubKey := range pubKeys {
+		check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	// Expect requests without the admin token to be rejected.
+	_, err := NewClient(http.DefaultClient, server.URL).GC(ctx)
+	require.Error(t, err)
+
+	// Expect the idle connections to be evicted, and the keys to keep their histories.
+	result, err := client.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.EvictedConns)
+	require.Zero(t, result.InUseConns)
+	result, err = client.GC(ctx)
+	require.NoError(t, err)
+	require.Zero(t, result.EvictedConns)
+	check, err := client.CheckProposal(ctx, "mainnet", pubKeys[0], phase0.Root{0x2}, 1)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+}
+
+func TestClient_Delete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	prtc := protector.New(dir)
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL, WithClientAdminToken("secret"))
+
+	// Sign with two keys.
+	pubKeys := []phase0.BLSPubKey{{0x1}, {0x2}}
+	for _, pubKey := range pubKeys {
+		check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	// Expect requests without the admin token to be rejected.
+	_, err := NewClient(http.DefaultClient, server.URL).Delete(ctx, "mainnet", pubKeys, true)
+	require.Error(t, err)
+
+	// Delete and expect the histories to be archived.
+	archiveDir, err := client.Delete(ctx, "mainnet", pubKeys, true)
+	require.NoError(t, err)
+	require.DirExists(t, archiveDir)
+	pubKeysLeft, err := prtc.(protector.ProtectorPooler).Pool().PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Empty(t, pubKeysLeft)
+
+	// Expect the keys to start over.
+	check, err := client.CheckProposal(ctx, "mainnet", pubKeys[0], phase0.Root{0x2}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestClient_Deactivate(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL, WithClientAdminToken("secret"))
+
+	pubKey := phase0.BLSPubKey{0x1}
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect requests without the admin token to be rejected.
+	require.Error(t, NewClient(http.DefaultClient, server.URL).Deactivate(ctx, "mainnet", []phase0.BLSPubKey{pubKey}))
+
+	// Deactivate and expect checks to be rejected, while the history is kept.
+	require.NoError(t, client.Deactivate(ctx, "mainnet", []phase0.BLSPubKey{pubKey}))
+	_, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 2)
+	require.ErrorContains(t, err, protector.ErrInactive.Error())
+	_, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, createAttestationData(1, 2))
+	require.ErrorContains(t, err, protector.ErrInactive.Error())
+	history, err := prtc.History(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Len(t, history.Proposals, 1)
+
+	// Expect other keys to be unaffected.
+	check, err = client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x2}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Activate and expect checks to pass again.
+	require.NoError(t, client.Activate(ctx, "mainnet", []phase0.BLSPubKey{pubKey}))
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 2)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Sign a proposal, and then attempt a double proposal.
+	pubKey := phase0.BLSPubKey{0x1}
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 1)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect browsers to be asked for the admin token.
+	resp, err := http.Get(server.URL + "/v1/admin/dashboard")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+
+	// Expect the dashboard to show the network and both decisions.
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/dashboard", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("admin", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "<td>mainnet</td><td>1</td>")
+	require.Equal(t, 2, strings.Count(string(body), "slashable: "), "expected in both tables")
+	require.Contains(t, string(body), "allowed")
+}
+
+func TestServer_Forensics(t *testing.T) {
+	ctx := context.Background()
+	recorder := forensics.New(zap.NewNop(), t.TempDir())
+	prtc := protector.New(t.TempDir(), protector.WithForensics(recorder))
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret"), WithForensics(recorder)))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Sign an attestation, and then attempt a surrounding one.
+	pubKey := phase0.BLSPubKey{0x1}
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(2, 3))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, createAttestationData(1, 4))
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	get := func(path string, v interface{}) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/forensics"+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+		return resp.StatusCode
+	}
+
+	// Expect a bundle with the conflicting attestation and the watermarks,
+	// once it's written in the background.
+	var entries []forensics.Entry
+	require.Eventually(t, func() bool {
+		return get("", &entries) == http.StatusOK && len(entries) == 1
+	}, time.Second, time.Millisecond)
+	var bundle forensics.Bundle
+	require.Equal(t, http.StatusOK, get("/"+entries[0].Name, &bundle))
+	require.Equal(t, "mainnet", bundle.Network)
+	require.Equal(t, forensics.KindAttestation, bundle.Kind)
+	require.Equal(t, check.Reason, bundle.Reason)
+	require.Equal(t, phase0.Epoch(4), bundle.Attestation.Target.Epoch)
+	require.Equal(t, []*forensics.Attestation{{
+		SourceEpoch: 2,
+		TargetEpoch: 3,
+		SigningRoot: fmt.Sprintf("%#x", phase0.Root{0x1}),
+	}}, bundle.Attestations)
+	require.Equal(t, phase0.Epoch(3), *bundle.Watermarks.LowestTargetEpoch)
+	require.Nil(t, bundle.Watermarks.LowestSlot)
+	require.Empty(t, bundle.Error)
+
+	// Expect only bundles to be served.
+	require.Equal(t, http.StatusNotFound, get("/missing.json", nil))
+	require.Equal(t, http.StatusNotFound, get("/..%2Fprotection.db", nil))
+}
+
+func TestClient_Export(t *testing.T) {
+	ctx := context.Background()
+	client, server := setupClient(t)
+
+	// Sign with three keys.
+	pubKeys := []phase0.BLSPubKey{{0x1}, {0x2}, {0x3}}
+	for _, pubKey := range pubKeys {
+		check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+		check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, createAttestationData(1, 2))
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	// Export some of the keys.
+	exported, err := client.Export(ctx, "mainnet", pubKeys[1:])
+	require.NoError(t, err)
+	require.Equal(t, interchange.FormatVersion, exported.Metadata.InterchangeFormatVersion)
+	require.Equal(t, interchange.GenesisValidatorsRoots["mainnet"], exported.Metadata.GenesisValidatorsRoot)
+	require.Len(t, exported.Data, 2)
+	require.Equal(t, fmt.Sprintf("%#x", pubKeys[1]), exported.Data[0].PubKey)
+	require.Equal(t, []*interchange.SignedBlock{
+		{Slot: "1", SigningRoot: fmt.Sprintf("%#x", phase0.Root{0x1})},
+	}, exported.Data[0].SignedBlocks)
+	require.Equal(t, []*interchange.SignedAttestation{
+		{SourceEpoch: "1", TargetEpoch: "2", SigningRoot: fmt.Sprintf("%#x", phase0.Root{0x2})},
+	}, exported.Data[0].SignedAttestations)
+
+	// Export all of the keys.
+	exported, err = client.Export(ctx, "mainnet", nil)
+	require.NoError(t, err)
+	require.Len(t, exported.Data, 3)
+
+	// Export the watermarks of a key.
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKeys[2], phase0.Root{0x3}, createAttestationData(2, 5))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	exported, err = client.ExportMinimal(ctx, "mainnet", pubKeys[2:])
+	require.NoError(t, err)
+	require.Len(t, exported.Data, 1)
+	require.Equal(t, []*interchange.SignedBlock{{Slot: "1"}}, exported.Data[0].SignedBlocks)
+	require.Equal(t, []*interchange.SignedAttestation{
+		{SourceEpoch: "2", TargetEpoch: "5"},
+	}, exported.Data[0].SignedAttestations)
+
+	// Export a key as CSV.
+	resp, err := http.Post(
+		server.URL+"/v1/mainnet/export?format=csv",
+		"application/json",
+		strings.NewReader(fmt.Sprintf(`{"pub_keys":["%#x"]}`, pubKeys[0])),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	require.Len(t, strings.Split(strings.TrimSpace(string(body)), "\n"), 3)
+}
+
+func TestClient_Import(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(
+		zap.NewNop(),
+		prtc,
+		WithAdminToken("secret"),
+		WithGenesisValidatorsRoots(map[string]phase0.Root{"devnet": {0xd}}),
+	))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL, WithClientAdminToken("secret"))
+
+	// Export a key from mainnet.
+	pubKey := phase0.BLSPubKey{0x1}
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(2, 3))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	exported, err := client.Export(ctx, "mainnet", nil)
+	require.NoError(t, err)
+
+	// Expect imports of another chain to be refused, including
+	// those whose chain is unknown.
+	_, err = client.Import(ctx, "holesky", exported)
+	require.Error(t, err)
+	require.True(t, requests.HasStatusErr(err, http.StatusConflict), err)
+	zero := *exported
+	zero.Metadata.GenesisValidatorsRoot = fmt.Sprintf("%#x", phase0.Root{})
+	_, err = client.Import(ctx, "mainnet", &zero)
+	require.True(t, requests.HasStatusErr(err, http.StatusConflict), err)
+	history, err := prtc.History(ctx, "holesky", pubKey)
+	require.NoError(t, err)
+	require.Empty(t, history.Attestations)
+
+	// Import into a configured network, and expect its history to be enforced.
+	devnet := *exported
+	devnet.Metadata.GenesisValidatorsRoot = fmt.Sprintf("%#x", phase0.Root{0xd})
+	imported, err := client.Import(ctx, "devnet", &devnet)
+	require.NoError(t, err)
+	require.Equal(t, 1, imported)
+	check, err = client.CheckAttestation(ctx, "devnet", pubKey, phase0.Root{0x2}, createAttestationData(1, 4))
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect networks without a genesis validators root to accept any.
+	_, err = client.Import(ctx, "other", exported)
+	require.NoError(t, err)
 }
 
-// setupClient creates a test client for testing.
-func setupClient(t testing.TB) (*Client, *httptest.Server) {
+func TestServer_Replication(t *testing.T) {
+	ctx := context.Background()
+	primaryDir := t.TempDir()
+	primary := protector.New(primaryDir)
+	defer primary.Close()
+	_, err := primary.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	rep := replica.New(zap.NewNop(), primaryDir, dir)
+	require.NoError(t, rep.Refresh())
+	prtc := protector.New(dir, protector.WithReadOnly())
+	defer prtc.Close()
+
+	get := func(server *httptest.Server, v interface{}) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/replication", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+		return resp.StatusCode
+	}
+
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret"), WithReplica(rep)))
+	defer server.Close()
+	var status replica.Status
+	require.Equal(t, http.StatusOK, get(server, &status))
+	require.False(t, status.LastRefresh.IsZero())
+	require.Equal(t, 1, status.Networks["mainnet"].Stores)
+	require.NotZero(t, status.Networks["mainnet"].Sequence)
+
+	// Expect primaries not to report a status.
+	server = httptest.NewServer(NewServer(zap.NewNop(), primary, WithAdminToken("secret")))
+	defer server.Close()
+	require.Equal(t, http.StatusNotFound, get(server, &status))
+}
+
+func TestServer_Leader(t *testing.T) {
+	ctx := context.Background()
+	leaderDir := t.TempDir()
+	leader := protector.New(leaderDir)
+	defer leader.Close()
+	leaderServer := httptest.NewServer(NewServer(zap.NewNop(), leader))
+	defer leaderServer.Close()
+	leaderURL, err := url.Parse(leaderServer.URL)
+	require.NoError(t, err)
+
+	follower := protector.New(t.TempDir(), protector.WithReadOnly())
+	defer follower.Close()
+	followerServer := httptest.NewServer(NewServer(zap.NewNop(), follower, WithLeader(leaderURL)))
+	defer followerServer.Close()
+	client := NewClient(http.DefaultClient, followerServer.URL)
+
+	// Expect checks to be forwarded to the leader.
+	pubKey := phase0.BLSPubKey{0x1}
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	history, err := leader.History(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Len(t, history.Attestations, 1)
+
+	// Expect reads to be served by the follower, which has yet to replicate the check.
+	stats, err := client.Stats(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Zero(t, stats.Attestations)
+
+	// Expect checks which were already forwarded not to be forwarded again.
+	loop := httptest.NewServer(NewServer(zap.NewNop(), follower, WithLeader(leaderURL)))
+	defer loop.Close()
+	loopURL, err := url.Parse(loop.URL)
+	require.NoError(t, err)
+	looping := httptest.NewServer(NewServer(zap.NewNop(), follower, WithLeader(loopURL)))
+	defer looping.Close()
+	_, err = NewClient(http.DefaultClient, looping.URL).CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+	require.Error(t, err)
+}
+
+func TestServer_Drain(t *testing.T) {
+	ctx := context.Background()
+	w, err := wal.Open(t.TempDir())
+	require.NoError(t, err)
+	prtc := protector.New(t.TempDir(), protector.WithAsyncWrites(w, time.Hour))
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	status := func(method, path string) int {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, status(http.MethodGet, "/readyz"))
+	check, err := client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	depth, _ := prtc.(protector.ProtectorQueuer).AsyncQueue()
+	require.Equal(t, 1, depth)
+
+	// Expect draining to save the pending writes and fail readiness.
+	require.Equal(t, http.StatusNoContent, status(http.MethodPost, "/v1/admin/drain"))
+	depth, _ = prtc.(protector.ProtectorQueuer).AsyncQueue()
+	require.Zero(t, depth)
+	require.Equal(t, http.StatusServiceUnavailable, status(http.MethodGet, "/readyz"))
+
+	// Expect further checks to be refused, and draining to be repeatable.
+	_, err = client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x2}, createAttestationData(2, 3))
+	require.Error(t, err)
+	require.Equal(t, http.StatusNoContent, status(http.MethodPost, "/v1/admin/drain"))
+}
+
+func TestClient_WatermarkCache(t *testing.T) {
+	ctx := context.Background()
+
+	// Serve a protector which wrongly allows everything.
+	mock := &protectortest.Mock{}
+	server := httptest.NewServer(NewServer(zap.NewNop(), mock))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL, WithWatermarkCache())
+	pubKey := phase0.BLSPubKey{0x1}
+
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(2, 5))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 5)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	mock.Reset()
+
+	// Expect provably slashable checks to be refused without asking the server.
+	for _, attestation := range []struct {
+		source, target phase0.Epoch
+		root           phase0.Root
+	}{
+		{1, 6, phase0.Root{0x1}},
+		{3, 4, phase0.Root{0x1}},
+		{2, 5, phase0.Root{0x2}},
+	} {
+		data := createAttestationData(attestation.source, attestation.target)
+		check, err = client.CheckAttestation(ctx, "mainnet", pubKey, attestation.root, data)
+		require.NoError(t, err)
+		require.True(t, check.Slashable, "(%d, %d)", attestation.source, attestation.target)
+	}
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 5)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	checks, err := client.CheckAttestations(ctx, "mainnet", createAttestationData(1, 6), []AttestationSigner{
+		{PubKey: pubKey, SigningRoot: phase0.Root{0x1}},
+		{PubKey: phase0.BLSPubKey{0x2}, SigningRoot: phase0.Root{0x1}},
+	})
+	require.NoError(t, err)
+	require.True(t, checks[0].Slashable)
+	require.False(t, checks[1].Slashable)
+	require.Len(t, mock.Calls(), 1)
+	require.Equal(t, phase0.BLSPubKey{0x2}, mock.Calls()[0].PubKey)
+	mock.Reset()
+
+	// Expect repeated and advancing checks, checks which are only slashable
+	// against the rest of the history, and checks of other networks, to be
+	// asked from the server.
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(2, 5))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(5, 6))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 4)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckProposal(ctx, "holesky", pubKey, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	require.Len(t, mock.Calls(), 5)
+}
+
+func TestClient_WatermarkCacheAgreement(t *testing.T) {
+	type attestation struct {
+		source, target phase0.Epoch
+		root           phase0.Root
+	}
+	for _, test := range []struct {
+		name      string
+		history   []attestation
+		proposals []phase0.Slot
+		check     *attestation
+		slot      *phase0.Slot
+		refused   bool
+	}{
+		{
+			name:    "lower target above the lowest",
+			history: []attestation{{1, 2, phase0.Root{0x1}}, {9, 10, phase0.Root{0x1}}},
+			check:   &attestation{9, 9, phase0.Root{0x2}},
+		},
+		{
+			name:    "lower target between records",
+			history: []attestation{{1, 2, phase0.Root{0x1}}, {5, 6, phase0.Root{0x1}}},
+			check:   &attestation{3, 4, phase0.Root{0x2}},
+		},
+		{
+			name:    "repeated attestation",
+			history: []attestation{{1, 2, phase0.Root{0x1}}},
+			check:   &attestation{1, 2, phase0.Root{0x1}},
+		},
+		{
+			name:    "double vote",
+			history: []attestation{{1, 2, phase0.Root{0x1}}},
+			check:   &attestation{1, 2, phase0.Root{0x2}},
+			refused: true,
+		},
+		{
+			name:    "surrounding vote",
+			history: []attestation{{2, 3, phase0.Root{0x1}}},
+			check:   &attestation{1, 4, phase0.Root{0x2}},
+			refused: true,
+		},
+		{
+			name:    "surrounded vote",
+			history: []attestation{{1, 2, phase0.Root{0x1}}, {2, 5, phase0.Root{0x1}}},
+			check:   &attestation{3, 4, phase0.Root{0x2}},
+			refused: true,
+		},
+		{
+			name:      "lower slot above the lowest",
+			proposals: []phase0.Slot{1, 10},
+			slot:      func() *phase0.Slot { s := phase0.Slot(5); return &s }(),
+		},
+		{
+			name:      "double proposal",
+			proposals: []phase0.Slot{1, 10},
+			slot:      func() *phase0.Slot { s := phase0.Slot(10); return &s }(),
+			refused:   true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			uncached, server := setupClient(t)
+			client := NewClient(http.DefaultClient, server.URL, WithWatermarkCache())
+			pubKey := phase0.BLSPubKey{0x1}
+			for _, a := range test.history {
+				check, err := client.CheckAttestation(ctx, "mainnet", pubKey, a.root, createAttestationData(a.source, a.target))
+				require.NoError(t, err)
+				require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+			}
+			for _, slot := range test.proposals {
+				check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, slot)
+				require.NoError(t, err)
+				require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+			}
+
+			// Expect the cache to refuse only what the server refuses,
+			// and these cases to be refused by both or neither.
+			var cached, served *protector.Check
+			var err error
+			if test.check != nil {
+				data := createAttestationData(test.check.source, test.check.target)
+				cached = client.watermarks.refuseAttestation("mainnet", pubKey, test.check.root, data)
+				served, err = uncached.CheckAttestation(ctx, "mainnet", pubKey, test.check.root, data)
+			} else {
+				cached = client.watermarks.refuseProposal("mainnet", pubKey, phase0.Root{0x2}, *test.slot)
+				served, err = uncached.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, *test.slot)
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.refused, cached != nil)
+			require.Equal(t, test.refused, served.Slashable, "server: %s", served.Reason)
+		})
+	}
+}
+
+func TestServer_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Sign with keys in two networks.
+	for _, network := range []string{"mainnet", "prater"} {
+		check, err := client.CheckProposal(ctx, network, phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	// Take a compressed snapshot and restore it.
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/snapshot?compress=zstd", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	restoreDir := t.TempDir()
+	manifest, err := backup.Restore(resp.Body, restoreDir)
+	require.NoError(t, err)
+	var names []string
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+	}
+	require.ElementsMatch(t, []string{
+		path.Join(kvpool.StoreName("mainnet", phase0.BLSPubKey{0x1}), kv.DbFileName),
+		path.Join(kvpool.StoreName("prater", phase0.BLSPubKey{0x1}), kv.DbFileName),
+	}, names)
+
+	// Expect the restored history to be complete.
+	restored := protector.New(restoreDir)
+	defer restored.Close()
+	history, err := restored.History(ctx, "prater", phase0.BLSPubKey{0x1})
+	require.NoError(t, err)
+	require.Len(t, history.Proposals, 1)
+}
+
+func setupClient(t testing.TB, opts ...protector.Option) (*Client, *httptest.Server) {
 	// Create a protector in a temporary directory.
 	tempDir := t.TempDir()
-	protector := protector.New(tempDir)
+	protector := protector.New(tempDir, opts...)
 
 	// Create a test server.
 	server := httptest.NewServer(NewServer(zap.NewNop(), protector))
@@ -217,6 +1880,65 @@ func setupClient(t testing.TB) (*Client, *httptest.Server) {
 	return NewClient(http.DefaultClient, server.URL), server
 }
 
+func TestClient_FindRecord(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	pubKey := phase0.BLSPubKey{0x1}
+	data := createAttestationData(1, 2)
+	data.BeaconBlockRoot = phase0.Root{0x3}
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, data)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+
+	// Expect signed records to be found.
+	attestation, err := client.FindAttestation(ctx, "mainnet", pubKey, 2)
+	require.NoError(t, err)
+	require.Equal(t, &protector.AttestationRecord{
+		Source:          1,
+		Target:          2,
+		SigningRoot:     phase0.Root{0x1},
+		BeaconBlockRoot: phase0.Root{0x3},
+	}, attestation)
+	proposal, err := client.FindProposal(ctx, "mainnet", pubKey, 10)
+	require.NoError(t, err)
+	require.Equal(t, &protector.ProposalRecord{Slot: 10, SigningRoot: phase0.Root{0x2}}, proposal)
+
+	// Expect records which weren't signed not to be found,
+	// without creating stores for unknown keys.
+	attestation, err = client.FindAttestation(ctx, "mainnet", pubKey, 3)
+	require.NoError(t, err)
+	require.Nil(t, attestation)
+	proposal, err = client.FindProposal(ctx, "mainnet", phase0.BLSPubKey{0x2}, 10)
+	require.NoError(t, err)
+	require.Nil(t, proposal)
+	pubKeys, err := prtc.(protector.ProtectorPooler).Pool().PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Equal(t, []phase0.BLSPubKey{pubKey}, pubKeys)
+
+	// Expect exactly one of target_epoch and slot.
+	for _, query := range []string{"", "?target_epoch=1&slot=1", "?slot=abc"} {
+		resp, err := http.Get(fmt.Sprintf("%s/v1/mainnet/record/%#x%s", server.URL, pubKey, query))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+// mainnetGenesisValidatorsRoot returns the genesis validators root of mainnet,
+// which domains of mainnet checks must be computed with.
+func mainnetGenesisValidatorsRoot() phase0.Root {
+	root, _ := interchange.KnownGenesisValidatorsRoot("mainnet")
+	return root
+}
+
 func createAttestationData(sourceEpoch, targetEpoch phase0.Epoch) *phase0.AttestationData {
 	return &phase0.AttestationData{
 		Source: &phase0.Checkpoint{
@@ -227,3 +1949,481 @@ func createAttestationData(sourceEpoch, targetEpoch phase0.Epoch) *phase0.Attest
 		},
 	}
 }
+
+func TestClient_Rollback(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithAdminToken("secret")))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL, WithClientAdminToken("secret"))
+
+	pubKey := phase0.BLSPubKey{0x1}
+	for _, data := range []*phase0.AttestationData{createAttestationData(1, 2), createAttestationData(2, 3)} {
+		check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, data)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect only the latest records to be rolled back, and only with a reason.
+	_, err = client.RollbackAttestation(ctx, "mainnet", pubKey, 2, "never broadcast")
+	require.True(t, requests.HasStatusErr(err, http.StatusConflict), err)
+	_, err = client.RollbackProposal(ctx, "mainnet", pubKey, 10, "")
+	require.True(t, requests.HasStatusErr(err, http.StatusBadRequest), err)
+
+	removed, err := client.RollbackAttestation(ctx, "mainnet", pubKey, 3, "never broadcast")
+	require.NoError(t, err)
+	require.Equal(t, &kv.AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x1}}, removed)
+	proposal, err := client.RollbackProposal(ctx, "mainnet", pubKey, 10, "never broadcast")
+	require.NoError(t, err)
+	require.Equal(t, &kv.Proposal{Slot: 10, SigningRoot: phase0.Root{0x1}}, proposal)
+
+	// Expect the rolled back data to be signable again, even with other signing roots.
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, createAttestationData(2, 3))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestClient_Signer(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, initBLS())
+	var sk bls.SecretKey
+	sk.SetByCSPRNG()
+	var pubKey phase0.BLSPubKey
+	copy(pubKey[:], sk.GetPublicKey().Serialize())
+
+	// Expect keys whose secret doesn't match their public key to be refused.
+	_, err := NewSigner([]*keystore.Key{{PubKey: phase0.BLSPubKey{0x1}, SecretKey: sk.Serialize()}})
+	require.ErrorContains(t, err, "doesn't match")
+	signer, err := NewSigner([]*keystore.Key{{PubKey: pubKey, SecretKey: sk.Serialize()}})
+	require.NoError(t, err)
+
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithSigner(signer)))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	domain, err := signing.ComputeDomain(signing.DomainBeaconAttester, phase0.Version{0x1}, mainnetGenesisValidatorsRoot())
+	require.NoError(t, err)
+
+	// Expect passing checks with a computed signing root to be signed.
+	var signed phase0.BLSSignature
+	data := createAttestationData(1, 2)
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{}, data, WithDomain(domain), WithSignedInto(&signed))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	signingRoot, err := signing.SigningRoot(data, domain)
+	require.NoError(t, err)
+	require.NoError(t, verifySignature(pubKey, signingRoot, signed))
+
+	// Expect slashable checks, given signing roots and other keys not to be signed.
+	signed = phase0.BLSSignature{}
+	data.BeaconBlockRoot = phase0.Root{0xff}
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{}, data, WithDomain(domain), WithSignedInto(&signed))
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	_, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(2, 3), WithSignedInto(&signed))
+	require.NoError(t, err)
+	_, err = client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{}, createAttestationData(2, 3), WithDomain(domain), WithSignedInto(&signed))
+	require.NoError(t, err)
+	require.Equal(t, phase0.BLSSignature{}, signed)
+
+	// Expect the passing checks of multi-key attestation checks to be signed.
+	var signedA, signedB phase0.BLSSignature
+	data = createAttestationData(3, 4)
+	checks, err := client.CheckAttestations(ctx, "mainnet", data, []AttestationSigner{
+		{PubKey: pubKey, SignedInto: &signedA},
+		{PubKey: phase0.BLSPubKey{0x1}, SignedInto: &signedB},
+	}, WithDomain(domain))
+	require.NoError(t, err)
+	require.False(t, checks[0].Slashable, "unexpected slashing: %s", checks[0].Reason)
+	signingRoot, err = signing.SigningRoot(data, domain)
+	require.NoError(t, err)
+	require.NoError(t, verifySignature(pubKey, signingRoot, signedA))
+	require.Equal(t, phase0.BLSSignature{}, signedB)
+	signedA = phase0.BLSSignature{}
+	data.BeaconBlockRoot = phase0.Root{0xff}
+	checks, err = client.CheckAttestations(ctx, "mainnet", data, []AttestationSigner{{PubKey: pubKey, SignedInto: &signedA}}, WithDomain(domain))
+	require.NoError(t, err)
+	require.True(t, checks[0].Slashable)
+	require.Equal(t, phase0.BLSSignature{}, signedA)
+
+	// Expect block headers to be signed.
+	proposerDomain, err := signing.ComputeDomain(signing.DomainBeaconProposer, phase0.Version{0x1}, mainnetGenesisValidatorsRoot())
+	require.NoError(t, err)
+	header := &phase0.BeaconBlockHeader{Slot: 10, ParentRoot: phase0.Root{0x1}, StateRoot: phase0.Root{0x2}, BodyRoot: phase0.Root{0x3}}
+	check, err = client.CheckBlockHeader(ctx, "mainnet", pubKey, header, WithDomain(proposerDomain), WithSignedInto(&signed))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	signingRoot, err = signing.SigningRoot(header, proposerDomain)
+	require.NoError(t, err)
+	require.NoError(t, verifySignature(pubKey, signingRoot, signed))
+
+	// Expect nothing to be signed in networks without a configured genesis
+	// validators root, whatever the domain.
+	signed = phase0.BLSSignature{}
+	_, err = client.CheckAttestation(ctx, "madeup", pubKey, phase0.Root{}, createAttestationData(1, 2), WithDomain(domain), WithSignedInto(&signed))
+	require.ErrorContains(t, err, "no known genesis validators root")
+	_, err = client.CheckBlockHeader(ctx, "madeup", pubKey, header, WithDomain(proposerDomain), WithSignedInto(&signed))
+	require.ErrorContains(t, err, "no known genesis validators root")
+	require.Equal(t, phase0.BLSSignature{}, signed)
+	srv := NewServer(zap.NewNop(), prtc, WithSigner(signer))
+	require.Nil(t, srv.signChecked(&protector.Check{}, "madeup", jsonPubKey(pubKey), signingRoot))
+	require.NotNil(t, srv.signChecked(&protector.Check{}, "mainnet", jsonPubKey(pubKey), signingRoot))
+}
+
+func TestClient_CheckBundle(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	check, err := client.CheckBundle(ctx, "mainnet", pubKey, &protector.Bundle{
+		Proposal: &protector.BundledProposal{SigningRoot: phase0.Root{0x1}, Slot: 10},
+		Attestations: []*protector.BundledAttestation{
+			{SigningRoot: phase0.Root{0x1}, Data: createAttestationData(1, 2)},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+
+	// Expect a slashable attestation to leave the whole bundle unrecorded.
+	check, err = client.CheckBundle(ctx, "mainnet", pubKey, &protector.Bundle{
+		Proposal: &protector.BundledProposal{SigningRoot: phase0.Root{0x1}, Slot: 11},
+		Attestations: []*protector.BundledAttestation{
+			{SigningRoot: phase0.Root{0x2}, Data: createAttestationData(1, 2)},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	require.False(t, check.Proposal.Slashable)
+	require.True(t, check.Attestations[0].Slashable)
+	history, err := prtc.History(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Len(t, history.Proposals, 1)
+
+	// Expect empty bundles to be refused.
+	_, err = client.CheckBundle(ctx, "mainnet", pubKey, &protector.Bundle{})
+	require.ErrorContains(t, err, "proposal or attestations are required")
+}
+
+func TestClient_Liveness(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	// Create a key which is known but was never checked.
+	checked, unchecked := phase0.BLSPubKey{0x1}, phase0.BLSPubKey{0x2}
+	check, err := client.CheckAttestation(ctx, "mainnet", checked, phase0.Root{0x1}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	conn, err := prtc.(protector.ProtectorPooler).Pool().Acquire(ctx, "mainnet", unchecked)
+	require.NoError(t, err)
+	require.NoError(t, conn.Release())
+
+	// Expect every key, with the last check of checked keys.
+	liveness, err := client.Liveness(ctx, "mainnet", 0)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), liveness.Started, time.Minute)
+	require.Len(t, liveness.LastChecks, 2)
+	require.WithinDuration(t, time.Now(), liveness.LastChecks[checked], time.Minute)
+	require.True(t, liveness.LastChecks[unchecked].IsZero())
+
+	// Expect no key to be stale yet, since the protector just started.
+	liveness, err = client.Liveness(ctx, "mainnet", time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, liveness.LastChecks)
+
+	// Expect both keys to be stale once neither was checked within staleFor.
+	time.Sleep(10 * time.Millisecond)
+	liveness, err = client.Liveness(ctx, "mainnet", time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, liveness.LastChecks, 2)
+}
+
+func TestClient_Counts(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	pubKey := phase0.BLSPubKey{0x1}
+	check, err := client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, createAttestationData(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+
+	counts, err := client.Counts(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Equal(t, 1, counts.Attestations)
+	require.Equal(t, 1, counts.Proposals)
+	require.Positive(t, counts.Size)
+
+	// Expect unknown keys to have no records, without creating their store.
+	counts, err = client.Counts(ctx, "mainnet", phase0.BLSPubKey{0x2})
+	require.NoError(t, err)
+	require.Equal(t, &kv.Counts{}, counts)
+	pubKeys, err := prtc.(protector.ProtectorPooler).Pool().PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Equal(t, []phase0.BLSPubKey{pubKey}, pubKeys)
+}
+
+func TestClient_Lease(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pool := prtc.(protector.ProtectorPooler).Pool()
+
+	pubKey := phase0.BLSPubKey{0x1}
+	expires, err := client.Lease(ctx, "mainnet", pubKey, time.Minute)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Minute), expires, 5*time.Second)
+	require.Equal(t, 1, pool.LeasedConns())
+
+	// Expect checks of the leased key to keep it's store open.
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	require.Equal(t, 1, pool.LeasedConns())
+
+	_, err = client.Lease(ctx, "mainnet", pubKey, time.Hour)
+	require.ErrorContains(t, err, "400")
+}
+
+func TestClient_DecisionEvents(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	var (
+		mu     sync.Mutex
+		events []*DecisionEvent
+	)
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithDecisionEvents(func(event *DecisionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+
+	pubKey := phase0.BLSPubKey{0x1}
+	_, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 10)
+	require.NoError(t, err)
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, events, 2)
+	require.Equal(t, "signable", events[0].Outcome())
+	require.Equal(t, "slashable", events[1].Outcome())
+	require.Equal(t, "mainnet", events[1].Network)
+	require.Equal(t, "proposal", events[1].Kind)
+	require.Equal(t, fmt.Sprintf("%#x", pubKey), events[1].PubKey)
+	require.Equal(t, check.Reason, events[1].Reason)
+}
+
+func TestClient_Hedging(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	handler := NewServer(zap.NewNop(), prtc)
+	replica := httptest.NewServer(handler)
+	defer replica.Close()
+
+	// Expect a stalled server's checks to be answered by the replica.
+	stall := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stall
+		handler.ServeHTTP(w, r)
+	}))
+	defer stalled.Close()
+	defer close(stall)
+	client := NewClient(http.DefaultClient, stalled.URL, WithHedging(replica.URL, 10*time.Millisecond))
+	start := time.Now()
+	check, err := client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	require.Less(t, time.Since(start), time.Second)
+
+	// Expect a failing server's checks to be hedged without waiting for the delay.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	client = NewClient(http.DefaultClient, down.URL, WithHedging(replica.URL, time.Hour))
+	check, err = client.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	})
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+
+	// Expect the errors of both to be returned when neither answers.
+	client = NewClient(http.DefaultClient, down.URL, WithHedging(down.URL, 0))
+	_, err = client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 10)
+	require.ErrorContains(t, err, "replica")
+}
+
+func TestClient_Shards(t *testing.T) {
+	ctx := context.Background()
+
+	// Start two shards, which are named by their URL.
+	var (
+		handlers [2]http.Handler
+		servers  [2]*httptest.Server
+		pools    = map[string]*kvpool.Pool{}
+	)
+	for i := range servers {
+		i := i
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		defer servers[i].Close()
+	}
+	ring, err := shard.New([]string{servers[0].URL, servers[1].URL})
+	require.NoError(t, err)
+	for i, server := range servers {
+		prtc := protector.New(t.TempDir())
+		defer prtc.Close()
+		pools[server.URL] = prtc.(protector.ProtectorPooler).Pool()
+		opts := []Option{WithShard(ring, server.URL)}
+		if i == 1 {
+			opts = append(opts, WithShardProxy())
+		}
+		handlers[i] = NewServer(zap.NewNop(), prtc, opts...)
+	}
+
+	// Find a key owned by each shard.
+	var pubKeys []phase0.BLSPubKey
+	for _, server := range servers {
+		for i := 0; ; i++ {
+			pubKey := phase0.BLSPubKey{byte(i), 0x1}
+			if ring.Owner(pubKey) == server.URL {
+				pubKeys = append(pubKeys, pubKey)
+				break
+			}
+		}
+	}
+
+	// Expect a routing client's checks to be recorded by their owner.
+	client := NewClient(http.DefaultClient, servers[0].URL, WithShards(ring))
+	for i, pubKey := range pubKeys {
+		check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 10)
+		require.NoError(t, err)
+		require.False(t, check.Slashable)
+		require.True(t, pools[servers[i].URL].Exists("mainnet", pubKey))
+		require.False(t, pools[servers[1-i].URL].Exists("mainnet", pubKey))
+		_, err = client.Stats(ctx, "mainnet", pubKey)
+		require.NoError(t, err)
+	}
+	checks, err := client.CheckAttestations(ctx, "mainnet", &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}, []AttestationSigner{{PubKey: pubKeys[0]}, {PubKey: pubKeys[1]}})
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+	for _, check := range checks {
+		require.NotNil(t, check)
+		require.False(t, check.Slashable)
+	}
+
+	// Expect other shards' keys to be refused by a shard without the proxy,
+	// and forwarded by a shard with it.
+	_, err = NewClient(http.DefaultClient, servers[0].URL).CheckProposal(ctx, "mainnet", pubKeys[1], phase0.Root{0x2}, 20)
+	require.ErrorContains(t, err, "is owned by shard "+servers[1].URL)
+	_, err = NewClient(http.DefaultClient, servers[0].URL).Stats(ctx, "mainnet", pubKeys[1])
+	require.ErrorContains(t, err, "421")
+	check, err := NewClient(http.DefaultClient, servers[1].URL).CheckProposal(ctx, "mainnet", pubKeys[0], phase0.Root{0x2}, 20)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	require.False(t, pools[servers[1].URL].Exists("mainnet", pubKeys[0]))
+
+	// Expect bodies too large for any check to be refused before they're read
+	// in full, both when routing and by the multi-key attestation check.
+	large := bytes.Repeat([]byte(" "), maxCheckBodySize+1)
+	for _, path := range []string{"/v1/mainnet/slashable/proposal", "/v1/mainnet/slashable/attestations"} {
+		resp, err := http.Post(servers[0].URL+path, "application/json", bytes.NewReader(large))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode, path)
+	}
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	unsharded := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer unsharded.Close()
+	resp, err := http.Post(unsharded.URL+"/v1/mainnet/slashable/attestations", "application/json", bytes.NewReader(large))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestClient_Attempts(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc))
+	defer server.Close()
+	client := NewClient(http.DefaultClient, server.URL)
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect keys without a store to have no attempts.
+	attempts, err := client.Attempts(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Empty(t, attempts)
+	require.False(t, prtc.(protector.ProtectorPooler).Pool().Exists("mainnet", pubKey))
+
+	// Expect only refused checks to be recorded as attempts.
+	check, err := client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	data := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x1}, data)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = client.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{0x2}, data)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	attempts, err = client.Attempts(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	require.Equal(t, kv.AttemptProposal, attempts[0].Kind)
+	require.Equal(t, phase0.Root{0x2}, attempts[0].SigningRoot)
+	require.Equal(t, phase0.Slot(10), attempts[0].Slot)
+	require.NotEmpty(t, attempts[0].Reason)
+	require.WithinDuration(t, time.Now(), attempts[0].Time, time.Minute)
+	require.Equal(t, kv.AttemptAttestation, attempts[1].Kind)
+	require.Equal(t, phase0.Epoch(1), attempts[1].Source)
+	require.Equal(t, phase0.Epoch(2), attempts[1].Target)
+
+	// Expect the records to only count what was signed.
+	counts, err := client.Counts(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Equal(t, 1, counts.Proposals)
+	require.Equal(t, 1, counts.Attestations)
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type countsResponse struct {
+	PubKey       jsonPubKey `json:"pub_key"`
+	Attestations int        `json:"attestations"`
+	Proposals    int        `json:"proposals"`
+	Size         int64      `json:"size"`
+}
+
+// handleCounts responds with the number of records of a key and the
+// approximate size of it's database, which are cheaper to read than it's stats.
+func (s *Server) handleCounts(w http.ResponseWriter, r *http.Request) {
+	counter, ok := s.protector.(protector.ProtectorCounter)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+
+	counts, err := counter.Counts(r.Context(), getNetwork(r.Context()), pubKey)
+	if err != nil {
+		s.logger.Error("failed to count records", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	render.JSON(w, r, &countsResponse{
+		PubKey:       jsonPubKey(pubKey),
+		Attestations: counts.Attestations,
+		Proposals:    counts.Proposals,
+		Size:         counts.Size,
+	})
+}
+
+// Counts returns the number of records of a public key
+// and the approximate size of it's database.
+func (c *Client) Counts(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Counts, error) {
+	var resp countsResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/counts/%#x", network, pubKey).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &kv.Counts{
+		Attestations: resp.Attestations,
+		Proposals:    resp.Proposals,
+		Size:         resp.Size,
+	}, nil
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"go.uber.org/zap"
+)
+
+const (
+	// recentDecisions is the number of recent check decisions kept.
+	recentDecisions = 100
+
+	// recentSlashable is the number of recent slashable attempts kept, which
+	// are kept apart so that they're not pushed out by regular checks.
+	recentSlashable = 100
+)
+
+// decision is the outcome of a check.
+type decision struct {
+	At        time.Time
+	Network   string
+	Kind      string
+	PubKey    phase0.BLSPubKey
+	Slashable bool
+	Reason    string
+	Error     string
+}
+
+// decisionLog keeps the most recent decisions and slashable attempts.
+type decisionLog struct {
+	mu        sync.Mutex
+	decisions []decision
+	slashable []decision
+	next      int
+	nextSlash int
+
+	// publish publishes every decision, or is nil. See WithDecisionEvents.
+	publish func(*DecisionEvent)
+}
+
+func newDecisionLog() *decisionLog {
+	return &decisionLog{
+		decisions: make([]decision, 0, recentDecisions),
+		slashable: make([]decision, 0, recentSlashable),
+	}
+}
+
+// add records the outcome of a check of the given kind.
+func (l *decisionLog) add(network, kind string, pubKey phase0.BLSPubKey, check *protector.Check, err error) {
+	d := decision{
+		At:      time.Now(),
+		Network: network,
+		Kind:    kind,
+		PubKey:  pubKey,
+	}
+	if err != nil {
+		d.Error = err.Error()
+	} else if check != nil {
+		d.Slashable = check.Slashable
+		d.Reason = check.Reason
+	}
+	if l.publish != nil {
+		l.publish(d.event())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next = addDecision(&l.decisions, l.next, recentDecisions, d)
+	if d.Slashable {
+		l.nextSlash = addDecision(&l.slashable, l.nextSlash, recentSlashable, d)
+	}
+}
+
+// addDecision adds d to the ring of decisions, overwriting
+// the oldest at next once it's full, and returns the next position.
+func addDecision(ring *[]decision, next, size int, d decision) int {
+	if len(*ring) < size {
+		*ring = append(*ring, d)
+		return next
+	}
+	(*ring)[next] = d
+	return (next + 1) % size
+}
+
+// recent returns the decisions and slashable attempts, most recent first.
+func (l *decisionLog) recent() (decisions, slashable []decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return newestFirst(l.decisions), newestFirst(l.slashable)
+}
+
+func newestFirst(ring []decision) []decision {
+	sorted := append([]decision(nil), ring...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].At.After(sorted[j].At)
+	})
+	return sorted
+}
+
+type dashboardNetwork struct {
+	Name string
+	Keys int
+}
+
+type dashboardData struct {
+	Now           time.Time
+	Networks      []dashboardNetwork
+	AcquiredConns int
+	DiskUsage     string
+	AsyncQueue    *int
+	CheckP99      *time.Duration
+	Decisions     []decision
+	Slashable     []decision
+}
+
+// handleDashboard renders an overview of the service for on-call engineers.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pool := pooler.Pool()
+	data := dashboardData{
+		Now:           time.Now().UTC(),
+		AcquiredConns: pool.AcquiredConns(),
+	}
+	networks, err := pool.Networks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(networks)
+	for _, network := range networks {
+		pubKeys, err := pool.PubKeys(network)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Networks = append(data.Networks, dashboardNetwork{Name: network, Keys: len(pubKeys)})
+	}
+	diskUsage, err := pool.DiskUsage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data.DiskUsage = formatBytes(diskUsage)
+	if queuer, ok := s.protector.(protector.ProtectorQueuer); ok {
+		depth, _ := queuer.AsyncQueue()
+		data.AsyncQueue = &depth
+	}
+	if s.slo != nil {
+		p99 := s.slo.p99()
+		data.CheckP99 = &p99
+	}
+	data.Decisions, data.Slashable = s.decisions.recent()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		s.logger.Error("failed to render dashboard", zap.Error(err))
+	}
+}
+
+// formatBytes formats a size in bytes with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.UTC().Format("15:04:05.000")
+	},
+	"pubKey": func(pubKey phase0.BLSPubKey) string {
+		return fmt.Sprintf("%#x", pubKey)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>slashing-protector</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; font-size: 0.9em; }
+td.key { font-family: monospace; }
+tr.slashable { background: #fdd; }
+tr.error { background: #ffd; }
+</style>
+</head>
+<body>
+<h1>slashing-protector</h1>
+<p>As of {{.Now.Format "2006-01-02 15:04:05 UTC"}}, refreshed every 10 seconds.</p>
+
+<h2>Pool</h2>
+<table>
+<tr><th>Acquired connections</th><td>{{.AcquiredConns}}</td></tr>
+<tr><th>Disk usage</th><td>{{.DiskUsage}}</td></tr>
+{{with .AsyncQueue}}<tr><th>Async queue depth</th><td>{{.}}</td></tr>{{end}}
+{{with .CheckP99}}<tr><th>Check latency (p99)</th><td>{{.}}</td></tr>{{end}}
+</table>
+
+<h2>Networks</h2>
+<table>
+<tr><th>Network</th><th>Keys</th></tr>
+{{range .Networks}}<tr><td>{{.Name}}</td><td>{{.Keys}}</td></tr>
+{{else}}<tr><td colspan="2">None</td></tr>
+{{end}}</table>
+
+<h2>Slashable attempts</h2>
+{{template "decisions" .Slashable}}
+
+<h2>Recent decisions</h2>
+{{template "decisions" .Decisions}}
+</body>
+</html>
+{{define "decisions"}}<table>
+<tr><th>Time (UTC)</th><th>Network</th><th>Kind</th><th>Public key</th><th>Decision</th></tr>
+{{range .}}<tr{{if .Slashable}} class="slashable"{{else if .Error}} class="error"{{end}}>
+<td>{{time .At}}</td><td>{{.Network}}</td><td>{{.Kind}}</td><td class="key">{{pubKey .PubKey}}</td>
+<td>{{if .Error}}error: {{.Error}}{{else if .Slashable}}slashable: {{.Reason}}{{else}}allowed{{end}}</td>
+</tr>
+{{else}}<tr><td colspan="5">None</td></tr>
+{{end}}</table>{{end}}
+`))
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector"
+	"go.uber.org/zap"
+)
+
+// drainer tracks the checks in flight, so that draining
+// can wait for them before saving the pending writes.
+type drainer struct {
+	draining bool
+	inFlight sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// begin reports whether a check may begin, and if so, tracks it until
+// done is called. Checks may not begin once draining has begun.
+func (d *drainer) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inFlight.Add(1)
+	return true
+}
+
+func (d *drainer) done() {
+	d.inFlight.Done()
+}
+
+// drain stops checks from beginning, and returns a channel
+// which is closed once the checks in flight are done.
+func (d *drainer) drain() <-chan struct{} {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+	return done
+}
+
+func (d *drainer) isDraining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// trackInFlight tracks checks for draining, and rejects them with 503 once
+// draining has begun, so that the validator client retries them against
+// another instance instead of them being lost when this one is killed.
+func (s *Server) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.drainer.begin() {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, "instance is draining", http.StatusServiceUnavailable)
+			return
+		}
+		defer s.drainer.done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleReadyz fails once draining has begun, so that
+// Kubernetes stops routing checks to the instance.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.drainer.isDraining() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// handleDrain marks the instance as not ready, waits for the checks in flight
+// and saves the pending writes, so that it can be killed without losing any
+// acknowledged attestation. It's meant to be called from a preStop hook, and
+// may be called again, such as if it timed out.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s.logger.Info("draining")
+	select {
+	case <-s.drainer.drain():
+	case <-r.Context().Done():
+		http.Error(w, "timed out waiting for checks in flight", http.StatusServiceUnavailable)
+		return
+	}
+	if flusher, ok := s.protector.(protector.ProtectorFlusher); ok {
+		if err := flusher.Flush(); err != nil {
+			s.logger.Error("failed to flush pending writes", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.logger.Info("drained", zap.Duration("took", time.Since(start)))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/signing"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+// DutyRole is the role of an SSV consensus duty, named as in SSV's spec.
+type DutyRole string
+
+const (
+	RoleAttester                  DutyRole = "ATTESTER"
+	RoleAggregator                DutyRole = "AGGREGATOR"
+	RoleProposer                  DutyRole = "PROPOSER"
+	RoleSyncCommittee             DutyRole = "SYNC_COMMITTEE"
+	RoleSyncCommitteeContribution DutyRole = "SYNC_COMMITTEE_CONTRIBUTION"
+	RoleValidatorRegistration     DutyRole = "VALIDATOR_REGISTRATION"
+	RoleVoluntaryExit             DutyRole = "VOLUNTARY_EXIT"
+)
+
+// Duty is a consensus duty to be signed, along with its signing payload.
+type Duty struct {
+	Role        DutyRole
+	Slot        phase0.Slot
+	PubKey      phase0.BLSPubKey
+	SigningRoot phase0.Root
+
+	// Attestation is the attestation data of attester duties.
+	Attestation *phase0.AttestationData
+
+	// Signature is the signature of SigningRoot, which is
+	// required if the server verifies signatures.
+	Signature *phase0.BLSSignature
+
+	// Domain is the signing domain of attester duties, which lets the
+	// server compute SigningRoot itself (in which case it may be zero.)
+	Domain *phase0.Domain
+}
+
+type checkDutyRequest struct {
+	Timestamp   int64                   `json:"timestamp"`
+	Role        DutyRole                `json:"role"`
+	Slot        phase0.Slot             `json:"slot"`
+	PubKey      jsonPubKey              `json:"pub_key"`
+	SigningRoot jsonRoot                `json:"signing_root"`
+	Attestation *phase0.AttestationData `json:"attestation,omitempty"`
+	Signature   *jsonSignature          `json:"signature,omitempty"`
+	signingParams
+}
+
+// handleCheckDuty checks any duty an SSV node signs, so that it can ask before
+// every signature the same way. Attester and proposer duties are checked
+// (and recorded) as attestations and proposals, while duties of other roles
+// can't be slashed and are always allowed.
+func (s *Server) handleCheckDuty(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var request checkDutyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		render.JSON(w, r, &checkResponse{
+			StatusCode: http.StatusBadRequest,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	resp := checkResponse{Timestamp: request.Timestamp}
+	defer func() {
+		s.logCheck("CheckDuty", resp.Check, resp.Error,
+			zap.String("role", string(request.Role)),
+			zap.Uint64("slot", uint64(request.Slot)),
+			zap.String("pub_key", hex.EncodeToString(request.PubKey[:])),
+			zap.String("signing_root", hex.EncodeToString(request.SigningRoot[:])),
+			zap.Any("result", resp.Check),
+			zap.Any("error", resp.Error),
+			zap.Duration("took", time.Since(start)),
+		)
+	}()
+
+	// Only the signing roots of attester duties are computed, since the
+	// signing payloads of other roles aren't part of the request.
+	var err error
+	if request.Role == RoleAttester && request.Attestation != nil {
+		err = s.validateAttestationData(getNetwork(r.Context()), request.Attestation)
+		if err == nil {
+			request.SigningRoot, err = s.resolveSigningRoot(r.Context(), getNetwork(r.Context()), &request.signingParams, signing.DomainBeaconAttester, request.Attestation, request.SigningRoot)
+		}
+	}
+	if err == nil {
+		err = s.checkSignature(request.PubKey, request.SigningRoot, request.Signature)
+	}
+	if err != nil {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+
+	switch request.Role {
+	case RoleAttester:
+		if request.Attestation == nil {
+			resp.StatusCode = http.StatusBadRequest
+			resp.Error = "attestation is required for attester duties"
+			break
+		}
+		if request.Attestation.Slot != request.Slot {
+			resp.StatusCode = http.StatusBadRequest
+			resp.Error = fmt.Sprintf("attestation slot %d doesn't match duty slot %d", request.Attestation.Slot, request.Slot)
+			break
+		}
+		resp.Check, err = s.protector.CheckAttestation(
+			r.Context(),
+			getNetwork(r.Context()),
+			phase0.BLSPubKey(request.PubKey),
+			phase0.Root(request.SigningRoot),
+			request.Attestation,
+		)
+		s.decisions.add(getNetwork(r.Context()), "attestation", phase0.BLSPubKey(request.PubKey), resp.Check, err)
+	case RoleProposer:
+		if request.Slot == 0 {
+			resp.StatusCode = http.StatusBadRequest
+			resp.Error = "can not propose at genesis slot"
+			break
+		}
+		resp.Check, err = s.protector.CheckProposal(
+			r.Context(),
+			getNetwork(r.Context()),
+			phase0.BLSPubKey(request.PubKey),
+			phase0.Root(request.SigningRoot),
+			request.Slot,
+		)
+		s.decisions.add(getNetwork(r.Context()), "proposal", phase0.BLSPubKey(request.PubKey), resp.Check, err)
+	case RoleAggregator,
+		RoleSyncCommittee,
+		RoleSyncCommitteeContribution,
+		RoleValidatorRegistration,
+		RoleVoluntaryExit:
+		resp.Check = &protector.Check{}
+	default:
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = fmt.Sprintf("unknown duty role %q", request.Role)
+	}
+	if err != nil {
+		s.logger.Error("failed at CheckDuty", zap.Any("duty", request), zap.Error(err))
+		resp.StatusCode = s.checkErrorStatus(err)
+		resp.Error = err.Error()
+		setCheckStatus(w, r, resp.StatusCode)
+	}
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"time"
+)
+
+// DecisionEvent is the outcome of a check, as published to WithDecisionEvents.
+type DecisionEvent struct {
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+
+	// Kind is "attestation", "proposal" or "bundle".
+	Kind   string `json:"kind"`
+	PubKey string `json:"pub_key"`
+
+	// Slashable is whether the check was refused as slashable, for which
+	// Reason is why, while Error is why the check failed, if it did.
+	Slashable bool   `json:"slashable"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Outcome returns "slashable" if the check was refused as slashable, "error"
+// if it failed, and "signable" otherwise, such as to route events by it.
+func (e *DecisionEvent) Outcome() string {
+	switch {
+	case e.Slashable:
+		return "slashable"
+	case e.Error != "":
+		return "error"
+	}
+	return "signable"
+}
+
+// WithDecisionEvents calls publish with the outcome of every check, so that
+// data pipelines and SIEMs can consume them without scraping logs. publish
+// is called as soon as the check completes, and should return quickly.
+func WithDecisionEvents(publish func(*DecisionEvent)) Option {
+	return func(s *Server) {
+		s.decisions.publish = publish
+	}
+}
+
+// event returns the event of d.
+func (d *decision) event() *DecisionEvent {
+	return &DecisionEvent{
+		Time:      d.At.UTC(),
+		Network:   d.Network,
+		Kind:      d.Kind,
+		PubKey:    fmt.Sprintf("%#x", d.PubKey),
+		Slashable: d.Slashable,
+		Reason:    d.Reason,
+		Error:     d.Error,
+	}
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Fault is what's injected into the checks of an endpoint, for testing how
+// clients handle a protector which misbehaves.
+type Fault struct {
+	// Latency is added to every check.
+	Latency time.Duration
+
+	// ErrorRate is the fraction of checks which fail with 500.
+	ErrorRate float64
+
+	// SlashableRate is the fraction of checks which are refused as slashable,
+	// without checking or recording them.
+	SlashableRate float64
+}
+
+// faultEndpoints are the endpoints of checks faults can be injected into,
+// and whether they respond with a checkResponse, which are the only ones
+// spurious slashable checks can be injected into.
+var faultEndpoints = map[string]bool{
+	"proposal":     true,
+	"block-header": true,
+	"attestation":  true,
+	"duty":         true,
+	"attestations": false,
+	"bundle":       false,
+}
+
+// ParseFault parses the fault of an endpoint from a comma-separated list
+// such as "latency:200ms,error:0.1,slashable:0.05".
+func ParseFault(endpoint, spec string) (Fault, error) {
+	var f Fault
+	slashable, ok := faultEndpoints[endpoint]
+	if !ok {
+		return f, errors.Errorf("unknown endpoint %q", endpoint)
+	}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			return f, errors.Errorf("invalid fault %q, expected key:value", field)
+		}
+		var err error
+		switch key {
+		case "latency":
+			f.Latency, err = time.ParseDuration(value)
+		case "error":
+			f.ErrorRate, err = parseRate(value)
+		case "slashable":
+			if !slashable {
+				return f, errors.Errorf("can not inject slashable checks into %s", endpoint)
+			}
+			f.SlashableRate, err = parseRate(value)
+		default:
+			return f, errors.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return f, errors.Wrapf(err, "invalid %s", key)
+		}
+	}
+	return f, nil
+}
+
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err == nil && (rate < 0 || rate > 1) {
+		err = errors.New("rate must be between 0 and 1")
+	}
+	return rate, err
+}
+
+// WithFaults injects faults into the checks of each endpoint (such as
+// "attestation" for /v1/{network}/slashable/attestation), so that validator
+// client teams can test that they fail closed against a realistic protector.
+// It must never be enabled in production.
+func WithFaults(faults map[string]Fault) Option {
+	return func(s *Server) {
+		s.faults = faults
+	}
+}
+
+// injectFaults injects the faults of the endpoint of a check, if any.
+func (s *Server) injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := path.Base(r.URL.Path)
+		fault, ok := s.faults[endpoint]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		injectError := rand.Float64() < fault.ErrorRate
+		injectSlashable := !injectError && rand.Float64() < fault.SlashableRate
+		if !injectError && !injectSlashable {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Responses carry the timestamp of their request, which clients match.
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var request struct {
+			Timestamp int64 `json:"timestamp"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.logger.Debug("injecting fault",
+			zap.String("endpoint", endpoint),
+			zap.Bool("error", injectError),
+			zap.Bool("slashable", injectSlashable),
+		)
+		resp := &checkResponse{Timestamp: request.Timestamp}
+		if injectError {
+			resp.StatusCode = http.StatusInternalServerError
+			resp.Error = "injected fault"
+			render.Status(r, resp.StatusCode)
+		} else {
+			resp.Check = &protector.Check{Slashable: true, Reason: "injected fault"}
+		}
+		render.JSON(w, r, resp)
+	})
+}
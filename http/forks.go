@@ -0,0 +1,64 @@
+package http
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/pkg/errors"
+)
+
+// defaultElectraForkEpochs are the epochs of the Electra fork in known networks.
+var defaultElectraForkEpochs = map[string]phase0.Epoch{
+	"mainnet": 364032,
+	"sepolia": 222464,
+	"holesky": 115968,
+	"hoodi":   2048,
+}
+
+// knownForkVersions are the fork versions of known networks, from phase0 to
+// Fulu, which domains given without a fork version are verified against.
+var knownForkVersions = map[string][]phase0.Version{
+	"mainnet": {
+		{0x00, 0x00, 0x00, 0x00}, {0x01, 0x00, 0x00, 0x00}, {0x02, 0x00, 0x00, 0x00}, {0x03, 0x00, 0x00, 0x00},
+		{0x04, 0x00, 0x00, 0x00}, {0x05, 0x00, 0x00, 0x00}, {0x06, 0x00, 0x00, 0x00},
+	},
+	"sepolia": {
+		{0x90, 0x00, 0x00, 0x69}, {0x90, 0x00, 0x00, 0x70}, {0x90, 0x00, 0x00, 0x71}, {0x90, 0x00, 0x00, 0x72},
+		{0x90, 0x00, 0x00, 0x73}, {0x90, 0x00, 0x00, 0x74}, {0x90, 0x00, 0x00, 0x75},
+	},
+	"holesky": {
+		{0x01, 0x01, 0x70, 0x00}, {0x02, 0x01, 0x70, 0x00}, {0x03, 0x01, 0x70, 0x00}, {0x04, 0x01, 0x70, 0x00},
+		{0x05, 0x01, 0x70, 0x00}, {0x06, 0x01, 0x70, 0x00}, {0x07, 0x01, 0x70, 0x00},
+	},
+	"hoodi": {
+		{0x10, 0x00, 0x09, 0x10}, {0x20, 0x00, 0x09, 0x10}, {0x30, 0x00, 0x09, 0x10}, {0x40, 0x00, 0x09, 0x10},
+		{0x50, 0x00, 0x09, 0x10}, {0x60, 0x00, 0x09, 0x10}, {0x70, 0x00, 0x09, 0x10},
+	},
+}
+
+// WithElectraForkEpochs sets the epochs of the Electra fork in
+// networks which aren't known, or overrides those of known ones.
+func WithElectraForkEpochs(epochs map[string]phase0.Epoch) Option {
+	return func(s *Server) {
+		for network, epoch := range epochs {
+			s.electraForkEpochs[network] = epoch
+		}
+	}
+}
+
+// validateAttestationData returns an error if the attestation data is invalid in
+// the fork of it's slot. From Electra onwards, the committee index moved out of
+// the attestation data (into the attestation's committee bits), so it must be 0.
+func (s *Server) validateAttestationData(network string, data *phase0.AttestationData) error {
+	if data.Source == nil || data.Target == nil {
+		return errors.New("attestation source and target are required")
+	}
+	if epoch, ok := s.electraForkEpochs[network]; ok &&
+		data.Slot/protector.SlotsPerEpoch >= phase0.Slot(epoch) && data.Index != 0 {
+		return errors.Errorf(
+			"attestation data index must be 0 from Electra onwards, got %d at slot %d",
+			data.Index,
+			data.Slot,
+		)
+	}
+	return nil
+}
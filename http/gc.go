@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// GCResult reports what was reclaimed by collecting garbage.
+type GCResult struct {
+	// EvictedConns is the number of pool connections which weren't
+	// in use and were evicted, and InUseConns is the number kept.
+	EvictedConns int `json:"evicted_conns"`
+	InUseConns   int `json:"in_use_conns"`
+
+	// HeapBefore and HeapAfter are the bytes of heap in use before
+	// and after collecting, and ReleasedBytes is the number of bytes
+	// returned to the operating system.
+	HeapBefore    uint64 `json:"heap_before"`
+	HeapAfter     uint64 `json:"heap_after"`
+	ReleasedBytes int64  `json:"released_bytes"`
+
+	Took time.Duration `json:"took"`
+}
+
+// handleGC evicts the idle connections of the pool, fsyncs pending writes
+// and returns freed memory to the operating system, which reclaims what
+// bulk imports or deletions leave behind without restarting.
+func (s *Server) handleGC(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	start := time.Now()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	collection, err := pooler.Pool().Collect()
+	if err != nil {
+		s.logger.Error("failed to collect pool", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	debug.FreeOSMemory()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	resp := &GCResult{
+		EvictedConns:  collection.Evicted,
+		InUseConns:    collection.InUse,
+		HeapBefore:    before.HeapInuse,
+		HeapAfter:     after.HeapInuse,
+		ReleasedBytes: int64(after.HeapReleased) - int64(before.HeapReleased),
+		Took:          time.Since(start),
+	}
+	s.logger.Info("collected garbage",
+		zap.Int("evicted_conns", resp.EvictedConns),
+		zap.Int("in_use_conns", resp.InUseConns),
+		zap.Uint64("heap_before", resp.HeapBefore),
+		zap.Uint64("heap_after", resp.HeapAfter),
+		zap.Int64("released_bytes", resp.ReleasedBytes),
+		zap.Duration("took", resp.Took),
+	)
+	render.JSON(w, r, resp)
+}
+
+// GC evicts the idle connections of the server's pool and returns
+// freed memory to the operating system, reporting what was reclaimed.
+func (c *Client) GC(ctx context.Context) (*GCResult, error) {
+	var resp GCResult
+	err := requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Path("/v1/admin/gc").
+		Bearer(c.adminToken).
+		Method(http.MethodPost).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &resp, nil
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// WithGenesisValidatorsRoots sets the genesis validators roots of networks
+// which aren't known, or overrides those of known ones. Networks without
+// one learn it from their beacon node, if there is one.
+func WithGenesisValidatorsRoots(roots map[string]phase0.Root) Option {
+	return func(s *Server) {
+		for network, root := range roots {
+			s.genesisValidatorsRoots[network] = root
+		}
+	}
+}
+
+// genesisValidatorsRoot returns the genesis validators root of a network,
+// or a zero root if it's neither configured nor learnable.
+func (s *Server) genesisValidatorsRoot(ctx context.Context, network string) (phase0.Root, error) {
+	if root, ok := s.genesisValidatorsRoots[network]; ok {
+		return root, nil
+	}
+	node, ok := s.beaconNodes[network]
+	if !ok {
+		return phase0.Root{}, nil
+	}
+	root, err := node.GenesisValidatorsRoot(ctx)
+	if err != nil {
+		return root, fmt.Errorf("%w: %v", errBeaconUnavailable, err)
+	}
+	return root, nil
+}
+
+type importResponse struct {
+	Imported int `json:"imported"`
+}
+
+// handleImport imports an interchange, unless it's of another
+// genesis validators root than the network's.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "protector doesn't support imports", http.StatusNotImplemented)
+		return
+	}
+	var request interchange.Interchange
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	network := getNetwork(r.Context())
+	root, err := s.genesisValidatorsRoot(r.Context(), network)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if root == (phase0.Root{}) {
+		s.logger.Warn("importing without a genesis validators root to enforce", zap.String("network", network))
+	}
+	// Imports which time out or are interrupted resume when they're retried.
+	defer s.imports.done(network)
+	err = interchange.Import(r.Context(), pooler.Pool(), network, root, &request,
+		interchange.WithResume(),
+		interchange.WithProgress(func(progress interchange.ImportProgress) {
+			s.imports.update(progress)
+			s.logger.Info("importing interchange",
+				zap.String("network", network),
+				zap.Int("imported", progress.Imported),
+				zap.Int("total", progress.Total),
+			)
+		}),
+	)
+	if err != nil {
+		if errors.Is(err, interchange.ErrGenesisValidatorsRootMismatch) || errors.Is(err, kvpool.ErrImporting) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, kvpool.ErrTooManyKeys) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		s.logger.Error("failed to import", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logger.Info("imported interchange",
+		zap.String("network", network),
+		zap.Int("count", len(request.Data)),
+	)
+	render.JSON(w, r, &importResponse{Imported: len(request.Data)})
+}
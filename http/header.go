@@ -0,0 +1,133 @@
+package http
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/signing"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type checkBlockHeaderRequest struct {
+	Timestamp int64          `json:"timestamp"`
+	PubKey    jsonPubKey     `json:"pub_key"`
+	Signature *jsonSignature `json:"signature,omitempty"`
+
+	// ValidatorIndex may be given instead of PubKey.
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index,omitempty"`
+
+	// Header is the block header, given either as JSON or as SSZ.
+	Header    *phase0.BeaconBlockHeader `json:"block_header,omitempty"`
+	HeaderSSZ jsonBytes                 `json:"block_header_ssz,omitempty"`
+
+	signingParams
+}
+
+// header returns the block header of the request.
+func (r *checkBlockHeaderRequest) header() (*phase0.BeaconBlockHeader, error) {
+	switch {
+	case r.Header != nil && r.HeaderSSZ != nil:
+		return nil, errors.New("only one of block_header and block_header_ssz can be given")
+	case r.Header != nil:
+		return r.Header, nil
+	case r.HeaderSSZ != nil:
+		header := &phase0.BeaconBlockHeader{}
+		if err := header.UnmarshalSSZ(r.HeaderSSZ); err != nil {
+			return nil, errors.Wrap(err, "invalid block_header_ssz")
+		}
+		return header, nil
+	}
+	return nil, errors.New("block_header or block_header_ssz is required")
+}
+
+// handleCheckBlockHeader checks a proposal by its block header, deriving the slot
+// and signing root from the header rather than trusting them from the caller.
+// The signing root of a header equals that of its block, since their
+// hash tree roots are equal.
+func (s *Server) handleCheckBlockHeader(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var request checkBlockHeaderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		render.JSON(w, r, &checkResponse{
+			StatusCode: http.StatusBadRequest,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	resp := checkResponse{Timestamp: request.Timestamp}
+	var (
+		header      *phase0.BeaconBlockHeader
+		signingRoot phase0.Root
+	)
+	defer func() {
+		s.logCheck("CheckBlockHeader", resp.Check, resp.Error,
+			zap.Any("header", header),
+			zap.String("pub_key", hex.EncodeToString(request.PubKey[:])),
+			zap.String("signing_root", hex.EncodeToString(signingRoot[:])),
+			zap.Any("result", resp.Check),
+			zap.Any("error", resp.Error),
+			zap.Duration("took", time.Since(start)),
+		)
+	}()
+
+	err := s.resolvePubKey(r.Context(), getNetwork(r.Context()), &request.PubKey, request.ValidatorIndex)
+	if err != nil {
+		resp.StatusCode = resolveStatus(err)
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+
+	err = func() error {
+		var err error
+		header, err = request.header()
+		if err != nil {
+			return err
+		}
+		if header.Slot == 0 {
+			return errors.New("can not propose at genesis slot")
+		}
+		domain, ok, err := s.signingDomain(r.Context(), getNetwork(r.Context()), &request.signingParams, signing.DomainBeaconProposer)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("domain or fork_version is required")
+		}
+		signingRoot, err = signing.SigningRoot(header, domain)
+		if err != nil {
+			return err
+		}
+		return s.checkSignature(request.PubKey, jsonRoot(signingRoot), request.Signature)
+	}()
+	if err != nil {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+
+	resp.Check, err = s.protector.CheckProposal(
+		r.Context(),
+		getNetwork(r.Context()),
+		phase0.BLSPubKey(request.PubKey),
+		signingRoot,
+		header.Slot,
+	)
+	s.decisions.add(getNetwork(r.Context()), "proposal", phase0.BLSPubKey(request.PubKey), resp.Check, err)
+	if err != nil {
+		resp.StatusCode = s.checkErrorStatus(err)
+		resp.Error = err.Error()
+		setCheckStatus(w, r, resp.StatusCode)
+	} else {
+		resp.Signature = s.signChecked(resp.Check, getNetwork(r.Context()), request.PubKey, signingRoot)
+	}
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/carlmjohnson/requests"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// hedging is where and when checks are hedged.
+type hedging struct {
+	replica string
+	delay   time.Duration
+}
+
+// WithHedging hedges checks of a single attestation, proposal or block header
+// by sending the same check to replica when the server didn't answer within
+// delay (or failed before), and taking whichever answer comes first. Such
+// checks are idempotent, since checking the same signing root again isn't
+// slashable, so both instances may answer without harm. This trims the tail
+// latency of an instance with a GC pause or a slow disk, at the cost of
+// checking twice when it's slow.
+//
+// The replica must share the protection history of the server, such as a
+// follower of the same leader (see WithLeader), or the leader itself.
+func WithHedging(replica string, delay time.Duration) ClientOption {
+	return func(c *Client) {
+		c.hedging = &hedging{replica: replica, delay: delay}
+	}
+}
+
+// fetchCheck posts a check request of pubKey to path, and returns the
+// definitive answer (with neither an error nor a timestamp mismatch) of the
+// server, or of the replica if hedging is enabled and it answers first.
+// Checks aren't hedged when they're routed to shards (see WithShards).
+func (c *Client) fetchCheck(
+	ctx context.Context,
+	pubKey phase0.BLSPubKey,
+	path string,
+	req interface{},
+	timestamp int64,
+) (*checkResponse, error) {
+	if c.hedging == nil || c.shards != nil {
+		return c.fetchCheckFrom(ctx, c.shardURL(pubKey), path, req, timestamp)
+	}
+
+	// The slower fetch is aborted once either answers.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		resp *checkResponse
+		err  error
+	}
+	results := make(chan result, 2)
+	fetch := func(baseURL string) {
+		resp, err := c.fetchCheckFrom(ctx, baseURL, path, req, timestamp)
+		if err != nil && baseURL == c.hedging.replica {
+			err = errors.Wrap(err, "replica")
+		}
+		results <- result{resp, err}
+	}
+	go fetch(c.baseURL)
+	pending, hedged := 1, false
+	hedge := func() {
+		if !hedged {
+			hedged = true
+			pending++
+			go fetch(c.hedging.replica)
+		}
+	}
+	timer := time.NewTimer(c.hedging.delay)
+	defer timer.Stop()
+	var err error
+	for {
+		select {
+		case <-timer.C:
+			hedge()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.resp, nil
+			}
+			err = multierr.Append(err, r.err)
+			if hedged && pending == 0 {
+				return nil, err
+			}
+			hedge()
+		}
+	}
+}
+
+// fetchCheckFrom posts a check request to path at baseURL.
+func (c *Client) fetchCheckFrom(ctx context.Context, baseURL, path string, req interface{}, timestamp int64) (*checkResponse, error) {
+	var resp checkResponse
+	err := requests.
+		URL(baseURL).
+		Client(c.http).
+		Path(path).
+		BodyJSON(req).
+		AddValidator(nil). // Don't check http.StatusOK
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	if resp.Error != "" {
+		return nil, errors.Wrap(errors.New(resp.Error), "error from server")
+	}
+	if resp.Timestamp != timestamp {
+		return nil, errors.New("timestamp mismatch")
+	}
+	return &resp, nil
+}
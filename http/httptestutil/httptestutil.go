@@ -0,0 +1,99 @@
+// Package httptestutil runs a real slashing-protector server for tests,
+// so that integrations can be tested end-to-end against it.
+package httptestutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sp "github.com/bloxapp/slashing-protector/http"
+	"github.com/bloxapp/slashing-protector/protector"
+	"go.uber.org/zap"
+)
+
+// ProtectorServer is a server backed by a protector in a temporary directory.
+type ProtectorServer struct {
+	// Dir is the directory of the protector's databases.
+	Dir string
+
+	Protector protector.ProtectorCloser
+	Server    *httptest.Server
+
+	// Client is a client of Server, authenticated with the
+	// admin token if one was set with WithAdminToken.
+	Client *sp.Client
+}
+
+type config struct {
+	logger           *zap.Logger
+	protectorOptions []protector.Option
+	serverOptions    []sp.Option
+	adminToken       string
+}
+
+// Option configures a ProtectorServer.
+type Option func(*config)
+
+// WithLogger sets the logger of the server, which is a no-op logger by default.
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithProtectorOptions sets the options of the protector.
+func WithProtectorOptions(opts ...protector.Option) Option {
+	return func(c *config) {
+		c.protectorOptions = append(c.protectorOptions, opts...)
+	}
+}
+
+// WithServerOptions sets the options of the server.
+func WithServerOptions(opts ...sp.Option) Option {
+	return func(c *config) {
+		c.serverOptions = append(c.serverOptions, opts...)
+	}
+}
+
+// WithAdminToken enables the admin routes of the server,
+// and authenticates the client with the token.
+func WithAdminToken(token string) Option {
+	return func(c *config) {
+		c.adminToken = token
+	}
+}
+
+// NewProtectorServer starts a server backed by a protector in a temporary
+// directory, which are closed when the test and it's subtests complete.
+func NewProtectorServer(t testing.TB, opts ...Option) *ProtectorServer {
+	t.Helper()
+	cfg := config{logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	dir := t.TempDir()
+	prtc := protector.New(dir, cfg.protectorOptions...)
+	serverOptions := cfg.serverOptions
+	var clientOptions []sp.ClientOption
+	if cfg.adminToken != "" {
+		serverOptions = append(serverOptions, sp.WithAdminToken(cfg.adminToken))
+		clientOptions = append(clientOptions, sp.WithClientAdminToken(cfg.adminToken))
+	}
+	server := httptest.NewServer(sp.NewServer(cfg.logger, prtc, serverOptions...))
+
+	t.Cleanup(func() {
+		server.Close()
+		if err := prtc.Close(); err != nil {
+			t.Errorf("failed to close protector: %v", err)
+		}
+	})
+
+	return &ProtectorServer{
+		Dir:       dir,
+		Protector: prtc,
+		Server:    server,
+		Client:    sp.NewClient(http.DefaultClient, server.URL, clientOptions...),
+	}
+}
@@ -0,0 +1,29 @@
+package httptestutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProtectorServer(t *testing.T) {
+	ctx := context.Background()
+	s := NewProtectorServer(t, WithAdminToken("secret"))
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect a double proposal to be slashable.
+	check, err := s.Client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 10)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	check, err = s.Client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x2}, 10)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect the client to be authenticated for admin routes.
+	require.NoError(t, s.Client.Deactivate(ctx, "mainnet", []phase0.BLSPubKey{pubKey}))
+	_, err = s.Client.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x3}, 11)
+	require.ErrorContains(t, err, protector.ErrInactive.Error())
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/go-chi/render"
+)
+
+// importTracker tracks the progress of the imports underway by network.
+type importTracker struct {
+	progress map[string]interchange.ImportProgress
+	mu       sync.Mutex
+}
+
+func newImportTracker() *importTracker {
+	return &importTracker{progress: make(map[string]interchange.ImportProgress)}
+}
+
+func (t *importTracker) update(progress interchange.ImportProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress[progress.Network] = progress
+}
+
+func (t *importTracker) done(network string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.progress, network)
+}
+
+// handleImports lists the progress of the imports underway.
+func (s *Server) handleImports(w http.ResponseWriter, r *http.Request) {
+	s.imports.mu.Lock()
+	progress := make([]interchange.ImportProgress, 0, len(s.imports.progress))
+	for _, p := range s.imports.progress {
+		progress = append(progress, p)
+	}
+	s.imports.mu.Unlock()
+	sort.Slice(progress, func(i, j int) bool {
+		return progress[i].Network < progress[j].Network
+	})
+	render.JSON(w, r, progress)
+}
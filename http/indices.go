@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/beacon"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+// errBeaconUnavailable is returned when a validator index can't be
+// resolved because the beacon node didn't respond as expected.
+var errBeaconUnavailable = errors.New("beacon node is unavailable")
+
+// WithBeaconNodes enables addressing validators by their index, which is
+// resolved to a public key through the beacon node of the network.
+func WithBeaconNodes(nodes map[string]*beacon.Client) Option {
+	return func(s *Server) {
+		s.beaconNodes = nodes
+	}
+}
+
+// resolveIndex returns the public key of a validator index.
+func (s *Server) resolveIndex(ctx context.Context, network string, index phase0.ValidatorIndex) (phase0.BLSPubKey, error) {
+	node, ok := s.beaconNodes[network]
+	if !ok {
+		return phase0.BLSPubKey{}, errors.Errorf("validator indices require a beacon node for network %s", network)
+	}
+	pubKey, err := node.PubKey(ctx, index)
+	if err != nil && !errors.Is(err, beacon.ErrUnknownValidator) {
+		return pubKey, fmt.Errorf("%w: %v", errBeaconUnavailable, err)
+	}
+	return pubKey, err
+}
+
+// resolvePubKey sets pubKey to the public key of index, if it's given
+// instead of pubKey.
+func (s *Server) resolvePubKey(
+	ctx context.Context,
+	network string,
+	pubKey *jsonPubKey,
+	index *phase0.ValidatorIndex,
+) error {
+	if index == nil {
+		return nil
+	}
+	if *pubKey != (jsonPubKey{}) {
+		return errors.New("only one of pub_key and validator_index can be given")
+	}
+	resolved, err := s.resolveIndex(ctx, network, *index)
+	if err != nil {
+		return err
+	}
+	*pubKey = jsonPubKey(resolved)
+	return nil
+}
+
+// pubKeyParam decodes the pub_key URL parameter, which
+// is either a hex-encoded public key or a validator index.
+func (s *Server) pubKeyParam(r *http.Request) (pubKey phase0.BLSPubKey, err error) {
+	param := chi.URLParam(r, "pub_key")
+	if len(param) <= 20 && !strings.HasPrefix(param, "0x") {
+		if index, err := strconv.ParseUint(param, 10, 64); err == nil {
+			return s.resolveIndex(r.Context(), getNetwork(r.Context()), phase0.ValidatorIndex(index))
+		}
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(param, "0x"))
+	if err != nil {
+		return pubKey, err
+	}
+	copy(pubKey[:], b)
+	return pubKey, nil
+}
+
+// resolveStatus returns the status code of a request whose
+// public key couldn't be decoded or resolved with err.
+func resolveStatus(err error) int {
+	switch {
+	case errors.Is(err, beacon.ErrUnknownValidator):
+		return http.StatusNotFound
+	case errors.Is(err, errBeaconUnavailable):
+		return http.StatusBadGateway
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// jwtMaxSkew is how far the issue time of a JWT may be from the server's
+// time, which is what the Engine API allows.
+const jwtMaxSkew = 60 * time.Second
+
+// jwtHeader is the header of the JWTs issued by clients.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// ParseJWTSecret parses a JWT secret file in the format of the Engine API,
+// which is 32 hex-encoded bytes, optionally prefixed with 0x.
+func ParseJWTSecret(data []byte) ([]byte, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(string(data)), "0x")
+	secret, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hex")
+	}
+	if len(secret) != 32 {
+		return nil, errors.Errorf("secret is %d bytes long, expected 32", len(secret))
+	}
+	return secret, nil
+}
+
+// WithJWTSecret requires the network endpoints (such as checks and
+// histories) to carry a bearer JWT signed with secret, as with the Engine
+// API: HS256 tokens whose issue time (iat) is within 60 seconds of the
+// server's time. Admin endpoints keep requiring the admin token instead.
+func WithJWTSecret(secret []byte) Option {
+	return func(s *Server) {
+		s.jwtSecret = secret
+	}
+}
+
+// authenticateJWT rejects requests without a valid JWT, if a secret is set.
+func (s *Server) authenticateJWT(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.jwtSecret == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err := verifyJWT(s.jwtSecret, token, time.Now()); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="slashing-protector"`)
+			http.Error(w, "invalid JWT: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verifyJWT verifies that token is an HS256 JWT signed with secret,
+// which was issued within jwtMaxSkew of now.
+func verifyJWT(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.Wrap(err, "malformed signature")
+	}
+	// The signature is verified first, so that the algorithm of the header
+	// can't be chosen by forgers.
+	if !hmac.Equal(signature, signJWT(secret, parts[0]+"."+parts[1])) {
+		return errors.New("invalid signature")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return errors.Wrap(err, "malformed header")
+	}
+	if header.Alg != "HS256" {
+		return errors.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	var claims struct {
+		IssuedAt *int64 `json:"iat"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return errors.Wrap(err, "malformed claims")
+	}
+	if claims.IssuedAt == nil {
+		return errors.New("missing iat")
+	}
+	if skew := now.Sub(time.Unix(*claims.IssuedAt, 0)); skew > jwtMaxSkew || skew < -jwtMaxSkew {
+		return errors.New("stale iat")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// signJWT returns the HS256 signature of the signing input of a JWT.
+func signJWT(secret []byte, input string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
+
+// issueJWT returns an HS256 JWT signed with secret, issued at now.
+func issueJWT(secret []byte, now time.Time) string {
+	claims, _ := json.Marshal(map[string]int64{"iat": now.Unix()})
+	input := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return input + "." + base64.RawURLEncoding.EncodeToString(signJWT(secret, input))
+}
+
+// jwtTransport authenticates requests with a fresh JWT,
+// unless they're already authenticated (such as with the admin token).
+type jwtTransport struct {
+	base   http.RoundTripper
+	secret []byte
+}
+
+func (t *jwtTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+issueJWT(t.secret, time.Now()))
+	return t.base.RoundTrip(r)
+}
+
+// withJWT returns a copy of client which authenticates its requests with JWTs.
+func withJWT(client *http.Client, secret []byte) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	authenticated := *client
+	authenticated.Transport = &jwtTransport{base: base, secret: secret}
+	return &authenticated
+}
+
+// WithClientJWTSecret authenticates requests with JWTs signed with secret,
+// for servers which require them (see WithJWTSecret).
+func WithClientJWTSecret(secret []byte) ClientOption {
+	return func(c *Client) {
+		c.jwtSecret = secret
+	}
+}
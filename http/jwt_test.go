@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestServer_JWT(t *testing.T) {
+	ctx := context.Background()
+	secret, err := ParseJWTSecret([]byte("0x" + strings.Repeat("ab", 32) + "\n"))
+	require.NoError(t, err)
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	server := httptest.NewServer(NewServer(zap.NewNop(), prtc, WithJWTSecret(secret), WithAdminToken("admin")))
+	defer server.Close()
+
+	// Expect checks without a valid JWT to be refused.
+	_, err = NewClient(http.DefaultClient, server.URL).CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1)
+	require.Error(t, err)
+	_, err = NewClient(http.DefaultClient, server.URL, WithClientJWTSecret(make([]byte, 32))).
+		Stats(ctx, "mainnet", phase0.BLSPubKey{0x1})
+	require.ErrorContains(t, err, "401")
+
+	// Expect checks with a valid JWT to pass, and admin
+	// requests to keep requiring the admin token instead.
+	client := NewClient(http.DefaultClient, server.URL, WithClientJWTSecret(secret), WithClientAdminToken("admin"))
+	check, err := client.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	_, err = client.GC(ctx)
+	require.NoError(t, err)
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	require.NoError(t, verifyJWT(secret, issueJWT(secret, now), now))
+	require.NoError(t, verifyJWT(secret, issueJWT(secret, now.Add(-59*time.Second)), now))
+
+	// Expect tokens issued too long ago or in the future to be refused.
+	require.ErrorContains(t, verifyJWT(secret, issueJWT(secret, now.Add(-2*time.Minute)), now), "stale iat")
+	require.ErrorContains(t, verifyJWT(secret, issueJWT(secret, now.Add(2*time.Minute)), now), "stale iat")
+
+	// Expect unsigned tokens and tokens of other algorithms to be refused.
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	claims := encode(fmt.Sprintf(`{"iat":%d}`, now.Unix()))
+	require.ErrorContains(t, verifyJWT(secret, encode(`{"alg":"none"}`)+"."+claims+".", now), "invalid signature")
+	input := encode(`{"alg":"HS512"}`) + "." + claims
+	token := input + "." + base64.RawURLEncoding.EncodeToString(signJWT(secret, input))
+	require.ErrorContains(t, verifyJWT(secret, token, now), "unsupported algorithm")
+
+	// Expect secrets of the wrong length to be refused.
+	_, err := ParseJWTSecret([]byte("abcd"))
+	require.ErrorContains(t, err, "expected 32")
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// forwardedHeader marks checks forwarded by a follower, so that
+// they aren't forwarded again if the leader is misconfigured as one.
+const forwardedHeader = "X-Slashing-Protector-Forwarded"
+
+// WithLeader makes the server a follower, which forwards checks to the
+// leader at the given URL while serving reads from it's own protector
+// (such as a read replica), so that validator clients can check with
+// any instance regardless of which one owns the data.
+func WithLeader(leader *url.URL) Option {
+	return func(s *Server) {
+		s.leader = httputil.NewSingleHostReverseProxy(leader)
+		s.leader.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			s.logger.Error("failed to forward check to the leader", zap.String("path", r.URL.Path), zap.Error(err))
+			http.Error(w, "failed to forward check to the leader", http.StatusBadGateway)
+		}
+	}
+}
+
+// forwardToLeader forwards checks to the leader, if any. Checks which
+// were already forwarded are served locally instead, which rejects them
+// on read-only protectors rather than forwarding them in a loop.
+func (s *Server) forwardToLeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.leader == nil || r.Header.Get(forwardedHeader) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Set(forwardedHeader, "1")
+		s.leader.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type leaseResponse struct {
+	PubKey  jsonPubKey `json:"pub_key"`
+	Expires time.Time  `json:"expires"`
+}
+
+// handleLease keeps the store of a key open for the duration given by "for",
+// so that the checks of a key which is about to make several of them (such
+// as a block proposer) don't wait for it to be opened every time.
+func (s *Server) handleLease(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("for"))
+	if err != nil || d <= 0 || d > kvpool.MaxLease {
+		http.Error(w, "invalid for, must be positive and at most "+kvpool.MaxLease.String(), http.StatusBadRequest)
+		return
+	}
+	expires, err := pooler.Pool().Lease(r.Context(), getNetwork(r.Context()), pubKey, d)
+	if err != nil {
+		s.logger.Error("failed to lease", zap.Error(err))
+		http.Error(w, err.Error(), s.checkErrorStatus(err))
+		return
+	}
+	render.JSON(w, r, &leaseResponse{
+		PubKey:  jsonPubKey(pubKey),
+		Expires: expires.UTC(),
+	})
+}
+
+// Lease keeps the store of a public key open for d, which is at most
+// kvpool.MaxLease, so that it's checks meanwhile are faster. Leasing a
+// leased key extends it's lease. It returns when the lease expires.
+func (c *Client) Lease(ctx context.Context, network string, pubKey phase0.BLSPubKey, d time.Duration) (time.Time, error) {
+	var resp leaseResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/lease/%#x", network, pubKey).
+		Param("for", d.String()).
+		Method(http.MethodPost).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to fetch")
+	}
+	return resp.Expires, nil
+}
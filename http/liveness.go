@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Liveness is when each public key of a network was last successfully checked.
+type Liveness struct {
+	// Started is when the protector started, before which no checks are known.
+	Started time.Time
+
+	// LastChecks is when each public key was last checked, which is zero
+	// for keys which weren't checked since the protector started.
+	LastChecks map[phase0.BLSPubKey]time.Time
+}
+
+type livenessResponse struct {
+	Started    time.Time            `json:"started"`
+	Validators []*validatorLiveness `json:"validators"`
+}
+
+type validatorLiveness struct {
+	PubKey    jsonPubKey `json:"pub_key"`
+	LastCheck *time.Time `json:"last_check"`
+}
+
+// handleLiveness responds with when every public key in the network was last
+// successfully checked, which is read from memory rather than the stores.
+// Given stale_for, it responds only with the keys which weren't checked
+// within it, so that monitoring can alert on them directly.
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.protector.(protector.ProtectorLivenessReporter)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	var staleFor time.Duration
+	if param := r.URL.Query().Get("stale_for"); param != "" {
+		var err error
+		staleFor, err = time.ParseDuration(param)
+		if err != nil || staleFor <= 0 {
+			http.Error(w, "invalid stale_for", http.StatusBadRequest)
+			return
+		}
+	}
+
+	network := getNetwork(r.Context())
+	pubKeys, err := pooler.Pool().PubKeys(network)
+	if err != nil {
+		s.logger.Error("failed to list public keys", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	started := reporter.Started()
+	lastChecks := reporter.LastChecks(network)
+	resp := livenessResponse{
+		Started:    started.UTC(),
+		Validators: []*validatorLiveness{},
+	}
+	for _, pubKey := range pubKeys {
+		lastCheck := lastChecks[pubKey]
+		if staleFor > 0 {
+			// Keys which weren't checked yet are only stale once
+			// the protector has been up for longer than staleFor.
+			since := lastCheck
+			if since.IsZero() {
+				since = started
+			}
+			if time.Since(since) < staleFor {
+				continue
+			}
+		}
+		resp.Validators = append(resp.Validators, &validatorLiveness{
+			PubKey:    jsonPubKey(pubKey),
+			LastCheck: jsonTime(lastCheck),
+		})
+	}
+	render.JSON(w, r, resp)
+}
+
+// Liveness returns when every public key in the network was last
+// successfully checked. Given a non-zero staleFor, it returns only
+// the keys which weren't checked within it.
+func (c *Client) Liveness(ctx context.Context, network string, staleFor time.Duration) (*Liveness, error) {
+	req := requests.
+		URL(c.baseURL).
+		Client(c.http).
+		Pathf("/v1/%s/liveness", network)
+	if staleFor > 0 {
+		req.Param("stale_for", staleFor.String())
+	}
+	var resp livenessResponse
+	if err := req.ToJSON(&resp).Fetch(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	liveness := &Liveness{
+		Started:    resp.Started,
+		LastChecks: make(map[phase0.BLSPubKey]time.Time, len(resp.Validators)),
+	}
+	for _, v := range resp.Validators {
+		var lastCheck time.Time
+		if v.LastCheck != nil {
+			lastCheck = *v.LastCheck
+		}
+		liveness.LastChecks[phase0.BLSPubKey(v.PubKey)] = lastCheck
+	}
+	return liveness, nil
+}
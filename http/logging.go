@@ -0,0 +1,83 @@
+package http
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// checkLogTick is the period over which the logs of checks are sampled.
+const checkLogTick = time.Second
+
+// checkLog samples the logs of passing checks, which are logged on every
+// check and would otherwise grow to megabytes per minute at scale.
+type checkLog struct {
+	sampled *zap.Logger
+	dropped int64
+
+	mu           sync.Mutex
+	summarized   int64
+	summarizedAt time.Time
+}
+
+// WithCheckLogSampling logs only the first n passing checks of each kind per
+// second, and every nth check after that, along with a summary of how many
+// were dropped. Slashable checks and errors are always logged in full.
+func WithCheckLogSampling(n int) Option {
+	return func(s *Server) {
+		s.checkLog = &checkLog{summarizedAt: time.Now()}
+		s.checkLog.sampled = s.logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, checkLogTick, n, n,
+				zapcore.SamplerHook(func(_ zapcore.Entry, decision zapcore.SamplingDecision) {
+					if decision&zapcore.LogDropped != 0 {
+						atomic.AddInt64(&s.checkLog.dropped, 1)
+					}
+				}),
+			)
+		}))
+	}
+}
+
+// droppedLogs returns the number of check logs dropped by sampling.
+func (l *checkLog) droppedLogs() int64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&l.dropped)
+}
+
+// summarize logs the number of check logs dropped since the last
+// summary, at most once per checkLogTick.
+func (l *checkLog) summarize(logger *zap.Logger) {
+	dropped := atomic.LoadInt64(&l.dropped)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if dropped == l.summarized || time.Since(l.summarizedAt) < checkLogTick {
+		return
+	}
+	logger.Info("sampled check logs",
+		zap.Int64("dropped", dropped-l.summarized),
+		zap.Duration("since", time.Since(l.summarizedAt).Truncate(time.Millisecond)),
+	)
+	l.summarized, l.summarizedAt = dropped, time.Now()
+}
+
+// logCheck logs the result of a check. Slashable checks and errors are
+// logged in full at info level, so that they're kept regardless of the log
+// level and sampling, while passing checks are logged at debug level.
+func (s *Server) logCheck(msg string, check *protector.Check, errMsg string, fields ...zap.Field) {
+	if errMsg != "" || (check != nil && check.Slashable) {
+		s.logger.Info(msg, fields...)
+		return
+	}
+	if s.checkLog == nil {
+		s.logger.Debug(msg, fields...)
+		return
+	}
+	s.checkLog.sampled.Debug(msg, fields...)
+	s.checkLog.summarize(s.logger)
+}
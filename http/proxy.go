@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies trusts the X-Forwarded-For header of requests from the
+// given ranges (such as of a load balancer), so that the real client's
+// address is logged instead of the proxy's. The header of other requests is
+// ignored, since anyone can set it.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = prefixes
+	}
+}
+
+// resolveClientAddr replaces the remote address of requests from trusted
+// proxies with the client's address in X-Forwarded-For, which is the
+// rightmost address not of a trusted proxy, since proxies append the
+// address they received the request from and earlier ones may be forged.
+func (s *Server) resolveClientAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.trustedProxies) == 0 || !s.trustedProxy(r.RemoteAddr) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+			if err != nil {
+				break
+			}
+			if !s.trusted(addr) || i == 0 {
+				r.RemoteAddr = addr.String()
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedProxy returns whether a remote address (with or without a port)
+// is of a trusted proxy.
+func (s *Server) trustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && s.trusted(addr)
+}
+
+func (s *Server) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_TrustedProxies(t *testing.T) {
+	s := &Server{trustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"untrusted proxy", "1.2.3.4:1234", []string{"5.6.7.8"}, "1.2.3.4:1234"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"5.6.7.8"}, "5.6.7.8"},
+		{"trusted proxy over IPv6", "[fd00::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"5.6.7.8, 10.0.0.2", "10.0.0.3"}, "5.6.7.8"},
+		{"forged address", "10.0.0.1:1234", []string{"6.6.6.6, 5.6.7.8"}, "5.6.7.8"},
+		{"only trusted proxies", "10.0.0.1:1234", []string{"10.0.0.2, 10.0.0.3"}, "10.0.0.2"},
+		{"malformed address", "10.0.0.1:1234", []string{"5.6.7.8, garbage"}, "10.0.0.1:1234"},
+		{"missing header", "10.0.0.1:1234", nil, "10.0.0.1:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			var got string
+			s.resolveClientAddr(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// recordResponse is whether a record exists, and the record if it does.
+type recordResponse struct {
+	Exists      bool          `json:"exists"`
+	SourceEpoch *phase0.Epoch `json:"source_epoch,omitempty"`
+	TargetEpoch *phase0.Epoch `json:"target_epoch,omitempty"`
+	Slot        *phase0.Slot  `json:"slot,omitempty"`
+	SigningRoot *jsonRoot     `json:"signing_root,omitempty"`
+
+	// The roots voted for by an attestation are omitted if they aren't known.
+	SourceRoot      *jsonRoot `json:"source_root,omitempty"`
+	TargetRoot      *jsonRoot `json:"target_root,omitempty"`
+	BeaconBlockRoot *jsonRoot `json:"beacon_block_root,omitempty"`
+}
+
+// handleRecord reports whether a key signed an attestation at the target
+// epoch given by target_epoch, or a proposal at the slot given by slot, and
+// responds with it if so.
+func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
+	finder, ok := s.protector.(protector.ProtectorFinder)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+	targetEpoch, slot := r.URL.Query().Get("target_epoch"), r.URL.Query().Get("slot")
+	if (targetEpoch == "") == (slot == "") {
+		http.Error(w, "exactly one of target_epoch and slot is required", http.StatusBadRequest)
+		return
+	}
+
+	network := getNetwork(r.Context())
+	var resp recordResponse
+	if targetEpoch != "" {
+		target, err := strconv.ParseUint(targetEpoch, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid target_epoch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		a, err := finder.FindAttestation(r.Context(), network, pubKey, phase0.Epoch(target))
+		if err != nil {
+			s.logger.Error("failed to find attestation", zap.Error(err))
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		if a != nil {
+			resp = recordResponse{
+				Exists:      true,
+				SourceEpoch: &a.Source,
+				TargetEpoch: &a.Target,
+				SigningRoot: (*jsonRoot)(&a.SigningRoot),
+			}
+			if a.SourceRoot != (phase0.Root{}) || a.TargetRoot != (phase0.Root{}) || a.BeaconBlockRoot != (phase0.Root{}) {
+				resp.SourceRoot = (*jsonRoot)(&a.SourceRoot)
+				resp.TargetRoot = (*jsonRoot)(&a.TargetRoot)
+				resp.BeaconBlockRoot = (*jsonRoot)(&a.BeaconBlockRoot)
+			}
+		}
+	} else {
+		v, err := strconv.ParseUint(slot, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid slot: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		p, err := finder.FindProposal(r.Context(), network, pubKey, phase0.Slot(v))
+		if err != nil {
+			s.logger.Error("failed to find proposal", zap.Error(err))
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		if p != nil {
+			resp = recordResponse{
+				Exists:      true,
+				Slot:        &p.Slot,
+				SigningRoot: (*jsonRoot)(&p.SigningRoot),
+			}
+		}
+	}
+	render.JSON(w, r, resp)
+}
+
+// FindAttestation returns the attestation a public key signed at the
+// given target epoch, or nil if it signed none.
+func (c *Client) FindAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	target phase0.Epoch,
+) (*protector.AttestationRecord, error) {
+	resp, err := c.record(ctx, network, pubKey, "target_epoch", uint64(target))
+	if err != nil || !resp.Exists {
+		return nil, err
+	}
+	a := &protector.AttestationRecord{
+		Source:      *resp.SourceEpoch,
+		Target:      *resp.TargetEpoch,
+		SigningRoot: phase0.Root(*resp.SigningRoot),
+	}
+	if resp.BeaconBlockRoot != nil {
+		a.SourceRoot = phase0.Root(*resp.SourceRoot)
+		a.TargetRoot = phase0.Root(*resp.TargetRoot)
+		a.BeaconBlockRoot = phase0.Root(*resp.BeaconBlockRoot)
+	}
+	return a, nil
+}
+
+// FindProposal returns the proposal a public key signed at
+// the given slot, or nil if it signed none.
+func (c *Client) FindProposal(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	slot phase0.Slot,
+) (*protector.ProposalRecord, error) {
+	resp, err := c.record(ctx, network, pubKey, "slot", uint64(slot))
+	if err != nil || !resp.Exists {
+		return nil, err
+	}
+	return &protector.ProposalRecord{Slot: *resp.Slot, SigningRoot: phase0.Root(*resp.SigningRoot)}, nil
+}
+
+func (c *Client) record(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	param string,
+	value uint64,
+) (*recordResponse, error) {
+	var resp recordResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/%s/record/%#x", network, pubKey).
+		Param(param, strconv.FormatUint(value, 10)).
+		ToJSON(&resp).
+		Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &resp, nil
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// problem is an RFC 7807 problem details response. Error repeats the
+// title for clients which read it from check responses.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error"`
+}
+
+// recoverPanics recovers from panics in handlers, logging them with their
+// stack and responding with 500, so that a panic in one handler never takes
+// down the whole server.
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// ErrAbortHandler aborts the response on purpose.
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			atomic.AddInt64(&s.panics, 1)
+			s.logger.Error("recovered from panic",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Any("panic", rec),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(&problem{
+				Type:   "about:blank",
+				Title:  http.StatusText(http.StatusInternalServerError),
+				Status: http.StatusInternalServerError,
+				Detail: "the request failed unexpectedly, see the logs of the server",
+				Error:  "internal server error",
+			})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// rollbackRequest identifies the latest record of a key to remove by either
+// it's target epoch or it's slot, and why it's known to have never been signed.
+type rollbackRequest struct {
+	TargetEpoch *phase0.Epoch `json:"target_epoch,omitempty"`
+	Slot        *phase0.Slot  `json:"slot,omitempty"`
+	Reason      string        `json:"reason"`
+}
+
+// rollbackResponse is the removed record.
+type rollbackResponse struct {
+	SourceEpoch *phase0.Epoch `json:"source_epoch,omitempty"`
+	TargetEpoch *phase0.Epoch `json:"target_epoch,omitempty"`
+	Slot        *phase0.Slot  `json:"slot,omitempty"`
+	SigningRoot jsonRoot      `json:"signing_root"`
+}
+
+// handleRollback removes the latest attestation or proposal of a key, for when
+// a check passed but the signature was provably never produced or broadcast.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	rollbacker, ok := s.protector.(protector.ProtectorRollbacker)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+	var request rollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (request.TargetEpoch == nil) == (request.Slot == nil) {
+		http.Error(w, "exactly one of target_epoch and slot is required", http.StatusBadRequest)
+		return
+	}
+	if request.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	network := getNetwork(r.Context())
+	var resp rollbackResponse
+	if request.TargetEpoch != nil {
+		var removed *kv.AttestationRecord
+		removed, err = rollbacker.RollbackAttestation(r.Context(), network, pubKey, *request.TargetEpoch)
+		if err == nil {
+			resp = rollbackResponse{
+				SourceEpoch: &removed.Source,
+				TargetEpoch: &removed.Target,
+				SigningRoot: jsonRoot(removed.SigningRoot),
+			}
+		}
+	} else {
+		var removed *kv.Proposal
+		removed, err = rollbacker.RollbackProposal(r.Context(), network, pubKey, *request.Slot)
+		if err == nil {
+			resp = rollbackResponse{Slot: &removed.Slot, SigningRoot: jsonRoot(removed.SigningRoot)}
+		}
+	}
+	switch {
+	case errors.Is(err, kv.ErrNotLatest):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, protector.ErrReadOnly):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.logger.Error("failed to roll back", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	// Rollbacks lower the protection of a key, so they're always audited.
+	s.logger.Warn("rolled back latest record",
+		zap.String("network", network),
+		zap.String("pub_key", hex.EncodeToString(pubKey[:])),
+		zap.Any("source_epoch", resp.SourceEpoch),
+		zap.Any("target_epoch", resp.TargetEpoch),
+		zap.Any("slot", resp.Slot),
+		zap.String("signing_root", hex.EncodeToString(resp.SigningRoot[:])),
+		zap.String("reason", request.Reason),
+		zap.String("remote_addr", r.RemoteAddr),
+	)
+	render.JSON(w, r, resp)
+}
+
+// RollbackAttestation removes the latest attestation of a public key, which
+// must be at the given target epoch, and returns it. It's only meant for an
+// attestation which was checked but provably never signed nor broadcast.
+func (c *Client) RollbackAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	target phase0.Epoch,
+	reason string,
+) (*kv.AttestationRecord, error) {
+	resp, err := c.rollback(ctx, network, pubKey, &rollbackRequest{TargetEpoch: &target, Reason: reason})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &kv.AttestationRecord{
+		Source:      *resp.SourceEpoch,
+		Target:      *resp.TargetEpoch,
+		SigningRoot: phase0.Root(resp.SigningRoot),
+	}, nil
+}
+
+// RollbackProposal removes the latest proposal of a public key, which must
+// be at the given slot, and returns it. It's only meant for a proposal
+// which was checked but provably never signed nor broadcast.
+func (c *Client) RollbackProposal(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	slot phase0.Slot,
+	reason string,
+) (*kv.Proposal, error) {
+	resp, err := c.rollback(ctx, network, pubKey, &rollbackRequest{Slot: &slot, Reason: reason})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch")
+	}
+	return &kv.Proposal{Slot: *resp.Slot, SigningRoot: phase0.Root(resp.SigningRoot)}, nil
+}
+
+func (c *Client) rollback(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	req *rollbackRequest,
+) (*rollbackResponse, error) {
+	var resp rollbackResponse
+	err := requests.
+		URL(c.shardURL(pubKey)).
+		Client(c.http).
+		Pathf("/v1/admin/rollback/%s/%#x", network, pubKey).
+		Bearer(c.adminToken).
+		BodyJSON(req).
+		ToJSON(&resp).
+		Fetch(ctx)
+	return &resp, err
+}
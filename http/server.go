@@ -2,18 +2,38 @@ package http
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/backup"
+	"github.com/bloxapp/slashing-protector/protector/beacon"
+	"github.com/bloxapp/slashing-protector/protector/forensics"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/bloxapp/slashing-protector/protector/replica"
+	"github.com/bloxapp/slashing-protector/protector/signing"
+	"github.com/bloxapp/slashing-protector/protector/snapshotter"
+	"github.com/bloxapp/slashing-protector/protector/verifier"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -21,37 +41,268 @@ type Server struct {
 	logger    *zap.Logger
 	protector protector.Protector
 	router    *chi.Mux
+
+	// slo is the latency objective of checks, or nil if disabled.
+	slo *latencySLO
+
+	// adminToken is the bearer token required by admin requests.
+	adminToken string
+
+	// trustedProxies are the ranges whose X-Forwarded-For header is trusted.
+	trustedProxies []netip.Prefix
+
+	// jwtSecret signs the JWTs required by the network endpoints, or is nil if they aren't required.
+	jwtSecret []byte
+
+	// verifySignatures requires checks to carry a valid signature.
+	verifySignatures bool
+
+	// electraForkEpochs are the epochs of the Electra fork by network.
+	electraForkEpochs map[string]phase0.Epoch
+
+	// beaconNodes resolve validator indices by network.
+	beaconNodes map[string]*beacon.Client
+
+	// genesisValidatorsRoots are the genesis validators roots by network,
+	// which are written into exports and enforced on imports.
+	genesisValidatorsRoots map[string]phase0.Root
+
+	// inactiveStatus is the status code of checks of inactive keys.
+	inactiveStatus int
+
+	// decisions are the recent decisions shown in the dashboard.
+	decisions *decisionLog
+
+	// forensics serves the evidence of slashable checks, or is nil if disabled.
+	forensics *forensics.Recorder
+
+	// replica reports the replication status of a standby, or is nil on a primary.
+	replica *replica.Replica
+
+	// verifier verifies the stores in the background, or is nil if disabled.
+	verifier *verifier.Verifier
+
+	// snapshotter takes scheduled backups, or is nil if disabled.
+	snapshotter *snapshotter.Snapshotter
+
+	// sharding is the shard this instance serves, or is nil if not sharded,
+	// and shardProxy is whether the requests of other shards are forwarded.
+	sharding   *sharding
+	shardProxy bool
+
+	// leader forwards checks to the leader, or is nil if not a follower.
+	leader *httputil.ReverseProxy
+
+	// imports tracks the progress of the imports underway.
+	imports *importTracker
+
+	// drainer tracks the checks in flight for draining.
+	drainer drainer
+
+	// signer signs passing checks, or is nil if there's no embedded signer.
+	signer *Signer
+
+	// batchConcurrency is the number of the checks of a multi-key
+	// attestation check which run concurrently.
+	batchConcurrency int
+
+	// checkLog samples the logs of passing checks, or is nil to log all.
+	checkLog *checkLog
+
+	// panics counts the panics recovered from in handlers.
+	panics int64
+
+	// faults are injected into the checks of each endpoint, for testing clients.
+	faults map[string]Fault
+
+	// capture captures check requests for replaying, or is nil if disabled.
+	capture *Capture
+
+	// timeouts are the time budgets of requests by kind of route.
+	timeouts Timeouts
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithLatencySLO sheds lower priority requests (such as history reads)
+// with 503 while the rolling p99 latency of checks exceeds objective,
+// which is reported by Degraded.
+func WithLatencySLO(objective time.Duration) Option {
+	return func(s *Server) {
+		s.slo = newLatencySLO(objective)
+	}
+}
+
+// WithAdminToken enables the admin endpoints, which
+// require the given bearer token. They're disabled by default.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithSignatureVerification requires check requests to carry the signature
+// produced for their signing root, and refuses them unless it's valid for
+// their public key, which catches signers that signed something other than
+// what was checked.
+func WithSignatureVerification() Option {
+	return func(s *Server) {
+		s.verifySignatures = true
+	}
 }
 
-func NewServer(logger *zap.Logger, protector protector.Protector) *Server {
+// WithInactiveStatus sets the status code of checks of inactive keys,
+// which is 410 Gone by default.
+func WithInactiveStatus(code int) Option {
+	return func(s *Server) {
+		s.inactiveStatus = code
+	}
+}
+
+// WithBatchConcurrency limits the number of the checks of a multi-key
+// attestation check which run concurrently to n, which is
+// DefaultBatchConcurrency by default.
+func WithBatchConcurrency(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.batchConcurrency = n
+		}
+	}
+}
+
+// WithForensics serves the evidence of slashable checks recorded by r
+// through the admin endpoints.
+func WithForensics(r *forensics.Recorder) Option {
+	return func(s *Server) {
+		s.forensics = r
+	}
+}
+
+// WithReplica serves the replication status of a standby
+// through the admin endpoints.
+func WithReplica(r *replica.Replica) Option {
+	return func(s *Server) {
+		s.replica = r
+	}
+}
+
+func NewServer(logger *zap.Logger, protector protector.Protector, opts ...Option) *Server {
 	s := &Server{
-		logger:    logger,
-		protector: protector,
+		logger:            logger,
+		protector:         protector,
+		inactiveStatus:    http.StatusGone,
+		batchConcurrency:  DefaultBatchConcurrency,
+		timeouts:          DefaultTimeouts,
+		decisions:         newDecisionLog(),
+		imports:           newImportTracker(),
+		electraForkEpochs: make(map[string]phase0.Epoch, len(defaultElectraForkEpochs)),
+
+		genesisValidatorsRoots: make(map[string]phase0.Root, len(interchange.GenesisValidatorsRoots)),
+	}
+	for network, epoch := range defaultElectraForkEpochs {
+		s.electraForkEpochs[network] = epoch
+	}
+	for network := range interchange.GenesisValidatorsRoots {
+		s.genesisValidatorsRoots[network], _ = interchange.KnownGenesisValidatorsRoot(network)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.sharding != nil && s.shardProxy {
+		s.sharding.proxies = s.newShardProxies()
 	}
 	s.router = chi.NewRouter()
-	s.router.Use(middleware.Timeout(60 * time.Second))
+	s.router.Use(s.resolveClientAddr)
 	s.router.Use(middleware.Logger)
+	s.router.Use(s.recoverPanics)
 	s.router.Use(render.SetContentType(render.ContentTypeJSON))
-	s.router.Mount("/debug", middleware.Profiler())
+	s.router.With(middleware.Timeout(s.timeouts.Admin)).Mount("/debug", middleware.Profiler())
 	s.router.Route("/v1", func(r chi.Router) {
 		r.Route("/{network}", func(r chi.Router) {
 			r.Use(networkCtx)
+			r.Use(s.authenticateJWT)
 			r.Route("/slashable", func(r chi.Router) {
+				r.Use(middleware.Timeout(s.timeouts.Check))
+				r.Use(s.trackInFlight)
+				r.Use(s.observeLatency)
+				if s.capture != nil {
+					r.Use(s.captureChecks)
+				}
+				if len(s.faults) > 0 {
+					r.Use(s.injectFaults)
+				}
+				r.Use(s.routeChecksToShard)
+				r.Use(s.forwardToLeader)
 				r.Post("/proposal", s.handleCheckProposal)
+				r.Post("/block-header", s.handleCheckBlockHeader)
 				r.Post("/attestation", s.handleCheckAttestation)
+				r.Post("/attestations", s.handleCheckAttestations)
+				r.Post("/duty", s.handleCheckDuty)
+				r.Post("/bundle", s.handleCheckBundle)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(s.shedWhenDegraded)
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.Timeout(s.timeouts.Read))
+					r.Use(s.routeToShard)
+					r.Get("/history/{pub_key}", s.handleHistory)
+					r.Get("/record/{pub_key}", s.handleRecord)
+					r.Get("/digest/{pub_key}", s.handleDigest)
+					r.Get("/stats/{pub_key}", s.handleStats)
+					r.Get("/counts/{pub_key}", s.handleCounts)
+					r.Get("/attempts/{pub_key}", s.handleAttempts)
+					r.Get("/validators", s.handleValidators)
+					r.Get("/last-signed", s.handleLastSigned)
+				})
+				r.With(middleware.Timeout(s.timeouts.Export)).Post("/export", s.handleExport)
+				r.With(middleware.Timeout(s.timeouts.Check), s.routeToShard).Post("/lease/{pub_key}", s.handleLease)
+			})
+			// Liveness is read from memory, and isn't shed so that
+			// monitoring keeps working while the instance is degraded.
+			r.With(middleware.Timeout(s.timeouts.Read)).Get("/liveness", s.handleLiveness)
+		})
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.authenticateAdmin)
+			adminTimeout := middleware.Timeout(s.timeouts.Admin)
+			// Draining isn't shed, since the instance is about to be killed regardless.
+			r.With(adminTimeout).Post("/drain", s.handleDrain)
+			r.Group(func(r chi.Router) {
+				r.Use(s.shedWhenDegraded)
+				r.With(networkCtx, middleware.Timeout(s.timeouts.Import)).Post("/import/{network}", s.handleImport)
+				r.Get("/snapshot", s.handleSnapshot)
+				r.Group(func(r chi.Router) {
+					r.Use(adminTimeout)
+					r.With(networkCtx, s.routeToShard).Post("/verify/{network}/{pub_key}", s.handleVerify)
+					r.With(networkCtx, s.routeToShard).Post("/rollback/{network}/{pub_key}", s.handleRollback)
+					r.With(networkCtx).Post("/delete/{network}", s.handleDelete)
+					r.With(networkCtx).Post("/deactivate/{network}", s.handleDeactivate)
+					r.With(networkCtx).Post("/activate/{network}", s.handleActivate)
+					r.Get("/imports", s.handleImports)
+					r.Get("/dashboard", s.handleDashboard)
+					r.Get("/forensics", s.handleForensics)
+					r.Get("/forensics/{name}", s.handleForensicsBundle)
+					r.Get("/replication", s.handleReplication)
+					r.Get("/verification", s.handleVerification)
+					r.Post("/gc", s.handleGC)
+				})
 			})
-			r.Get("/history/{pub_key}", s.handleHistory)
 		})
 		s.router.Get("/metrics", s.handleMetrics)
+		s.router.Get("/readyz", s.handleReadyz)
 	})
 	return s
 }
 
 type checkProposalRequest struct {
-	Timestamp   int64       `json:"timestamp"`
-	PubKey      jsonPubKey  `json:"pub_key"`
-	SigningRoot jsonRoot    `json:"signing_root"`
-	Slot        phase0.Slot `json:"block"`
+	Timestamp   int64          `json:"timestamp"`
+	PubKey      jsonPubKey     `json:"pub_key"`
+	SigningRoot jsonRoot       `json:"signing_root"`
+	Slot        phase0.Slot    `json:"block"`
+	Signature   *jsonSignature `json:"signature,omitempty"`
+
+	// ValidatorIndex may be given instead of PubKey.
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index,omitempty"`
 }
 
 func (s *Server) handleCheckProposal(w http.ResponseWriter, r *http.Request) {
@@ -68,7 +319,7 @@ func (s *Server) handleCheckProposal(w http.ResponseWriter, r *http.Request) {
 
 	resp := checkResponse{Timestamp: request.Timestamp}
 	defer func() {
-		s.logger.Debug("CheckProposal",
+		s.logCheck("CheckProposal", resp.Check, resp.Error,
 			zap.Uint64("slot", uint64(request.Slot)),
 			zap.String("pub_key", hex.EncodeToString(request.PubKey[:])),
 			zap.String("signing_root", hex.EncodeToString(request.SigningRoot[:])),
@@ -85,8 +336,20 @@ func (s *Server) handleCheckProposal(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	err := s.resolvePubKey(r.Context(), getNetwork(r.Context()), &request.PubKey, request.ValidatorIndex)
+	if err != nil {
+		resp.StatusCode = resolveStatus(err)
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+	if err := s.checkSignature(request.PubKey, request.SigningRoot, request.Signature); err != nil {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
 
-	var err error
 	resp.Check, err = s.protector.CheckProposal(
 		r.Context(),
 		getNetwork(r.Context()),
@@ -94,9 +357,11 @@ func (s *Server) handleCheckProposal(w http.ResponseWriter, r *http.Request) {
 		phase0.Root(request.SigningRoot),
 		request.Slot,
 	)
+	s.decisions.add(getNetwork(r.Context()), "proposal", phase0.BLSPubKey(request.PubKey), resp.Check, err)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
+		resp.StatusCode = s.checkErrorStatus(err)
 		resp.Error = err.Error()
+		setCheckStatus(w, r, resp.StatusCode)
 	}
 	render.JSON(w, r, resp)
 }
@@ -106,6 +371,11 @@ type checkAttestationRequest struct {
 	PubKey      jsonPubKey             `json:"pub_key"`
 	SigningRoot jsonRoot               `json:"signing_root"`
 	Data        phase0.AttestationData `json:"attestation"`
+	Signature   *jsonSignature         `json:"signature,omitempty"`
+	signingParams
+
+	// ValidatorIndex may be given instead of PubKey.
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index,omitempty"`
 }
 
 func (s *Server) handleCheckAttestation(w http.ResponseWriter, r *http.Request) {
@@ -124,7 +394,7 @@ func (s *Server) handleCheckAttestation(w http.ResponseWriter, r *http.Request)
 	// Log.
 	resp := checkResponse{Timestamp: request.Timestamp}
 	defer func() {
-		s.logger.Debug("CheckAttestation",
+		s.logCheck("CheckAttestation", resp.Check, resp.Error,
 			zap.String("pub_key", hex.EncodeToString(request.PubKey[:])),
 			zap.String("signing_root", hex.EncodeToString(request.SigningRoot[:])),
 			zap.Any("data", request.Data),
@@ -134,8 +404,28 @@ func (s *Server) handleCheckAttestation(w http.ResponseWriter, r *http.Request)
 		)
 	}()
 
+	err := s.resolvePubKey(r.Context(), getNetwork(r.Context()), &request.PubKey, request.ValidatorIndex)
+	if err != nil {
+		resp.StatusCode = resolveStatus(err)
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+	err = s.validateAttestationData(getNetwork(r.Context()), &request.Data)
+	if err == nil {
+		request.SigningRoot, err = s.resolveSigningRoot(r.Context(), getNetwork(r.Context()), &request.signingParams, signing.DomainBeaconAttester, &request.Data, request.SigningRoot)
+	}
+	if err == nil {
+		err = s.checkSignature(request.PubKey, request.SigningRoot, request.Signature)
+	}
+	if err != nil {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = err.Error()
+		render.JSON(w, r, resp)
+		return
+	}
+
 	// Check
-	var err error
 	resp.Check, err = s.protector.CheckAttestation(
 		r.Context(),
 		getNetwork(r.Context()),
@@ -143,40 +433,286 @@ func (s *Server) handleCheckAttestation(w http.ResponseWriter, r *http.Request)
 		phase0.Root(request.SigningRoot),
 		&request.Data,
 	)
+	s.decisions.add(getNetwork(r.Context()), "attestation", phase0.BLSPubKey(request.PubKey), resp.Check, err)
 	if err != nil {
 		s.logger.Error(
 			"failed at CheckAttestation",
 			zap.Any("attestation", request),
 			zap.Error(err),
 		)
-		resp.StatusCode = http.StatusInternalServerError
+		resp.StatusCode = s.checkErrorStatus(err)
 		resp.Error = err.Error()
+		setCheckStatus(w, r, resp.StatusCode)
+	} else if request.signingParams.given() {
+		resp.Signature = s.signChecked(resp.Check, getNetwork(r.Context()), request.PubKey, phase0.Root(request.SigningRoot))
+	}
+	render.JSON(w, r, resp)
+}
+
+// maxAttestationSigners is the maximum number of signers in a multi-key
+// attestation check.
+const maxAttestationSigners = 10000
+
+// maxCheckBodySize bounds the body of check requests in bytes, which fits
+// a multi-key attestation check of maxAttestationSigners with signatures.
+const maxCheckBodySize = 8 << 20
+
+// errBodyTooLarge is returned by readCheckBody for bodies over maxCheckBodySize.
+var errBodyTooLarge = errors.Errorf("request body is larger than %d bytes", maxCheckBodySize)
+
+// readCheckBody reads the body of a check request, up to maxCheckBodySize.
+func readCheckBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCheckBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxCheckBodySize {
+		return nil, errBodyTooLarge
+	}
+	return body, nil
+}
+
+// DefaultBatchConcurrency is the default number of the checks of a multi-key
+// attestation check which run concurrently (see WithBatchConcurrency).
+const DefaultBatchConcurrency = 64
+
+type attestationSigner struct {
+	PubKey      jsonPubKey     `json:"pub_key"`
+	SigningRoot jsonRoot       `json:"signing_root"`
+	Signature   *jsonSignature `json:"signature,omitempty"`
+
+	// ValidatorIndex may be given instead of PubKey.
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index,omitempty"`
+}
+
+type checkAttestationsRequest struct {
+	Timestamp int64                  `json:"timestamp"`
+	Data      phase0.AttestationData `json:"attestation"`
+	Signers   []attestationSigner    `json:"signers"`
+	signingParams
+}
+
+type checkAttestationsResult struct {
+	PubKey         jsonPubKey             `json:"pub_key"`
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index,omitempty"`
+	Check          *protector.Check       `json:"check"`
+	StatusCode     int                    `json:"status_code,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	Signature      *jsonSignature         `json:"signature,omitempty"`
+}
+
+type checkAttestationsResponse struct {
+	Timestamp  int64                      `json:"timestamp"`
+	Results    []*checkAttestationsResult `json:"results"`
+	StatusCode int                        `json:"status_code"`
+	Error      string                     `json:"error,omitempty"`
+}
+
+// handleCheckAttestations checks the same attestation data for many public keys,
+// such as validators of the same committee, concurrently. Results are in
+// the order of the signers, and each fails or succeeds on its own.
+func (s *Server) handleCheckAttestations(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var request checkAttestationsRequest
+	body, err := readCheckBody(r)
+	if err == errBodyTooLarge {
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.JSON(w, r, &checkAttestationsResponse{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Error:      err.Error(),
+		})
+		return
 	}
+	if err == nil {
+		err = json.Unmarshal(body, &request)
+	}
+	if err != nil {
+		render.JSON(w, r, &checkAttestationsResponse{
+			StatusCode: http.StatusBadRequest,
+			Error:      err.Error(),
+		})
+		return
+	}
+	if len(request.Signers) > maxAttestationSigners {
+		render.JSON(w, r, &checkAttestationsResponse{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Sprintf("too many signers, up to %d are allowed", maxAttestationSigners),
+		})
+		return
+	}
+	if err := s.validateAttestationData(getNetwork(r.Context()), &request.Data); err != nil {
+		render.JSON(w, r, &checkAttestationsResponse{
+			StatusCode: http.StatusBadRequest,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	resp := checkAttestationsResponse{
+		Timestamp: request.Timestamp,
+		Results:   make([]*checkAttestationsResult, len(request.Signers)),
+	}
+
+	// The signers are checked by a bounded number of workers, so that a
+	// large request doesn't start a goroutine for every signer.
+	network := getNetwork(r.Context())
+	workers := s.batchConcurrency
+	if workers > len(request.Signers) {
+		workers = len(request.Signers)
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				resp.Results[i] = s.checkAttestationSigner(r.Context(), network, &request, request.Signers[i])
+			}
+		}()
+	}
+	for i := range request.Signers {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	s.logger.Debug("CheckAttestations",
+		zap.Any("data", request.Data),
+		zap.Int("signers", len(request.Signers)),
+		zap.Duration("took", time.Since(start)),
+	)
 	render.JSON(w, r, resp)
 }
 
+// checkAttestationSigner checks the attestation of a multi-key attestation
+// check for one of it's signers, and signs it with the embedded signer if it
+// passed and the server computed it's signing root.
+func (s *Server) checkAttestationSigner(
+	ctx context.Context,
+	network string,
+	request *checkAttestationsRequest,
+	signer attestationSigner,
+) *checkAttestationsResult {
+	result := &checkAttestationsResult{ValidatorIndex: signer.ValidatorIndex}
+	err := s.resolvePubKey(ctx, network, &signer.PubKey, signer.ValidatorIndex)
+	result.PubKey = signer.PubKey
+	if err != nil {
+		result.StatusCode = resolveStatus(err)
+		result.Error = err.Error()
+		return result
+	}
+	signingRoot, err := s.resolveSigningRoot(ctx, network, &request.signingParams, signing.DomainBeaconAttester, &request.Data, signer.SigningRoot)
+	if err == nil {
+		err = s.checkSignature(signer.PubKey, signingRoot, signer.Signature)
+	}
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.Error = err.Error()
+		return result
+	}
+	result.Check, err = s.protector.CheckAttestation(
+		ctx,
+		network,
+		phase0.BLSPubKey(signer.PubKey),
+		phase0.Root(signingRoot),
+		&request.Data,
+	)
+	s.decisions.add(network, "attestation", phase0.BLSPubKey(signer.PubKey), result.Check, err)
+	if err != nil {
+		s.logger.Error(
+			"failed at CheckAttestation",
+			zap.String("pub_key", hex.EncodeToString(signer.PubKey[:])),
+			zap.Error(err),
+		)
+		result.StatusCode = s.checkErrorStatus(err)
+		result.Error = err.Error()
+		return result
+	}
+	if request.signingParams.given() {
+		result.Signature = s.signChecked(result.Check, network, signer.PubKey, phase0.Root(signingRoot))
+	}
+	return result
+}
+
+// retryAfter is the Retry-After header of checks rejected due to overload,
+// which is short since duties can't be retried for long.
+const retryAfter = "1"
+
+// setCheckStatus sets the status code of the response to a check which failed
+// with status if clients and load balancers are expected to act on it, which
+// are 429 (with a Retry-After) and 503. Other failures are only reported in
+// the response's body, as they always were.
+func setCheckStatus(w http.ResponseWriter, r *http.Request, status int) {
+	switch status {
+	case http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", retryAfter)
+		render.Status(r, status)
+	case http.StatusServiceUnavailable:
+		render.Status(r, status)
+	}
+}
+
+// checkErrorStatus returns the status code of a check which failed with err.
+func (s *Server) checkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, protector.ErrInactive):
+		return s.inactiveStatus
+	case errors.Is(err, protector.ErrReadOnly):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, protector.ErrOverloaded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, kvpool.ErrImporting):
+		return http.StatusConflict
+	case errors.Is(err, kvpool.ErrTooManyKeys):
+		return http.StatusForbidden
+	}
+	return errorStatus(err)
+}
+
+// errorStatus returns the status code of a request which failed with err,
+// which is 503 if it was abandoned because it's context was done, such as
+// when it timed out waiting for a key which is in use, and 404 if the key
+// has no history.
+func errorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, protector.ErrUnknownKey) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
 func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
-	// Decode the public key.
-	var pubKey phase0.BLSPubKey
-	b, err := hex.DecodeString(strings.TrimPrefix(chi.URLParam(r, "pub_key"), "0x"))
+	pubKey, err := s.pubKeyParam(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), resolveStatus(err))
 		return
 	}
-	copy(pubKey[:], b)
 
-	// Get the history.
-	history, err := s.protector.History(r.Context(), getNetwork(r.Context()), pubKey)
+	// Get the history, or only its records from since_epoch onwards.
+	var sinceEpoch phase0.Epoch
+	if v := r.URL.Query().Get("since_epoch"); v != "" {
+		epoch, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since_epoch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sinceEpoch = phase0.Epoch(epoch)
+	}
+	history, err := s.protector.HistorySince(r.Context(), getNetwork(r.Context()), pubKey, sinceEpoch)
 	if err != nil {
 		s.logger.Error("failed to get history", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
 	// Compact the proposals & attestations for a smaller JSON response.
 	type proposal struct {
-		SigningRoot string     `json:"signing_root"`
-		Slot        types.Slot `json:"slot"`
+		SigningRoot string      `json:"signing_root"`
+		Slot        phase0.Slot `json:"slot"`
 	}
 	proposals := make([]proposal, len(history.Proposals))
 	for i, p := range history.Proposals {
@@ -187,9 +723,14 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type attestation struct {
-		SigningRoot string      `json:"signing_root"`
-		Source      types.Epoch `json:"source"`
-		Target      types.Epoch `json:"target"`
+		SigningRoot string       `json:"signing_root"`
+		Source      phase0.Epoch `json:"source"`
+		Target      phase0.Epoch `json:"target"`
+
+		// The roots voted for are omitted if they aren't known.
+		SourceRoot      string `json:"source_root,omitempty"`
+		TargetRoot      string `json:"target_root,omitempty"`
+		BeaconBlockRoot string `json:"beacon_block_root,omitempty"`
 	}
 	attestations := make([]attestation, len(history.Attestations))
 	for i, a := range history.Attestations {
@@ -198,6 +739,11 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
 			Source:      a.Source,
 			Target:      a.Target,
 		}
+		if a.SourceRoot != (phase0.Root{}) || a.TargetRoot != (phase0.Root{}) || a.BeaconBlockRoot != (phase0.Root{}) {
+			attestations[i].SourceRoot = hex.EncodeToString(a.SourceRoot[:])
+			attestations[i].TargetRoot = hex.EncodeToString(a.TargetRoot[:])
+			attestations[i].BeaconBlockRoot = hex.EncodeToString(a.BeaconBlockRoot[:])
+		}
 	}
 
 	// Respond with the history.
@@ -210,17 +756,506 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+type digestResponse struct {
+	Digest       jsonRoot `json:"digest"`
+	Attestations int      `json:"attestations"`
+	Proposals    int      `json:"proposals"`
+}
+
+func (s *Server) handleDigest(w http.ResponseWriter, r *http.Request) {
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+
+	digest, err := s.protector.Digest(r.Context(), getNetwork(r.Context()), pubKey)
+	if err != nil {
+		s.logger.Error("failed to get digest", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	render.JSON(w, r, &digestResponse{
+		Digest:       jsonRoot(digest.Root),
+		Attestations: digest.Attestations,
+		Proposals:    digest.Proposals,
+	})
+}
+
+type statsResponse struct {
+	PubKey           jsonPubKey    `json:"pub_key"`
+	Attestations     int           `json:"attestations"`
+	Proposals        int           `json:"proposals"`
+	Size             int64         `json:"size"`
+	FirstTargetEpoch *phase0.Epoch `json:"first_target_epoch"`
+	LastTargetEpoch  *phase0.Epoch `json:"last_target_epoch"`
+	FirstSlot        *phase0.Slot  `json:"first_slot"`
+	LastSlot         *phase0.Slot  `json:"last_slot"`
+	LastActivity     *time.Time    `json:"last_activity"`
+	LastCheck        *time.Time    `json:"last_check"`
+}
+
+func newStatsResponse(pubKey phase0.BLSPubKey, stats *protector.Stats) *statsResponse {
+	return &statsResponse{
+		PubKey:           jsonPubKey(pubKey),
+		Attestations:     stats.Attestations,
+		Proposals:        stats.Proposals,
+		Size:             stats.Size,
+		FirstTargetEpoch: stats.FirstTargetEpoch,
+		LastTargetEpoch:  stats.LastTargetEpoch,
+		FirstSlot:        stats.FirstSlot,
+		LastSlot:         stats.LastSlot,
+		LastActivity:     jsonTime(stats.LastActivity),
+		LastCheck:        jsonTime(stats.LastCheck),
+	}
+}
+
+// stats returns the statistics of the response.
+func (r *statsResponse) stats() *protector.Stats {
+	stats := &protector.Stats{}
+	stats.Attestations = r.Attestations
+	stats.Proposals = r.Proposals
+	stats.Size = r.Size
+	stats.FirstTargetEpoch = r.FirstTargetEpoch
+	stats.LastTargetEpoch = r.LastTargetEpoch
+	stats.FirstSlot = r.FirstSlot
+	stats.LastSlot = r.LastSlot
+	if r.LastActivity != nil {
+		stats.LastActivity = *r.LastActivity
+	}
+	if r.LastCheck != nil {
+		stats.LastCheck = *r.LastCheck
+	}
+	return stats
+}
+
+// jsonTime returns t in UTC, or nil if it's zero.
+func jsonTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+
+	stats, err := s.protector.Stats(r.Context(), getNetwork(r.Context()), pubKey)
+	if err != nil {
+		s.logger.Error("failed to get stats", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	render.JSON(w, r, newStatsResponse(pubKey, stats))
+}
+
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request) {
 	pooler, ok := s.protector.(protector.ProtectorPooler)
 	if !ok {
 		http.Error(w, "not supported", http.StatusInternalServerError)
 		return
 	}
-	render.JSON(w, r, map[string]interface{}{
-		"AcquiredConns": pooler.Pool().AcquiredConns(),
+	network := getNetwork(r.Context())
+	pubKeys, err := pooler.Pool().PubKeys(network)
+	if err != nil {
+		s.logger.Error("failed to list public keys", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	validators := make([]*statsResponse, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		stats, err := s.protector.Stats(r.Context(), network, pubKey)
+		if err != nil {
+			s.logger.Error("failed to get stats", zap.Error(err))
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		validators[i] = newStatsResponse(pubKey, stats)
+	}
+	render.JSON(w, r, validators)
+}
+
+type lastSignedResponse struct {
+	PubKey      jsonPubKey    `json:"pub_key"`
+	SourceEpoch *phase0.Epoch `json:"source_epoch"`
+	TargetEpoch *phase0.Epoch `json:"target_epoch"`
+	Slot        *phase0.Slot  `json:"slot"`
+}
+
+// handleLastSigned responds with the highest signed data of
+// every public key in the network, which is cheap enough to poll.
+func (s *Server) handleLastSigned(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	network := getNetwork(r.Context())
+	pubKeys, err := pooler.Pool().PubKeys(network)
+	if err != nil {
+		s.logger.Error("failed to list public keys", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]*lastSignedResponse, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		last, err := s.protector.LastSigned(r.Context(), network, pubKey)
+		if err != nil {
+			s.logger.Error("failed to get last signed", zap.Error(err))
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		resp[i] = &lastSignedResponse{
+			PubKey:      jsonPubKey(pubKey),
+			SourceEpoch: last.SourceEpoch,
+			TargetEpoch: last.TargetEpoch,
+			Slot:        last.Slot,
+		}
+	}
+	render.JSON(w, r, resp)
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	pubKey, err := s.pubKeyParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+
+	findings, err := s.protector.Verify(r.Context(), getNetwork(r.Context()), pubKey)
+	if err != nil {
+		s.logger.Error("failed to verify", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if len(findings) > 0 {
+		s.logger.Warn("integrity check found violations",
+			zap.String("network", getNetwork(r.Context())),
+			zap.String("pub_key", hex.EncodeToString(pubKey[:])),
+			zap.Any("findings", findings),
+		)
+	}
+	if findings == nil {
+		findings = []kv.Finding{}
+	}
+	render.JSON(w, r, struct {
+		OK       bool         `json:"ok"`
+		Findings []kv.Finding `json:"findings"`
+	}{
+		OK:       len(findings) == 0,
+		Findings: findings,
+	})
+}
+
+type exportRequest struct {
+	PubKeys []jsonPubKey `json:"pub_keys"`
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	pubKeys, err := s.requestPubKeys(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	network := getNetwork(r.Context())
+	root, err := s.genesisValidatorsRoot(r.Context(), network)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	export := interchange.Export
+	if minimal, _ := strconv.ParseBool(r.URL.Query().Get("minimal")); minimal {
+		export = interchange.ExportMinimal
+	}
+	exported, err := export(r.Context(), s.protector, network, root, pubKeys)
+	if err != nil {
+		s.logger.Error("failed to export", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		render.JSON(w, r, exported)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := interchange.WriteCSV(w, exported); err != nil {
+			s.logger.Error("failed to write CSV", zap.Error(err))
+		}
+	default:
+		http.Error(w, "unsupported format "+format, http.StatusBadRequest)
+	}
+}
+
+// requestPubKeys returns the public keys in the body of an export request,
+// or all public keys in the network if there are none.
+func (s *Server) requestPubKeys(r *http.Request) ([]phase0.BLSPubKey, error) {
+	var request exportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(request.PubKeys) == 0 {
+		pooler, ok := s.protector.(protector.ProtectorPooler)
+		if !ok {
+			return nil, errors.New("pub_keys are required")
+		}
+		return pooler.Pool().PubKeys(getNetwork(r.Context()))
+	}
+	pubKeys := make([]phase0.BLSPubKey, len(request.PubKeys))
+	for i, pubKey := range request.PubKeys {
+		pubKeys[i] = phase0.BLSPubKey(pubKey)
+	}
+	return pubKeys, nil
+}
+
+type deleteRequest struct {
+	PubKeys []jsonPubKey `json:"pub_keys"`
+	Archive bool         `json:"archive"`
+}
+
+type deleteResponse struct {
+	Deleted    int    `json:"deleted"`
+	ArchiveDir string `json:"archive_dir,omitempty"`
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var request deleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pubKeys := make([]phase0.BLSPubKey, len(request.PubKeys))
+	for i, pubKey := range request.PubKeys {
+		pubKeys[i] = phase0.BLSPubKey(pubKey)
+	}
+
+	network := getNetwork(r.Context())
+	archiveDir, err := s.protector.Delete(r.Context(), network, pubKeys, request.Archive)
+	if err != nil {
+		s.logger.Error("failed to delete", zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	s.logger.Info("deleted histories",
+		zap.String("network", network),
+		zap.Int("count", len(pubKeys)),
+		zap.String("archive_dir", archiveDir),
+	)
+	render.JSON(w, r, &deleteResponse{
+		Deleted:    len(pubKeys),
+		ArchiveDir: archiveDir,
 	})
 }
 
+type activationRequest struct {
+	PubKeys []jsonPubKey `json:"pub_keys"`
+}
+
+// handleReplication reports how far a standby is behind its primary,
+// so that failover automation can tell whether it's safe to promote.
+func (s *Server) handleReplication(w http.ResponseWriter, r *http.Request) {
+	if s.replica == nil {
+		http.Error(w, "not a replica", http.StatusNotFound)
+		return
+	}
+	render.JSON(w, r, s.replica.Status())
+}
+
+// handleForensics lists the recorded forensics bundles, most recent first.
+func (s *Server) handleForensics(w http.ResponseWriter, r *http.Request) {
+	if s.forensics == nil {
+		http.Error(w, "forensics are disabled", http.StatusNotFound)
+		return
+	}
+	entries, err := s.forensics.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, entries)
+}
+
+// handleForensicsBundle serves a recorded forensics bundle.
+func (s *Server) handleForensicsBundle(w http.ResponseWriter, r *http.Request) {
+	if s.forensics == nil {
+		http.Error(w, "forensics are disabled", http.StatusNotFound)
+		return
+	}
+	f, err := s.forensics.Open(chi.URLParam(r, "name"))
+	if os.IsNotExist(err) {
+		http.Error(w, "bundle not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		s.logger.Error("failed to serve forensics bundle", zap.Error(err))
+	}
+}
+
+// handleDeactivate marks the public keys in the body as inactive, such as when
+// they're migrated away, so that any further attempt to sign with them fails.
+func (s *Server) handleDeactivate(w http.ResponseWriter, r *http.Request) {
+	s.handleActivation(w, r, false)
+}
+
+// handleActivate reverts handleDeactivate.
+func (s *Server) handleActivate(w http.ResponseWriter, r *http.Request) {
+	s.handleActivation(w, r, true)
+}
+
+func (s *Server) handleActivation(w http.ResponseWriter, r *http.Request, active bool) {
+	var request activationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pubKeys := make([]phase0.BLSPubKey, len(request.PubKeys))
+	for i, pubKey := range request.PubKeys {
+		pubKeys[i] = phase0.BLSPubKey(pubKey)
+	}
+
+	network := getNetwork(r.Context())
+	var err error
+	if active {
+		err = s.protector.Activate(r.Context(), network, pubKeys)
+	} else {
+		err = s.protector.Deactivate(r.Context(), network, pubKeys)
+	}
+	if err != nil {
+		s.logger.Error("failed to set activation", zap.Bool("active", active), zap.Error(err))
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	s.logger.Info("set activation",
+		zap.String("network", network),
+		zap.Int("count", len(pubKeys)),
+		zap.Bool("active", active),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSnapshot streams a backup archive of consistent copies of all databases,
+// laid out like the data directory, and compressed with zstd if requested.
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	networks, err := pooler.Pool().Networks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var compress bool
+	switch c := r.URL.Query().Get("compress"); c {
+	case "":
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", `attachment; filename="slashing-protector-snapshot.tar"`)
+	case "zstd":
+		compress = true
+		w.Header().Set("Content-Type", "application/zstd")
+		w.Header().Set("Content-Disposition", `attachment; filename="slashing-protector-snapshot.tar.zst"`)
+	default:
+		http.Error(w, "unsupported compression "+c, http.StatusBadRequest)
+		return
+	}
+	bw, err := backup.NewWriter(w, compress)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Snapshots may outlast the request timeout, so they're only
+	// aborted when writing to the client fails.
+	ctx := context.Background()
+	start := time.Now()
+	var count int
+	err = func() error {
+		for _, network := range networks {
+			pubKeys, err := pooler.Pool().PubKeys(network)
+			if err != nil {
+				return err
+			}
+			for _, pubKey := range pubKeys {
+				err := s.protector.Backup(ctx, network, pubKey, func(size int64) (io.Writer, error) {
+					return bw.Create(path.Join(pooler.Pool().StorePath(network, pubKey), kv.DbFileName), size)
+				})
+				if err != nil {
+					return errors.Wrapf(err, "failed to back up %#x", pubKey)
+				}
+				count++
+			}
+		}
+		return bw.Close()
+	}()
+	if err != nil {
+		// The response is already underway, so the client can only tell
+		// from the archive, which lacks its manifest.
+		s.logger.Error("failed to snapshot", zap.Error(err))
+		return
+	}
+	s.logger.Info("snapshot completed", zap.Int("stores", count), zap.Duration("took", time.Since(start)))
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	pooler, ok := s.protector.(protector.ProtectorPooler)
+	if !ok {
+		http.Error(w, "not supported", http.StatusInternalServerError)
+		return
+	}
+	metrics := map[string]interface{}{
+		"AcquiredConns":       pooler.Pool().AcquiredConns(),
+		"MemoryReservedBytes": pooler.Pool().ReservedMemory(),
+		"RejectedKeys":        pooler.Pool().RejectedKeys(),
+		"LeasedConns":         pooler.Pool().LeasedConns(),
+		"CheckLogsDropped":    s.checkLog.droppedLogs(),
+	}
+	if s.slo != nil {
+		degraded := s.Degraded()
+		_, degradations := s.slo.status()
+		metrics["CheckLatencyP99Seconds"] = s.slo.p99().Seconds()
+		metrics["Shedding"] = degraded
+		metrics["Degraded"] = degraded
+		metrics["Degradations"] = degradations
+	}
+	if queuer, ok := s.protector.(protector.ProtectorQueuer); ok {
+		depth, lag := queuer.AsyncQueue()
+		metrics["AsyncQueueDepth"] = depth
+		metrics["AsyncFlushLagSeconds"] = lag.Seconds()
+	}
+	if detector, ok := s.protector.(protector.ProtectorDuplicateDetector); ok {
+		metrics["DuplicateKeys"] = detector.DuplicateKeys()
+	}
+	if reporter, ok := s.protector.(protector.ProtectorSlowReporter); ok {
+		metrics["SlowOperations"] = reporter.SlowOperations()
+	}
+	if s.forensics != nil {
+		metrics["ForensicsDropped"] = s.forensics.Dropped()
+	}
+	if s.verifier != nil {
+		metrics["VerificationViolations"] = len(s.verifier.Status().Violations)
+	}
+	if s.snapshotter != nil {
+		status := s.snapshotter.Status()
+		metrics["LastBackupTimestamp"] = unixSeconds(status.LastBackup)
+		metrics["LastVerifiedBackupTimestamp"] = unixSeconds(status.LastVerifiedBackup)
+	}
+	metrics["Panics"] = atomic.LoadInt64(&s.panics)
+	metrics["Draining"] = s.drainer.isDraining()
+	render.JSON(w, r, metrics)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
@@ -237,6 +1272,27 @@ func networkCtx(next http.Handler) http.Handler {
 	})
 }
 
+// authenticateAdmin rejects requests without the admin token.
+func (s *Server) authenticateAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		// Browsers (such as of the dashboard) may give the token as a basic auth password.
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if _, password, ok := r.BasicAuth(); ok {
+			token = password
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="slashing-protector"`)
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func getNetwork(ctx context.Context) string {
 	return ctx.Value("network").(string)
 }
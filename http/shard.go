@@ -0,0 +1,205 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/shard"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+// shardForwardedHeader marks requests forwarded by another shard, so that
+// they aren't forwarded again if the shards disagree on their ring.
+const shardForwardedHeader = "X-Slashing-Protector-Shard-Forwarded"
+
+// sharding is the ring of a sharded deployment and this instance's shard in it.
+type sharding struct {
+	ring *shard.Ring
+	self string
+
+	// proxies forward requests to the other shards, or are nil
+	// if requests for their keys are refused instead.
+	proxies map[string]*httputil.ReverseProxy
+}
+
+// WithShard makes the server the shard self of a sharded deployment, which
+// only serves the keys it owns in ring, and refuses the requests of other
+// keys with 421 Misdirected Request, so that no key's history is ever split
+// across shards. Requests which don't name any key, such as exports and
+// imports of every key, are served from this shard's keys alone.
+func WithShard(ring *shard.Ring, self string) Option {
+	return func(s *Server) {
+		s.sharding = &sharding{ring: ring, self: self}
+	}
+}
+
+// WithShardProxy forwards the requests of keys which other shards own to
+// their owner instead of refusing them (requires WithShard), so that clients
+// which aren't aware of the shards can send requests to any of them.
+func WithShardProxy() Option {
+	return func(s *Server) {
+		s.shardProxy = true
+	}
+}
+
+// newShardProxies returns proxies to the shards other than this one.
+func (s *Server) newShardProxies() map[string]*httputil.ReverseProxy {
+	proxies := make(map[string]*httputil.ReverseProxy)
+	for _, shard := range s.sharding.ring.Shards() {
+		target, err := url.Parse(shard)
+		if err != nil || shard == s.sharding.self {
+			continue
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			s.logger.Error("failed to forward request to shard", zap.String("shard", shard), zap.String("path", r.URL.Path), zap.Error(err))
+			http.Error(w, "failed to forward request to shard", http.StatusBadGateway)
+		}
+		proxies[shard] = proxy
+	}
+	return proxies
+}
+
+type misdirectedResponse struct {
+	Error      string `json:"error"`
+	StatusCode int    `json:"status_code"`
+	Shard      string `json:"shard"`
+}
+
+// routeToShard serves requests for the key in the pub_key URL parameter
+// only if this shard owns it.
+func (s *Server) routeToShard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.sharding == nil || chi.URLParam(r, "pub_key") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pubKey, err := s.pubKeyParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), resolveStatus(err))
+			return
+		}
+		s.serveOwned(w, r, next, []phase0.BLSPubKey{pubKey})
+	})
+}
+
+// shardedRequest holds the keys of any check request.
+type shardedRequest struct {
+	PubKey         jsonPubKey             `json:"pub_key"`
+	ValidatorIndex *phase0.ValidatorIndex `json:"validator_index"`
+	Signers        []struct {
+		PubKey         jsonPubKey             `json:"pub_key"`
+		ValidatorIndex *phase0.ValidatorIndex `json:"validator_index"`
+	} `json:"signers"`
+}
+
+// routeChecksToShard serves check requests only if this shard owns
+// all of their keys. Bodies over maxCheckBodySize are refused, since
+// they're read in full to tell their keys.
+func (s *Server) routeChecksToShard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.sharding == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := readCheckBody(r)
+		if err == errBodyTooLarge {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req shardedRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			// Malformed requests are refused by their handler.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Keys given by their validator index are resolved to tell their
+		// owner, and are resolved again by the handler if this shard owns them.
+		network := getNetwork(r.Context())
+		var pubKeys []phase0.BLSPubKey
+		resolve := func(pubKey jsonPubKey, index *phase0.ValidatorIndex) error {
+			if err := s.resolvePubKey(r.Context(), network, &pubKey, index); err != nil {
+				return err
+			}
+			if pubKey != (jsonPubKey{}) {
+				pubKeys = append(pubKeys, phase0.BLSPubKey(pubKey))
+			}
+			return nil
+		}
+		if err := resolve(req.PubKey, req.ValidatorIndex); err != nil {
+			http.Error(w, err.Error(), resolveStatus(err))
+			return
+		}
+		for _, signer := range req.Signers {
+			if err := resolve(signer.PubKey, signer.ValidatorIndex); err != nil {
+				http.Error(w, err.Error(), resolveStatus(err))
+				return
+			}
+		}
+		s.serveOwned(w, r, next, pubKeys)
+	})
+}
+
+// serveOwned serves a request if this shard owns all of its keys, or
+// otherwise forwards it to their owner if they're all owned by the same
+// shard and forwarding is enabled, or refuses it.
+func (s *Server) serveOwned(w http.ResponseWriter, r *http.Request, next http.Handler, pubKeys []phase0.BLSPubKey) {
+	var foreign *phase0.BLSPubKey
+	owners := map[string]bool{}
+	for i, pubKey := range pubKeys {
+		owner := s.sharding.ring.Owner(pubKey)
+		owners[owner] = true
+		if owner != s.sharding.self && foreign == nil {
+			foreign = &pubKeys[i]
+		}
+	}
+	if foreign == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+	owner := s.sharding.ring.Owner(*foreign)
+	if proxy, ok := s.sharding.proxies[owner]; ok && len(owners) == 1 && r.Header.Get(shardForwardedHeader) == "" {
+		r.Header.Set(shardForwardedHeader, "1")
+		proxy.ServeHTTP(w, r)
+		return
+	}
+	render.Status(r, http.StatusMisdirectedRequest)
+	render.JSON(w, r, &misdirectedResponse{
+		Error:      fmt.Sprintf("public key %#x is owned by shard %s", *foreign, owner),
+		StatusCode: http.StatusMisdirectedRequest,
+		Shard:      owner,
+	})
+}
+
+// WithShards routes the requests of every key to the shard which owns it in
+// ring (see WithShard), where shards are named by their URL, and splits
+// CheckAttestations by shard. Requests which don't name a key are sent to
+// the client's address.
+func WithShards(ring *shard.Ring) ClientOption {
+	return func(c *Client) {
+		c.shards = ring
+	}
+}
+
+// shardURL returns the URL of the shard which owns a key,
+// or the client's address if it's not sharded.
+func (c *Client) shardURL(pubKey phase0.BLSPubKey) string {
+	if c.shards == nil {
+		return c.baseURL
+	}
+	return c.shards.Owner(pubKey)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidSignature is returned when a signature doesn't match
+// the public key and signing root it's checked with.
+var ErrInvalidSignature = errors.New("signature doesn't match the public key and signing root")
+
+var (
+	blsInitOnce sync.Once
+	blsInitErr  error
+)
+
+// initBLS initializes the BLS library for Ethereum's signature scheme.
+func initBLS() error {
+	blsInitOnce.Do(func() {
+		if err := bls.Init(bls.BLS12_381); err != nil {
+			blsInitErr = errors.Wrap(err, "bls.Init")
+			return
+		}
+		if err := bls.SetETHmode(bls.EthModeDraft07); err != nil {
+			blsInitErr = errors.Wrap(err, "bls.SetETHmode")
+			return
+		}
+		bls.VerifyPublicKeyOrder(true)
+		bls.VerifySignatureOrder(true)
+	})
+	return blsInitErr
+}
+
+// checkSignature verifies the signature of a check request if signature
+// verification is enabled, in which case the signature is required.
+func (s *Server) checkSignature(pubKey jsonPubKey, signingRoot jsonRoot, signature *jsonSignature) error {
+	if !s.verifySignatures {
+		return nil
+	}
+	if signature == nil {
+		return errors.New("signature is required")
+	}
+	err := verifySignature(phase0.BLSPubKey(pubKey), phase0.Root(signingRoot), phase0.BLSSignature(*signature))
+	if err != nil {
+		s.logger.Warn("refused a check with an invalid signature",
+			zap.String("pub_key", hex.EncodeToString(pubKey[:])),
+			zap.String("signing_root", hex.EncodeToString(signingRoot[:])),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+// verifySignature returns ErrInvalidSignature unless signature is
+// the signature of signingRoot by pubKey.
+func verifySignature(pubKey phase0.BLSPubKey, signingRoot phase0.Root, signature phase0.BLSSignature) error {
+	if err := initBLS(); err != nil {
+		return err
+	}
+	var pk bls.PublicKey
+	if err := pk.Deserialize(pubKey[:]); err != nil {
+		return errors.Wrap(ErrInvalidSignature, "invalid public key")
+	}
+	var sig bls.Sign
+	if err := sig.Deserialize(signature[:]); err != nil {
+		return errors.Wrap(ErrInvalidSignature, "invalid signature")
+	}
+	if !sig.VerifyByte(&pk, signingRoot[:]) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
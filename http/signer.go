@@ -0,0 +1,82 @@
+package http
+
+import (
+	"bytes"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/keystore"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+)
+
+// Signer holds the secret keys of the embedded signer.
+type Signer struct {
+	keys map[phase0.BLSPubKey]*bls.SecretKey
+}
+
+// NewSigner returns a Signer of the given keys, failing
+// if any secret key doesn't match it's public key.
+func NewSigner(keys []*keystore.Key) (*Signer, error) {
+	if err := initBLS(); err != nil {
+		return nil, err
+	}
+	s := &Signer{keys: make(map[phase0.BLSPubKey]*bls.SecretKey, len(keys))}
+	for _, key := range keys {
+		var sk bls.SecretKey
+		if err := sk.Deserialize(key.SecretKey); err != nil {
+			return nil, errors.Wrapf(err, "invalid secret key of %#x", key.PubKey)
+		}
+		if !bytes.Equal(sk.GetPublicKey().Serialize(), key.PubKey[:]) {
+			return nil, errors.Errorf("secret key doesn't match public key %#x", key.PubKey)
+		}
+		s.keys[key.PubKey] = &sk
+	}
+	return s, nil
+}
+
+// Len returns the number of keys held.
+func (s *Signer) Len() int {
+	return len(s.keys)
+}
+
+// sign returns the signature of signingRoot by pubKey, or nil if it isn't held.
+func (s *Signer) sign(pubKey phase0.BLSPubKey, signingRoot phase0.Root) *jsonSignature {
+	sk, ok := s.keys[pubKey]
+	if !ok {
+		return nil
+	}
+	var signature jsonSignature
+	copy(signature[:], sk.SignByte(signingRoot[:]).Serialize())
+	return &signature
+}
+
+// WithSigner signs the signing roots of passing checks of the keys held by
+// signer, and returns the signatures in their responses, so that nothing
+// can sign between the check and the signing.
+//
+// Only the signing roots computed by the server (of attestations with a
+// domain or fork data, and of block headers) are signed, since signing a
+// given one could sign anything at all. Their domains are of the network's
+// genesis validators root, and nothing is signed in networks whose genesis
+// validators root isn't configured (or known), so that the signature is only
+// valid on the chain whose history was checked.
+func WithSigner(signer *Signer) Option {
+	return func(s *Server) {
+		s.signer = signer
+	}
+}
+
+// signChecked returns the signature of the signing root of a check in network
+// by the embedded signer if the check passed, the key is held and the network's
+// genesis validators root is configured, or nil otherwise. The signing root must
+// have been computed with the network's domain.
+func (s *Server) signChecked(check *protector.Check, network string, pubKey jsonPubKey, signingRoot phase0.Root) *jsonSignature {
+	if s.signer == nil || check == nil || check.Slashable {
+		return nil
+	}
+	if root, ok := s.genesisValidatorsRoots[network]; !ok || root == (phase0.Root{}) {
+		return nil
+	}
+	return s.signer.sign(phase0.BLSPubKey(pubKey), signingRoot)
+}
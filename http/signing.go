@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/signing"
+	"github.com/pkg/errors"
+)
+
+// signingParams are the optional parameters of a check request which let the
+// server compute the signing root itself, given either the domain, or the fork
+// version to compute the domain from with the network's genesis validators root.
+// The genesis validators root may be given too, but must be the network's.
+type signingParams struct {
+	Domain                *jsonRoot    `json:"domain,omitempty"`
+	ForkVersion           *jsonVersion `json:"fork_version,omitempty"`
+	GenesisValidatorsRoot *jsonRoot    `json:"genesis_validators_root,omitempty"`
+}
+
+// given returns whether there are signing parameters.
+func (p *signingParams) given() bool {
+	return p.Domain != nil || p.ForkVersion != nil || p.GenesisValidatorsRoot != nil
+}
+
+// signingChain is the chain of a network, which the signing parameters of
+// it's checks must be of so that what's signed is what's checked.
+type signingChain struct {
+	// genesisValidatorsRoot is zero if the network's isn't known.
+	genesisValidatorsRoot phase0.Root
+
+	// forkVersions are the known fork versions of the network.
+	forkVersions []phase0.Version
+}
+
+// signingChain returns the chain of a network.
+func (s *Server) signingChain(ctx context.Context, network string) (*signingChain, error) {
+	root, err := s.genesisValidatorsRoot(ctx, network)
+	if err != nil {
+		return nil, err
+	}
+	return &signingChain{genesisValidatorsRoot: root, forkVersions: knownForkVersions[network]}, nil
+}
+
+// domain returns the domain of the given type, or false if there are no signing
+// parameters. The domain must be of the chain: a genesis_validators_root must be
+// it's, and a domain given without a fork_version must be of one of it's known
+// forks, since it can't be verified otherwise.
+func (p *signingParams) domain(domainType phase0.DomainType, chain *signingChain) (phase0.Domain, bool, error) {
+	if !p.given() {
+		return phase0.Domain{}, false, nil
+	}
+	root := chain.genesisValidatorsRoot
+	if root == (phase0.Root{}) {
+		return phase0.Domain{}, false, errors.New("network has no known genesis validators root to compute the domain of")
+	}
+	if p.GenesisValidatorsRoot != nil && phase0.Root(*p.GenesisValidatorsRoot) != root {
+		return phase0.Domain{}, false, errors.Errorf("genesis_validators_root %#x isn't the network's %#x", p.GenesisValidatorsRoot[:], root[:])
+	}
+	if p.Domain != nil && !bytes.Equal(p.Domain[:4], domainType[:]) {
+		return phase0.Domain{}, false, errors.Errorf("domain type %#x doesn't match the expected %#x", p.Domain[:4], domainType[:])
+	}
+
+	switch {
+	case p.ForkVersion != nil:
+		domain, err := signing.ComputeDomain(domainType, phase0.Version(*p.ForkVersion), root)
+		if err != nil {
+			return phase0.Domain{}, false, err
+		}
+		if p.Domain != nil && phase0.Domain(*p.Domain) != domain {
+			return phase0.Domain{}, false, errors.Errorf("domain %#x isn't of fork_version %#x in the network", p.Domain[:], p.ForkVersion[:])
+		}
+		return domain, true, nil
+	case p.Domain != nil:
+		for _, forkVersion := range chain.forkVersions {
+			domain, err := signing.ComputeDomain(domainType, forkVersion, root)
+			if err != nil {
+				return phase0.Domain{}, false, err
+			}
+			if phase0.Domain(*p.Domain) == domain {
+				return domain, true, nil
+			}
+		}
+		return phase0.Domain{}, false, errors.Errorf("domain %#x isn't of any known fork of the network, unless given with it's fork_version", p.Domain[:])
+	}
+	return phase0.Domain{}, false, errors.New("fork_version is required with genesis_validators_root")
+}
+
+// signingDomain returns the domain of the given type from the signing parameters
+// of a check in network, or false if there are none.
+func (s *Server) signingDomain(
+	ctx context.Context,
+	network string,
+	params *signingParams,
+	domainType phase0.DomainType,
+) (phase0.Domain, bool, error) {
+	if !params.given() {
+		return phase0.Domain{}, false, nil
+	}
+	chain, err := s.signingChain(ctx, network)
+	if err != nil {
+		return phase0.Domain{}, false, err
+	}
+	return params.domain(domainType, chain)
+}
+
+// resolveSigningRoot returns the signing root of obj if there are signing parameters,
+// and otherwise the given signing root. A given signing root which differs from the
+// computed one is refused, since it means the caller is mistaken about what's signed.
+func (s *Server) resolveSigningRoot(
+	ctx context.Context,
+	network string,
+	params *signingParams,
+	domainType phase0.DomainType,
+	obj interface{ HashTreeRoot() ([32]byte, error) },
+	given jsonRoot,
+) (jsonRoot, error) {
+	domain, ok, err := s.signingDomain(ctx, network, params, domainType)
+	if err != nil || !ok {
+		return given, err
+	}
+	computed, err := signing.SigningRoot(obj, domain)
+	if err != nil {
+		return given, err
+	}
+	if given != (jsonRoot{}) && given != jsonRoot(computed) {
+		return given, errors.Errorf("signing_root %#x doesn't match the computed signing root %#x", given[:], computed[:])
+	}
+	return jsonRoot(computed), nil
+}
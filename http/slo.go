@@ -0,0 +1,164 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// sloWindow is how far back check latencies are considered.
+	sloWindow = time.Minute
+
+	// sloSamples is the maximum number of check latencies kept.
+	sloSamples = 4096
+
+	// sloEvaluateInterval is how often checks evaluate the objective.
+	sloEvaluateInterval = time.Second
+)
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// latencySLO tracks the rolling p99 latency of checks against an objective.
+type latencySLO struct {
+	objective time.Duration
+
+	mu      sync.Mutex
+	samples []latencySample
+	next    int
+
+	// degraded is whether the objective was exceeded when last evaluated,
+	// and degradations counts the times it started being exceeded.
+	degraded     bool
+	degradations int
+	evaluatedAt  time.Time
+}
+
+func newLatencySLO(objective time.Duration) *latencySLO {
+	return &latencySLO{
+		objective: objective,
+		samples:   make([]latencySample, 0, sloSamples),
+	}
+}
+
+func (l *latencySLO) observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sample := latencySample{at: time.Now(), duration: d}
+	if len(l.samples) < sloSamples {
+		l.samples = append(l.samples, sample)
+		return
+	}
+	l.samples[l.next] = sample
+	l.next = (l.next + 1) % sloSamples
+}
+
+// p99 returns the p99 latency of the checks within the window.
+func (l *latencySLO) p99() time.Duration {
+	since := time.Now().Add(-sloWindow)
+	l.mu.Lock()
+	durations := make([]time.Duration, 0, len(l.samples))
+	for _, s := range l.samples {
+		if s.at.After(since) {
+			durations = append(durations, s.duration)
+		}
+	}
+	l.mu.Unlock()
+
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	return durations[len(durations)*99/100]
+}
+
+// evaluate compares the p99 latency to the objective, and reports whether
+// it's exceeded and whether that changed since the last evaluation.
+func (l *latencySLO) evaluate() (p99 time.Duration, degraded, changed bool) {
+	p99 = l.p99()
+	degraded = p99 > l.objective
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.evaluatedAt = time.Now()
+	changed = degraded != l.degraded
+	l.degraded = degraded
+	if changed && degraded {
+		l.degradations++
+	}
+	return p99, degraded, changed
+}
+
+// due reports whether the objective wasn't evaluated for sloEvaluateInterval.
+func (l *latencySLO) due() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Since(l.evaluatedAt) >= sloEvaluateInterval
+}
+
+// status returns the result of the last evaluation and the number of degradations.
+func (l *latencySLO) status() (degraded bool, degradations int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.degraded, l.degradations
+}
+
+// Degraded reports whether the latency of checks exceeds the SLO, in which
+// case the server runs in degraded mode until it recovers: lower priority
+// requests are shed, and background maintenance given this method (such as
+// compaction) should be deferred. It's always false without an SLO.
+func (s *Server) Degraded() bool {
+	if s.slo == nil {
+		return false
+	}
+	p99, degraded, changed := s.slo.evaluate()
+	switch {
+	case changed && degraded:
+		s.logger.Warn("check latency exceeds it's objective, degrading",
+			zap.Duration("p99", p99),
+			zap.Duration("objective", s.slo.objective),
+		)
+	case changed:
+		s.logger.Info("check latency recovered, leaving degraded mode",
+			zap.Duration("p99", p99),
+			zap.Duration("objective", s.slo.objective),
+		)
+	}
+	return degraded
+}
+
+// observeLatency records the latency of checks, and evaluates the SLO
+// every sloEvaluateInterval so that degrading doesn't wait for others.
+func (s *Server) observeLatency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		if s.slo != nil {
+			s.slo.observe(time.Since(start))
+			if s.slo.due() {
+				s.Degraded()
+			}
+		}
+	})
+}
+
+// shedWhenDegraded rejects lower priority requests with 503 while
+// the latency of checks exceeds the SLO, leaving room for checks.
+func (s *Server) shedWhenDegraded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Degraded() {
+			s.logger.Debug("shedding request", zap.String("path", r.URL.Path))
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, "check latency exceeds it's objective, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,53 @@
+package http
+
+import "time"
+
+// Timeouts are the time budgets of requests by kind of route, after which
+// their context is canceled, so that they stop waiting and fail.
+type Timeouts struct {
+	// Check bounds checks, which are useless to a validator client once
+	// it's duty is missed, so they fail fast instead of piling up.
+	Check time.Duration
+
+	// Read bounds reads of histories, digests, stats and watermarks.
+	Read time.Duration
+
+	// Export and Import bound exports and imports, which may
+	// walk the histories of many keys.
+	Export time.Duration
+	Import time.Duration
+
+	// Admin bounds the other admin requests and the profiler. Snapshots
+	// aren't bounded, since they're only aborted when the client is gone.
+	Admin time.Duration
+}
+
+// DefaultTimeouts are the timeouts of a Server unless set with WithTimeouts.
+var DefaultTimeouts = Timeouts{
+	Check:  5 * time.Second,
+	Read:   30 * time.Second,
+	Export: 5 * time.Minute,
+	Import: 10 * time.Minute,
+	Admin:  time.Minute,
+}
+
+// WithTimeouts sets the time budgets of requests by kind of route.
+// Zero timeouts keep their default.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *Server) {
+		for _, t := range []struct {
+			timeout *time.Duration
+			value   time.Duration
+		}{
+			{&s.timeouts.Check, timeouts.Check},
+			{&s.timeouts.Read, timeouts.Read},
+			{&s.timeouts.Export, timeouts.Export},
+			{&s.timeouts.Import, timeouts.Import},
+			{&s.timeouts.Admin, timeouts.Admin},
+		} {
+			if t.value > 0 {
+				*t.timeout = t.value
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// CertReloader serves a TLS certificate from files, and reloads it when
+// they're modified, so that certificates can be rotated without restarting
+// and interrupting signing.
+type CertReloader struct {
+	logger   *zap.Logger
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader returns a CertReloader of the certificate in certFile and
+// its private key in keyFile, which are loaded before it returns.
+func NewCertReloader(logger *zap.Logger, certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		logger:   logger,
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the certificate if either of its files was modified since it
+// was last loaded, and returns whether it was. The current certificate is
+// kept if the files can't be loaded, such as while they're being replaced.
+func (r *CertReloader) Reload() (bool, error) {
+	var modTime time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to stat certificate")
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load certificate")
+	}
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Run reloads the certificate every interval until ctx is done.
+func (r *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				r.logger.Warn("failed to reload certificate, keeping the current one", zap.Error(err))
+			} else if reloaded {
+				r.logger.Info("reloaded certificate", zap.String("cert_file", r.certFile))
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "first", time.Now().Add(-time.Hour))
+
+	certs, err := NewCertReloader(zap.NewNop(), certFile, keyFile)
+	require.NoError(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: certs.GetCertificate})
+	require.NoError(t, err)
+	defer listener.Close()
+	go http.Serve(listener, http.NotFoundHandler())
+	servedCert := func() string {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	require.Equal(t, "first", servedCert())
+
+	// Expect unmodified files not to be reloaded.
+	reloaded, err := certs.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	// Expect a half-written certificate to keep the current one.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0600))
+	_, err = certs.Reload()
+	require.Error(t, err)
+	require.Equal(t, "first", servedCert())
+
+	// Expect a rotated certificate to be served once it's reloaded.
+	writeCert(t, certFile, keyFile, "second", time.Now())
+	reloaded, err = certs.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, "second", servedCert())
+}
+
+// writeCert writes a self-signed certificate of commonName and it's private
+// key, with the given modification time.
+func writeCert(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
@@ -16,6 +16,9 @@ type checkResponse struct {
 	Check      *protector.Check `json:"check"`
 	StatusCode int              `json:"status_code"`
 	Error      string           `json:"error,omitempty"`
+
+	// Signature is the signature of the embedded signer, if it signed.
+	Signature *jsonSignature `json:"signature,omitempty"`
 }
 
 func (c *checkResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -63,3 +66,60 @@ func (j *jsonRoot) UnmarshalJSON(data []byte) error {
 	copy(j[:], v)
 	return nil
 }
+
+type jsonSignature phase0.BLSSignature
+
+func (j jsonSignature) MarshalJSON() ([]byte, error) {
+	return []byte(`"0x` + hex.EncodeToString(j[:]) + `"`), nil
+}
+
+func (j *jsonSignature) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	copy(j[:], v)
+	return nil
+}
+
+type jsonVersion phase0.Version
+
+func (j jsonVersion) MarshalJSON() ([]byte, error) {
+	return []byte(`"0x` + hex.EncodeToString(j[:]) + `"`), nil
+}
+
+func (j *jsonVersion) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	copy(j[:], v)
+	return nil
+}
+
+type jsonBytes []byte
+
+func (j jsonBytes) MarshalJSON() ([]byte, error) {
+	return []byte(`"0x` + hex.EncodeToString(j) + `"`), nil
+}
+
+func (j *jsonBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	*j = v
+	return nil
+}
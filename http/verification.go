@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/verifier"
+	"github.com/go-chi/render"
+)
+
+// WithVerifier serves the status of the background verification of the
+// stores by v through the admin endpoints and /metrics.
+func WithVerifier(v *verifier.Verifier) Option {
+	return func(s *Server) {
+		s.verifier = v
+	}
+}
+
+type verificationResponse struct {
+	LastPass   *time.Time           `json:"last_pass"`
+	Verified   int                  `json:"verified"`
+	Violations []*violationResponse `json:"violations"`
+}
+
+type violationResponse struct {
+	Network    string       `json:"network"`
+	PubKey     jsonPubKey   `json:"pub_key"`
+	Findings   []kv.Finding `json:"findings"`
+	VerifiedAt time.Time    `json:"verified_at"`
+}
+
+// handleVerification responds with the stores which were found violating
+// their invariants by the background verification.
+func (s *Server) handleVerification(w http.ResponseWriter, r *http.Request) {
+	if s.verifier == nil {
+		http.Error(w, "background verification is disabled", http.StatusNotFound)
+		return
+	}
+	status := s.verifier.Status()
+	resp := &verificationResponse{
+		LastPass:   jsonTime(status.LastPass),
+		Verified:   status.Verified,
+		Violations: make([]*violationResponse, len(status.Violations)),
+	}
+	for i, v := range status.Violations {
+		resp.Violations[i] = &violationResponse{
+			Network:    v.Network,
+			PubKey:     jsonPubKey(v.PubKey),
+			Findings:   v.Findings,
+			VerifiedAt: v.VerifiedAt.UTC(),
+		}
+	}
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+)
+
+// WithWatermarkCache has the client remember the latest attestation and
+// proposal which the server allowed each public key to sign, and refuse checks
+// which are provably slashable against them without asking the server: double
+// votes and double proposals at them, and attestations which surround them or
+// are surrounded by them. Besides saving a round trip, this protects against a
+// buggy or compromised server wrongly allowing them. Checks which are only
+// slashable against the rest of the history are left to the server.
+//
+// The cache is only learned from the responses of checks, so it starts empty
+// and doesn't see what's signed through other clients.
+func WithWatermarkCache() ClientOption {
+	return func(c *Client) {
+		c.watermarks = &watermarkCache{
+			keys: make(map[watermarkKey]*watermarks),
+		}
+	}
+}
+
+type watermarkKey struct {
+	network string
+	pubKey  phase0.BLSPubKey
+}
+
+// cachedAttestation is an attestation which was allowed to be signed.
+// It's signing root is zero if unknown.
+type cachedAttestation struct {
+	source phase0.Epoch
+	target phase0.Epoch
+	root   phase0.Root
+}
+
+// watermarks are the allowed attestations with the highest target epoch and
+// with the highest source epoch of a public key, and it's allowed proposal
+// with the highest slot. The signing root of the latter is zero if unknown.
+type watermarks struct {
+	attested      bool
+	highestTarget cachedAttestation
+	highestSource cachedAttestation
+
+	proposed bool
+	slot     phase0.Slot
+	slotRoot phase0.Root
+}
+
+type watermarkCache struct {
+	keys map[watermarkKey]*watermarks
+	mu   sync.Mutex
+}
+
+// refuseAttestation returns a slashable check if an attestation is provably
+// slashable against the cached attestations of a public key, or nil otherwise.
+func (c *watermarkCache) refuseAttestation(
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+) *protector.Check {
+	if data.Source == nil || data.Target == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.keys[watermarkKey{network, pubKey}]
+	if !ok || !w.attested {
+		return nil
+	}
+	source, target := data.Source.Epoch, data.Target.Epoch
+	if a := w.highestTarget; target == a.target && signingRoot != (phase0.Root{}) && a.root != (phase0.Root{}) &&
+		(signingRoot != a.root || source != a.source) {
+		return refused("double vote at the highest signed target epoch %d", target)
+	}
+	for _, a := range []cachedAttestation{w.highestTarget, w.highestSource} {
+		switch {
+		case source < a.source && target > a.target:
+			return refused("attestation (%d, %d) surrounds the signed attestation (%d, %d)", source, target, a.source, a.target)
+		case source > a.source && target < a.target:
+			return refused("attestation (%d, %d) is surrounded by the signed attestation (%d, %d)", source, target, a.source, a.target)
+		}
+	}
+	return nil
+}
+
+// attested advances the cached attestations of a public key to an allowed attestation.
+func (c *watermarkCache) attested(
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+) {
+	if data.Source == nil || data.Target == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.get(network, pubKey)
+	a := cachedAttestation{source: data.Source.Epoch, target: data.Target.Epoch, root: signingRoot}
+	if !w.attested || a.target > w.highestTarget.target {
+		w.highestTarget = a
+	}
+	if !w.attested || a.source > w.highestSource.source {
+		w.highestSource = a
+	}
+	w.attested = true
+}
+
+// refuseProposal returns a slashable check if a proposal is a double proposal
+// at the highest signed slot of a public key, or nil otherwise. signingRoot
+// is zero if it's unknown.
+func (c *watermarkCache) refuseProposal(
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	slot phase0.Slot,
+) *protector.Check {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.keys[watermarkKey{network, pubKey}]
+	if !ok || !w.proposed {
+		return nil
+	}
+	if slot == w.slot && signingRoot != (phase0.Root{}) && w.slotRoot != (phase0.Root{}) && signingRoot != w.slotRoot {
+		return refused("double proposal at the highest signed slot %d", slot)
+	}
+	return nil
+}
+
+// proposed advances the watermarks of a public key to an allowed proposal.
+func (c *watermarkCache) proposed(network string, pubKey phase0.BLSPubKey, signingRoot phase0.Root, slot phase0.Slot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.get(network, pubKey)
+	if !w.proposed || slot > w.slot {
+		w.slot, w.slotRoot = slot, signingRoot
+	}
+	w.proposed = true
+}
+
+// get returns the watermarks of a public key, creating them if necessary.
+// Must be called with mu held.
+func (c *watermarkCache) get(network string, pubKey phase0.BLSPubKey) *watermarks {
+	key := watermarkKey{network, pubKey}
+	w, ok := c.keys[key]
+	if !ok {
+		w = &watermarks{}
+		c.keys[key] = w
+	}
+	return w
+}
+
+// refused returns a slashable check which was refused by the watermark cache.
+func refused(reason string, args ...interface{}) *protector.Check {
+	return &protector.Check{
+		Slashable: true,
+		Reason:    fmt.Sprintf(reason, args...) + " (refused by the client's watermark cache)",
+	}
+}
@@ -0,0 +1,186 @@
+// Package archiver reclaims the disk space of exited validators by exporting
+// their histories to interchange files and removing their stores.
+package archiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/beacon"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// Archiver archives the histories of validators which exited
+// at least a retention period ago.
+type Archiver struct {
+	logger    *zap.Logger
+	protector protector.ProtectorPooler
+	nodes     map[string]*beacon.Client
+	dir       string
+	retention time.Duration
+
+	// deferred reports whether archiving should wait for the next interval.
+	deferred func() bool
+}
+
+// Option configures an Archiver.
+type Option func(*Archiver)
+
+// WithDeferral defers archiving to the next interval while deferred
+// returns true, such as while checks are slower than their objective.
+func WithDeferral(deferred func() bool) Option {
+	return func(a *Archiver) {
+		a.deferred = deferred
+	}
+}
+
+// New returns an Archiver which writes archives into dir, and learns about
+// exits in each network from its beacon node.
+func New(
+	logger *zap.Logger,
+	protector protector.ProtectorPooler,
+	nodes map[string]*beacon.Client,
+	dir string,
+	retention time.Duration,
+	opts ...Option,
+) *Archiver {
+	a := &Archiver{
+		logger:    logger,
+		protector: protector,
+		nodes:     nodes,
+		dir:       dir,
+		retention: retention,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// FileName returns the name of the archive of a public key within the archive directory.
+func FileName(network string, pubKey phase0.BLSPubKey) string {
+	return fmt.Sprintf("exited-%s-%x.json", network, pubKey)
+}
+
+// Run archives exited validators every interval until ctx is done.
+func (a *Archiver) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Archive(ctx); err != nil && ctx.Err() == nil {
+				a.logger.Error("failed to archive exited validators", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Archive archives the validators of every network which exited at least
+// the retention period ago, and returns the first error of any network.
+func (a *Archiver) Archive(ctx context.Context) error {
+	var err error
+	for network, node := range a.nodes {
+		err = multierr.Append(err, errors.Wrap(a.archiveNetwork(ctx, network, node), network))
+	}
+	return err
+}
+
+func (a *Archiver) archiveNetwork(ctx context.Context, network string, node *beacon.Client) error {
+	pubKeys, err := a.protector.Pool().PubKeys(network)
+	if err != nil || len(pubKeys) == 0 {
+		return err
+	}
+	validators, err := node.ValidatorsByPubKey(ctx, pubKeys)
+	if err != nil {
+		return err
+	}
+	genesis, slotDuration, err := node.Genesis(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range validators {
+		if !v.Exited() {
+			continue
+		}
+		exitTime := genesis.Add(time.Duration(v.ExitEpoch) * protector.SlotsPerEpoch * slotDuration)
+		if time.Since(exitTime) < a.retention {
+			continue
+		}
+		if a.deferred != nil && a.deferred() {
+			a.logger.Info("deferred archiving", zap.String("network", network))
+			return nil
+		}
+		if err := a.archive(ctx, network, node, v.PubKey); err != nil {
+			return errors.Wrapf(err, "failed to archive %#x", v.PubKey)
+		}
+		a.logger.Info("archived exited validator",
+			zap.String("network", network),
+			zap.String("pub_key", fmt.Sprintf("%#x", v.PubKey)),
+			zap.Uint64("exit_epoch", uint64(v.ExitEpoch)),
+		)
+	}
+	return nil
+}
+
+// archive exports the history of a public key into its archive and removes
+// its store. The key is deactivated first, so that nothing can be signed
+// between the export and the removal.
+func (a *Archiver) archive(ctx context.Context, network string, node *beacon.Client, pubKey phase0.BLSPubKey) error {
+	genesisValidatorsRoot, err := node.GenesisValidatorsRoot(ctx)
+	if err != nil {
+		return err
+	}
+	pubKeys := []phase0.BLSPubKey{pubKey}
+	if err := a.protector.Deactivate(ctx, network, pubKeys); err != nil {
+		return errors.Wrap(err, "failed to deactivate")
+	}
+	exported, err := interchange.Export(ctx, a.protector, network, genesisValidatorsRoot, pubKeys)
+	if err != nil {
+		return err
+	}
+	if err := a.write(FileName(network, pubKey), exported); err != nil {
+		return err
+	}
+	_, err = a.protector.Delete(ctx, network, pubKeys, false)
+	return err
+}
+
+// write writes the interchange into a file in the archive directory,
+// which is synced before it's renamed into place.
+func (a *Archiver) write(name string, exported *interchange.Interchange) (err error) {
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return errors.Wrap(err, "failed to create archive directory")
+	}
+	f, err := os.CreateTemp(a.dir, name+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create archive")
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err := json.NewEncoder(f).Encode(exported); err != nil {
+		return errors.Wrap(err, "failed to write archive")
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync archive")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to close archive")
+	}
+	return errors.Wrap(os.Rename(f.Name(), filepath.Join(a.dir, name)), "failed to rename archive")
+}
@@ -0,0 +1,102 @@
+package archiver
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/beacon"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestArchiver_Archive(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+
+	// Sign with a key which exited long ago, a key which exited
+	// recently and a key which is still active.
+	const secondsPerSlot = 12
+	genesis := time.Now().Add(-100 * protector.SlotsPerEpoch * secondsPerSlot * time.Second)
+	type validator struct {
+		status    string
+		exitEpoch uint64
+	}
+	validators := map[phase0.BLSPubKey]validator{
+		{0x1}: {"withdrawal_done", 10},
+		{0x2}: {"exited_unslashed", 99},
+		{0x3}: {"active_ongoing", 1<<64 - 1},
+	}
+	for pubKey := range validators {
+		check, err := prtc.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+		require.NoError(t, err)
+		require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eth/v1/beacon/genesis":
+			fmt.Fprintf(
+				w,
+				`{"data":{"genesis_time":"%d","genesis_validators_root":"%s"}}`,
+				genesis.Unix(), interchange.GenesisValidatorsRoots["mainnet"],
+			)
+		case "/eth/v1/config/spec":
+			fmt.Fprintf(w, `{"data":{"SECONDS_PER_SLOT":"%d"}}`, secondsPerSlot)
+		case "/eth/v1/beacon/states/head/validators":
+			var data []string
+			for i, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+				var pubKey phase0.BLSPubKey
+				b, err := hex.DecodeString(strings.TrimPrefix(id, "0x"))
+				require.NoError(t, err)
+				copy(pubKey[:], b)
+				v := validators[pubKey]
+				data = append(data, fmt.Sprintf(
+					`{"index":"%d","status":"%s","validator":{"pubkey":"%s","exit_epoch":"%d"}}`,
+					i, v.status, id, v.exitEpoch,
+				))
+			}
+			fmt.Fprintf(w, `{"data":[%s]}`, strings.Join(data, ","))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	nodes := map[string]*beacon.Client{
+		"mainnet": beacon.New(zap.NewNop(), server.Client(), server.URL),
+	}
+	arch := New(zap.NewNop(), prtc.(protector.ProtectorPooler), nodes, dir, time.Hour)
+	require.NoError(t, arch.Archive(ctx))
+
+	// Expect only the key which exited long ago to be archived.
+	pubKeys, err := prtc.(protector.ProtectorPooler).Pool().PubKeys("mainnet")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []phase0.BLSPubKey{{0x2}, {0x3}}, pubKeys)
+
+	b, err := os.ReadFile(filepath.Join(dir, FileName("mainnet", phase0.BLSPubKey{0x1})))
+	require.NoError(t, err)
+	var archived interchange.Interchange
+	require.NoError(t, json.Unmarshal(b, &archived))
+	require.Equal(t, interchange.GenesisValidatorsRoots["mainnet"], archived.Metadata.GenesisValidatorsRoot)
+	require.Len(t, archived.Data, 1)
+	require.Equal(t, fmt.Sprintf("%#x", phase0.BLSPubKey{0x1}), archived.Data[0].PubKey)
+	require.Len(t, archived.Data[0].SignedBlocks, 1)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
@@ -0,0 +1,242 @@
+package protector
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/bloxapp/slashing-protector/protector/wal"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// asyncKey holds the acknowledged attestations of a public key
+// which are yet to be saved.
+type asyncKey struct {
+	mu      sync.Mutex
+	pending pendingAttestations
+
+	// oldest is when the oldest pending attestation was acknowledged,
+	// in Unix nanoseconds, or zero if there are none.
+	oldest int64
+}
+
+// asyncState is the state of asynchronous writes.
+type asyncState struct {
+	wal      *wal.WAL
+	interval time.Duration
+	keys     map[keyID]*asyncKey
+	keysMu   sync.Mutex
+	depth    int64
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// WithAsyncWrites enables asynchronous writes: attestations are still checked
+// synchronously, but are acknowledged once they're appended to the WAL and
+// fsynced, and saved to their stores in the background every interval.
+//
+// This lowers tail latency, since concurrent appends share their fsyncs rather
+// than each committing a transaction to it's store. Attestations left in the
+// WAL by a crash of the process or the machine are saved on startup.
+func WithAsyncWrites(w *wal.WAL, interval time.Duration) Option {
+	return func(p *protector) {
+		p.async = &asyncState{
+			wal:      w,
+			interval: interval,
+			keys:     make(map[keyID]*asyncKey),
+			stop:     make(chan struct{}),
+			stopped:  make(chan struct{}),
+		}
+	}
+}
+
+// startAsync recovers the attestations left in the WAL
+// and starts saving pending attestations in the background.
+func (p *protector) startAsync() {
+	now := time.Now().UnixNano()
+	for _, e := range p.async.wal.Entries() {
+		k := p.asyncKey(keyID{e.Network, e.PubKey})
+		record := e.Record
+		k.pending.add(-1, &record)
+		k.oldest = now
+		p.async.depth++
+	}
+	go func() {
+		defer close(p.async.stopped)
+		ticker := time.NewTicker(p.async.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.async.stop:
+				return
+			case <-ticker.C:
+				// Errors are retried on the next checkpoint, and
+				// surface through the flush lag meanwhile.
+				_ = p.checkpoint()
+			}
+		}
+	}()
+}
+
+// stopAsync stops saving in the background and saves
+// the pending attestations a final time.
+func (p *protector) stopAsync() error {
+	close(p.async.stop)
+	<-p.async.stopped
+	return multierr.Append(p.checkpoint(), p.async.wal.Close())
+}
+
+// asyncKey returns the async state of a public key, creating it if necessary.
+func (p *protector) asyncKey(id keyID) *asyncKey {
+	p.async.keysMu.Lock()
+	defer p.async.keysMu.Unlock()
+	k, ok := p.async.keys[id]
+	if !ok {
+		k = &asyncKey{}
+		p.async.keys[id] = k
+	}
+	return k
+}
+
+// asyncAttestation checks an attestation and acknowledges it
+// once it's appended to the WAL, without saving it.
+func (p *protector) asyncAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+) (check *Check, err error) {
+	id := keyID{network, pubKey}
+	k := p.asyncKey(id)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	conn, err := p.acquireActive(ctx, network, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+
+	// Checks only see saved attestations, so an attestation
+	// which might conflict with a pending one requires saving first.
+	if k.pending.conflicts(data) {
+		if err := p.flushAsync(k, conn); err != nil {
+			return nil, err
+		}
+	}
+	check, err = p.checkAttestation(conn, id, signingRoot, data)
+	if err != nil || check.Slashable {
+		return check, err
+	}
+
+	record := newAttestationRecord(signingRoot, data)
+	if err := p.async.wal.Append(wal.Entry{Network: network, PubKey: pubKey, Record: *record}); err != nil {
+		return nil, errors.Wrap(err, "could not append attestation to the WAL")
+	}
+	k.pending.add(-1, record)
+	if len(k.pending.records) == 1 {
+		atomic.StoreInt64(&k.oldest, time.Now().UnixNano())
+	}
+	atomic.AddInt64(&p.async.depth, 1)
+	return notSlashable(), nil
+}
+
+// flushAsync saves the pending attestations of a key. Must be called with k.mu held.
+func (p *protector) flushAsync(k *asyncKey, conn *kvpool.Conn) error {
+	if len(k.pending.records) == 0 {
+		return nil
+	}
+	if err := conn.SaveAttestations(k.pending.records...); err != nil {
+		return errors.Wrap(err, "could not save attestation history for validator public key")
+	}
+	atomic.AddInt64(&p.async.depth, -int64(len(k.pending.records)))
+	atomic.StoreInt64(&k.oldest, 0)
+	k.pending = pendingAttestations{}
+	return nil
+}
+
+// acquireFlushed acquires a connection after saving the pending
+// attestations of the public key, so that reads see them.
+func (p *protector) acquireFlushed(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kvpool.Conn, error) {
+	if p.async == nil {
+		return p.pool.Acquire(ctx, network, pubKey)
+	}
+	k := p.asyncKey(keyID{network, pubKey})
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	conn, err := p.pool.Acquire(ctx, network, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.flushAsync(k, conn); err != nil {
+		return nil, p.release(err, conn)
+	}
+	return conn, nil
+}
+
+// checkpoint saves the pending attestations of all keys, and then removes
+// the WAL segments whose attestations are all saved.
+func (p *protector) checkpoint() error {
+	segments, err := p.async.wal.Rotate()
+	if err != nil {
+		return err
+	}
+
+	// Every attestation appended to the sealed segments is pending in
+	// it's key by now, since they're appended with the key locked.
+	p.async.keysMu.Lock()
+	keys := make(map[keyID]*asyncKey, len(p.async.keys))
+	for id, k := range p.async.keys {
+		keys[id] = k
+	}
+	p.async.keysMu.Unlock()
+
+	for id, k := range keys {
+		err = multierr.Append(err, p.checkpointKey(id, k))
+	}
+	if err != nil {
+		return err
+	}
+	return p.async.wal.Remove(segments)
+}
+
+func (p *protector) checkpointKey(id keyID, k *asyncKey) (err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.pending.records) == 0 {
+		return nil
+	}
+	conn, err := p.pool.Acquire(context.Background(), id.network, id.pubKey)
+	if err != nil {
+		return errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return p.flushAsync(k, conn)
+}
+
+// AsyncQueue returns the number of acknowledged attestations which are
+// yet to be saved, and how long ago the oldest of them was acknowledged.
+func (p *protector) AsyncQueue() (depth int, lag time.Duration) {
+	if p.async == nil {
+		return 0, 0
+	}
+	p.async.keysMu.Lock()
+	defer p.async.keysMu.Unlock()
+	now := time.Now().UnixNano()
+	for _, k := range p.async.keys {
+		if oldest := atomic.LoadInt64(&k.oldest); oldest != 0 && time.Duration(now-oldest) > lag {
+			lag = time.Duration(now - oldest)
+		}
+	}
+	return int(atomic.LoadInt64(&p.async.depth)), lag
+}
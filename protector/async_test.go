@@ -0,0 +1,53 @@
+package protector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/wal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncWrites(t *testing.T) {
+	ctx := context.Background()
+	dir, walDir := t.TempDir(), t.TempDir()
+	openWAL := func() *wal.WAL {
+		w, err := wal.Open(walDir)
+		require.NoError(t, err)
+		return w
+	}
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+
+	// Acknowledge an attestation without saving it, and then
+	// abandon the protector as if the process crashed.
+	prtc := New(dir, WithAsyncWrites(openWAL(), time.Hour))
+	check, err := prtc.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, attestation(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	depth, _ := prtc.(ProtectorQueuer).AsyncQueue()
+	require.Equal(t, 1, depth)
+
+	// Expect the attestation to be recovered from the WAL.
+	recovered := New(dir, WithAsyncWrites(openWAL(), time.Hour))
+	defer recovered.Close()
+	check, err = recovered.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x2}, attestation(1, 2))
+	require.NoError(t, err)
+	require.True(t, check.Slashable, "expected slashing")
+
+	// Expect pending attestations to be seen by reads.
+	check, err = recovered.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x3}, attestation(2, 3))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	history, err := recovered.History(ctx, "mainnet", phase0.BLSPubKey{})
+	require.NoError(t, err)
+	require.Len(t, history.Attestations, 2)
+	depth, _ = recovered.(ProtectorQueuer).AsyncQueue()
+	require.Equal(t, 0, depth)
+}
@@ -0,0 +1,48 @@
+package protector
+
+import (
+	"context"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+)
+
+// ProtectorAttemptRecorder is a protector that records the slashable attempts
+// it refused alongside the history of their public key, so that what almost
+// happened can be reviewed and not only what was signed.
+type ProtectorAttemptRecorder interface {
+	Protector
+
+	// Attempts returns the most recent refused attempts of a public key
+	// (up to kv.MaxAttempts) from oldest to newest, which are empty for
+	// keys without a store.
+	Attempts(ctx context.Context, network string, pubKey phase0.BLSPubKey) ([]*kv.Attempt, error)
+}
+
+func (p *protector) Attempts(ctx context.Context, network string, pubKey phase0.BLSPubKey) (attempts []*kv.Attempt, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return nil, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return conn.Attempts()
+}
+
+// saveAttempt records a refused attempt. Must be called with the
+// connection still acquired.
+func saveAttempt(conn *kvpool.Conn, attempt *kv.Attempt, check *Check) error {
+	attempt.Time = time.Now()
+	attempt.Reason = check.Reason
+	if err := conn.SaveAttempt(attempt); err != nil {
+		return errors.Wrap(err, "failed to save slashable attempt")
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package backup implements backup archives of a data directory: tar files,
+// optionally compressed with zstd, which end with a manifest of the
+// checksums of their files, so that truncated or corrupted archives
+// are detected on restore.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// ManifestName is the name of the manifest, which is the last file in an archive.
+const ManifestName = "MANIFEST.json"
+
+// zstdMagic is the magic number which zstd frames start with.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Manifest lists the files in an archive.
+type Manifest struct {
+	Created time.Time       `json:"created"`
+	Files   []*ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Writer writes an archive.
+type Writer struct {
+	tw       *tar.Writer
+	zw       *zstd.Encoder
+	manifest Manifest
+
+	// current is the file being written.
+	current *ManifestFile
+	hash    hash.Hash
+}
+
+// NewWriter returns a Writer of an archive to w, compressed with zstd if compress is true.
+func NewWriter(w io.Writer, compress bool) (*Writer, error) {
+	bw := &Writer{manifest: Manifest{Created: time.Now().UTC(), Files: []*ManifestFile{}}}
+	if compress {
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create zstd writer")
+		}
+		bw.zw, w = zw, zw
+	}
+	bw.tw = tar.NewWriter(w)
+	return bw, nil
+}
+
+// Create starts a file of the given size, which must be written fully
+// to the returned writer before the next file is created.
+func (w *Writer) Create(name string, size int64) (io.Writer, error) {
+	w.finishFile()
+	err := w.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: w.manifest.Created,
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.current = &ManifestFile{Name: name, Size: size}
+	w.hash = sha256.New()
+	return io.MultiWriter(w.tw, w.hash), nil
+}
+
+// finishFile adds the file being written to the manifest.
+func (w *Writer) finishFile() {
+	if w.current == nil {
+		return
+	}
+	w.current.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	w.manifest.Files = append(w.manifest.Files, w.current)
+	w.current = nil
+}
+
+// Close writes the manifest and completes the archive.
+func (w *Writer) Close() error {
+	w.finishFile()
+	manifest, err := json.MarshalIndent(&w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = w.tw.WriteHeader(&tar.Header{
+		Name:    ManifestName,
+		Mode:    0600,
+		Size:    int64(len(manifest)),
+		ModTime: w.manifest.Created,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.tw.Write(manifest); err != nil {
+		return err
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return nil
+}
+
+// Restore extracts an archive (compressed with zstd or not) into dir after
+// verifying it against its manifest. Files are extracted into a staging
+// directory first, and only moved into dir once the archive is verified.
+// Restoring fails if any of the files already exist in dir.
+func Restore(r io.Reader, dir string) (manifest *Manifest, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create zstd reader")
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	staging, err := os.MkdirTemp(dir, ".restore-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create staging directory")
+	}
+	defer func() {
+		err = multierr.Append(err, os.RemoveAll(staging))
+	}()
+
+	// Extract the files and their checksums.
+	extracted := map[string]*ManifestFile{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read archive")
+		}
+		if header.Name == ManifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, errors.Wrap(err, "failed to decode manifest")
+			}
+			continue
+		}
+		name, err := cleanName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := extract(tr, filepath.Join(staging, name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to extract %s", header.Name)
+		}
+		file.Name = header.Name
+		extracted[header.Name] = file
+	}
+
+	// Verify the files against the manifest.
+	if manifest == nil {
+		return nil, errors.New("archive has no manifest, it may be truncated")
+	}
+	if len(manifest.Files) != len(extracted) {
+		return nil, errors.Errorf("archive has %d files but its manifest lists %d", len(extracted), len(manifest.Files))
+	}
+	for _, expected := range manifest.Files {
+		file, ok := extracted[expected.Name]
+		if !ok {
+			return nil, errors.Errorf("file %s is missing from archive", expected.Name)
+		}
+		if *file != *expected {
+			return nil, errors.Errorf("file %s doesn't match the manifest", expected.Name)
+		}
+	}
+
+	// Move the files into place.
+	for name := range extracted {
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			return nil, errors.Errorf("file %s already exists", dst)
+		}
+	}
+	for name := range extracted {
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return nil, errors.Wrap(err, "failed to create directory")
+		}
+		if err := os.Rename(filepath.Join(staging, filepath.FromSlash(name)), dst); err != nil {
+			return nil, errors.Wrapf(err, "failed to move %s", name)
+		}
+	}
+	return manifest, nil
+}
+
+// cleanName returns the path of a file in an archive,
+// refusing names which would escape the directory.
+func cleanName(name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." ||
+		strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("invalid file name %q in archive", name)
+	}
+	return cleaned, nil
+}
+
+// extract writes a file from r to path and returns it's size and checksum.
+func extract(r io.Reader, path string) (file *ManifestFile, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = multierr.Append(err, f.Close())
+	}()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	return &ManifestFile{Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	files := map[string][]byte{
+		"kvstore-mainnet-01/protection.db": []byte("first"),
+		"kvstore-mainnet-02/protection.db": []byte("second"),
+	}
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, compress)
+		require.NoError(t, err)
+		for name, content := range files {
+			fw, err := w.Create(name, int64(len(content)))
+			require.NoError(t, err)
+			_, err = fw.Write(content)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		archive := buf.Bytes()
+
+		// Expect a truncated archive to be refused, leaving nothing behind.
+		dir := t.TempDir()
+		_, err = Restore(bytes.NewReader(archive[:len(archive)/2]), dir)
+		require.Error(t, err)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		// Expect a complete archive to be restored.
+		manifest, err := Restore(bytes.NewReader(archive), dir)
+		require.NoError(t, err)
+		require.Len(t, manifest.Files, len(files))
+		for name, content := range files {
+			restored, err := os.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err)
+			require.Equal(t, content, restored)
+		}
+
+		// Expect restoring over existing files to be refused.
+		_, err = Restore(bytes.NewReader(archive), dir)
+		require.ErrorContains(t, err, "already exists")
+	}
+}
@@ -0,0 +1,270 @@
+// Package beacon resolves validators through a beacon node's standard API.
+package beacon
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/carlmjohnson/requests"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrUnknownValidator is returned when the beacon node doesn't know a validator.
+var ErrUnknownValidator = errors.New("unknown validator")
+
+// batchSize is the maximum number of validators requested at once,
+// which keeps request URLs within common length limits.
+const batchSize = 64
+
+// Validator is the state of a validator on the beacon chain.
+type Validator struct {
+	Index     phase0.ValidatorIndex
+	PubKey    phase0.BLSPubKey
+	Status    string
+	ExitEpoch phase0.Epoch
+}
+
+// Exited returns whether the validator has exited the active set,
+// whether or not it's withdrawable yet.
+func (v *Validator) Exited() bool {
+	return strings.HasPrefix(v.Status, "exited_") || strings.HasPrefix(v.Status, "withdrawal_")
+}
+
+type validatorResponse struct {
+	Index     string `json:"index"`
+	Status    string `json:"status"`
+	Validator struct {
+		PubKey    string `json:"pubkey"`
+		ExitEpoch string `json:"exit_epoch"`
+	} `json:"validator"`
+}
+
+func (r *validatorResponse) decode() (*Validator, error) {
+	index, err := strconv.ParseUint(r.Index, 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid index")
+	}
+	exitEpoch, err := strconv.ParseUint(r.Validator.ExitEpoch, 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid exit epoch")
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(r.Validator.PubKey, "0x"))
+	if err != nil || len(b) != phase0.PublicKeyLength {
+		return nil, errors.Errorf("invalid public key %q", r.Validator.PubKey)
+	}
+	v := &Validator{
+		Index:     phase0.ValidatorIndex(index),
+		Status:    r.Status,
+		ExitEpoch: phase0.Epoch(exitEpoch),
+	}
+	copy(v.PubKey[:], b)
+	return v, nil
+}
+
+// Client resolves validators through a beacon node, and caches the public keys
+// of validator indices, which never change once assigned.
+type Client struct {
+	logger *zap.Logger
+	http   *http.Client
+	url    string
+
+	pubKeys   map[phase0.ValidatorIndex]phase0.BLSPubKey
+	pubKeysMu sync.RWMutex
+
+	// genesis, genesisValidatorsRoot and slotDuration are
+	// cached once fetched, since they never change.
+	genesis               time.Time
+	genesisValidatorsRoot phase0.Root
+	slotDuration          time.Duration
+	genesisMu             sync.Mutex
+}
+
+// New returns a Client of the beacon node at url.
+func New(logger *zap.Logger, http *http.Client, url string) *Client {
+	return &Client{
+		logger:  logger,
+		http:    http,
+		url:     strings.TrimSuffix(url, "/"),
+		pubKeys: make(map[phase0.ValidatorIndex]phase0.BLSPubKey),
+	}
+}
+
+// PubKey returns the public key of a validator index, asking
+// the beacon node only if it's not cached.
+func (c *Client) PubKey(ctx context.Context, index phase0.ValidatorIndex) (phase0.BLSPubKey, error) {
+	c.pubKeysMu.RLock()
+	pubKey, ok := c.pubKeys[index]
+	c.pubKeysMu.RUnlock()
+	if ok {
+		return pubKey, nil
+	}
+	validators, err := c.Validators(ctx, []string{strconv.FormatUint(uint64(index), 10)})
+	if err != nil {
+		return phase0.BLSPubKey{}, err
+	}
+	if len(validators) == 0 {
+		return phase0.BLSPubKey{}, errors.Wrapf(ErrUnknownValidator, "index %d", index)
+	}
+	return validators[0].PubKey, nil
+}
+
+// Validators returns the validators with the given ids (indices or hex-encoded
+// public keys) at the head of the chain, omitting those unknown to the beacon node.
+func (c *Client) Validators(ctx context.Context, ids []string) ([]*Validator, error) {
+	var validators []*Validator
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > batchSize {
+			n = batchSize
+		}
+		var resp struct {
+			Data []*validatorResponse `json:"data"`
+		}
+		err := requests.
+			URL(c.url).
+			Client(c.http).
+			Path("/eth/v1/beacon/states/head/validators").
+			Param("id", strings.Join(ids[:n], ",")).
+			ToJSON(&resp).
+			Fetch(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch validators")
+		}
+		for _, data := range resp.Data {
+			v, err := data.decode()
+			if err != nil {
+				return nil, err
+			}
+			validators = append(validators, v)
+		}
+		ids = ids[n:]
+	}
+
+	c.pubKeysMu.Lock()
+	for _, v := range validators {
+		c.pubKeys[v.Index] = v.PubKey
+	}
+	c.pubKeysMu.Unlock()
+	return validators, nil
+}
+
+// ValidatorsByPubKey returns the validators of the given public keys,
+// omitting those unknown to the beacon node.
+func (c *Client) ValidatorsByPubKey(ctx context.Context, pubKeys []phase0.BLSPubKey) ([]*Validator, error) {
+	ids := make([]string, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		ids[i] = "0x" + hex.EncodeToString(pubKey[:])
+	}
+	return c.Validators(ctx, ids)
+}
+
+// Genesis returns the genesis time of the chain and the duration of its slots.
+func (c *Client) Genesis(ctx context.Context) (genesis time.Time, slotDuration time.Duration, err error) {
+	c.genesisMu.Lock()
+	defer c.genesisMu.Unlock()
+	if c.slotDuration != 0 {
+		return c.genesis, c.slotDuration, nil
+	}
+	if err := c.fetchGenesis(ctx); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	var specResp struct {
+		Data struct {
+			SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+		} `json:"data"`
+	}
+	err = requests.
+		URL(c.url).
+		Client(c.http).
+		Path("/eth/v1/config/spec").
+		ToJSON(&specResp).
+		Fetch(ctx)
+	if err != nil {
+		return time.Time{}, 0, errors.Wrap(err, "failed to fetch spec")
+	}
+	secondsPerSlot, err := strconv.ParseUint(specResp.Data.SecondsPerSlot, 10, 64)
+	if err != nil || secondsPerSlot == 0 {
+		return time.Time{}, 0, errors.Errorf("invalid seconds per slot %q", specResp.Data.SecondsPerSlot)
+	}
+
+	c.slotDuration = time.Duration(secondsPerSlot) * time.Second
+	return c.genesis, c.slotDuration, nil
+}
+
+// GenesisValidatorsRoot returns the genesis validators root of the chain.
+func (c *Client) GenesisValidatorsRoot(ctx context.Context) (phase0.Root, error) {
+	c.genesisMu.Lock()
+	defer c.genesisMu.Unlock()
+	if err := c.fetchGenesis(ctx); err != nil {
+		return phase0.Root{}, err
+	}
+	return c.genesisValidatorsRoot, nil
+}
+
+// fetchGenesis fetches the genesis of the chain, unless it's cached.
+// Must be called with genesisMu held.
+func (c *Client) fetchGenesis(ctx context.Context) error {
+	if !c.genesis.IsZero() {
+		return nil
+	}
+	var genesisResp struct {
+		Data struct {
+			GenesisTime           string `json:"genesis_time"`
+			GenesisValidatorsRoot string `json:"genesis_validators_root"`
+		} `json:"data"`
+	}
+	err := requests.
+		URL(c.url).
+		Client(c.http).
+		Path("/eth/v1/beacon/genesis").
+		ToJSON(&genesisResp).
+		Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch genesis")
+	}
+	genesisTime, err := strconv.ParseInt(genesisResp.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid genesis time")
+	}
+	root, err := hex.DecodeString(strings.TrimPrefix(genesisResp.Data.GenesisValidatorsRoot, "0x"))
+	if err != nil || len(root) != len(c.genesisValidatorsRoot) {
+		return errors.Errorf("invalid genesis validators root %q", genesisResp.Data.GenesisValidatorsRoot)
+	}
+	c.genesis = time.Unix(genesisTime, 0)
+	copy(c.genesisValidatorsRoot[:], root)
+	return nil
+}
+
+// Run caches the indices of the public keys returned by pubKeys every
+// interval until ctx is done, so that lookups of known keys by index
+// don't wait for the beacon node.
+func (c *Client) Run(ctx context.Context, interval time.Duration, pubKeys func() ([]phase0.BLSPubKey, error)) {
+	refresh := func() {
+		keys, err := pubKeys()
+		if err == nil {
+			_, err = c.ValidatorsByPubKey(ctx, keys)
+		}
+		if err != nil && ctx.Err() == nil {
+			c.logger.Error("failed to refresh validator indices", zap.Error(err))
+		}
+	}
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package beacon
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestClient_PubKey(t *testing.T) {
+	pubKey := phase0.BLSPubKey{0x1}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "/eth/v1/beacon/states/head/validators", r.URL.Path)
+		if r.URL.Query().Get("id") != "7" {
+			fmt.Fprint(w, `{"data":[]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"data":[{"index":"7","status":"active_ongoing","validator":{"pubkey":"0x%s","exit_epoch":"18446744073709551615"}}]}`,
+			hex.EncodeToString(pubKey[:]))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := New(zap.NewNop(), server.Client(), server.URL)
+
+	// Expect the public key to be fetched and then cached.
+	for i := 0; i < 2; i++ {
+		resolved, err := client.PubKey(ctx, 7)
+		require.NoError(t, err)
+		require.Equal(t, pubKey, resolved)
+	}
+	require.Equal(t, 1, requests)
+
+	// Expect unknown validators to fail.
+	_, err := client.PubKey(ctx, 8)
+	require.ErrorIs(t, err, ErrUnknownValidator)
+}
@@ -0,0 +1,174 @@
+package protector
+
+import (
+	"context"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+)
+
+// Bundle is a block proposal of a key and the attestations accompanying it.
+type Bundle struct {
+	Proposal     *BundledProposal
+	Attestations []*BundledAttestation
+}
+
+// BundledProposal is the proposal of a Bundle.
+type BundledProposal struct {
+	SigningRoot phase0.Root
+	Slot        phase0.Slot
+}
+
+// BundledAttestation is an attestation of a Bundle.
+type BundledAttestation struct {
+	SigningRoot phase0.Root
+	Data        *phase0.AttestationData
+}
+
+// BundleCheck is the check of a Bundle, which is slashable if any of it's
+// proposal and attestations is, in which case none of them is recorded.
+type BundleCheck struct {
+	Check
+
+	// Proposal and Attestations are the checks of each, which are nil
+	// for those which weren't checked after one was found slashable.
+	Proposal     *Check   `json:"proposal,omitempty"`
+	Attestations []*Check `json:"attestations"`
+}
+
+// ProtectorBundler is a protector that checks bundles atomically.
+type ProtectorBundler interface {
+	Protector
+
+	// CheckBundle checks the proposal and attestations of a bundle under a
+	// single acquisition of the key, and records all of them in a single
+	// transaction if none of them is slashable, or none of them otherwise.
+	CheckBundle(ctx context.Context, network string, pubKey phase0.BLSPubKey, bundle *Bundle) (*BundleCheck, error)
+}
+
+func (p *protector) CheckBundle(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	bundle *Bundle,
+) (check *BundleCheck, err error) {
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
+	if bundle.Proposal == nil && len(bundle.Attestations) == 0 {
+		return nil, errors.New("bundle is empty")
+	}
+	defer p.timed(keyID{network, pubKey}, "CheckBundle")()
+	defer func() {
+		if err == nil {
+			p.checked(network, pubKey)
+		}
+	}()
+	prio := lowPriority
+	if bundle.Proposal != nil {
+		prio = highPriority
+	}
+	done, err := p.schedule(ctx, prio)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// Pending asynchronous attestations are saved first, and the key stays
+	// locked so that no others are acknowledged while the bundle is checked.
+	if p.async != nil {
+		k := p.asyncKey(keyID{network, pubKey})
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		conn, err := p.acquireActive(ctx, network, pubKey)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			err = p.release(err, conn)
+		}()
+		if err := p.flushAsync(k, conn); err != nil {
+			return nil, err
+		}
+		return p.checkBundle(conn, keyID{network, pubKey}, bundle)
+	}
+
+	conn, err := p.acquireActive(ctx, network, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return p.checkBundle(conn, keyID{network, pubKey}, bundle)
+}
+
+// checkBundle checks a bundle against the history in conn and against
+// itself, and saves it if none of it is slashable.
+func (p *protector) checkBundle(conn *kvpool.Conn, id keyID, bundle *Bundle) (*BundleCheck, error) {
+	result := &BundleCheck{Attestations: make([]*Check, len(bundle.Attestations))}
+	slashed := func(check *Check) (*BundleCheck, error) {
+		result.Check = *check
+		return result, nil
+	}
+
+	var proposals []*kv.Proposal
+	if bundle.Proposal != nil {
+		check, err := p.checkProposal(conn, id, bundle.Proposal.SigningRoot, bundle.Proposal.Slot)
+		if err != nil {
+			return nil, err
+		}
+		result.Proposal = check
+		if check.Slashable {
+			return slashed(check)
+		}
+		proposals = append(proposals, &kv.Proposal{Slot: bundle.Proposal.Slot, SigningRoot: bundle.Proposal.SigningRoot})
+	}
+
+	// Attestations are checked against the history, which doesn't have
+	// the bundle's yet, and then against those before them in the bundle.
+	records := make([]*kv.AttestationRecord, 0, len(bundle.Attestations))
+	for i, a := range bundle.Attestations {
+		if a == nil || a.Data == nil || a.Data.Source == nil || a.Data.Target == nil {
+			return nil, errors.Errorf("attestation %d is incomplete", i)
+		}
+		check, err := p.checkAttestation(conn, id, a.SigningRoot, a.Data)
+		if err != nil {
+			return nil, err
+		}
+		if !check.Slashable {
+			check = bundledConflict(records, a)
+		}
+		result.Attestations[i] = check
+		if check.Slashable {
+			return slashed(check)
+		}
+		records = append(records, newAttestationRecord(a.SigningRoot, a.Data))
+	}
+
+	defer p.timed(id, "SaveSigned")()
+	if err := conn.SaveSigned(proposals, records); err != nil {
+		return nil, errors.Wrap(err, "could not save bundle")
+	}
+	result.Check = *notSlashable()
+	return result, nil
+}
+
+// bundledConflict checks an attestation against the attestations
+// before it in it's bundle.
+func bundledConflict(records []*kv.AttestationRecord, a *BundledAttestation) *Check {
+	source, target := a.Data.Source.Epoch, a.Data.Target.Epoch
+	for _, r := range records {
+		switch {
+		case r.Target == target && r.SigningRoot != a.SigningRoot:
+			return slashable("Attestation is slashable as it is a double vote of another in the bundle at target epoch %d", target)
+		case source < r.Source && r.Target < target:
+			return slashable("Attestation is slashable as it is surrounding another in the bundle at target epoch %d", r.Target)
+		case r.Source < source && target < r.Target:
+			return slashable("Attestation is slashable as it is surrounded by another in the bundle at target epoch %d", r.Target)
+		}
+	}
+	return notSlashable()
+}
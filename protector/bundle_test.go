@@ -0,0 +1,77 @@
+package protector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBundle(t *testing.T) {
+	ctx := context.Background()
+	prtc := New(t.TempDir())
+	defer prtc.Close()
+	bundler := prtc.(ProtectorBundler)
+	attestation := func(source, target phase0.Epoch, root byte) *BundledAttestation {
+		return &BundledAttestation{
+			SigningRoot: phase0.Root{root},
+			Data: &phase0.AttestationData{
+				Source: &phase0.Checkpoint{Epoch: source},
+				Target: &phase0.Checkpoint{Epoch: target},
+			},
+		}
+	}
+	history := func() *History {
+		history, err := prtc.History(ctx, "mainnet", phase0.BLSPubKey{})
+		require.NoError(t, err)
+		return history
+	}
+
+	check, err := bundler.CheckBundle(ctx, "mainnet", phase0.BLSPubKey{}, &Bundle{
+		Proposal:     &BundledProposal{SigningRoot: phase0.Root{0x1}, Slot: 10},
+		Attestations: []*BundledAttestation{attestation(1, 2, 0x1)},
+	})
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	require.Len(t, history().Proposals, 1)
+	require.Len(t, history().Attestations, 1)
+
+	// Expect a slashable attestation to leave the proposal unrecorded.
+	check, err = bundler.CheckBundle(ctx, "mainnet", phase0.BLSPubKey{}, &Bundle{
+		Proposal:     &BundledProposal{SigningRoot: phase0.Root{0x1}, Slot: 11},
+		Attestations: []*BundledAttestation{attestation(2, 3, 0x1), attestation(1, 2, 0x2)},
+	})
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	require.False(t, check.Proposal.Slashable)
+	require.False(t, check.Attestations[0].Slashable)
+	require.True(t, check.Attestations[1].Slashable)
+	require.Len(t, history().Proposals, 1)
+	require.Len(t, history().Attestations, 1)
+
+	// Expect attestations to be checked against each other.
+	check, err = bundler.CheckBundle(ctx, "mainnet", phase0.BLSPubKey{}, &Bundle{
+		Attestations: []*BundledAttestation{attestation(3, 4, 0x1), attestation(2, 5, 0x1)},
+	})
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	require.Contains(t, check.Reason, "surrounding another in the bundle")
+	check, err = bundler.CheckBundle(ctx, "mainnet", phase0.BLSPubKey{}, &Bundle{
+		Attestations: []*BundledAttestation{attestation(3, 4, 0x1), attestation(3, 4, 0x2)},
+	})
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	require.Contains(t, check.Reason, "double vote of another in the bundle")
+	require.Len(t, history().Attestations, 1)
+
+	// Expect a slashable proposal to leave the attestations unchecked.
+	check, err = bundler.CheckBundle(ctx, "mainnet", phase0.BLSPubKey{}, &Bundle{
+		Proposal:     &BundledProposal{SigningRoot: phase0.Root{0x2}, Slot: 10},
+		Attestations: []*BundledAttestation{attestation(2, 3, 0x1)},
+	})
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	require.Nil(t, check.Attestations[0])
+	require.Len(t, history().Attestations, 1)
+}
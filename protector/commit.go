@@ -0,0 +1,227 @@
+package protector
+
+import (
+	"context"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+)
+
+// keyID identifies a public key in a network.
+type keyID struct {
+	network string
+	pubKey  phase0.BLSPubKey
+}
+
+// attestationRequest is an attestation check waiting for the next group commit.
+type attestationRequest struct {
+	signingRoot phase0.Root
+	data        *phase0.AttestationData
+	result      chan attestationResult
+}
+
+type attestationResult struct {
+	check *Check
+	err   error
+}
+
+// attestationQueue holds the attestation checks of a public key
+// which are waiting for the next group commit.
+type attestationQueue struct {
+	requests []*attestationRequest
+
+	// ctx is done once every queued request has given up waiting, so that
+	// the commit is admitted and acquires it's connection within the
+	// deadlines of it's callers.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiting int
+
+	// timer runs the commit once the commit interval elapses.
+	timer *time.Timer
+}
+
+// errClosed is returned by checks which arrive after the protector is closed.
+var errClosed = errors.New("protector is closed")
+
+// commitAttestation queues an attestation check for the next group commit
+// of the public key and waits for it's result.
+//
+// The first request to arrive at an empty queue schedules the commit, which
+// runs after the commit interval for as long as any of the requests
+// is still being waited for. This is safe, since recording an attestation
+// which ends up not being signed can only make future checks stricter.
+func (p *protector) commitAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+) (*Check, error) {
+	req := &attestationRequest{
+		signingRoot: signingRoot,
+		data:        data,
+		result:      make(chan attestationResult, 1),
+	}
+	id := keyID{network, pubKey}
+
+	p.queuesMu.Lock()
+	if p.closed {
+		p.queuesMu.Unlock()
+		return nil, errClosed
+	}
+	queue, ok := p.queues[id]
+	if !ok {
+		queue = &attestationQueue{}
+		queue.ctx, queue.cancel = context.WithCancel(context.Background())
+		p.queues[id] = queue
+		p.commits.Add(1)
+		queue.timer = time.AfterFunc(p.commitInterval, func() {
+			defer p.commits.Done()
+			p.commitQueue(id)
+		})
+	}
+	queue.requests = append(queue.requests, req)
+	queue.waiting++
+	p.queuesMu.Unlock()
+
+	select {
+	case res := <-req.result:
+		return res.check, res.err
+	case <-ctx.Done():
+		p.queuesMu.Lock()
+		queue.waiting--
+		if queue.waiting == 0 {
+			queue.cancel()
+		}
+		p.queuesMu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// flushCommits runs the scheduled group commits without waiting for the
+// commit interval, and waits for those already running. Further checks
+// are rejected with errClosed.
+func (p *protector) flushCommits() {
+	p.queuesMu.Lock()
+	p.closed = true
+	var due []keyID
+	for id, queue := range p.queues {
+		if queue.timer.Stop() {
+			due = append(due, id)
+		}
+	}
+	p.queuesMu.Unlock()
+
+	for _, id := range due {
+		p.commitQueue(id)
+		p.commits.Done()
+	}
+	p.commits.Wait()
+}
+
+// commitQueue checks the queued attestations of a public key in order of
+// arrival and saves the non-slashable ones in a single transaction.
+func (p *protector) commitQueue(id keyID) {
+	p.queuesMu.Lock()
+	queue := p.queues[id]
+	delete(p.queues, id)
+	p.queuesMu.Unlock()
+
+	defer queue.cancel()
+
+	results := make([]attestationResult, len(queue.requests))
+	defer func() {
+		for i, req := range queue.requests {
+			req.result <- results[i]
+		}
+	}()
+
+	ctx := queue.ctx
+	done, err := p.schedule(ctx, lowPriority)
+	if err != nil {
+		for i := range results {
+			results[i].err = err
+		}
+		return
+	}
+	defer done()
+
+	conn, err := p.acquireActive(ctx, id.network, id.pubKey)
+	if err != nil {
+		for i := range results {
+			results[i].err = err
+		}
+		return
+	}
+	defer func() {
+		if err := p.release(nil, conn); err != nil {
+			for i := range results {
+				if results[i].err == nil {
+					results[i] = attestationResult{err: err}
+				}
+			}
+		}
+	}()
+
+	var pending pendingAttestations
+	for i, req := range queue.requests {
+		// Checks only see saved attestations, so an attestation
+		// which might conflict with a pending one requires flushing first.
+		if pending.conflicts(req.data) {
+			pending.flush(conn, results)
+		}
+		check, err := p.checkAttestation(conn, id, req.signingRoot, req.data)
+		if err != nil || check.Slashable {
+			results[i] = attestationResult{check: check, err: err}
+			continue
+		}
+		pending.add(i, newAttestationRecord(req.signingRoot, req.data))
+	}
+	pending.flush(conn, results)
+}
+
+// pendingAttestations are checked attestations which are yet to be saved.
+type pendingAttestations struct {
+	indices []int
+	records []*kv.AttestationRecord
+}
+
+func (p *pendingAttestations) add(i int, record *kv.AttestationRecord) {
+	p.indices = append(p.indices, i)
+	p.records = append(p.records, record)
+}
+
+// conflicts reports whether data might be slashable with any of the pending
+// attestations. Only attestations that strictly advance both the source and
+// target epochs of every pending attestation are guaranteed not to be.
+func (p *pendingAttestations) conflicts(data *phase0.AttestationData) bool {
+	for _, r := range p.records {
+		if data.Source.Epoch < r.Source || data.Target.Epoch <= r.Target {
+			return true
+		}
+	}
+	return false
+}
+
+// flush saves the pending attestations in a single transaction and
+// sets their results accordingly.
+func (p *pendingAttestations) flush(conn *kvpool.Conn, results []attestationResult) {
+	if len(p.records) == 0 {
+		return
+	}
+	err := conn.SaveAttestations(p.records...)
+	for _, i := range p.indices {
+		if err != nil {
+			results[i] = attestationResult{
+				err: errors.Wrap(err, "could not save attestation history for validator public key"),
+			}
+		} else {
+			results[i] = attestationResult{check: notSlashable()}
+		}
+	}
+	*p = pendingAttestations{}
+}
@@ -0,0 +1,98 @@
+package protector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitAdmission(t *testing.T) {
+	ctx := context.Background()
+	prtc := New(t.TempDir(),
+		WithCommitInterval(time.Millisecond),
+		WithMaxConcurrentChecks(1),
+		WithMaxQueuedChecks(1),
+	)
+	defer prtc.Close()
+	s := prtc.(*protector).scheduler
+	attestation := func(source, target phase0.Epoch) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: source},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}
+	}
+	queued := func() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.waiting[lowPriority].Len() + s.waiting[highPriority].Len()
+	}
+
+	// Occupy the only slot.
+	require.NoError(t, s.acquire(ctx, highPriority))
+
+	// Expect a queued commit to give up with it's caller.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err := prtc.CheckAttestation(timeoutCtx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, attestation(1, 2))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Eventually(t, func() bool { return queued() == 0 }, time.Second, time.Millisecond)
+
+	// Fill the queue, and expect further commits to be rejected.
+	go func() {
+		if s.acquire(ctx, lowPriority) == nil {
+			s.release()
+		}
+	}()
+	require.Eventually(t, func() bool { return queued() == 1 }, time.Second, time.Millisecond)
+	_, err = prtc.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x2}, attestation(1, 2))
+	require.ErrorIs(t, err, ErrOverloaded)
+
+	// Expect neither attestation to have been saved.
+	s.release()
+	check, err := prtc.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x3}, attestation(1, 2))
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+}
+
+func TestCommitClose(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	prtc := New(dir, WithCommitInterval(time.Hour))
+	p := prtc.(*protector)
+	attestation := &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}
+
+	// Queue a commit which isn't due for an hour.
+	result := make(chan error, 1)
+	go func() {
+		check, err := prtc.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x1}, attestation)
+		if err == nil && check.Slashable {
+			err = errors.New(check.Reason)
+		}
+		result <- err
+	}()
+	require.Eventually(t, func() bool {
+		p.queuesMu.Lock()
+		defer p.queuesMu.Unlock()
+		return len(p.queues) == 1
+	}, time.Second, time.Millisecond)
+
+	// Expect closing to commit it, and to reject further checks.
+	require.NoError(t, prtc.Close())
+	require.NoError(t, <-result)
+	_, err := prtc.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x2}, attestation)
+	require.ErrorIs(t, err, errClosed)
+
+	// Expect the attestation to have been saved.
+	prtc = New(dir)
+	defer prtc.Close()
+	check, err := prtc.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{}, phase0.Root{0x2}, attestation)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+}
@@ -0,0 +1,140 @@
+// Package compactor keeps the databases of a pool compact in the background,
+// so that their size on disk stays bounded without a maintenance window.
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"go.uber.org/zap"
+)
+
+// Compactor periodically compacts the stores of a pool
+// which weren't modified for a while.
+type Compactor struct {
+	logger  *zap.Logger
+	pool    *kvpool.Pool
+	coldFor time.Duration
+
+	// deferred reports whether compaction should wait for the next interval.
+	deferred func() bool
+
+	// freezeAfter is how long stores must be cold for to be frozen
+	// instead of compacted, or zero if they aren't frozen.
+	freezeAfter time.Duration
+}
+
+// Option configures a Compactor.
+type Option func(*Compactor)
+
+// WithDeferral defers compaction to the next interval while deferred
+// returns true, such as while checks are slower than their objective.
+func WithDeferral(deferred func() bool) Option {
+	return func(c *Compactor) {
+		c.deferred = deferred
+	}
+}
+
+// WithFreezing freezes the stores which weren't modified within the last
+// freezeAfter (which should be longer than coldFor, so that they're
+// compacted first) instead of compacting them. See kvpool.Pool.Freeze.
+func WithFreezing(freezeAfter time.Duration) Option {
+	return func(c *Compactor) {
+		c.freezeAfter = freezeAfter
+	}
+}
+
+// New returns a Compactor of the stores in pool which weren't modified within
+// the last coldFor, which avoids delaying the checks of keys which are in use.
+func New(logger *zap.Logger, pool *kvpool.Pool, coldFor time.Duration, opts ...Option) *Compactor {
+	c := &Compactor{
+		logger:  logger,
+		pool:    pool,
+		coldFor: coldFor,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run compacts the cold stores every interval until ctx is done.
+func (c *Compactor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Compact(ctx); err != nil && ctx.Err() == nil {
+				c.logger.Error("failed to compact", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Compact compacts the cold stores of every network one at a time,
+// so that at most one key waits for compaction at any time.
+func (c *Compactor) Compact(ctx context.Context) error {
+	networks, err := c.pool.Networks()
+	if err != nil {
+		return err
+	}
+	var compacted, frozen int
+	var reclaimed int64
+	for _, network := range networks {
+		pubKeys, err := c.pool.PubKeys(network)
+		if err != nil {
+			return err
+		}
+		for _, pubKey := range pubKeys {
+			if c.deferred != nil && c.deferred() {
+				c.logger.Info("deferred compaction", zap.Int("compacted", compacted), zap.Int("frozen", frozen))
+				return nil
+			}
+			if c.freezeAfter > 0 {
+				freezing, err := c.pool.Freeze(ctx, network, pubKey, c.freezeAfter)
+				if err != nil {
+					return err
+				}
+				if freezing != nil {
+					frozen++
+					reclaimed += freezing.Before - freezing.After
+					c.logger.Debug("froze store",
+						zap.String("network", network),
+						zap.String("pub_key", fmt.Sprintf("%#x", pubKey)),
+						zap.Int64("before", freezing.Before),
+						zap.Int64("after", freezing.After),
+					)
+					continue
+				}
+			}
+			compaction, err := c.pool.Compact(ctx, network, pubKey, c.coldFor)
+			if err != nil {
+				return err
+			}
+			if compaction == nil {
+				continue
+			}
+			compacted++
+			reclaimed += compaction.Before - compaction.After
+			c.logger.Debug("compacted store",
+				zap.String("network", network),
+				zap.String("pub_key", fmt.Sprintf("%#x", pubKey)),
+				zap.Int64("before", compaction.Before),
+				zap.Int64("after", compaction.After),
+			)
+		}
+	}
+	if compacted > 0 || frozen > 0 {
+		c.logger.Info("compacted stores",
+			zap.Int("count", compacted),
+			zap.Int("frozen", frozen),
+			zap.Int64("reclaimed_bytes", reclaimed),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Package conformance replays the slashing protection interchange test vectors
+// (https://github.com/eth-clients/slashing-protection-interchange-tests)
+// against a protector, to check its conformance with EIP-3076.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/interchange"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// network is the network which test vectors are replayed on.
+const network = "conformance"
+
+// Test is a test vector.
+type Test struct {
+	Name                  string  `json:"name"`
+	GenesisValidatorsRoot string  `json:"genesis_validators_root"`
+	Steps                 []*Step `json:"steps"`
+}
+
+// Step imports an interchange, and then checks blocks and attestations.
+type Step struct {
+	ShouldSucceed         bool                     `json:"should_succeed"`
+	ContainsSlashableData bool                     `json:"contains_slashable_data"`
+	Interchange           *interchange.Interchange `json:"interchange"`
+	Blocks                []*Block                 `json:"blocks"`
+	Attestations          []*Attestation           `json:"attestations"`
+}
+
+// Expectation is whether a block or attestation should be signed.
+//
+// ShouldSucceedComplete is set where implementations which keep the complete
+// history (such as this one) may sign messages which minimal ones refuse.
+type Expectation struct {
+	ShouldSucceed         bool  `json:"should_succeed"`
+	ShouldSucceedComplete *bool `json:"should_succeed_complete,omitempty"`
+}
+
+func (e Expectation) want() bool {
+	if e.ShouldSucceedComplete != nil {
+		return *e.ShouldSucceedComplete
+	}
+	return e.ShouldSucceed
+}
+
+type Block struct {
+	Expectation
+	PubKey      string `json:"pubkey"`
+	Slot        string `json:"slot"`
+	SigningRoot string `json:"signing_root"`
+}
+
+type Attestation struct {
+	Expectation
+	PubKey      string `json:"pubkey"`
+	SourceEpoch string `json:"source_epoch"`
+	TargetEpoch string `json:"target_epoch"`
+	SigningRoot string `json:"signing_root"`
+}
+
+// Load reads a test vector from a file.
+func Load(fileName string) (*Test, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var test Test
+	if err := json.Unmarshal(b, &test); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", fileName)
+	}
+	return &test, nil
+}
+
+// Mismatch is an outcome which differs from the expected one.
+type Mismatch struct {
+	Step int
+	What string
+	Want bool
+	Err  error
+}
+
+func (m *Mismatch) String() string {
+	outcome := func(succeeded bool) string {
+		if succeeded {
+			return "succeed"
+		}
+		return "fail"
+	}
+	s := fmt.Sprintf("step %d: %s should %s, but didn't", m.Step, m.What, outcome(m.Want))
+	if m.Err != nil {
+		s += fmt.Sprintf(" (%v)", m.Err)
+	}
+	return s
+}
+
+// Run replays the test against a new protector in dir,
+// and returns the outcomes which differ from the expected ones.
+func Run(ctx context.Context, dir string, test *Test) (mismatches []*Mismatch, err error) {
+	prtc := protector.New(dir, protector.WithPoolOptions(kvpool.WithDurability(kv.DurabilityNone, 0)))
+	defer func() {
+		err = multierr.Append(err, prtc.Close())
+	}()
+	pool := prtc.(protector.ProtectorPooler).Pool()
+
+	for i, step := range test.Steps {
+		importErr := importStep(ctx, pool, test, step)
+		if (importErr == nil) != step.ShouldSucceed {
+			mismatches = append(mismatches, &Mismatch{
+				Step: i,
+				What: "import",
+				Want: step.ShouldSucceed,
+				Err:  importErr,
+			})
+		}
+
+		for _, b := range step.Blocks {
+			pubKey, root, err := parseMessage(b.PubKey, b.SigningRoot)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid block in step %d", i)
+			}
+			slot, err := strconv.ParseUint(b.Slot, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid slot in step %d", i)
+			}
+			check, err := prtc.CheckProposal(ctx, network, pubKey, root, phase0.Slot(slot))
+			if err != nil {
+				return nil, err
+			}
+			if !check.Slashable != b.want() {
+				mismatches = append(mismatches, &Mismatch{
+					Step: i,
+					What: fmt.Sprintf("block at slot %s of %s", b.Slot, b.PubKey),
+					Want: b.want(),
+				})
+			}
+		}
+
+		for _, a := range step.Attestations {
+			pubKey, root, err := parseMessage(a.PubKey, a.SigningRoot)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid attestation in step %d", i)
+			}
+			source, err := strconv.ParseUint(a.SourceEpoch, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid source epoch in step %d", i)
+			}
+			target, err := strconv.ParseUint(a.TargetEpoch, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid target epoch in step %d", i)
+			}
+			check, err := prtc.CheckAttestation(ctx, network, pubKey, root, &phase0.AttestationData{
+				Source: &phase0.Checkpoint{Epoch: phase0.Epoch(source)},
+				Target: &phase0.Checkpoint{Epoch: phase0.Epoch(target)},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !check.Slashable != a.want() {
+				mismatches = append(mismatches, &Mismatch{
+					Step: i,
+					What: fmt.Sprintf("attestation (%s, %s) of %s", a.SourceEpoch, a.TargetEpoch, a.PubKey),
+					Want: a.want(),
+				})
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// importStep imports the interchange of a step, refusing
+// interchanges of another genesis validators root.
+func importStep(ctx context.Context, pool *kvpool.Pool, test *Test, step *Step) error {
+	if step.Interchange == nil {
+		return errors.New("missing interchange")
+	}
+	// Test vectors may be of a zero genesis validators root,
+	// which interchange.Import takes for an unknown one.
+	gvr, err := interchange.ParseRoot(test.GenesisValidatorsRoot)
+	if err != nil {
+		return errors.Wrap(err, "invalid genesis validators root of test")
+	}
+	if imported, err := interchange.ParseRoot(step.Interchange.Metadata.GenesisValidatorsRoot); err != nil || imported != gvr {
+		return errors.Errorf(
+			"genesis validators root %s doesn't match %s",
+			step.Interchange.Metadata.GenesisValidatorsRoot,
+			test.GenesisValidatorsRoot,
+		)
+	}
+	return interchange.Import(ctx, pool, network, gvr, step.Interchange)
+}
+
+func parseMessage(pubKeyHex, rootHex string) (phase0.BLSPubKey, phase0.Root, error) {
+	pubKey, err := interchange.ParsePubKey(pubKeyHex)
+	if err != nil {
+		return pubKey, phase0.Root{}, err
+	}
+	root, err := interchange.ParseRoot(rootHex)
+	return pubKey, root, err
+}
@@ -0,0 +1,35 @@
+package conformance
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	fileNames, err := filepath.Glob("testdata/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, fileNames)
+	for _, fileName := range fileNames {
+		test, err := Load(fileName)
+		require.NoError(t, err)
+		t.Run(test.Name, func(t *testing.T) {
+			mismatches, err := Run(context.Background(), t.TempDir(), test)
+			require.NoError(t, err)
+			require.Empty(t, mismatches)
+		})
+	}
+}
+
+func TestRun_Mismatch(t *testing.T) {
+	test, err := Load("testdata/single_validator_single_block.json")
+	require.NoError(t, err)
+	test.Steps[0].Blocks[0].ShouldSucceed = true
+
+	mismatches, err := Run(context.Background(), t.TempDir(), test)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	require.Contains(t, mismatches[0].String(), "block at slot 9")
+}
@@ -0,0 +1,103 @@
+package protector
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// DuplicateKey is a public key which was checked on more than one network,
+// which almost always means a validator client is misconfigured to check
+// with the wrong network.
+type DuplicateKey struct {
+	PubKey phase0.BLSPubKey
+
+	// Networks are the networks the key was checked on within the window.
+	Networks []string
+
+	// Time is when it was detected.
+	Time time.Time
+}
+
+// ProtectorDuplicateDetector is a protector that detects public keys
+// which are checked on more than one network.
+type ProtectorDuplicateDetector interface {
+	Protector
+
+	// DuplicateKeys returns the number of duplicate keys reported so far.
+	DuplicateKeys() int64
+}
+
+type duplicateDetector struct {
+	window time.Duration
+	report func(*DuplicateKey)
+
+	// checks are when each public key was last checked on each network,
+	// and reported is when each public key was last reported.
+	checks   map[phase0.BLSPubKey]map[string]time.Time
+	reported map[phase0.BLSPubKey]time.Time
+	mu       sync.Mutex
+
+	count int64
+}
+
+// WithDuplicateKeyDetection reports public keys which are checked on more than
+// one network within window, at most once per window for each key. report is
+// called after the check which detected it, and should return quickly.
+func WithDuplicateKeyDetection(window time.Duration, report func(*DuplicateKey)) Option {
+	return func(p *protector) {
+		p.duplicates = &duplicateDetector{
+			window:   window,
+			report:   report,
+			checks:   make(map[phase0.BLSPubKey]map[string]time.Time),
+			reported: make(map[phase0.BLSPubKey]time.Time),
+		}
+	}
+}
+
+// observe records a check, and returns the duplicate key
+// it detected if it's due to be reported, or nil.
+func (d *duplicateDetector) observe(network string, pubKey phase0.BLSPubKey, now time.Time) *DuplicateKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	networks, ok := d.checks[pubKey]
+	if !ok {
+		networks = make(map[string]time.Time, 1)
+		d.checks[pubKey] = networks
+	}
+	networks[network] = now
+	if len(networks) == 1 {
+		return nil
+	}
+
+	// Forget the networks which weren't checked within the window.
+	for n, t := range networks {
+		if now.Sub(t) > d.window {
+			delete(networks, n)
+		}
+	}
+	if len(networks) == 1 || now.Sub(d.reported[pubKey]) < d.window {
+		return nil
+	}
+	d.reported[pubKey] = now
+	atomic.AddInt64(&d.count, 1)
+
+	duplicate := &DuplicateKey{PubKey: pubKey, Time: now}
+	for n := range networks {
+		duplicate.Networks = append(duplicate.Networks, n)
+	}
+	sort.Strings(duplicate.Networks)
+	return duplicate
+}
+
+// DuplicateKeys returns the number of duplicate keys reported so far.
+func (p *protector) DuplicateKeys() int64 {
+	if p.duplicates == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&p.duplicates.count)
+}
@@ -0,0 +1,60 @@
+package protector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicateKeyDetection(t *testing.T) {
+	ctx := context.Background()
+	var reported []*DuplicateKey
+	prtc := New(t.TempDir(), WithDuplicateKeyDetection(time.Hour, func(d *DuplicateKey) {
+		reported = append(reported, d)
+	}))
+	defer prtc.Close()
+
+	// Expect nothing to be reported while keys are checked on a single network.
+	for _, pubKey := range []phase0.BLSPubKey{{0x1}, {0x2}} {
+		for slot := phase0.Slot(1); slot <= 2; slot++ {
+			_, err := prtc.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, slot)
+			require.NoError(t, err)
+		}
+	}
+	require.Empty(t, reported)
+
+	// Expect a key checked on another network to be reported once per window.
+	for slot := phase0.Slot(1); slot <= 2; slot++ {
+		_, err := prtc.CheckProposal(ctx, "holesky", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, slot)
+		require.NoError(t, err)
+	}
+	require.Len(t, reported, 1)
+	require.Equal(t, phase0.BLSPubKey{0x1}, reported[0].PubKey)
+	require.Equal(t, []string{"holesky", "mainnet"}, reported[0].Networks)
+	require.Equal(t, int64(1), prtc.(ProtectorDuplicateDetector).DuplicateKeys())
+}
+
+func TestDuplicateDetector_Window(t *testing.T) {
+	d := &duplicateDetector{
+		window:   time.Minute,
+		checks:   make(map[phase0.BLSPubKey]map[string]time.Time),
+		reported: make(map[phase0.BLSPubKey]time.Time),
+	}
+	pubKey := phase0.BLSPubKey{0x1}
+	now := time.Now()
+
+	// Expect networks which weren't checked within the window to be forgotten.
+	require.Nil(t, d.observe("mainnet", pubKey, now))
+	require.Nil(t, d.observe("holesky", pubKey, now.Add(2*time.Minute)))
+
+	// Expect reports to be repeated once the window passed.
+	require.NotNil(t, d.observe("mainnet", pubKey, now.Add(3*time.Minute)))
+	require.Nil(t, d.observe("holesky", pubKey, now.Add(3*time.Minute+30*time.Second)))
+	duplicate := d.observe("mainnet", pubKey, now.Add(4*time.Minute+30*time.Second))
+	require.NotNil(t, duplicate)
+	require.Equal(t, []string{"holesky", "mainnet"}, duplicate.Networks)
+	require.Equal(t, int64(2), d.count)
+}
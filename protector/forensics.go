@@ -0,0 +1,114 @@
+package protector
+
+import (
+	"fmt"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/forensics"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"go.uber.org/multierr"
+)
+
+// WithForensics records the evidence of every slashable check with r.
+func WithForensics(r *forensics.Recorder) Option {
+	return func(p *protector) {
+		p.forensics = r
+	}
+}
+
+// attestationForensics records the evidence of a slashable attestation check.
+// Must be called with the connection still acquired, so that the evidence is
+// what the check saw.
+func (p *protector) attestationForensics(
+	conn *kvpool.Conn,
+	id keyID,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+	check *Check,
+) {
+	if p.forensics == nil {
+		return
+	}
+	b := newBundle(id, forensics.KindAttestation, signingRoot, check)
+	b.Attestation = data
+
+	records, err := conn.AttestationHistory()
+	for _, r := range records {
+		surrounding := data.Source.Epoch < r.Source && data.Target.Epoch > r.Target
+		surrounded := data.Source.Epoch > r.Source && data.Target.Epoch < r.Target
+		if r.Target == data.Target.Epoch || surrounding || surrounded {
+			b.Attestations = append(b.Attestations, &forensics.Attestation{
+				SourceEpoch: r.Source,
+				TargetEpoch: r.Target,
+				SigningRoot: fmt.Sprintf("%#x", r.SigningRoot),
+			})
+		}
+	}
+	p.recordBundle(conn, b, err)
+}
+
+// proposalForensics records the evidence of a slashable proposal check.
+// Must be called with the connection still acquired.
+func (p *protector) proposalForensics(
+	conn *kvpool.Conn,
+	id keyID,
+	signingRoot phase0.Root,
+	slot phase0.Slot,
+	check *Check,
+) {
+	if p.forensics == nil {
+		return
+	}
+	b := newBundle(id, forensics.KindProposal, signingRoot, check)
+	b.Slot = &slot
+
+	existing, exists, err := conn.ProposalHistoryForSlot(slot)
+	if exists {
+		b.Proposals = append(b.Proposals, &forensics.Proposal{
+			Slot:        slot,
+			SigningRoot: fmt.Sprintf("%#x", existing),
+		})
+	}
+	p.recordBundle(conn, b, err)
+}
+
+func newBundle(id keyID, kind string, signingRoot phase0.Root, check *Check) *forensics.Bundle {
+	return &forensics.Bundle{
+		Network:     id.network,
+		PubKey:      fmt.Sprintf("%#x", id.pubKey),
+		Kind:        kind,
+		Reason:      check.Reason,
+		SigningRoot: fmt.Sprintf("%#x", signingRoot),
+	}
+}
+
+// recordBundle adds the watermarks to a bundle and records it,
+// noting err and any error reading the watermarks in the bundle.
+func (p *protector) recordBundle(conn *kvpool.Conn, b *forensics.Bundle, err error) {
+	w := &b.Watermarks
+	if source, ok, sourceErr := conn.LowestSignedSourceEpoch(); ok {
+		w.LowestSourceEpoch = &source
+	} else {
+		err = multierr.Append(err, sourceErr)
+	}
+	if target, ok, targetErr := conn.LowestSignedTargetEpoch(); ok {
+		w.LowestTargetEpoch = &target
+	} else {
+		err = multierr.Append(err, targetErr)
+	}
+	if slot, ok, slotErr := conn.LowestSignedProposal(); ok {
+		w.LowestSlot = &slot
+	} else {
+		err = multierr.Append(err, slotErr)
+	}
+	last, lastErr := conn.LastSigned()
+	if lastErr == nil {
+		w.HighestSourceEpoch = last.SourceEpoch
+		w.HighestTargetEpoch = last.TargetEpoch
+		w.HighestSlot = last.Slot
+	}
+	if err = multierr.Append(err, lastErr); err != nil {
+		b.Error = err.Error()
+	}
+	p.forensics.Record(b)
+}
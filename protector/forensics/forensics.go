@@ -0,0 +1,303 @@
+// Package forensics keeps evidence of slashable checks in a directory,
+// so that it survives log rotation.
+package forensics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	KindAttestation = "attestation"
+	KindProposal    = "proposal"
+)
+
+// Bundle is the evidence of a slashable check.
+type Bundle struct {
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+	PubKey  string    `json:"pubkey"`
+	Kind    string    `json:"kind"`
+	Reason  string    `json:"reason"`
+
+	// SigningRoot and either Attestation or Slot are the checked message.
+	SigningRoot string                  `json:"signing_root"`
+	Attestation *phase0.AttestationData `json:"attestation,omitempty"`
+	Slot        *phase0.Slot            `json:"slot,omitempty"`
+
+	// Attestations and Proposals are the signed messages
+	// which the checked message conflicts with.
+	Attestations []*Attestation `json:"conflicting_attestations,omitempty"`
+	Proposals    []*Proposal    `json:"conflicting_proposals,omitempty"`
+
+	Watermarks Watermarks `json:"watermarks"`
+
+	// Error is why the evidence is incomplete, if it is.
+	Error string `json:"error,omitempty"`
+}
+
+type Attestation struct {
+	SourceEpoch phase0.Epoch `json:"source_epoch"`
+	TargetEpoch phase0.Epoch `json:"target_epoch"`
+	SigningRoot string       `json:"signing_root"`
+}
+
+type Proposal struct {
+	Slot        phase0.Slot `json:"slot"`
+	SigningRoot string      `json:"signing_root"`
+}
+
+// Watermarks are the lowest and highest signed epochs and slots,
+// or nil if nothing was signed.
+type Watermarks struct {
+	LowestSourceEpoch  *phase0.Epoch `json:"lowest_source_epoch"`
+	LowestTargetEpoch  *phase0.Epoch `json:"lowest_target_epoch"`
+	HighestSourceEpoch *phase0.Epoch `json:"highest_source_epoch"`
+	HighestTargetEpoch *phase0.Epoch `json:"highest_target_epoch"`
+	LowestSlot         *phase0.Slot  `json:"lowest_slot"`
+	HighestSlot        *phase0.Slot  `json:"highest_slot"`
+}
+
+// Entry is a recorded bundle.
+type Entry struct {
+	Name string    `json:"name"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+}
+
+const (
+	// DefaultQueueSize is the default number of bundles waiting to be
+	// written, beyond which further bundles are dropped (see WithQueueSize).
+	DefaultQueueSize = 256
+
+	// DefaultMaxBundles is the default number of bundles kept (see WithMaxBundles).
+	DefaultMaxBundles = 10000
+)
+
+// Recorder writes bundles to a directory in the background, so that
+// slashable checks don't wait for them to be written.
+type Recorder struct {
+	logger     *zap.Logger
+	dir        string
+	queueSize  int
+	maxBundles int
+	maxAge     time.Duration
+
+	mu      sync.RWMutex
+	queue   chan *Bundle
+	closed  bool
+	done    chan struct{}
+	dropped int64
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithQueueSize sets the number of bundles waiting to be written, beyond
+// which further bundles are dropped and counted (see Dropped), so that
+// repeated slashable checks can't pile up in memory.
+func WithQueueSize(n int) Option {
+	return func(r *Recorder) {
+		r.queueSize = n
+	}
+}
+
+// WithMaxBundles keeps only the latest n bundles, or all of them if zero.
+func WithMaxBundles(n int) Option {
+	return func(r *Recorder) {
+		r.maxBundles = n
+	}
+}
+
+// WithMaxAge removes bundles older than d, or none if zero.
+func WithMaxAge(d time.Duration) Option {
+	return func(r *Recorder) {
+		r.maxAge = d
+	}
+}
+
+// New returns a Recorder which writes bundles to dir until it's closed.
+func New(logger *zap.Logger, dir string, opts ...Option) *Recorder {
+	r := newRecorder(logger, dir, opts...)
+	go r.run()
+	return r
+}
+
+func newRecorder(logger *zap.Logger, dir string, opts ...Option) *Recorder {
+	r := &Recorder{
+		logger:     logger,
+		dir:        dir,
+		queueSize:  DefaultQueueSize,
+		maxBundles: DefaultMaxBundles,
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.queue = make(chan *Bundle, r.queueSize)
+	return r
+}
+
+// Record queues a bundle to be written, or drops it if the queue is full.
+// Failures are logged rather than returned, since they mustn't change
+// the outcome of the check.
+func (r *Recorder) Record(b *Bundle) {
+	if b.Time.IsZero() {
+		b.Time = time.Now()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.closed {
+		select {
+		case r.queue <- b:
+			return
+		default:
+		}
+	}
+	atomic.AddInt64(&r.dropped, 1)
+}
+
+// Dropped returns the number of bundles dropped since the queue was full.
+func (r *Recorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Close writes the queued bundles and stops recording.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+// run writes the queued bundles and removes the expired ones, logging
+// how many were dropped meanwhile, until the recorder is closed.
+func (r *Recorder) run() {
+	defer close(r.done)
+	r.removeExpired()
+	var logged int64
+	for b := range r.queue {
+		name := fmt.Sprintf(
+			"%s-%s-%s-%s.json",
+			b.Time.UTC().Format("20060102T150405.000000000Z"),
+			b.Network,
+			strings.TrimPrefix(b.PubKey, "0x"),
+			b.Kind,
+		)
+		if err := r.write(name, b); err != nil {
+			r.logger.Error("failed to record forensics bundle", zap.String("name", name), zap.Error(err))
+		} else {
+			r.logger.Warn("recorded forensics bundle of slashable check", zap.String("name", name))
+		}
+		if dropped := r.Dropped(); dropped > logged {
+			r.logger.Warn("dropped forensics bundles since the queue was full", zap.Int64("dropped", dropped-logged))
+			logged = dropped
+		}
+		r.removeExpired()
+	}
+}
+
+// removeExpired removes the bundles beyond the latest maxBundles
+// or older than maxAge.
+func (r *Recorder) removeExpired() {
+	if r.maxBundles <= 0 && r.maxAge <= 0 {
+		return
+	}
+	entries, err := r.List()
+	if err != nil {
+		r.logger.Error("failed to list forensics bundles", zap.Error(err))
+		return
+	}
+	for i, e := range entries {
+		expired := r.maxAge > 0 && time.Since(e.Time) > r.maxAge
+		if expired || (r.maxBundles > 0 && i >= r.maxBundles) {
+			if err := os.Remove(filepath.Join(r.dir, e.Name)); err != nil && !os.IsNotExist(err) {
+				r.logger.Error("failed to remove forensics bundle", zap.String("name", e.Name), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(name string, b *Bundle) (err error) {
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return errors.Wrap(err, "failed to create forensics directory")
+	}
+	f, err := os.CreateTemp(r.dir, name+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create bundle")
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return errors.Wrap(err, "failed to write bundle")
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync bundle")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to close bundle")
+	}
+	return errors.Wrap(os.Rename(f.Name(), filepath.Join(r.dir, name)), "failed to rename bundle")
+}
+
+// List returns the recorded bundles, most recent first.
+func (r *Recorder) List() ([]Entry, error) {
+	dirEntries, err := os.ReadDir(r.dir)
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		if !validName(d.Name()) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Name: d.Name(), Size: info.Size(), Time: info.ModTime()})
+	}
+	// Names begin with the time of the check.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name > entries[j].Name
+	})
+	return entries, nil
+}
+
+// Open opens a recorded bundle by name. It returns an error
+// satisfying os.IsNotExist if there's no such bundle.
+func (r *Recorder) Open(name string) (*os.File, error) {
+	if !validName(name) {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(filepath.Join(r.dir, name))
+}
+
+// validName reports whether name is the name of a bundle,
+// rather than a temporary file or a path outside the directory.
+func validName(name string) bool {
+	return filepath.Base(name) == name && strings.HasSuffix(name, ".json")
+}
@@ -0,0 +1,62 @@
+package forensics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRecorder_Overflow(t *testing.T) {
+	// Queue bundles without writing them, and expect
+	// those beyond the queue's size to be dropped.
+	dir := t.TempDir()
+	r := newRecorder(zap.NewNop(), dir, WithQueueSize(2))
+	for i := 0; i < 5; i++ {
+		r.Record(&Bundle{Network: "mainnet", PubKey: "0x01", Kind: KindProposal})
+	}
+	require.Equal(t, int64(3), r.Dropped())
+
+	// Expect the queued bundles to be written when closing,
+	// and bundles recorded afterwards to be dropped.
+	go r.run()
+	r.Close()
+	entries, err := r.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	r.Record(&Bundle{Network: "mainnet", PubKey: "0x01", Kind: KindProposal})
+	require.Equal(t, int64(4), r.Dropped())
+}
+
+func TestRecorder_Retention(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), nil, 0600))
+
+	// Expect only the latest bundles to be kept.
+	r := New(zap.NewNop(), dir, WithMaxBundles(3))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		r.Record(&Bundle{Time: start.Add(time.Duration(i) * time.Second), Network: "mainnet", PubKey: "0x01", Kind: KindProposal})
+	}
+	r.Close()
+	entries, err := r.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Contains(t, entries[0].Name, start.Add(4*time.Second).UTC().Format("20060102T150405"))
+	require.Contains(t, entries[2].Name, start.Add(2*time.Second).UTC().Format("20060102T150405"))
+
+	// Expect expired bundles to be removed when starting,
+	// leaving other files alone.
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, entries[2].Name), old, old))
+	r = New(zap.NewNop(), dir, WithMaxAge(time.Hour))
+	r.Close()
+	entries, err = r.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	_, err = os.Stat(filepath.Join(dir, "other.txt"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,151 @@
+package interchange
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// Minimize converts an interchange to the minimal format, which only has the
+// watermarks of each public key: a block at the highest slot, and an
+// attestation at the highest source and target epochs, without signing roots.
+// Entries of the same public key are merged.
+func Minimize(interchange *Interchange) (*Interchange, error) {
+	merged, err := merge(interchange)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range merged.Data {
+		blocks, attestations := data.SignedBlocks, data.SignedAttestations
+		data.SignedBlocks = make([]*SignedBlock, 0, 1)
+		data.SignedAttestations = make([]*SignedAttestation, 0, 1)
+		if len(blocks) > 0 {
+			data.SignedBlocks = append(data.SignedBlocks, &SignedBlock{Slot: blocks[len(blocks)-1].Slot})
+		}
+		if len(attestations) > 0 {
+			var source uint64
+			for _, a := range attestations {
+				s, _ := strconv.ParseUint(a.SourceEpoch, 10, 64)
+				if s > source {
+					source = s
+				}
+			}
+			data.SignedAttestations = append(data.SignedAttestations, &SignedAttestation{
+				SourceEpoch: strconv.FormatUint(source, 10),
+				TargetEpoch: attestations[len(attestations)-1].TargetEpoch,
+			})
+		}
+	}
+	return merged, nil
+}
+
+// Complete converts an interchange to the complete format, with a single entry
+// per public key whose blocks and attestations are ordered and deduplicated.
+// Records which were dropped from a minimal interchange can't be recovered,
+// so its watermarks are kept as signed blocks and attestations, which refuse
+// what the minimal interchange would refuse when imported.
+func Complete(interchange *Interchange) (*Interchange, error) {
+	return merge(interchange)
+}
+
+// merge returns a copy of an interchange with the entries of each public key
+// merged, and their blocks and attestations sorted by slot and target epoch.
+// Identical records are deduplicated, while conflicting ones are kept.
+func merge(interchange *Interchange) (*Interchange, error) {
+	merged := &Interchange{Metadata: interchange.Metadata}
+	byPubKey := make(map[phase0.BLSPubKey]*Data)
+	type block struct {
+		slot uint64
+		root string
+	}
+	type attestation struct {
+		source, target uint64
+		root           string
+	}
+	blocks := make(map[*Data]map[block]struct{})
+	attestations := make(map[*Data]map[attestation]struct{})
+	for _, d := range interchange.Data {
+		pubKey, err := ParsePubKey(d.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		data, ok := byPubKey[pubKey]
+		if !ok {
+			data = &Data{PubKey: fmt.Sprintf("%#x", pubKey)}
+			byPubKey[pubKey] = data
+			blocks[data] = make(map[block]struct{})
+			attestations[data] = make(map[attestation]struct{})
+			merged.Data = append(merged.Data, data)
+		}
+		for _, b := range d.SignedBlocks {
+			slot, err := strconv.ParseUint(b.Slot, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid slot of %s", d.PubKey)
+			}
+			root, err := ParseRoot(b.SigningRoot)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid signing root of %s", d.PubKey)
+			}
+			blocks[data][block{slot, formatRoot(root)}] = struct{}{}
+		}
+		for _, a := range d.SignedAttestations {
+			source, err := strconv.ParseUint(a.SourceEpoch, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid source epoch of %s", d.PubKey)
+			}
+			target, err := strconv.ParseUint(a.TargetEpoch, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid target epoch of %s", d.PubKey)
+			}
+			root, err := ParseRoot(a.SigningRoot)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid signing root of %s", d.PubKey)
+			}
+			attestations[data][attestation{source, target, formatRoot(root)}] = struct{}{}
+		}
+	}
+
+	for _, data := range merged.Data {
+		sortedBlocks := make([]block, 0, len(blocks[data]))
+		for b := range blocks[data] {
+			sortedBlocks = append(sortedBlocks, b)
+		}
+		sort.Slice(sortedBlocks, func(i, j int) bool {
+			if sortedBlocks[i].slot != sortedBlocks[j].slot {
+				return sortedBlocks[i].slot < sortedBlocks[j].slot
+			}
+			return sortedBlocks[i].root < sortedBlocks[j].root
+		})
+		data.SignedBlocks = make([]*SignedBlock, len(sortedBlocks))
+		for i, b := range sortedBlocks {
+			data.SignedBlocks[i] = &SignedBlock{Slot: strconv.FormatUint(b.slot, 10), SigningRoot: b.root}
+		}
+
+		sortedAttestations := make([]attestation, 0, len(attestations[data]))
+		for a := range attestations[data] {
+			sortedAttestations = append(sortedAttestations, a)
+		}
+		sort.Slice(sortedAttestations, func(i, j int) bool {
+			a, b := sortedAttestations[i], sortedAttestations[j]
+			if a.target != b.target {
+				return a.target < b.target
+			}
+			if a.source != b.source {
+				return a.source < b.source
+			}
+			return a.root < b.root
+		})
+		data.SignedAttestations = make([]*SignedAttestation, len(sortedAttestations))
+		for i, a := range sortedAttestations {
+			data.SignedAttestations[i] = &SignedAttestation{
+				SourceEpoch: strconv.FormatUint(a.source, 10),
+				TargetEpoch: strconv.FormatUint(a.target, 10),
+				SigningRoot: a.root,
+			}
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,58 @@
+package interchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	const pubKey = "0xa99a76ed7796f7be22d5b7e85deeb7c5677e88e511e0b337618f8c4eb61349b4bf2d153f649f7b53359fe8b94a38e44c"
+	const root = "0x0100000000000000000000000000000000000000000000000000000000000000"
+	metadata := Metadata{InterchangeFormatVersion: FormatVersion, GenesisValidatorsRoot: GenesisValidatorsRoots["mainnet"]}
+	in := &Interchange{
+		Metadata: metadata,
+		Data: []*Data{
+			{
+				PubKey:             pubKey,
+				SignedBlocks:       []*SignedBlock{{Slot: "12", SigningRoot: root}, {Slot: "10"}},
+				SignedAttestations: []*SignedAttestation{{SourceEpoch: "3", TargetEpoch: "4", SigningRoot: root}},
+			},
+			{
+				// A duplicate entry of the same key, in upper case.
+				PubKey:             "0xA99A76ED7796F7BE22D5B7E85DEEB7C5677E88E511E0B337618F8C4EB61349B4BF2D153F649F7B53359FE8B94A38E44C",
+				SignedBlocks:       []*SignedBlock{{Slot: "12", SigningRoot: root}},
+				SignedAttestations: []*SignedAttestation{{SourceEpoch: "1", TargetEpoch: "2"}, {SourceEpoch: "2", TargetEpoch: "6"}},
+			},
+		},
+	}
+
+	// Expect the entries to be merged, with their records ordered and deduplicated.
+	complete, err := Complete(in)
+	require.NoError(t, err)
+	require.Equal(t, &Interchange{
+		Metadata: metadata,
+		Data: []*Data{{
+			PubKey:       pubKey,
+			SignedBlocks: []*SignedBlock{{Slot: "10"}, {Slot: "12", SigningRoot: root}},
+			SignedAttestations: []*SignedAttestation{
+				{SourceEpoch: "1", TargetEpoch: "2"},
+				{SourceEpoch: "3", TargetEpoch: "4", SigningRoot: root},
+				{SourceEpoch: "2", TargetEpoch: "6"},
+			},
+		}},
+	}, complete)
+
+	// Expect only the watermarks to be kept.
+	minimal, err := Minimize(in)
+	require.NoError(t, err)
+	require.Equal(t, &Interchange{
+		Metadata: metadata,
+		Data: []*Data{{
+			PubKey:             pubKey,
+			SignedBlocks:       []*SignedBlock{{Slot: "12"}},
+			SignedAttestations: []*SignedAttestation{{SourceEpoch: "3", TargetEpoch: "6"}},
+		}},
+	}, minimal)
+	require.Empty(t, Validate(minimal, GenesisValidatorsRoots["mainnet"]))
+}
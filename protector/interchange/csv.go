@@ -0,0 +1,33 @@
+package interchange
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// csvHeader is the header of CSV exports, which have a row per signed block
+// or attestation, with the columns which don't apply to it left empty.
+var csvHeader = []string{"pubkey", "type", "slot", "source_epoch", "target_epoch", "signing_root"}
+
+// WriteCSV writes the history in the interchange as CSV,
+// for spreadsheets and BI tools.
+func WriteCSV(w io.Writer, interchange *Interchange) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, data := range interchange.Data {
+		for _, b := range data.SignedBlocks {
+			if err := cw.Write([]string{data.PubKey, "block", b.Slot, "", "", b.SigningRoot}); err != nil {
+				return err
+			}
+		}
+		for _, a := range data.SignedAttestations {
+			if err := cw.Write([]string{data.PubKey, "attestation", "", a.SourceEpoch, a.TargetEpoch, a.SigningRoot}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,23 @@
+package interchange
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, &Interchange{
+		Data: []*Data{{
+			PubKey:             "0x01",
+			SignedBlocks:       []*SignedBlock{{Slot: "10", SigningRoot: "0x02"}},
+			SignedAttestations: []*SignedAttestation{{SourceEpoch: "1", TargetEpoch: "2"}},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pubkey,type,slot,source_epoch,target_epoch,signing_root\n"+
+		"0x01,block,10,,,0x02\n"+
+		"0x01,attestation,,1,2,\n", buf.String())
+}
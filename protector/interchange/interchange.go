@@ -0,0 +1,380 @@
+// Package interchange implements the EIP-3076 slashing protection
+// interchange format (https://eips.ethereum.org/EIPS/eip-3076).
+package interchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// FormatVersion is the version of the interchange format.
+const FormatVersion = "5"
+
+// ErrGenesisValidatorsRootMismatch is returned when importing an interchange
+// of another chain than the one it's imported into.
+var ErrGenesisValidatorsRootMismatch = errors.New("genesis validators root doesn't match the network")
+
+// Interchange is a slashing protection interchange file.
+type Interchange struct {
+	Metadata Metadata `json:"metadata"`
+	Data     []*Data  `json:"data"`
+}
+
+type Metadata struct {
+	InterchangeFormatVersion string `json:"interchange_format_version"`
+	GenesisValidatorsRoot    string `json:"genesis_validators_root"`
+}
+
+// Data is the slashing protection history of a public key.
+type Data struct {
+	PubKey             string               `json:"pubkey"`
+	SignedBlocks       []*SignedBlock       `json:"signed_blocks"`
+	SignedAttestations []*SignedAttestation `json:"signed_attestations"`
+}
+
+type SignedBlock struct {
+	Slot        string `json:"slot"`
+	SigningRoot string `json:"signing_root,omitempty"`
+}
+
+type SignedAttestation struct {
+	SourceEpoch string `json:"source_epoch"`
+	TargetEpoch string `json:"target_epoch"`
+	SigningRoot string `json:"signing_root,omitempty"`
+}
+
+// Export returns the interchange of the given public keys, whose metadata
+// has the genesis validators root of the network, or a zero root if it's unknown.
+//
+// Exports are canonically ordered, by public key and by slot or by target and
+// source epochs, so that exports of the same histories are byte-identical
+// and can be diffed or checksummed.
+func Export(
+	ctx context.Context,
+	p protector.Protector,
+	network string,
+	genesisValidatorsRoot phase0.Root,
+	pubKeys []phase0.BLSPubKey,
+) (*Interchange, error) {
+	interchange := &Interchange{
+		Metadata: Metadata{
+			InterchangeFormatVersion: FormatVersion,
+			GenesisValidatorsRoot:    fmt.Sprintf("%#x", genesisValidatorsRoot),
+		},
+		Data: make([]*Data, 0, len(pubKeys)),
+	}
+	for _, pubKey := range sortedPubKeys(pubKeys) {
+		history, err := p.History(ctx, network, pubKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get history of %#x", pubKey)
+		}
+		sortHistory(history)
+		data := &Data{
+			PubKey:             fmt.Sprintf("%#x", pubKey),
+			SignedBlocks:       make([]*SignedBlock, len(history.Proposals)),
+			SignedAttestations: make([]*SignedAttestation, len(history.Attestations)),
+		}
+		for i, p := range history.Proposals {
+			data.SignedBlocks[i] = &SignedBlock{
+				Slot:        strconv.FormatUint(uint64(p.Slot), 10),
+				SigningRoot: formatRoot(p.SigningRoot),
+			}
+		}
+		for i, a := range history.Attestations {
+			data.SignedAttestations[i] = &SignedAttestation{
+				SourceEpoch: strconv.FormatUint(uint64(a.Source), 10),
+				TargetEpoch: strconv.FormatUint(uint64(a.Target), 10),
+				SigningRoot: formatRoot(a.SigningRoot),
+			}
+		}
+		interchange.Data = append(interchange.Data, data)
+	}
+	return interchange, nil
+}
+
+// sortedPubKeys returns a sorted copy of pubKeys.
+func sortedPubKeys(pubKeys []phase0.BLSPubKey) []phase0.BLSPubKey {
+	sorted := append([]phase0.BLSPubKey(nil), pubKeys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	return sorted
+}
+
+// sortHistory sorts the proposals of a history by slot, and it's
+// attestations by target and source epochs, and then by signing root.
+func sortHistory(history *protector.History) {
+	sort.Slice(history.Proposals, func(i, j int) bool {
+		a, b := history.Proposals[i], history.Proposals[j]
+		if a.Slot != b.Slot {
+			return a.Slot < b.Slot
+		}
+		return bytes.Compare(a.SigningRoot[:], b.SigningRoot[:]) < 0
+	})
+	sort.Slice(history.Attestations, func(i, j int) bool {
+		a, b := history.Attestations[i], history.Attestations[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return bytes.Compare(a.SigningRoot[:], b.SigningRoot[:]) < 0
+	})
+}
+
+// ExportMinimal returns the interchange of the given public keys in the
+// minimal format of EIP-3076: a block at the highest signed slot, and an
+// attestation at the highest signed source and target epochs of each key,
+// without signing roots. They're read from the watermarks of each key,
+// which are cheaper to read than histories, and are kept by histories
+// pruned below them. Keys are ordered like in Export.
+func ExportMinimal(
+	ctx context.Context,
+	p protector.Protector,
+	network string,
+	genesisValidatorsRoot phase0.Root,
+	pubKeys []phase0.BLSPubKey,
+) (*Interchange, error) {
+	interchange := &Interchange{
+		Metadata: Metadata{
+			InterchangeFormatVersion: FormatVersion,
+			GenesisValidatorsRoot:    fmt.Sprintf("%#x", genesisValidatorsRoot),
+		},
+		Data: make([]*Data, 0, len(pubKeys)),
+	}
+	for _, pubKey := range sortedPubKeys(pubKeys) {
+		last, err := p.LastSigned(ctx, network, pubKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get last signed of %#x", pubKey)
+		}
+		data := &Data{
+			PubKey:             fmt.Sprintf("%#x", pubKey),
+			SignedBlocks:       make([]*SignedBlock, 0, 1),
+			SignedAttestations: make([]*SignedAttestation, 0, 1),
+		}
+		if last.Slot != nil {
+			data.SignedBlocks = append(data.SignedBlocks, &SignedBlock{
+				Slot: strconv.FormatUint(uint64(*last.Slot), 10),
+			})
+		}
+		if last.SourceEpoch != nil && last.TargetEpoch != nil {
+			data.SignedAttestations = append(data.SignedAttestations, &SignedAttestation{
+				SourceEpoch: strconv.FormatUint(uint64(*last.SourceEpoch), 10),
+				TargetEpoch: strconv.FormatUint(uint64(*last.TargetEpoch), 10),
+			})
+		}
+		interchange.Data = append(interchange.Data, data)
+	}
+	return interchange, nil
+}
+
+// Import imports the history in the interchange into the stores in pool.
+// The interchange is validated before anything is imported. Records which
+// conflict with the history are imported as described in kv.Store.Import.
+//
+// Unless genesisValidatorsRoot is zero (when the network's is unknown),
+// an interchange of another genesis validators root is refused with
+// ErrGenesisValidatorsRootMismatch, since its history is of another chain.
+// That includes interchanges with a zero root, whose chain is unknown.
+//
+// Keys are imported in batches, after each of which the progress is
+// reported and persisted as configured by opts. Until each key is imported,
+// it's marked as being imported in pool, so that it's checks are refused,
+// and the import fails with kvpool.ErrImporting if another import of any
+// of the keys is underway.
+func Import(
+	ctx context.Context,
+	pool *kvpool.Pool,
+	network string,
+	genesisValidatorsRoot phase0.Root,
+	interchange *Interchange,
+	opts ...ImportOption,
+) error {
+	o := importOptions{batchSize: defaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if interchange.Metadata.InterchangeFormatVersion != FormatVersion {
+		return errors.Errorf(
+			"unsupported interchange format version %q",
+			interchange.Metadata.InterchangeFormatVersion,
+		)
+	}
+	gvr, err := ParseRoot(interchange.Metadata.GenesisValidatorsRoot)
+	if err != nil {
+		return errors.Wrap(err, "invalid genesis validators root")
+	}
+	if genesisValidatorsRoot != (phase0.Root{}) && gvr != genesisValidatorsRoot {
+		return errors.Wrapf(
+			ErrGenesisValidatorsRootMismatch,
+			"%#x isn't %#x of %s",
+			gvr, genesisValidatorsRoot, network,
+		)
+	}
+	histories, err := parseHistories(interchange)
+	if err != nil {
+		return err
+	}
+
+	var start int
+	var resumeFile, resumeDigest string
+	if o.resume {
+		resumeFile = progressFileName(pool, network)
+		if resumeDigest, err = digest(interchange); err != nil {
+			return errors.Wrap(err, "failed to digest interchange")
+		}
+		if start, err = readProgress(resumeFile, resumeDigest); err != nil {
+			return err
+		}
+	}
+	pending := make([]phase0.BLSPubKey, 0, len(histories)-start)
+	for _, h := range histories[start:] {
+		pending = append(pending, h.pubKey)
+	}
+	if err := pool.StartImport(network, pending); err != nil {
+		return err
+	}
+	defer func() {
+		pool.FinishImport(network, pending)
+	}()
+
+	for i := start; i < len(histories); i += o.batchSize {
+		end := i + o.batchSize
+		if end > len(histories) {
+			end = len(histories)
+		}
+		for _, h := range histories[i:end] {
+			conn, err := pool.Acquire(ctx, network, h.pubKey)
+			if err != nil {
+				return errors.Wrapf(err, "failed to acquire %#x", h.pubKey)
+			}
+			err = conn.Import(h.attestations, h.proposals)
+			if err = multierr.Append(err, conn.Release()); err != nil {
+				return errors.Wrapf(err, "failed to import %#x", h.pubKey)
+			}
+			pool.FinishImport(network, pending[:1])
+			pending = pending[1:]
+		}
+		if o.resume {
+			if err := writeProgress(resumeFile, resumeDigest, end); err != nil {
+				return err
+			}
+		}
+		if o.progress != nil {
+			o.progress(ImportProgress{Network: network, Imported: end, Total: len(histories)})
+		}
+	}
+	if o.resume {
+		if err := os.Remove(resumeFile); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to remove import progress")
+		}
+	}
+	return nil
+}
+
+// history is the parsed history of a public key in an interchange.
+type history struct {
+	pubKey       phase0.BLSPubKey
+	attestations []*kv.AttestationRecord
+	proposals    []*kv.Proposal
+}
+
+// parseHistories parses the history of every public key in the interchange.
+func parseHistories(interchange *Interchange) ([]history, error) {
+	histories := make([]history, len(interchange.Data))
+	for i, data := range interchange.Data {
+		h := &histories[i]
+		var err error
+		if h.pubKey, err = ParsePubKey(data.PubKey); err != nil {
+			return nil, err
+		}
+		for _, b := range data.SignedBlocks {
+			slot, err := strconv.ParseUint(b.Slot, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid slot of %s", data.PubKey)
+			}
+			p := &kv.Proposal{Slot: phase0.Slot(slot)}
+			if p.SigningRoot, err = ParseRoot(b.SigningRoot); err != nil {
+				return nil, errors.Wrapf(err, "invalid signing root of %s", data.PubKey)
+			}
+			h.proposals = append(h.proposals, p)
+		}
+		for _, a := range data.SignedAttestations {
+			source, err := strconv.ParseUint(a.SourceEpoch, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid source epoch of %s", data.PubKey)
+			}
+			target, err := strconv.ParseUint(a.TargetEpoch, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid target epoch of %s", data.PubKey)
+			}
+			if source > target {
+				return nil, errors.Errorf("source epoch %d is greater than target epoch %d of %s", source, target, data.PubKey)
+			}
+			r := &kv.AttestationRecord{Source: phase0.Epoch(source), Target: phase0.Epoch(target)}
+			if r.SigningRoot, err = ParseRoot(a.SigningRoot); err != nil {
+				return nil, errors.Wrapf(err, "invalid signing root of %s", data.PubKey)
+			}
+			h.attestations = append(h.attestations, r)
+		}
+	}
+	return histories, nil
+}
+
+// ParsePubKey decodes a hex-encoded public key.
+func ParsePubKey(s string) (pubKey phase0.BLSPubKey, err error) {
+	b, err := decodeHex(s, len(pubKey))
+	if err != nil {
+		return pubKey, errors.Wrap(err, "invalid public key")
+	}
+	copy(pubKey[:], b)
+	return pubKey, nil
+}
+
+// ParseRoot decodes a hex-encoded signing root,
+// or returns a zero root if it's empty.
+func ParseRoot(s string) (root phase0.Root, err error) {
+	if s == "" {
+		return root, nil
+	}
+	b, err := decodeHex(s, len(root))
+	if err != nil {
+		return root, errors.Wrap(err, "invalid signing root")
+	}
+	copy(root[:], b)
+	return root, nil
+}
+
+func decodeHex(s string, length int) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != length {
+		return nil, errors.Errorf("%q is not %d bytes long", s, length)
+	}
+	return b, nil
+}
+
+// formatRoot returns the hex encoding of root, or an empty string if it's zero,
+// since the signing roots of records imported without them are unknown.
+func formatRoot(root phase0.Root) string {
+	if root == (phase0.Root{}) {
+		return ""
+	}
+	return fmt.Sprintf("%#x", root)
+}
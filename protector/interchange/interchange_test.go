@@ -0,0 +1,80 @@
+package interchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport_Canonical(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+
+	pubKeys := []phase0.BLSPubKey{{0x3}, {0x1}, {0x2}}
+	for _, pubKey := range pubKeys {
+		conn, err := prtc.(protector.ProtectorPooler).Pool().Acquire(ctx, "mainnet", pubKey)
+		require.NoError(t, err)
+		for _, slot := range []phase0.Slot{300, 2, 10} {
+			require.NoError(t, conn.SaveProposal(slot, phase0.Root{byte(slot)}))
+		}
+		require.NoError(t, conn.Release())
+	}
+
+	// Expect exports to be ordered by public key and slot, regardless
+	// of the order of the public keys, and to be byte-identical.
+	exported, err := Export(ctx, prtc, "mainnet", phase0.Root{}, pubKeys)
+	require.NoError(t, err)
+	require.Len(t, exported.Data, 3)
+	for i, data := range exported.Data {
+		require.Equal(t, fmt.Sprintf("%#x", phase0.BLSPubKey{byte(i + 1)}), data.PubKey)
+		var slots []string
+		for _, b := range data.SignedBlocks {
+			slots = append(slots, b.Slot)
+		}
+		require.Equal(t, []string{"2", "10", "300"}, slots)
+	}
+	reexported, err := Export(ctx, prtc, "mainnet", phase0.Root{}, []phase0.BLSPubKey{{0x2}, {0x3}, {0x1}})
+	require.NoError(t, err)
+	var a, b, csvA, csvB bytes.Buffer
+	require.NoError(t, json.NewEncoder(&a).Encode(exported))
+	require.NoError(t, json.NewEncoder(&b).Encode(reexported))
+	require.Equal(t, a.Bytes(), b.Bytes())
+	require.NoError(t, WriteCSV(&csvA, exported))
+	require.NoError(t, WriteCSV(&csvB, reexported))
+	require.Equal(t, csvA.Bytes(), csvB.Bytes())
+}
+
+func TestSortHistory(t *testing.T) {
+	history := &protector.History{
+		Attestations: []*protector.AttestationRecord{
+			{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+			{Source: 1, Target: 3, SigningRoot: phase0.Root{0x1}},
+			{Source: 0, Target: 1},
+			{Source: 2, Target: 3, SigningRoot: phase0.Root{0x1}},
+		},
+		Proposals: []*protector.ProposalRecord{
+			{Slot: 5, SigningRoot: phase0.Root{0x2}},
+			{Slot: 1},
+			{Slot: 5, SigningRoot: phase0.Root{0x1}},
+		},
+	}
+	sortHistory(history)
+	require.Equal(t, []*protector.AttestationRecord{
+		{Source: 0, Target: 1},
+		{Source: 1, Target: 3, SigningRoot: phase0.Root{0x1}},
+		{Source: 2, Target: 3, SigningRoot: phase0.Root{0x1}},
+		{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+	}, history.Attestations)
+	require.Equal(t, []*protector.ProposalRecord{
+		{Slot: 1},
+		{Slot: 5, SigningRoot: phase0.Root{0x1}},
+		{Slot: 5, SigningRoot: phase0.Root{0x2}},
+	}, history.Proposals)
+}
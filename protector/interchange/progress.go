@@ -0,0 +1,125 @@
+package interchange
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+)
+
+// progressDirName is the directory in a pool's directory
+// which the progress of resumable imports is persisted in.
+const progressDirName = "imports"
+
+// defaultBatchSize is the number of keys imported between progress reports.
+const defaultBatchSize = 100
+
+// ImportProgress is the progress of an import.
+type ImportProgress struct {
+	Network string `json:"network"`
+
+	// Imported is the number of keys imported so far, including
+	// by the interrupted attempts which the import resumed.
+	Imported int `json:"imported"`
+	Total    int `json:"total"`
+}
+
+// ImportOption configures Import.
+type ImportOption func(*importOptions)
+
+type importOptions struct {
+	batchSize int
+	resume    bool
+	progress  func(ImportProgress)
+}
+
+// WithBatchSize sets the number of keys imported between
+// progress reports, which is 100 by default.
+func WithBatchSize(n int) ImportOption {
+	return func(o *importOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithResume persists the progress of the import in the pool's directory
+// after every batch, so that importing the same interchange again after an
+// interruption skips the keys which were already imported.
+func WithResume() ImportOption {
+	return func(o *importOptions) {
+		o.resume = true
+	}
+}
+
+// WithProgress calls report after every batch.
+func WithProgress(report func(ImportProgress)) ImportOption {
+	return func(o *importOptions) {
+		o.progress = report
+	}
+}
+
+// progressFile is the persisted progress of an import.
+type progressFile struct {
+	// Digest identifies the interchange, so that the progress
+	// of another one isn't mistaken for it's own.
+	Digest   string `json:"digest"`
+	Imported int    `json:"imported"`
+}
+
+// progressFileName returns the file which the progress of
+// resumable imports into a network is persisted in.
+func progressFileName(pool *kvpool.Pool, network string) string {
+	return filepath.Join(pool.Dir(), progressDirName, network+".json")
+}
+
+// digest returns a digest of an interchange, encoding one key at a
+// time rather than the whole interchange, which may be large.
+func digest(interchange *Interchange) (string, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	if err := enc.Encode(&interchange.Metadata); err != nil {
+		return "", err
+	}
+	for _, data := range interchange.Data {
+		if err := enc.Encode(data); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readProgress returns the number of keys of the interchange with the
+// given digest which were imported, or zero if another one was.
+func readProgress(fileName, digest string) (int, error) {
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read import progress")
+	}
+	var progress progressFile
+	if err := json.Unmarshal(b, &progress); err != nil || progress.Digest != digest {
+		return 0, nil
+	}
+	return progress.Imported, nil
+}
+
+// writeProgress persists the number of keys imported atomically.
+func writeProgress(fileName, digest string, imported int) error {
+	b, err := json.Marshal(&progressFile{Digest: digest, Imported: imported})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "failed to write import progress")
+	}
+	return errors.Wrap(os.Rename(tmp, fileName), "failed to write import progress")
+}
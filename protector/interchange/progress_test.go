@@ -0,0 +1,96 @@
+package interchange
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport_Resume(t *testing.T) {
+	ctx := context.Background()
+	pool := kvpool.New(t.TempDir())
+	defer pool.Close()
+
+	ic := &Interchange{Metadata: Metadata{InterchangeFormatVersion: FormatVersion}}
+	for i := 1; i <= 5; i++ {
+		ic.Data = append(ic.Data, &Data{
+			PubKey:       fmt.Sprintf("%#x", phase0.BLSPubKey{byte(i)}),
+			SignedBlocks: []*SignedBlock{{Slot: "1", SigningRoot: fmt.Sprintf("%#x", phase0.Root{0x1})}},
+		})
+	}
+
+	// Persist the progress of an interrupted import of the first 2 keys.
+	d, err := digest(ic)
+	require.NoError(t, err)
+	require.NoError(t, writeProgress(progressFileName(pool, "mainnet"), d, 2))
+
+	// Expect the import to resume after them, reporting every batch.
+	var reported []int
+	err = Import(ctx, pool, "mainnet", phase0.Root{}, ic, WithResume(), WithBatchSize(2), WithProgress(func(p ImportProgress) {
+		require.Equal(t, 5, p.Total)
+		reported = append(reported, p.Imported)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []int{4, 5}, reported)
+	pubKeys, err := pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []phase0.BLSPubKey{{0x3}, {0x4}, {0x5}}, pubKeys)
+	require.NoFileExists(t, progressFileName(pool, "mainnet"))
+
+	// Expect the progress of another interchange to be ignored.
+	require.NoError(t, writeProgress(progressFileName(pool, "mainnet"), d, 2))
+	ic.Data[0].SignedBlocks[0].Slot = "2"
+	require.NoError(t, Import(ctx, pool, "mainnet", phase0.Root{}, ic, WithResume()))
+	pubKeys, err = pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Len(t, pubKeys, 5)
+}
+
+func TestImport_Coordination(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	pool := prtc.(protector.ProtectorPooler).Pool()
+
+	ic := &Interchange{Metadata: Metadata{InterchangeFormatVersion: FormatVersion}}
+	for i := 1; i <= 4; i++ {
+		ic.Data = append(ic.Data, &Data{
+			PubKey:       fmt.Sprintf("%#x", phase0.BLSPubKey{byte(i)}),
+			SignedBlocks: []*SignedBlock{{Slot: "1", SigningRoot: fmt.Sprintf("%#x", phase0.Root{0x1})}},
+		})
+	}
+
+	// Expect the keys which weren't imported yet to refuse checks and other
+	// imports, and those which were to be checked against their history.
+	var reported int
+	err := Import(ctx, pool, "mainnet", phase0.Root{}, ic, WithBatchSize(2), WithProgress(func(p ImportProgress) {
+		if reported++; reported > 1 {
+			return
+		}
+		require.False(t, pool.Importing("mainnet", phase0.BLSPubKey{0x2}))
+		require.True(t, pool.Importing("mainnet", phase0.BLSPubKey{0x3}))
+		_, err := prtc.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x3}, phase0.Root{0x2}, 2)
+		require.ErrorIs(t, err, kvpool.ErrImporting)
+		check, err := prtc.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x2}, phase0.Root{0x2}, 1)
+		require.NoError(t, err)
+		require.True(t, check.Slashable)
+		err = Import(ctx, pool, "mainnet", phase0.Root{}, &Interchange{
+			Metadata: ic.Metadata,
+			Data:     ic.Data[3:],
+		})
+		require.ErrorIs(t, err, kvpool.ErrImporting)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 2, reported)
+
+	// Expect checks to be accepted once the import is done.
+	require.False(t, pool.Importing("mainnet", phase0.BLSPubKey{0x3}))
+	check, err := prtc.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x3}, phase0.Root{0x2}, 2)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+}
@@ -0,0 +1,29 @@
+package interchange
+
+import (
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+)
+
+// WritePrysm writes the history in the interchange into a new database with
+// Prysm's validator kv schema at fileName, which a Prysm validator client
+// uses directly, without importing the interchange.
+func WritePrysm(fileName string, interchange *Interchange) error {
+	genesisValidatorsRoot, err := ParseRoot(interchange.Metadata.GenesisValidatorsRoot)
+	if err != nil {
+		return errors.Wrap(err, "invalid genesis validators root")
+	}
+	histories, err := parseHistories(interchange)
+	if err != nil {
+		return err
+	}
+	prysmHistories := make([]*kv.PrysmHistory, len(histories))
+	for i, h := range histories {
+		prysmHistories[i] = &kv.PrysmHistory{
+			PubKey:       h.pubKey,
+			Attestations: h.attestations,
+			Proposals:    h.proposals,
+		}
+	}
+	return kv.WritePrysm(fileName, genesisValidatorsRoot, prysmHistories)
+}
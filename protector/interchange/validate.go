@@ -0,0 +1,156 @@
+package interchange
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// GenesisValidatorsRoots are the genesis validators roots of known networks.
+var GenesisValidatorsRoots = map[string]string{
+	"mainnet": "0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95",
+	"sepolia": "0xd8ea171f3c94aea21ebc42a1ed61052acf3f9209c00e4efbaaddac09ed9b8078",
+	"holesky": "0x9143aa7c615a7f7115e2b6aac319c03529df8242ae705fba9df39b79c59fa8b1",
+	"hoodi":   "0x212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f",
+}
+
+// KnownGenesisValidatorsRoot returns the genesis validators root
+// of a known network, or false if the network is unknown.
+func KnownGenesisValidatorsRoot(network string) (phase0.Root, bool) {
+	s, ok := GenesisValidatorsRoots[network]
+	if !ok {
+		return phase0.Root{}, false
+	}
+	root, err := ParseRoot(s)
+	if err != nil {
+		panic(err)
+	}
+	return root, true
+}
+
+// Issue is a violation of the interchange format,
+// or a contradiction within an interchange.
+type Issue struct {
+	// PubKey is the public key whose data has the issue,
+	// or empty if the issue is with the metadata.
+	PubKey  string
+	Message string
+}
+
+func (i *Issue) String() string {
+	if i.PubKey == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", i.PubKey, i.Message)
+}
+
+// Validate returns the issues of an interchange: invalid fields, double
+// proposals, and double or surround votes between its attestations. Unless
+// genesisValidatorsRoot is empty, it's also an issue if the interchange is
+// of another genesis validators root.
+func Validate(interchange *Interchange, genesisValidatorsRoot string) []*Issue {
+	var issues []*Issue
+	issuef := func(pubKey string, format string, args ...interface{}) {
+		issues = append(issues, &Issue{PubKey: pubKey, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if v := interchange.Metadata.InterchangeFormatVersion; v != FormatVersion {
+		issuef("", "unsupported interchange format version %q", v)
+	}
+	gvr, err := ParseRoot(interchange.Metadata.GenesisValidatorsRoot)
+	switch {
+	case err != nil || interchange.Metadata.GenesisValidatorsRoot == "":
+		issuef("", "invalid genesis validators root %q", interchange.Metadata.GenesisValidatorsRoot)
+	case genesisValidatorsRoot != "":
+		if want, err := ParseRoot(genesisValidatorsRoot); err != nil || gvr != want {
+			issuef("", "genesis validators root %#x doesn't match %s", gvr, genesisValidatorsRoot)
+		}
+	}
+
+	for _, data := range interchange.Data {
+		pubKey := data.PubKey
+		if _, err := ParsePubKey(pubKey); err != nil {
+			issuef(pubKey, "%v", err)
+		}
+
+		// Check for double proposals.
+		blocks := make(map[phase0.Slot]phase0.Root)
+		for _, b := range data.SignedBlocks {
+			slot, err := strconv.ParseUint(b.Slot, 10, 64)
+			if err != nil {
+				issuef(pubKey, "invalid slot %q", b.Slot)
+				continue
+			}
+			root, err := ParseRoot(b.SigningRoot)
+			if err != nil {
+				issuef(pubKey, "block at slot %d has %v", slot, err)
+				continue
+			}
+			if existing, ok := blocks[phase0.Slot(slot)]; ok && existing != root {
+				issuef(pubKey, "double proposal at slot %d", slot)
+			}
+			blocks[phase0.Slot(slot)] = root
+		}
+
+		// Check for double votes.
+		type vote struct{ source, target uint64 }
+		var votes []vote
+		sources := make(map[phase0.Epoch]phase0.Epoch)
+		roots := make(map[phase0.Epoch]phase0.Root)
+		for _, a := range data.SignedAttestations {
+			source, sourceErr := strconv.ParseUint(a.SourceEpoch, 10, 64)
+			target, targetErr := strconv.ParseUint(a.TargetEpoch, 10, 64)
+			if sourceErr != nil || targetErr != nil {
+				issuef(pubKey, "invalid epochs (%q, %q)", a.SourceEpoch, a.TargetEpoch)
+				continue
+			}
+			root, err := ParseRoot(a.SigningRoot)
+			if err != nil {
+				issuef(pubKey, "attestation (%d, %d) has %v", source, target, err)
+				continue
+			}
+			if source > target {
+				issuef(pubKey, "attestation (%d, %d) has a source epoch greater than its target epoch", source, target)
+				continue
+			}
+			t := phase0.Epoch(target)
+			if existing, ok := sources[t]; ok && (existing != phase0.Epoch(source) || roots[t] != root) {
+				issuef(pubKey, "double vote at target epoch %d", target)
+			}
+			sources[t], roots[t] = phase0.Epoch(source), root
+			votes = append(votes, vote{source, target})
+		}
+
+		// Check for surround votes: sorted by source epoch, an attestation is
+		// surrounded by one with a lower source epoch and a higher target epoch.
+		sort.Slice(votes, func(i, j int) bool {
+			if votes[i].source != votes[j].source {
+				return votes[i].source < votes[j].source
+			}
+			return votes[i].target < votes[j].target
+		})
+		var widest *vote // the vote with the highest target among lower sources
+		for i := 0; i < len(votes); {
+			// Check a group of votes with the same source epoch
+			// before considering them as surrounding ones.
+			j := i
+			for ; j < len(votes) && votes[j].source == votes[i].source; j++ {
+				if widest != nil && votes[j].target < widest.target {
+					issuef(
+						pubKey,
+						"attestation (%d, %d) is surrounded by (%d, %d)",
+						votes[j].source, votes[j].target, widest.source, widest.target,
+					)
+				}
+			}
+			for ; i < j; i++ {
+				if widest == nil || votes[i].target > widest.target {
+					widest = &votes[i]
+				}
+			}
+		}
+	}
+	return issues
+}
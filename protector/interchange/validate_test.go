@@ -0,0 +1,56 @@
+package interchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	const pubKey = "0xa99a76ed7796f7be22d5b7e85deeb7c5677e88e511e0b337618f8c4eb61349b4bf2d153f649f7b53359fe8b94a38e44c"
+	interchange := &Interchange{
+		Metadata: Metadata{
+			InterchangeFormatVersion: FormatVersion,
+			GenesisValidatorsRoot:    GenesisValidatorsRoots["mainnet"],
+		},
+		Data: []*Data{{
+			PubKey: pubKey,
+			SignedBlocks: []*SignedBlock{
+				{Slot: "10", SigningRoot: "0x0100000000000000000000000000000000000000000000000000000000000000"},
+				{Slot: "10", SigningRoot: "0x0100000000000000000000000000000000000000000000000000000000000000"},
+				{Slot: "11"},
+			},
+			SignedAttestations: []*SignedAttestation{
+				{SourceEpoch: "1", TargetEpoch: "2"},
+				{SourceEpoch: "2", TargetEpoch: "3"},
+				{SourceEpoch: "3", TargetEpoch: "4"},
+			},
+		}},
+	}
+
+	// Expect a valid interchange to have no issues.
+	require.Empty(t, Validate(interchange, GenesisValidatorsRoots["mainnet"]))
+	require.Empty(t, Validate(interchange, ""))
+
+	// Expect contradictions and mismatches to be issues.
+	interchange.Data[0].SignedBlocks = append(interchange.Data[0].SignedBlocks, &SignedBlock{Slot: "11", SigningRoot: "0x0200000000000000000000000000000000000000000000000000000000000000"})
+	interchange.Data[0].SignedAttestations = append(interchange.Data[0].SignedAttestations,
+		&SignedAttestation{SourceEpoch: "2", TargetEpoch: "4"},
+		&SignedAttestation{SourceEpoch: "0", TargetEpoch: "5"},
+		&SignedAttestation{SourceEpoch: "6", TargetEpoch: "5"},
+	)
+	var messages []string
+	for _, issue := range Validate(interchange, GenesisValidatorsRoots["sepolia"]) {
+		messages = append(messages, issue.String())
+	}
+	require.Equal(t, []string{
+		"genesis validators root " + GenesisValidatorsRoots["mainnet"] + " doesn't match " + GenesisValidatorsRoots["sepolia"],
+		pubKey + ": double proposal at slot 11",
+		pubKey + ": double vote at target epoch 4",
+		pubKey + ": attestation (6, 5) has a source epoch greater than its target epoch",
+		pubKey + ": attestation (1, 2) is surrounded by (0, 5)",
+		pubKey + ": attestation (2, 3) is surrounded by (0, 5)",
+		pubKey + ": attestation (2, 4) is surrounded by (0, 5)",
+		pubKey + ": attestation (3, 4) is surrounded by (0, 5)",
+	}, messages)
+}
@@ -0,0 +1,31 @@
+package keystore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestPBKDF2(t *testing.T) {
+	dk := pbkdf2.Key([]byte("password"), []byte("salt"), 1, 32, sha256.New)
+	require.Equal(t, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b", hex.EncodeToString(dk))
+	dk = pbkdf2.Key([]byte("password"), []byte("salt"), 2, 32, sha256.New)
+	require.Equal(t, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43", hex.EncodeToString(dk))
+}
+
+func TestScrypt(t *testing.T) {
+	// The test vectors of RFC 7914.
+	dk, err := scrypt.Key(nil, nil, 16, 1, 1, 64)
+	require.NoError(t, err)
+	require.Equal(t, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906", hex.EncodeToString(dk))
+	dk, err = scrypt.Key([]byte("password"), []byte("NaCl"), 1024, 8, 16, 64)
+	require.NoError(t, err)
+	require.Equal(t, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640", hex.EncodeToString(dk))
+
+	_, err = scrypt.Key(nil, nil, 15, 1, 1, 64)
+	require.Error(t, err)
+}
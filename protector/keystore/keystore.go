@@ -0,0 +1,212 @@
+// Package keystore decrypts EIP-2335 keystores of BLS secret keys.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrInvalidPassword is returned when decrypting a keystore with the wrong password.
+var ErrInvalidPassword = errors.New("invalid keystore password")
+
+// Version is the keystore version of EIP-2335.
+const Version = 4
+
+// Keystore is an EIP-2335 keystore.
+type Keystore struct {
+	Crypto struct {
+		KDF      module `json:"kdf"`
+		Checksum module `json:"checksum"`
+		Cipher   module `json:"cipher"`
+	} `json:"crypto"`
+	PubKey  hexBytes `json:"pubkey"`
+	Path    string   `json:"path"`
+	Version int      `json:"version"`
+}
+
+type module struct {
+	Function string          `json:"function"`
+	Params   json.RawMessage `json:"params"`
+	Message  hexBytes        `json:"message"`
+}
+
+type hexBytes []byte
+
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// Key is a decrypted secret key.
+type Key struct {
+	PubKey    phase0.BLSPubKey
+	SecretKey []byte
+
+	// Path is the derivation path of the key, if it's known.
+	Path string
+
+	// FileName is the keystore the key was decrypted from.
+	FileName string
+}
+
+// Decrypt decrypts the secret key of the keystore.
+//
+// Passwords are expected to already be NFKD normalized, as EIP-2335
+// requires, which ASCII passwords always are. Control characters are
+// stripped from them.
+func (k *Keystore) Decrypt(password string) ([]byte, error) {
+	if k.Version != Version {
+		return nil, errors.Errorf("unsupported keystore version %d", k.Version)
+	}
+	decryptionKey, err := k.deriveKey(normalizePassword(password))
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the password with the checksum before decrypting.
+	if k.Crypto.Checksum.Function != "sha256" {
+		return nil, errors.Errorf("unsupported checksum function %q", k.Crypto.Checksum.Function)
+	}
+	checksum := sha256.New()
+	checksum.Write(decryptionKey[16:32])
+	checksum.Write(k.Crypto.Cipher.Message)
+	if subtle.ConstantTimeCompare(checksum.Sum(nil), k.Crypto.Checksum.Message) != 1 {
+		return nil, ErrInvalidPassword
+	}
+
+	if k.Crypto.Cipher.Function != "aes-128-ctr" {
+		return nil, errors.Errorf("unsupported cipher function %q", k.Crypto.Cipher.Function)
+	}
+	var params struct {
+		IV hexBytes `json:"iv"`
+	}
+	if err := json.Unmarshal(k.Crypto.Cipher.Params, &params); err != nil {
+		return nil, errors.Wrap(err, "invalid cipher params")
+	}
+	if len(params.IV) != aes.BlockSize {
+		return nil, errors.Errorf("invalid cipher iv length %d", len(params.IV))
+	}
+	block, err := aes.NewCipher(decryptionKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	secretKey := make([]byte, len(k.Crypto.Cipher.Message))
+	cipher.NewCTR(block, params.IV).XORKeyStream(secretKey, k.Crypto.Cipher.Message)
+	return secretKey, nil
+}
+
+// deriveKey derives the decryption key from the password with the keystore's KDF.
+func (k *Keystore) deriveKey(password []byte) ([]byte, error) {
+	var params struct {
+		DKLen int      `json:"dklen"`
+		Salt  hexBytes `json:"salt"`
+
+		// Parameters of scrypt.
+		N int `json:"n"`
+		R int `json:"r"`
+		P int `json:"p"`
+
+		// Parameters of pbkdf2.
+		C   int    `json:"c"`
+		PRF string `json:"prf"`
+	}
+	if err := json.Unmarshal(k.Crypto.KDF.Params, &params); err != nil {
+		return nil, errors.Wrap(err, "invalid kdf params")
+	}
+	if params.DKLen < 32 {
+		return nil, errors.Errorf("kdf dklen %d is less than 32", params.DKLen)
+	}
+	switch k.Crypto.KDF.Function {
+	case "scrypt":
+		return scrypt.Key(password, params.Salt, params.N, params.R, params.P, params.DKLen)
+	case "pbkdf2":
+		if params.PRF != "hmac-sha256" {
+			return nil, errors.Errorf("unsupported pbkdf2 prf %q", params.PRF)
+		}
+		if params.C <= 0 {
+			return nil, errors.Errorf("invalid pbkdf2 c %d", params.C)
+		}
+		return pbkdf2.Key(password, params.Salt, params.C, params.DKLen, sha256.New), nil
+	}
+	return nil, errors.Errorf("unsupported kdf function %q", k.Crypto.KDF.Function)
+}
+
+// normalizePassword strips the C0 and C1 control characters and
+// Delete from a password, and returns it's UTF-8 encoding.
+func normalizePassword(password string) []byte {
+	return []byte(strings.Map(func(r rune) rune {
+		if r <= 0x1f || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, password))
+}
+
+// Load reads a keystore file.
+func Load(fileName string) (*Keystore, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var k Keystore
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrap(err, "invalid keystore")
+	}
+	return &k, nil
+}
+
+// LoadDir decrypts the keystores in a directory with the given password. JSON
+// files which aren't keystores (such as deposit data) are skipped, but a
+// keystore which can't be decrypted fails the whole load.
+func LoadDir(dir, password string) ([]*Key, error) {
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fileNames)
+
+	var keys []*Key
+	for _, fileName := range fileNames {
+		k, err := Load(fileName)
+		if err != nil || k.Version != Version || len(k.Crypto.Cipher.Message) == 0 {
+			continue
+		}
+		secretKey, err := k.Decrypt(password)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt %s", filepath.Base(fileName))
+		}
+		var pubKey phase0.BLSPubKey
+		if len(k.PubKey) != len(pubKey) {
+			return nil, errors.Errorf("invalid public key in %s", filepath.Base(fileName))
+		}
+		copy(pubKey[:], k.PubKey)
+		keys = append(keys, &Key{
+			PubKey:    pubKey,
+			SecretKey: secretKey,
+			Path:      k.Path,
+			FileName:  fileName,
+		})
+	}
+	return keys, nil
+}
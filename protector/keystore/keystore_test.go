@@ -0,0 +1,114 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The test vectors of EIP-2335.
+const (
+	pbkdf2Keystore = `{
+    "crypto": {
+        "kdf": {
+            "function": "pbkdf2",
+            "params": {
+                "dklen": 32,
+                "c": 262144,
+                "prf": "hmac-sha256",
+                "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+            },
+            "message": ""
+        },
+        "checksum": {
+            "function": "sha256",
+            "params": {},
+            "message": "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1"
+        },
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {
+                "iv": "264daa3f303d7259501c93d997d84fe6"
+            },
+            "message": "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad"
+        }
+    },
+    "description": "This is a test keystore that uses PBKDF2 to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/0/0",
+    "uuid": "64625def-3331-4eea-ab6f-782f3ed16a83",
+    "version": 4
+}`
+	scryptKeystore = `{
+    "crypto": {
+        "kdf": {
+            "function": "scrypt",
+            "params": {
+                "dklen": 32,
+                "n": 262144, "r": 8, "p": 1,
+                "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+            },
+            "message": ""
+        },
+        "checksum": {
+            "function": "sha256",
+            "params": {},
+            "message": "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484"
+        },
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {
+                "iv": "264daa3f303d7259501c93d997d84fe6"
+            },
+            "message": "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f"
+        }
+    },
+    "description": "This is a test keystore that uses PBKDF2 to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/0/0",
+    "uuid": "64625def-3331-4eea-ab6f-782f3ed16a83",
+    "version": 4
+}`
+
+	// testPassword is the NFKD normalized password of the test vectors.
+	testPassword = "testpassword\U0001F511"
+	testSecret   = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+)
+
+func TestKeystore_Decrypt(t *testing.T) {
+	dir := t.TempDir()
+	for name, keystore := range map[string]string{"pbkdf2": pbkdf2Keystore, "scrypt": scryptKeystore} {
+		fileName := filepath.Join(dir, name+".json")
+		require.NoError(t, os.WriteFile(fileName, []byte(keystore), 0600))
+		k, err := Load(fileName)
+		require.NoError(t, err)
+
+		// Expect control characters to be stripped from the password.
+		secretKey, err := k.Decrypt("\x7f" + testPassword + "\n")
+		require.NoError(t, err, name)
+		require.Equal(t, testSecret, hex.EncodeToString(secretKey), name)
+
+		_, err = k.Decrypt("wrong")
+		require.ErrorIs(t, err, ErrInvalidPassword, name)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keystore-0.json"), []byte(pbkdf2Keystore), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deposit_data.json"), []byte(`[{"pubkey": "9612"}]`), 0600))
+
+	// Expect files which aren't keystores to be skipped.
+	keys, err := LoadDir(dir, testPassword)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07", hex.EncodeToString(keys[0].PubKey[:]))
+	require.Equal(t, testSecret, hex.EncodeToString(keys[0].SecretKey))
+	require.Equal(t, "m/12381/60/0/0", keys[0].Path)
+
+	_, err = LoadDir(dir, "wrong")
+	require.ErrorIs(t, err, ErrInvalidPassword)
+}
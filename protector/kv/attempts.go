@@ -0,0 +1,141 @@
+package kv
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var attemptsBucket = []byte("attempts")
+
+// MaxAttempts is the number of the most recent attempts which are kept in a
+// store, so that a client which keeps retrying a slashable duty can't grow
+// it without bound.
+const MaxAttempts = 1000
+
+// AttemptKind is the kind of duty of an attempt.
+type AttemptKind byte
+
+const (
+	AttemptAttestation AttemptKind = iota + 1
+	AttemptProposal
+)
+
+func (k AttemptKind) String() string {
+	switch k {
+	case AttemptAttestation:
+		return "attestation"
+	case AttemptProposal:
+		return "proposal"
+	default:
+		return "unknown"
+	}
+}
+
+// Attempt is a slashable attempt to sign which was refused, and therefore
+// isn't part of the history.
+type Attempt struct {
+	Time        time.Time
+	Kind        AttemptKind
+	SigningRoot phase0.Root
+
+	// Slot is the slot of a proposal, and Source and
+	// Target are the epochs of an attestation.
+	Slot   phase0.Slot
+	Source phase0.Epoch
+	Target phase0.Epoch
+
+	// Reason is why the attempt was refused.
+	Reason string
+}
+
+// SaveAttempt records a refused attempt, removing the oldest attempts beyond
+// MaxAttempts. Attempts aren't part of the history, so recording them doesn't
+// count as a write to the store, such as for it's last activity.
+//
+// It therefore bypasses update, and doesn't increment the sequence either:
+// the sequence detects rollbacks of the history, which losing attempts can't
+// make any less strict, and tells replicas that there's history to copy,
+// which refused attempts (that a misbehaving client may keep retrying)
+// would otherwise have them copy for nothing.
+func (s *Store) SaveAttempt(a *Attempt) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(attemptsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := b.Put(uint64Bytes(seq), encodeAttempt(a)); err != nil {
+			return err
+		}
+		if seq <= MaxAttempts {
+			return nil
+		}
+		var expired [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= seq-MaxAttempts; k, _ = c.Next() {
+			expired = append(expired, k)
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Attempts returns the refused attempts, from oldest to newest.
+func (s *Store) Attempts() ([]*Attempt, error) {
+	var attempts []*Attempt
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(attemptsBucket).ForEach(func(k, v []byte) error {
+			a, err := decodeAttempt(v)
+			if err != nil {
+				return errors.Wrapf(err, "attempt %d", binary.BigEndian.Uint64(k))
+			}
+			attempts = append(attempts, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// attemptHeaderSize is the size of an encoded attempt without it's reason.
+const attemptHeaderSize = 1 + 8 + phase0.RootLength + 3*8
+
+func encodeAttempt(a *Attempt) []byte {
+	b := make([]byte, attemptHeaderSize+len(a.Reason))
+	b[0] = byte(a.Kind)
+	binary.BigEndian.PutUint64(b[1:], uint64(a.Time.UnixNano()))
+	copy(b[9:], a.SigningRoot[:])
+	values := b[9+phase0.RootLength:]
+	binary.BigEndian.PutUint64(values, uint64(a.Slot))
+	binary.BigEndian.PutUint64(values[8:], uint64(a.Source))
+	binary.BigEndian.PutUint64(values[16:], uint64(a.Target))
+	copy(b[attemptHeaderSize:], a.Reason)
+	return b
+}
+
+func decodeAttempt(v []byte) (*Attempt, error) {
+	if len(v) < attemptHeaderSize {
+		return nil, errors.Errorf("invalid length %d", len(v))
+	}
+	a := &Attempt{
+		Kind: AttemptKind(v[0]),
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(v[1:]))).UTC(),
+	}
+	copy(a.SigningRoot[:], v[9:])
+	values := v[9+phase0.RootLength:]
+	a.Slot = phase0.Slot(binary.BigEndian.Uint64(values))
+	a.Source = phase0.Epoch(binary.BigEndian.Uint64(values[8:]))
+	a.Target = phase0.Epoch(binary.BigEndian.Uint64(values[16:]))
+	a.Reason = string(v[attemptHeaderSize:])
+	return a, nil
+}
@@ -0,0 +1,292 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/spans"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SlashingKind is the kind of slashable offense an attestation commits.
+type SlashingKind int
+
+const (
+	NotSlashable SlashingKind = iota
+	DoubleVote
+	SurroundingVote
+	SurroundedVote
+)
+
+// AttestationRecord is a signed attestation.
+type AttestationRecord struct {
+	Source      phase0.Epoch
+	Target      phase0.Epoch
+	SigningRoot phase0.Root
+
+	// SourceRoot, TargetRoot and BeaconBlockRoot are the roots the
+	// attestation voted for, which are zero if they aren't known (such as
+	// for imported attestations, or those signed before they were kept).
+	SourceRoot      phase0.Root
+	TargetRoot      phase0.Root
+	BeaconBlockRoot phase0.Root
+}
+
+// hasVoteRoots returns whether any of the roots voted for is known.
+func (r *AttestationRecord) hasVoteRoots() bool {
+	return r.SourceRoot != (phase0.Root{}) || r.TargetRoot != (phase0.Root{}) || r.BeaconBlockRoot != (phase0.Root{})
+}
+
+// Conflict is a previously signed attestation which
+// an incoming attestation is slashable with.
+type Conflict struct {
+	Kind     SlashingKind
+	Existing *AttestationRecord
+}
+
+func (c *Conflict) Error() string {
+	switch c.Kind {
+	case DoubleVote:
+		return fmt.Sprintf(
+			"double vote found, existing attestation at target epoch %d with conflicting signing root %#x",
+			c.Existing.Target,
+			c.Existing.SigningRoot,
+		)
+	case SurroundingVote:
+		return fmt.Sprintf(
+			"attestation surrounds another with (source %d, target %d)",
+			c.Existing.Source,
+			c.Existing.Target,
+		)
+	case SurroundedVote:
+		return fmt.Sprintf(
+			"attestation is surrounded by another with (source %d, target %d)",
+			c.Existing.Source,
+			c.Existing.Target,
+		)
+	}
+	return "not slashable"
+}
+
+// LowestSignedSourceEpoch returns the lowest signed source epoch, if any.
+func (s *Store) LowestSignedSourceEpoch() (epoch phase0.Epoch, exists bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v, ok := getWatermark(tx, lowestSourceKey)
+		epoch, exists = phase0.Epoch(v), ok
+		return nil
+	})
+	return
+}
+
+// LowestSignedTargetEpoch returns the lowest signed target epoch, if any.
+func (s *Store) LowestSignedTargetEpoch() (epoch phase0.Epoch, exists bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v, ok := getWatermark(tx, lowestTargetKey)
+		epoch, exists = phase0.Epoch(v), ok
+		return nil
+	})
+	return
+}
+
+// SigningRootAtTargetEpoch returns the signing root of the attestation
+// signed at the given target epoch, if any.
+func (s *Store) SigningRootAtTargetEpoch(target phase0.Epoch) (root phase0.Root, exists bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		var record *AttestationRecord
+		record, exists = getAttestation(tx, target)
+		if exists {
+			root = record.SigningRoot
+		}
+		return nil
+	})
+	return
+}
+
+// AttestationAtTargetEpoch returns the attestation signed at
+// the given target epoch, or nil if there's none.
+func (s *Store) AttestationAtTargetEpoch(target phase0.Epoch) (record *AttestationRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		record, _ = getAttestation(tx, target)
+		return nil
+	})
+	return
+}
+
+// CheckSlashableAttestation checks whether an incoming attestation is a double
+// vote or a surround vote with any of the signed attestations. It returns
+// nil if the attestation isn't slashable.
+func (s *Store) CheckSlashableAttestation(
+	source, target phase0.Epoch,
+	signingRoot phase0.Root,
+) (conflict *Conflict, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		// Check for a double vote.
+		if existing, ok := getAttestation(tx, target); ok {
+			// If the existing signing root is empty, we always consider
+			// the incoming attestation as a double vote to be safe.
+			if existing.SigningRoot == (phase0.Root{}) || existing.SigningRoot != signingRoot {
+				conflict = &Conflict{Kind: DoubleVote, Existing: existing}
+				return nil
+			}
+		}
+
+		// Check for surround votes.
+		surround, existingTarget, err := spans.Check(tx, spans.Attestation{Source: source, Target: target})
+		if err != nil {
+			return errors.Wrap(err, "spans.Check")
+		}
+		existing, ok := getAttestation(tx, existingTarget)
+		if !ok {
+			existing = &AttestationRecord{Target: existingTarget}
+		}
+		switch surround {
+		case spans.Surrounding:
+			conflict = &Conflict{Kind: SurroundingVote, Existing: existing}
+		case spans.Surrounded:
+			conflict = &Conflict{Kind: SurroundedVote, Existing: existing}
+		}
+		return nil
+	})
+	return
+}
+
+// SaveAttestations saves attestations in a single transaction.
+func (s *Store) SaveAttestations(records ...*AttestationRecord) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return saveAttestations(tx, records...)
+	})
+}
+
+func saveAttestations(tx *bolt.Tx, records ...*AttestationRecord) error {
+	bucket := tx.Bucket(attestationsBucket)
+	atts := make([]spans.Attestation, len(records))
+	added := 0
+	for i, r := range records {
+		k := uint64Bytes(uint64(r.Target))
+		if bucket.Get(k) == nil {
+			added++
+		}
+		if err := bucket.Put(k, encodeAttestation(r)); err != nil {
+			return errors.Wrapf(err, "could not save attestation at target epoch %d", r.Target)
+		}
+		if err := lowerWatermark(tx, lowestSourceKey, uint64(r.Source)); err != nil {
+			return err
+		}
+		if err := lowerWatermark(tx, lowestTargetKey, uint64(r.Target)); err != nil {
+			return err
+		}
+		if err := raiseWatermark(tx, highestSourceKey, uint64(r.Source)); err != nil {
+			return err
+		}
+		if err := raiseWatermark(tx, highestTargetKey, uint64(r.Target)); err != nil {
+			return err
+		}
+		atts[i] = spans.Attestation{Source: r.Source, Target: r.Target}
+	}
+	if err := addCount(tx, attestationCountKey, added); err != nil {
+		return err
+	}
+	return spans.Update(tx, atts, func() ([]spans.Attestation, error) {
+		var all []spans.Attestation
+		err := forEachAttestation(tx, func(r *AttestationRecord) error {
+			all = append(all, spans.Attestation{Source: r.Source, Target: r.Target})
+			return nil
+		})
+		return all, err
+	})
+}
+
+// AttestationHistory returns all signed attestations, ordered by target epoch.
+func (s *Store) AttestationHistory() ([]*AttestationRecord, error) {
+	return s.AttestationHistorySince(0)
+}
+
+// AttestationHistorySince returns the signed attestations with a target epoch
+// at or after the given one, ordered by target epoch.
+func (s *Store) AttestationHistorySince(target phase0.Epoch) ([]*AttestationRecord, error) {
+	records := make([]*AttestationRecord, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(attestationsBucket).Cursor()
+		for k, v := c.Seek(uint64Bytes(uint64(target))); k != nil; k, v = c.Next() {
+			records = append(records, decodeAttestation(k, v))
+		}
+		return nil
+	})
+	return records, err
+}
+
+func forEachAttestation(tx *bolt.Tx, fn func(*AttestationRecord) error) error {
+	return tx.Bucket(attestationsBucket).ForEach(func(k, v []byte) error {
+		return fn(decodeAttestation(k, v))
+	})
+}
+
+func getAttestation(tx *bolt.Tx, target phase0.Epoch) (*AttestationRecord, bool) {
+	k := uint64Bytes(uint64(target))
+	v := tx.Bucket(attestationsBucket).Get(k)
+	if v == nil {
+		return nil, false
+	}
+	return decodeAttestation(k, v), true
+}
+
+// attestationSize is the size of an encoded attestation.
+const attestationSize = 8 + 4*phase0.RootLength
+
+// encodeAttestation encodes the source epoch and signing root of an
+// attestation, followed by the roots it voted for, which are zero if
+// they aren't known.
+func encodeAttestation(r *AttestationRecord) []byte {
+	b := make([]byte, attestationSize)
+	binary.BigEndian.PutUint64(b, uint64(r.Source))
+	roots := b[8:]
+	copy(roots, r.SigningRoot[:])
+	copy(roots[phase0.RootLength:], r.SourceRoot[:])
+	copy(roots[2*phase0.RootLength:], r.TargetRoot[:])
+	copy(roots[3*phase0.RootLength:], r.BeaconBlockRoot[:])
+	return b
+}
+
+func decodeAttestation(k, v []byte) *AttestationRecord {
+	r := &AttestationRecord{
+		Target: phase0.Epoch(binary.BigEndian.Uint64(k)),
+		Source: phase0.Epoch(binary.BigEndian.Uint64(v)),
+	}
+	roots := v[8:]
+	copy(r.SigningRoot[:], roots)
+	copy(r.SourceRoot[:], roots[phase0.RootLength:])
+	copy(r.TargetRoot[:], roots[2*phase0.RootLength:])
+	copy(r.BeaconBlockRoot[:], roots[3*phase0.RootLength:])
+	return r
+}
+
+// addVoteRoots migrates attestations encoded with only their source epoch and
+// signing root to the encoding with the roots voted for, which are unknown.
+func addVoteRoots(tx *bolt.Tx) error {
+	b := tx.Bucket(attestationsBucket)
+	var keys, values [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		if len(v) == attestationSize {
+			return nil
+		}
+		if len(v) != 8+phase0.RootLength {
+			return errors.Errorf("attestation at target %d has invalid size %d", binary.BigEndian.Uint64(k), len(v))
+		}
+		value := make([]byte, attestationSize)
+		copy(value, v)
+		keys = append(keys, append([]byte(nil), k...))
+		values = append(values, value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, k := range keys {
+		if err := b.Put(k, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/multierr"
+)
+
+// compactTxMaxSize bounds the size of the transactions which copy a
+// database during compaction, so that large histories don't have to fit in memory.
+const compactTxMaxSize = 64 << 20
+
+// Compaction is the result of compacting a store.
+type Compaction struct {
+	// Before and After are the sizes of the store's database
+	// file in bytes before and after compaction.
+	Before int64
+	After  int64
+}
+
+// Compact rewrites the database of the store in dir without its free pages,
+// and replaces it with the copy once the copy is complete. The replacement is
+// fsynced to dir, so that a crash can't bring back the database from before it.
+// The store must not be open, and is left as is on failure.
+func Compact(dir string) (*Compaction, error) {
+	path := filepath.Join(dir, DbFileName)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open database")
+	}
+	defer src.Close()
+
+	tmpPath := path + ".compact"
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to remove previous copy")
+	}
+	dst, err := bolt.Open(tmpPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create copy")
+	}
+	err = bolt.Compact(dst, src, compactTxMaxSize)
+	err = multierr.Append(err, dst.Close())
+	if err != nil {
+		return nil, multierr.Append(errors.Wrap(err, "failed to compact"), os.Remove(tmpPath))
+	}
+	compacted, err := os.Stat(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := src.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close database")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, errors.Wrap(err, "failed to replace database")
+	}
+	if err := syncDir(dir); err != nil {
+		return nil, errors.Wrap(err, "failed to sync directory")
+	}
+	return &Compaction{Before: info.Size(), After: compacted.Size()}, nil
+}
+
+// syncDir fsyncs the directory dir, which persists the renames made in it.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return multierr.Append(f.Sync(), f.Close())
+}
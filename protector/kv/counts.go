@@ -0,0 +1,62 @@
+package kv
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	attestationCountKey = []byte("attestation-count")
+	proposalCountKey    = []byte("proposal-count")
+)
+
+// Counts is the number of records of a store.
+type Counts struct {
+	Attestations int
+	Proposals    int
+
+	// Size is the size of the store's database in bytes.
+	Size int64
+}
+
+// Counts returns the number of records of the store, which are kept in it's
+// metadata and are therefore cheap to read regardless of the size of it's history.
+func (s *Store) Counts() (*Counts, error) {
+	counts := &Counts{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		attestations, _ := getUint64(meta.Get(attestationCountKey))
+		proposals, _ := getUint64(meta.Get(proposalCountKey))
+		counts.Attestations = int(attestations)
+		counts.Proposals = int(proposals)
+		counts.Size = tx.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// addCount adds delta to the count of records at key.
+func addCount(tx *bolt.Tx, key []byte, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	meta := tx.Bucket(metaBucket)
+	count, _ := getUint64(meta.Get(key))
+	return meta.Put(key, uint64Bytes(uint64(int64(count)+int64(delta))))
+}
+
+// countRecords sets the counts of records from the buckets they're in.
+func countRecords(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	attestations := tx.Bucket(attestationsBucket).Stats().KeyN
+	if err := meta.Put(attestationCountKey, uint64Bytes(uint64(attestations))); err != nil {
+		return err
+	}
+	proposals := tx.Bucket(proposalsBucket).Stats().KeyN
+	return meta.Put(proposalCountKey, uint64Bytes(uint64(proposals)))
+}
@@ -0,0 +1,73 @@
+package kv
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Digest is a deterministic summary of a store's history.
+type Digest struct {
+	// Root is a SHA-256 hash over the attestations, proposals and watermarks
+	// in key order, which is equal for stores with identical histories.
+	// Attestations are hashed by their EIP-3076 fields alone (their epochs and
+	// signing root), so that the vote roots which are only known for
+	// attestations checked locally don't tell apart an imported history from
+	// the same history signed locally.
+	Root         phase0.Root
+	Attestations int
+	Proposals    int
+}
+
+// attestationDigestSize is the size of the source epoch and signing root
+// which an encoded attestation starts with, before the vote roots.
+const attestationDigestSize = 8 + phase0.RootLength
+
+// Digest returns the digest of the store's history.
+func (s *Store) Digest() (*Digest, error) {
+	digest := &Digest{}
+	h := sha256.New()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{attestationsBucket, proposalsBucket, watermarksBucket} {
+			// Enclose every bucket with it's name and record count, so that
+			// records can't be mistaken for ones in another bucket.
+			n := 0
+			writeLengthPrefixed(h, name)
+			err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				if string(name) == string(attestationsBucket) {
+					v = v[:attestationDigestSize]
+				}
+				writeLengthPrefixed(h, k)
+				writeLengthPrefixed(h, v)
+				n++
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			h.Write(uint64Bytes(uint64(n)))
+			switch string(name) {
+			case string(attestationsBucket):
+				digest.Attestations = n
+			case string(proposalsBucket):
+				digest.Proposals = n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	copy(digest.Root[:], h.Sum(nil))
+	return digest, nil
+}
+
+func writeLengthPrefixed(w io.Writer, b []byte) {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(b)))
+	w.Write(prefix[:])
+	w.Write(b)
+}
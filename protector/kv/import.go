@@ -0,0 +1,63 @@
+package kv
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Import saves imported attestations and proposals in a single transaction.
+//
+// Unlike signed messages, imported ones may conflict with each other or with
+// the history (such as when importing an interchange file which contains
+// slashable data). A conflicting record is saved with an empty signing root,
+// so that neither of the conflicting messages can be signed again.
+func (s *Store) Import(attestations []*AttestationRecord, proposals []*Proposal) error {
+	return s.update(func(tx *bolt.Tx) error {
+		roots := make(map[phase0.Epoch]phase0.Root)
+		records := make([]*AttestationRecord, len(attestations))
+		for i, a := range attestations {
+			record := *a
+			existing, ok := roots[a.Target]
+			if !ok {
+				var r *AttestationRecord
+				if r, ok = getAttestation(tx, a.Target); ok {
+					existing = r.SigningRoot
+
+					// Keep the roots voted for by the signed attestation.
+					if existing == a.SigningRoot && !a.hasVoteRoots() {
+						record.SourceRoot, record.TargetRoot, record.BeaconBlockRoot = r.SourceRoot, r.TargetRoot, r.BeaconBlockRoot
+					}
+				}
+			}
+			if ok && (existing == (phase0.Root{}) || existing != a.SigningRoot) {
+				record.SigningRoot = phase0.Root{}
+			}
+			roots[a.Target] = record.SigningRoot
+			records[i] = &record
+		}
+		if err := saveAttestations(tx, records...); err != nil {
+			return errors.Wrap(err, "failed to save attestations")
+		}
+
+		slotRoots := make(map[phase0.Slot]phase0.Root)
+		bucket := tx.Bucket(proposalsBucket)
+		merged := make([]*Proposal, len(proposals))
+		for i, p := range proposals {
+			proposal := *p
+			existing, ok := slotRoots[p.Slot]
+			if !ok {
+				if v := bucket.Get(uint64Bytes(uint64(p.Slot))); v != nil {
+					copy(existing[:], v)
+					ok = true
+				}
+			}
+			if ok && (existing == (phase0.Root{}) || existing != p.SigningRoot) {
+				proposal.SigningRoot = phase0.Root{}
+			}
+			slotRoots[p.Slot] = proposal.SigningRoot
+			merged[i] = &proposal
+		}
+		return errors.Wrap(saveProposals(tx, merged...), "failed to save proposals")
+	})
+}
@@ -0,0 +1,246 @@
+// Package kv implements a bolt-backed store of a single validator's
+// slashing protection history, following EIP-3076 semantics.
+package kv
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/multierr"
+)
+
+// DbFileName is the name of the database file within a store's directory.
+const DbFileName = "protection.db"
+
+// ErrLocked is returned by Open when the lock of the database can't be
+// obtained within the timeout, which is usually transient.
+var ErrLocked = errors.New("cannot obtain database lock, database may be in use by another process")
+
+var (
+	metaBucket         = []byte("meta")
+	attestationsBucket = []byte("attestations")
+	proposalsBucket    = []byte("proposals")
+	watermarksBucket   = []byte("watermarks")
+
+	schemaVersionKey = []byte("schema-version")
+	sequenceKey      = []byte("sequence")
+	lastWriteKey     = []byte("last-write")
+
+	lowestSourceKey  = []byte("lowest-source")
+	lowestTargetKey  = []byte("lowest-target")
+	lowestSlotKey    = []byte("lowest-slot")
+	highestSourceKey = []byte("highest-source")
+	highestTargetKey = []byte("highest-target")
+	highestSlotKey   = []byte("highest-slot")
+)
+
+// Durability is how writes to a store are made durable.
+type Durability string
+
+const (
+	// DurabilityAlways fsyncs every write before it completes. This is the default.
+	DurabilityAlways Durability = "always"
+
+	// DurabilityGroup doesn't fsync writes, leaving it to the caller to
+	// fsync the database files periodically. Writes since the last fsync may
+	// be lost (or, since bolt relies on fsync to order its writes, may
+	// corrupt the database) if the machine crashes, but not if the process does.
+	DurabilityGroup Durability = "group"
+
+	// DurabilityNone never fsyncs writes, which is only suitable for tests.
+	DurabilityNone Durability = "none"
+)
+
+// Config configures how a store is opened.
+type Config struct {
+	Durability Durability
+	Tuning
+
+	// WatermarksOnly keeps only the latest attestation and proposal, pruning
+	// the rest of the history with every write, which refuses anything at or
+	// below them like EIP-3076's minimal interchange format does. It keeps
+	// stores small at the cost of their history, which is no longer
+	// available for forensics and exports.
+	WatermarksOnly bool
+}
+
+// Tuning are the bolt options of a store which only affect performance.
+type Tuning struct {
+	// FreelistType is the type of bolt's freelist, which is an array by default.
+	FreelistType bolt.FreelistType
+
+	// InitialMmapSize is the initial size of the database's memory map in
+	// bytes, which avoids remapping as the database grows up to it.
+	InitialMmapSize int
+
+	// Timeout is how long to wait for the lock of the database,
+	// or zero for a second.
+	Timeout time.Duration
+}
+
+// options returns the bolt options of the config.
+func (c Config) options() *bolt.Options {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	return &bolt.Options{
+		Timeout:         timeout,
+		NoSync:          c.Durability == DurabilityGroup || c.Durability == DurabilityNone,
+		FreelistType:    c.FreelistType,
+		InitialMmapSize: c.InitialMmapSize,
+	}
+}
+
+// Store is the slashing protection history of a single validator.
+type Store struct {
+	db   *bolt.DB
+	path string
+
+	// watermarksOnly prunes the history with every write. See Config.WatermarksOnly.
+	watermarksOnly bool
+}
+
+// Open opens or creates the store in the given directory.
+// If the directory contains a database created by Prysm's validator kv,
+// it is migrated into the new store first.
+func Open(dir string, cfg Config) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	db, err := bolt.Open(filepath.Join(dir, DbFileName), 0600, cfg.options())
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	s := &Store{db: db, path: dir, watermarksOnly: cfg.WatermarksOnly}
+	if err := s.migrate(); err != nil {
+		return nil, multierr.Append(errors.Wrap(err, "failed to migrate schema"), db.Close())
+	}
+	if err := s.migratePrysm(dir); err != nil {
+		return nil, multierr.Append(errors.Wrap(err, "failed to migrate Prysm database"), db.Close())
+	}
+	return s, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SchemaVersion returns the schema version of the store.
+func (s *Store) SchemaVersion() (version uint64, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		version = schemaVersion(tx)
+		return nil
+	})
+	return
+}
+
+// Sequence returns the number of write transactions committed to the store's
+// history, which only ever increases and therefore allows detecting rollbacks.
+// Recording refused attempts doesn't increment it, see SaveAttempt.
+func (s *Store) Sequence() (sequence uint64, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		sequence, _ = getUint64(tx.Bucket(metaBucket).Get(sequenceKey))
+		return nil
+	})
+	return
+}
+
+// ReadMeta returns the sequence of a store and when it was last written to
+// from a transaction of its database, for readers which don't open it as a
+// Store (such as replicas, which copy databases opened by their primary).
+func ReadMeta(tx *bolt.Tx) (sequence uint64, lastWrite time.Time) {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0, time.Time{}
+	}
+	sequence, _ = getUint64(meta.Get(sequenceKey))
+	if v, ok := getUint64(meta.Get(lastWriteKey)); ok {
+		lastWrite = time.Unix(0, int64(v))
+	}
+	return sequence, lastWrite
+}
+
+// update runs fn in a read-write transaction, increments the sequence
+// and records the time of the write. With Config.WatermarksOnly, it also
+// prunes everything but the latest attestation and proposal.
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if s.watermarksOnly {
+			if _, _, err := prune(tx, math.MaxUint64, math.MaxUint64); err != nil {
+				return errors.Wrap(err, "failed to prune")
+			}
+		}
+		meta := tx.Bucket(metaBucket)
+		sequence, _ := getUint64(meta.Get(sequenceKey))
+		if err := meta.Put(sequenceKey, uint64Bytes(sequence+1)); err != nil {
+			return err
+		}
+		return meta.Put(lastWriteKey, uint64Bytes(uint64(time.Now().UnixNano())))
+	})
+}
+
+// Backup writes a consistent copy of the store's database to the writer
+// returned by open, which is given the size of the copy.
+func (s *Store) Backup(open func(size int64) (io.Writer, error)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		w, err := open(tx.Size())
+		if err != nil {
+			return err
+		}
+		_, err = tx.WriteTo(w)
+		return err
+	})
+}
+
+// DatabasePath returns the directory of the store.
+func (s *Store) DatabasePath() string {
+	return s.path
+}
+
+// getWatermark returns the value of a watermark, if it exists.
+func getWatermark(tx *bolt.Tx, key []byte) (uint64, bool) {
+	return getUint64(tx.Bucket(watermarksBucket).Get(key))
+}
+
+// lowerWatermark sets a watermark to value if it's lower than the current one.
+func lowerWatermark(tx *bolt.Tx, key []byte, value uint64) error {
+	if current, ok := getWatermark(tx, key); ok && current <= value {
+		return nil
+	}
+	return tx.Bucket(watermarksBucket).Put(key, uint64Bytes(value))
+}
+
+// raiseWatermark sets a watermark to value if it's higher than the current one.
+func raiseWatermark(tx *bolt.Tx, key []byte, value uint64) error {
+	if current, ok := getWatermark(tx, key); ok && current >= value {
+		return nil
+	}
+	return tx.Bucket(watermarksBucket).Put(key, uint64Bytes(value))
+}
+
+func getUint64(v []byte) (uint64, bool) {
+	if len(v) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(v), true
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,448 @@
+package kv
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpen_SchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	// Expect a new store to be stamped with the current schema version.
+	store, err := Open(dir, Config{})
+	require.NoError(t, err)
+	version, err := store.SchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion, version)
+
+	// Stamp the store with a newer schema version.
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(schemaVersionKey, uint64Bytes(SchemaVersion+1))
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// Expect the store to be refused.
+	_, err = Open(dir, Config{})
+	require.ErrorContains(t, err, "is newer than the supported version")
+}
+
+func TestOpen_Tuning(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, Config{Tuning: Tuning{
+		FreelistType:    bolt.FreelistMapType,
+		InitialMmapSize: 1 << 20,
+		Timeout:         10 * time.Millisecond,
+	}})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.SaveProposal(1, phase0.Root{0x1}))
+
+	// Expect opening the locked store to time out.
+	start := time.Now()
+	_, err = Open(dir, Config{Tuning: Tuning{Timeout: 10 * time.Millisecond}})
+	require.ErrorIs(t, err, ErrLocked)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestStore_Import(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.SaveProposal(10, phase0.Root{0x1}))
+
+	// Expect conflicting records to be imported with an empty signing root.
+	err = store.Import([]*AttestationRecord{
+		{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}},
+		{Source: 1, Target: 2, SigningRoot: phase0.Root{0x2}},
+		{Source: 2, Target: 3, SigningRoot: phase0.Root{0x3}},
+		{Source: 2, Target: 3, SigningRoot: phase0.Root{0x3}},
+	}, []*Proposal{
+		{Slot: 10, SigningRoot: phase0.Root{0x2}},
+		{Slot: 11, SigningRoot: phase0.Root{0x3}},
+	})
+	require.NoError(t, err)
+	attestations, err := store.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*AttestationRecord{
+		{Source: 1, Target: 2},
+		{Source: 2, Target: 3, SigningRoot: phase0.Root{0x3}},
+	}, attestations)
+	proposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*Proposal{
+		{Slot: 10},
+		{Slot: 11, SigningRoot: phase0.Root{0x3}},
+	}, proposals)
+}
+
+func TestStore_AddVoteRoots(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, Config{})
+	require.NoError(t, err)
+	require.NoError(t, store.SaveAttestations(&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}, SourceRoot: phase0.Root{0x2}}))
+
+	// Downgrade the store to before attestations were encoded with the roots
+	// they voted for, with an attestation encoded as back then.
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		v := make([]byte, 8+phase0.RootLength)
+		binary.BigEndian.PutUint64(v, 3)
+		v[8] = 0x3
+		if err := tx.Bucket(attestationsBucket).Put(uint64Bytes(4), v); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(schemaVersionKey, uint64Bytes(3))
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// Expect the migration to encode it with unknown roots, and
+	// to leave the other attestation as it was.
+	store, err = Open(dir, Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(attestationsBucket).ForEach(func(_, v []byte) error {
+			require.Len(t, v, attestationSize)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	attestations, err := store.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*AttestationRecord{
+		{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}, SourceRoot: phase0.Root{0x2}},
+		{Source: 3, Target: 4, SigningRoot: phase0.Root{0x3}},
+	}, attestations)
+}
+
+func TestStore_VoteRoots(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	signed := &AttestationRecord{
+		Source:          1,
+		Target:          2,
+		SigningRoot:     phase0.Root{0x1},
+		SourceRoot:      phase0.Root{0x2},
+		TargetRoot:      phase0.Root{0x3},
+		BeaconBlockRoot: phase0.Root{0x4},
+	}
+	require.NoError(t, store.SaveAttestations(signed))
+
+	require.NoError(t, store.SaveAttestations(&AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x5}}))
+
+	// Expect importing a signed record again to keep it's roots.
+	require.NoError(t, store.Import([]*AttestationRecord{{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}}}, nil))
+	attestations, err := store.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*AttestationRecord{
+		signed,
+		{Source: 2, Target: 3, SigningRoot: phase0.Root{0x5}},
+	}, attestations)
+}
+
+func TestStore_Rollback(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.SaveAttestations(
+		&AttestationRecord{Source: 1, Target: 4, SigningRoot: phase0.Root{0x1}},
+		&AttestationRecord{Source: 5, Target: 6, SigningRoot: phase0.Root{0x1}},
+	))
+	require.NoError(t, store.SaveProposal(1, phase0.Root{0x1}))
+	require.NoError(t, store.SaveProposal(5, phase0.Root{0x1}))
+
+	// Expect only the latest records to be rolled back.
+	_, err = store.RollbackAttestation(4)
+	require.ErrorIs(t, err, ErrNotLatest)
+	_, err = store.RollbackProposal(1)
+	require.ErrorIs(t, err, ErrNotLatest)
+
+	removed, err := store.RollbackAttestation(6)
+	require.NoError(t, err)
+	require.Equal(t, &AttestationRecord{Source: 5, Target: 6, SigningRoot: phase0.Root{0x1}}, removed)
+	proposal, err := store.RollbackProposal(5)
+	require.NoError(t, err)
+	require.Equal(t, &Proposal{Slot: 5, SigningRoot: phase0.Root{0x1}}, proposal)
+
+	// Expect the watermarks and spans to be of the remaining history.
+	last, err := store.LastSigned()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(1), *last.SourceEpoch)
+	require.Equal(t, phase0.Epoch(4), *last.TargetEpoch)
+	require.Equal(t, phase0.Slot(1), *last.Slot)
+	conflict, err := store.CheckSlashableAttestation(5, 6, phase0.Root{0x2})
+	require.NoError(t, err)
+	require.Nil(t, conflict)
+	conflict, err = store.CheckSlashableAttestation(2, 3, phase0.Root{0x2})
+	require.NoError(t, err)
+	require.NotNil(t, conflict)
+	findings, err := store.Verify()
+	require.NoError(t, err)
+	require.Empty(t, findings)
+
+	// Expect rolling back the only records to allow signing them again,
+	// but nothing below them.
+	_, err = store.RollbackAttestation(4)
+	require.NoError(t, err)
+	_, err = store.RollbackProposal(1)
+	require.NoError(t, err)
+	last, err = store.LastSigned()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(3), *last.TargetEpoch)
+	require.Equal(t, phase0.Slot(0), *last.Slot)
+	target, _, err := store.LowestSignedTargetEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(3), target)
+	findings, err = store.Verify()
+	require.NoError(t, err)
+	require.Empty(t, findings)
+}
+
+func TestStore_RollbackPruned(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.SaveAttestations(
+		&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}},
+		&AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+		&AttestationRecord{Source: 3, Target: 4, SigningRoot: phase0.Root{0x3}},
+	))
+	require.NoError(t, store.SaveProposal(5, phase0.Root{0x1}))
+	require.NoError(t, store.SaveProposal(10, phase0.Root{0x1}))
+	_, err = store.Prune(100, 100)
+	require.NoError(t, err)
+
+	// Roll back the only records left by pruning.
+	_, err = store.RollbackAttestation(4)
+	require.NoError(t, err)
+	_, err = store.RollbackProposal(10)
+	require.NoError(t, err)
+
+	// Expect the pruned records to still be refused, and
+	// the rolled back ones to be allowed again.
+	source, ok, err := store.LowestSignedSourceEpoch()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, phase0.Epoch(3), source)
+	target, ok, err := store.LowestSignedTargetEpoch()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, phase0.Epoch(3), target)
+	slot, ok, err := store.LowestSignedProposal()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, phase0.Slot(9), slot)
+	last, err := store.LastSigned()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(3), *last.TargetEpoch)
+	require.Equal(t, phase0.Slot(9), *last.Slot)
+	findings, err := store.Verify()
+	require.NoError(t, err)
+	require.Empty(t, findings)
+}
+
+func TestStore_Counts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, Config{})
+	require.NoError(t, err)
+
+	// Expect overwritten records to be counted once, and rolled back ones not at all.
+	require.NoError(t, store.SaveAttestations(
+		&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}},
+		&AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+	))
+	require.NoError(t, store.Import([]*AttestationRecord{{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}}}, nil))
+	require.NoError(t, store.SaveProposal(10, phase0.Root{0x1}))
+	require.NoError(t, store.SaveProposal(11, phase0.Root{0x2}))
+	_, err = store.RollbackProposal(11)
+	require.NoError(t, err)
+	counts, err := store.Counts()
+	require.NoError(t, err)
+	require.Equal(t, 2, counts.Attestations)
+	require.Equal(t, 1, counts.Proposals)
+	require.Positive(t, counts.Size)
+
+	// Downgrade the store to before records were counted.
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if err := meta.Delete(attestationCountKey); err != nil {
+			return err
+		}
+		if err := meta.Delete(proposalCountKey); err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, uint64Bytes(1))
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// Expect the migration to count the existing records.
+	store, err = Open(dir, Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	counts, err = store.Counts()
+	require.NoError(t, err)
+	require.Equal(t, 2, counts.Attestations)
+	require.Equal(t, 1, counts.Proposals)
+}
+
+func TestStore_WatermarksOnly(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{WatermarksOnly: true})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Expect only the latest records to be kept, with the lowest
+	// watermarks raised to them.
+	require.NoError(t, store.SaveAttestations(
+		&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}},
+		&AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+	))
+	require.NoError(t, store.SaveAttestations(&AttestationRecord{Source: 3, Target: 5, SigningRoot: phase0.Root{0x3}}))
+	require.NoError(t, store.SaveProposal(10, phase0.Root{0x1}))
+	require.NoError(t, store.SaveProposal(12, phase0.Root{0x2}))
+	attestations, err := store.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*AttestationRecord{{Source: 3, Target: 5, SigningRoot: phase0.Root{0x3}}}, attestations)
+	proposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*Proposal{{Slot: 12, SigningRoot: phase0.Root{0x2}}}, proposals)
+	source, _, err := store.LowestSignedSourceEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(3), source)
+	target, _, err := store.LowestSignedTargetEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(5), target)
+	slot, _, err := store.LowestSignedProposal()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(12), slot)
+	counts, err := store.Counts()
+	require.NoError(t, err)
+	require.Equal(t, 1, counts.Attestations)
+	require.Equal(t, 1, counts.Proposals)
+
+	// Expect the spans to be rebuilt from the kept attestation.
+	conflict, err := store.CheckSlashableAttestation(4, 6, phase0.Root{0x4})
+	require.NoError(t, err)
+	require.Nil(t, conflict)
+
+	findings, err := store.Verify()
+	require.NoError(t, err)
+	require.Empty(t, findings)
+}
+
+func TestStore_Prune(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.SaveAttestations(
+		&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}},
+		&AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+		&AttestationRecord{Source: 3, Target: 4, SigningRoot: phase0.Root{0x3}},
+	))
+	require.NoError(t, store.SaveProposal(10, phase0.Root{0x1}))
+
+	// Expect the latest records to be kept regardless of the bounds.
+	pruning, err := store.Prune(3, 100)
+	require.NoError(t, err)
+	require.Equal(t, &Pruning{Attestations: 1}, pruning)
+	target, _, err := store.LowestSignedTargetEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(3), target)
+	proposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+
+	// Expect stores with nothing to prune not to be written to.
+	sequence, err := store.Sequence()
+	require.NoError(t, err)
+	pruning, err = store.Prune(3, 10)
+	require.NoError(t, err)
+	require.Equal(t, &Pruning{}, pruning)
+	after, err := store.Sequence()
+	require.NoError(t, err)
+	require.Equal(t, sequence, after)
+	findings, err := store.Verify()
+	require.NoError(t, err)
+	require.Empty(t, findings)
+}
+
+func TestStore_Attempts(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{Durability: DurabilityNone})
+	require.NoError(t, err)
+	defer store.Close()
+
+	attempts, err := store.Attempts()
+	require.NoError(t, err)
+	require.Empty(t, attempts)
+
+	// Expect attempts to be returned from oldest to newest.
+	now := time.Unix(1700000000, 0).UTC()
+	expected := []*Attempt{
+		{Time: now, Kind: AttemptProposal, SigningRoot: phase0.Root{0x1}, Slot: 10, Reason: "double proposal"},
+		{Time: now.Add(time.Second), Kind: AttemptAttestation, SigningRoot: phase0.Root{0x2}, Source: 1, Target: 2, Reason: "double vote"},
+	}
+	for _, a := range expected {
+		require.NoError(t, store.SaveAttempt(a))
+	}
+	attempts, err = store.Attempts()
+	require.NoError(t, err)
+	require.Equal(t, expected, attempts)
+
+	// Expect attempts not to count as writes to the history.
+	sequence, err := store.Sequence()
+	require.NoError(t, err)
+	require.Zero(t, sequence)
+	err = store.db.View(func(tx *bolt.Tx) error {
+		_, lastWrite := ReadMeta(tx)
+		require.True(t, lastWrite.IsZero())
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Expect only the most recent attempts to be kept.
+	for i := 0; i < MaxAttempts; i++ {
+		require.NoError(t, store.SaveAttempt(&Attempt{Time: now, Kind: AttemptProposal, Slot: phase0.Slot(100 + i)}))
+	}
+	attempts, err = store.Attempts()
+	require.NoError(t, err)
+	require.Len(t, attempts, MaxAttempts)
+	require.Equal(t, phase0.Slot(100), attempts[0].Slot)
+	require.Equal(t, phase0.Slot(100+MaxAttempts-1), attempts[MaxAttempts-1].Slot)
+}
+
+func TestStore_Digest(t *testing.T) {
+	digest := func(records ...*AttestationRecord) *Digest {
+		store, err := Open(t.TempDir(), Config{})
+		require.NoError(t, err)
+		defer store.Close()
+		require.NoError(t, store.SaveAttestations(records...))
+		digest, err := store.Digest()
+		require.NoError(t, err)
+		return digest
+	}
+	imported := digest(&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}})
+
+	// Expect the vote roots of attestations checked locally not to change
+	// the digest of their EIP-3076 fields.
+	signed := digest(&AttestationRecord{
+		Source:          1,
+		Target:          2,
+		SigningRoot:     phase0.Root{0x1},
+		SourceRoot:      phase0.Root{0x2},
+		TargetRoot:      phase0.Root{0x3},
+		BeaconBlockRoot: phase0.Root{0x4},
+	})
+	require.Equal(t, imported, signed)
+	require.Equal(t, 1, signed.Attestations)
+
+	// Expect any difference in the EIP-3076 fields to change it.
+	require.NotEqual(t, imported.Root, digest(&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x2}}).Root)
+	require.NotEqual(t, imported.Root, digest(&AttestationRecord{Source: 0, Target: 2, SigningRoot: phase0.Root{0x1}}).Root)
+}
@@ -0,0 +1,93 @@
+package kv
+
+import (
+	"github.com/bloxapp/slashing-protector/protector/spans"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// migration upgrades the schema of a store by a single version.
+type migration struct {
+	description string
+	migrate     func(tx *bolt.Tx) error
+}
+
+// migrations upgrade stores to the current schema, where migrations[i]
+// upgrades a store from version i to version i+1. Stores are migrated lazily
+// when they're opened, so migrations must never be removed or reordered;
+// new migrations are appended to the end.
+var migrations = []migration{
+	{
+		description: "create the initial buckets",
+		migrate: func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{attestationsBucket, proposalsBucket, watermarksBucket} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return errors.Wrapf(err, "failed to create bucket %s", name)
+				}
+			}
+			return spans.CreateBuckets(tx)
+		},
+	},
+	{
+		description: "count the records",
+		migrate:     countRecords,
+	},
+	{
+		description: "create the attempts bucket",
+		migrate: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(attemptsBucket)
+			return err
+		},
+	},
+	{
+		description: "encode attestations with the roots they voted for",
+		migrate:     addVoteRoots,
+	},
+}
+
+// SchemaVersion is the schema version of stores created or migrated by this package.
+var SchemaVersion = uint64(len(migrations))
+
+// migrate runs the migrations which the store hasn't undergone yet.
+func (s *Store) migrate() error {
+	var version uint64
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		version = schemaVersion(tx)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+	if version > SchemaVersion {
+		return errors.Errorf(
+			"schema version %d is newer than the supported version %d",
+			version,
+			SchemaVersion,
+		)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for v := schemaVersion(tx); v < SchemaVersion; v++ {
+			if err := migrations[v].migrate(tx); err != nil {
+				return errors.Wrapf(err, "migration to version %d (%s)", v+1, migrations[v].description)
+			}
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, uint64Bytes(SchemaVersion))
+	})
+}
+
+// schemaVersion returns the schema version of the store, where stores
+// which predate versioning are version 0.
+func schemaVersion(tx *bolt.Tx) uint64 {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0
+	}
+	version, _ := getUint64(meta.Get(schemaVersionKey))
+	return version
+}
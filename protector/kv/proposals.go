@@ -0,0 +1,100 @@
+package kv
+
+import (
+	"encoding/binary"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Proposal is a signed block proposal.
+type Proposal struct {
+	Slot        phase0.Slot
+	SigningRoot phase0.Root
+}
+
+// ProposalHistoryForSlot returns the signing root of the proposal
+// signed at the given slot, if any.
+func (s *Store) ProposalHistoryForSlot(slot phase0.Slot) (root phase0.Root, exists bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(proposalsBucket).Get(uint64Bytes(uint64(slot)))
+		if v == nil {
+			return nil
+		}
+		exists = true
+		copy(root[:], v)
+		return nil
+	})
+	return
+}
+
+// LowestSignedProposal returns the lowest signed proposal slot, if any.
+func (s *Store) LowestSignedProposal() (slot phase0.Slot, exists bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v, ok := getWatermark(tx, lowestSlotKey)
+		slot, exists = phase0.Slot(v), ok
+		return nil
+	})
+	return
+}
+
+// SaveProposal saves a signed proposal.
+func (s *Store) SaveProposal(slot phase0.Slot, signingRoot phase0.Root) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return saveProposals(tx, &Proposal{Slot: slot, SigningRoot: signingRoot})
+	})
+}
+
+// SaveSigned saves proposals and attestations in a single transaction,
+// so that either all of them or none of them are saved.
+func (s *Store) SaveSigned(proposals []*Proposal, attestations []*AttestationRecord) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if err := saveProposals(tx, proposals...); err != nil {
+			return err
+		}
+		return saveAttestations(tx, attestations...)
+	})
+}
+
+func saveProposals(tx *bolt.Tx, proposals ...*Proposal) error {
+	bucket := tx.Bucket(proposalsBucket)
+	added := 0
+	for _, p := range proposals {
+		k := uint64Bytes(uint64(p.Slot))
+		if bucket.Get(k) == nil {
+			added++
+		}
+		if err := bucket.Put(k, p.SigningRoot[:]); err != nil {
+			return errors.Wrapf(err, "could not save proposal at slot %d", p.Slot)
+		}
+		if err := lowerWatermark(tx, lowestSlotKey, uint64(p.Slot)); err != nil {
+			return err
+		}
+		if err := raiseWatermark(tx, highestSlotKey, uint64(p.Slot)); err != nil {
+			return err
+		}
+	}
+	return addCount(tx, proposalCountKey, added)
+}
+
+// ProposalHistory returns all signed proposals, ordered by slot.
+func (s *Store) ProposalHistory() ([]*Proposal, error) {
+	return s.ProposalHistorySince(0)
+}
+
+// ProposalHistorySince returns the signed proposals at or after
+// the given slot, ordered by slot.
+func (s *Store) ProposalHistorySince(slot phase0.Slot) ([]*Proposal, error) {
+	proposals := make([]*Proposal, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(proposalsBucket).Cursor()
+		for k, v := c.Seek(uint64Bytes(uint64(slot))); k != nil; k, v = c.Next() {
+			p := &Proposal{Slot: phase0.Slot(binary.BigEndian.Uint64(k))}
+			copy(p.SigningRoot[:], v)
+			proposals = append(proposals, p)
+		}
+		return nil
+	})
+	return proposals, err
+}
@@ -0,0 +1,126 @@
+package kv
+
+import (
+	"encoding/binary"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/spans"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// prune removes the attestations with a target epoch below target and the
+// proposals below slot, always keeping the latest of each, and raises the
+// lowest watermarks to the remaining records, so that nothing at or below
+// what was removed can be signed again. It returns the number of
+// attestations and proposals removed.
+func prune(tx *bolt.Tx, target phase0.Epoch, slot phase0.Slot) (attestations, proposals int, err error) {
+	if highest, ok := getWatermark(tx, highestTargetKey); ok && uint64(target) > highest {
+		target = phase0.Epoch(highest)
+	}
+	if highest, ok := getWatermark(tx, highestSlotKey); ok && uint64(slot) > highest {
+		slot = phase0.Slot(highest)
+	}
+
+	attestations, err = deleteBelow(tx.Bucket(attestationsBucket), uint64(target))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to prune attestations")
+	}
+	if attestations > 0 {
+		var atts []spans.Attestation
+		lowestSource := ^uint64(0)
+		err := forEachAttestation(tx, func(r *AttestationRecord) error {
+			if uint64(r.Source) < lowestSource {
+				lowestSource = uint64(r.Source)
+			}
+			atts = append(atts, spans.Attestation{Source: r.Source, Target: r.Target})
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := raiseWatermark(tx, lowestSourceKey, lowestSource); err != nil {
+			return 0, 0, err
+		}
+		if err := raiseWatermark(tx, lowestTargetKey, uint64(target)); err != nil {
+			return 0, 0, err
+		}
+		if err := addCount(tx, attestationCountKey, -attestations); err != nil {
+			return 0, 0, err
+		}
+		if err := spans.Rebuild(tx, atts); err != nil {
+			return 0, 0, errors.Wrap(err, "failed to rebuild spans")
+		}
+	}
+
+	proposals, err = deleteBelow(tx.Bucket(proposalsBucket), uint64(slot))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to prune proposals")
+	}
+	if proposals > 0 {
+		if err := raiseWatermark(tx, lowestSlotKey, uint64(slot)); err != nil {
+			return 0, 0, err
+		}
+		if err := addCount(tx, proposalCountKey, -proposals); err != nil {
+			return 0, 0, err
+		}
+	}
+	return attestations, proposals, nil
+}
+
+// deleteBelow deletes the keys of a bucket below the given
+// epoch or slot, and returns how many were deleted.
+func deleteBelow(bucket *bolt.Bucket, below uint64) (int, error) {
+	var deleted int
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) < below; k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Pruning is the result of pruning a store.
+type Pruning struct {
+	// Attestations and Proposals are the number of records removed.
+	Attestations int
+	Proposals    int
+}
+
+// Prune removes the attestations with a target epoch below target and the
+// proposals below slot, always keeping the latest of each, and raises the
+// lowest watermarks so that nothing at or below what was removed can be
+// signed again. Stores with nothing to prune aren't written to, so that
+// they don't look in use.
+func (s *Store) Prune(target phase0.Epoch, slot phase0.Slot) (*Pruning, error) {
+	var prunable bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prunable = hasBelow(tx, attestationsBucket, highestTargetKey, uint64(target)) ||
+			hasBelow(tx, proposalsBucket, highestSlotKey, uint64(slot))
+		return nil
+	})
+	if err != nil || !prunable {
+		return &Pruning{}, err
+	}
+	pruning := &Pruning{}
+	err = s.update(func(tx *bolt.Tx) (err error) {
+		pruning.Attestations, pruning.Proposals, err = prune(tx, target, slot)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pruning, nil
+}
+
+// hasBelow returns whether a bucket has a key below the given epoch or slot,
+// other than the latest one, which is at the watermark of highestKey.
+func hasBelow(tx *bolt.Tx, bucket, highestKey []byte, below uint64) bool {
+	if highest, ok := getWatermark(tx, highestKey); ok && below > highest {
+		below = highest
+	}
+	k, _ := tx.Bucket(bucket).Cursor().First()
+	return k != nil && binary.BigEndian.Uint64(k) < below
+}
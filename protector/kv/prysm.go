@@ -0,0 +1,354 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/multierr"
+)
+
+const (
+	// prysmDbFileName is the name of the database file created by Prysm's validator kv.
+	prysmDbFileName = "validator.db"
+
+	// prysmMigratedSuffix is appended to Prysm's database file name once it's migrated.
+	prysmMigratedSuffix = ".migrated"
+
+	// spansDbFileName is the name of the min-max spans database which used to be
+	// stored alongside Prysm's database, and is superseded by the store.
+	spansDbFileName = "spans.db"
+)
+
+// Buckets of Prysm's validator kv schema, which is shared by Prysm v3, v4 and v5.
+// See https://github.com/prysmaticlabs/prysm/blob/v3.1.1/validator/db/kv/schema.go
+var (
+	prysmDeprecatedAttHistoryBucket = []byte("attestation-history-bucket-interchange")
+	prysmMigrationsBucket           = []byte("migrations")
+	prysmPubKeysBucket              = []byte("pubkeys-bucket")
+	prysmAttSigningRootsBucket      = []byte("att-signing-roots-bucket")
+	prysmAttSourceEpochsBucket      = []byte("att-source-epochs-bucket")
+	prysmAttTargetEpochsBucket      = []byte("att-target-epochs-bucket")
+	prysmLowestSignedSourceBucket   = []byte("lowest-signed-source-bucket")
+	prysmLowestSignedTargetBucket   = []byte("lowest-signed-target-bucket")
+	prysmHistoricProposalsBucket    = []byte("proposal-history-bucket-interchange")
+	prysmLowestSignedProposalsBkt   = []byte("lowest-signed-proposals-bucket")
+	prysmHighestSignedProposalsBkt  = []byte("highest-signed-proposals-bucket")
+	prysmGenesisInfoBucket          = []byte("genesis-info-bucket")
+
+	// prysmOptimalAttesterProtectionKey marks that Prysm has migrated the deprecated
+	// attestation history into the optimized buckets, which are the ones migrated here.
+	prysmOptimalAttesterProtectionKey = []byte("optimal_attester_protection_0")
+	prysmMigrationCompleted           = []byte("done")
+
+	prysmGenesisValidatorsRootKey = []byte("genesis-val-root")
+)
+
+// migratePrysm imports the history from Prysm's database in dir, if it exists,
+// and renames it so that it's not migrated again. Importing is idempotent,
+// so an interrupted migration is simply retried on the next open.
+//
+// Prysm's database is keyed by public key, but only ever contains the
+// validator of the store, so all public keys in it are imported.
+func (s *Store) migratePrysm(dir string) error {
+	prysmPath := filepath.Join(dir, prysmDbFileName)
+	if _, err := os.Stat(prysmPath); os.IsNotExist(err) {
+		return nil
+	}
+	prysmDB, err := bolt.Open(prysmPath, 0600, &bolt.Options{
+		Timeout:  time.Second,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to open Prysm database")
+	}
+	err = prysmDB.View(func(prysmTx *bolt.Tx) error {
+		return s.update(func(tx *bolt.Tx) error {
+			return migratePrysmTx(prysmTx, tx)
+		})
+	})
+	if err := multierr.Append(err, prysmDB.Close()); err != nil {
+		return err
+	}
+	if err := os.Rename(prysmPath, prysmPath+prysmMigratedSuffix); err != nil {
+		return errors.Wrap(err, "failed to rename Prysm database")
+	}
+	if err := os.Remove(filepath.Join(dir, spansDbFileName)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove spans database")
+	}
+	return nil
+}
+
+func migratePrysmTx(prysmTx, tx *bolt.Tx) error {
+	if err := checkPrysmSchema(prysmTx); err != nil {
+		return err
+	}
+
+	// Migrate attestations.
+	var attestations []*AttestationRecord
+	if pubKeys := prysmTx.Bucket(prysmPubKeysBucket); pubKeys != nil {
+		err := pubKeys.ForEach(func(pubKey, _ []byte) error {
+			pkBucket := pubKeys.Bucket(pubKey)
+			if pkBucket == nil {
+				return nil
+			}
+			signingRoots := pkBucket.Bucket(prysmAttSigningRootsBucket)
+			sourceEpochs := pkBucket.Bucket(prysmAttSourceEpochsBucket)
+			if sourceEpochs == nil {
+				return nil
+			}
+			return sourceEpochs.ForEach(func(sourceBytes, targetsBytes []byte) error {
+				source := phase0.Epoch(binary.BigEndian.Uint64(sourceBytes))
+				for i := 0; i+8 <= len(targetsBytes); i += 8 {
+					record := &AttestationRecord{
+						Source: source,
+						Target: phase0.Epoch(binary.BigEndian.Uint64(targetsBytes[i : i+8])),
+					}
+					if signingRoots != nil {
+						copy(record.SigningRoot[:], signingRoots.Get(targetsBytes[i:i+8]))
+					}
+					attestations = append(attestations, record)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to read attestations")
+		}
+	}
+	if err := saveAttestations(tx, attestations...); err != nil {
+		return errors.Wrap(err, "failed to save attestations")
+	}
+
+	// Migrate proposals.
+	var proposals []*Proposal
+	if historic := prysmTx.Bucket(prysmHistoricProposalsBucket); historic != nil {
+		err := historic.ForEach(func(pubKey, _ []byte) error {
+			valBucket := historic.Bucket(pubKey)
+			if valBucket == nil {
+				return nil
+			}
+			return valBucket.ForEach(func(slotBytes, signingRoot []byte) error {
+				p := &Proposal{Slot: phase0.Slot(binary.BigEndian.Uint64(slotBytes))}
+				copy(p.SigningRoot[:], signingRoot)
+				proposals = append(proposals, p)
+				return nil
+			})
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to read proposals")
+		}
+	}
+	if err := saveProposals(tx, proposals...); err != nil {
+		return errors.Wrap(err, "failed to save proposals")
+	}
+
+	// Migrate watermarks, which may be lower than the lowest record
+	// (such as after importing an EIP-3076 minimal interchange file.)
+	watermarks := []struct {
+		bucket []byte
+		key    []byte
+		set    func(*bolt.Tx, []byte, uint64) error
+	}{
+		{prysmLowestSignedSourceBucket, lowestSourceKey, lowerWatermark},
+		{prysmLowestSignedTargetBucket, lowestTargetKey, lowerWatermark},
+		{prysmLowestSignedProposalsBkt, lowestSlotKey, lowerWatermark},
+		{prysmHighestSignedProposalsBkt, highestSlotKey, raiseWatermark},
+	}
+	for _, w := range watermarks {
+		bucket := prysmTx.Bucket(w.bucket)
+		if bucket == nil {
+			continue
+		}
+		err := bucket.ForEach(func(_, v []byte) error {
+			if len(v) < 8 {
+				return nil
+			}
+			return w.set(tx, w.key, binary.BigEndian.Uint64(v))
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to migrate watermark %s", w.key)
+		}
+	}
+	return nil
+}
+
+// checkPrysmSchema returns an error if the database has attestations in the
+// deprecated format which Prysm hasn't migrated, since they would be lost.
+// Such databases must be opened once by a Prysm validator client (v3 to v5)
+// which runs it's own migrations first.
+func checkPrysmSchema(prysmTx *bolt.Tx) error {
+	deprecated := prysmTx.Bucket(prysmDeprecatedAttHistoryBucket)
+	if deprecated == nil {
+		return nil
+	}
+	if k, _ := deprecated.Cursor().First(); k == nil {
+		return nil
+	}
+	if migrations := prysmTx.Bucket(prysmMigrationsBucket); migrations != nil &&
+		bytes.Equal(migrations.Get(prysmOptimalAttesterProtectionKey), prysmMigrationCompleted) {
+		return nil
+	}
+	return errors.New("database has attestation history in a deprecated Prysm format, " +
+		"open it with a Prysm validator client first to migrate it")
+}
+
+// PrysmHistory is the history of a public key to write into Prysm's database.
+type PrysmHistory struct {
+	PubKey       phase0.BLSPubKey
+	Attestations []*AttestationRecord
+	Proposals    []*Proposal
+}
+
+// WritePrysm creates a database with Prysm's validator kv schema at fileName
+// with the given histories, which a Prysm validator client (v3 to v5) uses
+// as it's own. The database must not exist yet.
+//
+// The watermarks are those of the records, and the deprecated attestation
+// history is marked as migrated so that Prysm doesn't look for it.
+func WritePrysm(fileName string, genesisValidatorsRoot phase0.Root, histories []*PrysmHistory) (err error) {
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		return errors.Errorf("%s already exists", fileName)
+	}
+	db, err := bolt.Open(fileName, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return errors.Wrap(err, "failed to create Prysm database")
+	}
+	defer func() {
+		err = multierr.Append(err, db.Close())
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		migrations, err := tx.CreateBucketIfNotExists(prysmMigrationsBucket)
+		if err != nil {
+			return err
+		}
+		if err := migrations.Put(prysmOptimalAttesterProtectionKey, prysmMigrationCompleted); err != nil {
+			return err
+		}
+		if genesisValidatorsRoot != (phase0.Root{}) {
+			genesis, err := tx.CreateBucketIfNotExists(prysmGenesisInfoBucket)
+			if err != nil {
+				return err
+			}
+			if err := genesis.Put(prysmGenesisValidatorsRootKey, genesisValidatorsRoot[:]); err != nil {
+				return err
+			}
+		}
+		for _, h := range histories {
+			if err := writePrysmAttestations(tx, h.PubKey, h.Attestations); err != nil {
+				return errors.Wrapf(err, "failed to write attestations of %#x", h.PubKey)
+			}
+			if err := writePrysmProposals(tx, h.PubKey, h.Proposals); err != nil {
+				return errors.Wrapf(err, "failed to write proposals of %#x", h.PubKey)
+			}
+		}
+		return nil
+	})
+}
+
+// writePrysmAttestations writes the attestations of a public key, which Prysm
+// indexes by source epoch and by target epoch, with their signing roots by
+// target epoch, and their lowest source and target epochs.
+func writePrysmAttestations(tx *bolt.Tx, pubKey phase0.BLSPubKey, records []*AttestationRecord) error {
+	pubKeys, err := tx.CreateBucketIfNotExists(prysmPubKeysBucket)
+	if err != nil {
+		return err
+	}
+	pkBucket, err := pubKeys.CreateBucketIfNotExists(pubKey[:])
+	if err != nil {
+		return err
+	}
+	signingRoots, err := pkBucket.CreateBucketIfNotExists(prysmAttSigningRootsBucket)
+	if err != nil {
+		return err
+	}
+	sourceEpochs, err := pkBucket.CreateBucketIfNotExists(prysmAttSourceEpochsBucket)
+	if err != nil {
+		return err
+	}
+	targetEpochs, err := pkBucket.CreateBucketIfNotExists(prysmAttTargetEpochsBucket)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	// Values read within a transaction mustn't be modified, so they're copied.
+	appendEpoch := func(b *bolt.Bucket, key, epoch []byte) error {
+		return b.Put(key, append(append([]byte{}, b.Get(key)...), epoch...))
+	}
+	lowestSource, lowestTarget := records[0].Source, records[0].Target
+	for _, r := range records {
+		source, target := uint64Bytes(uint64(r.Source)), uint64Bytes(uint64(r.Target))
+		if err := appendEpoch(sourceEpochs, source, target); err != nil {
+			return err
+		}
+		if err := appendEpoch(targetEpochs, target, source); err != nil {
+			return err
+		}
+		if r.SigningRoot != (phase0.Root{}) {
+			if err := signingRoots.Put(target, r.SigningRoot[:]); err != nil {
+				return err
+			}
+		}
+		if r.Source < lowestSource {
+			lowestSource = r.Source
+		}
+		if r.Target < lowestTarget {
+			lowestTarget = r.Target
+		}
+	}
+	if err := putPrysmWatermark(tx, prysmLowestSignedSourceBucket, pubKey, uint64(lowestSource)); err != nil {
+		return err
+	}
+	return putPrysmWatermark(tx, prysmLowestSignedTargetBucket, pubKey, uint64(lowestTarget))
+}
+
+// writePrysmProposals writes the proposals of a public key by slot,
+// and their lowest and highest slots.
+func writePrysmProposals(tx *bolt.Tx, pubKey phase0.BLSPubKey, proposals []*Proposal) error {
+	historic, err := tx.CreateBucketIfNotExists(prysmHistoricProposalsBucket)
+	if err != nil {
+		return err
+	}
+	valBucket, err := historic.CreateBucketIfNotExists(pubKey[:])
+	if err != nil {
+		return err
+	}
+	if len(proposals) == 0 {
+		return nil
+	}
+	lowest, highest := proposals[0].Slot, proposals[0].Slot
+	for _, p := range proposals {
+		var signingRoot []byte
+		if p.SigningRoot != (phase0.Root{}) {
+			signingRoot = p.SigningRoot[:]
+		}
+		if err := valBucket.Put(uint64Bytes(uint64(p.Slot)), signingRoot); err != nil {
+			return err
+		}
+		if p.Slot < lowest {
+			lowest = p.Slot
+		}
+		if p.Slot > highest {
+			highest = p.Slot
+		}
+	}
+	if err := putPrysmWatermark(tx, prysmLowestSignedProposalsBkt, pubKey, uint64(lowest)); err != nil {
+		return err
+	}
+	return putPrysmWatermark(tx, prysmHighestSignedProposalsBkt, pubKey, uint64(highest))
+}
+
+func putPrysmWatermark(tx *bolt.Tx, bucketName []byte, pubKey phase0.BLSPubKey, value uint64) error {
+	bucket, err := tx.CreateBucketIfNotExists(bucketName)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(pubKey[:], uint64Bytes(value))
+}
@@ -0,0 +1,168 @@
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpen_MigratePrysm(t *testing.T) {
+	dir := t.TempDir()
+	pubKey := []byte{0x1}
+
+	// Create a database with Prysm's schema.
+	db, err := bolt.Open(filepath.Join(dir, prysmDbFileName), 0600, nil)
+	require.NoError(t, err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		pubKeys, err := tx.CreateBucket(prysmPubKeysBucket)
+		require.NoError(t, err)
+		pkBucket, err := pubKeys.CreateBucket(pubKey)
+		require.NoError(t, err)
+		signingRoots, err := pkBucket.CreateBucket(prysmAttSigningRootsBucket)
+		require.NoError(t, err)
+		sourceEpochs, err := pkBucket.CreateBucket(prysmAttSourceEpochsBucket)
+		require.NoError(t, err)
+		require.NoError(t, sourceEpochs.Put(uint64Bytes(1), append(uint64Bytes(2), uint64Bytes(3)...)))
+		require.NoError(t, signingRoots.Put(uint64Bytes(2), []byte{0x2}))
+		require.NoError(t, signingRoots.Put(uint64Bytes(3), []byte{0x3}))
+
+		historic, err := tx.CreateBucket(prysmHistoricProposalsBucket)
+		require.NoError(t, err)
+		valBucket, err := historic.CreateBucket(pubKey)
+		require.NoError(t, err)
+		require.NoError(t, valBucket.Put(uint64Bytes(100), []byte{0x4}))
+
+		// Watermarks lower than the records, as left by a minimal interchange import.
+		for name, value := range map[string]uint64{
+			string(prysmLowestSignedSourceBucket): 0,
+			string(prysmLowestSignedTargetBucket): 1,
+			string(prysmLowestSignedProposalsBkt): 50,
+		} {
+			b, err := tx.CreateBucket([]byte(name))
+			require.NoError(t, err)
+			require.NoError(t, b.Put(pubKey, uint64Bytes(value)))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Open the store and expect the history to be migrated.
+	store, err := Open(dir, Config{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	attestations, err := store.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*AttestationRecord{
+		{Source: 1, Target: 2, SigningRoot: phase0.Root{0x2}},
+		{Source: 1, Target: 3, SigningRoot: phase0.Root{0x3}},
+	}, attestations)
+
+	proposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*Proposal{{Slot: 100, SigningRoot: phase0.Root{0x4}}}, proposals)
+
+	lowestSource, _, err := store.LowestSignedSourceEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(0), lowestSource)
+	lowestTarget, _, err := store.LowestSignedTargetEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(1), lowestTarget)
+	lowestSlot, _, err := store.LowestSignedProposal()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(50), lowestSlot)
+
+	// Expect Prysm's database to be renamed.
+	_, err = os.Stat(filepath.Join(dir, prysmDbFileName))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, prysmDbFileName+prysmMigratedSuffix))
+	require.NoError(t, err)
+}
+
+func TestOpen_MigratePrysm_DeprecatedSchema(t *testing.T) {
+	dir := t.TempDir()
+
+	// Create a database with attestations in Prysm's deprecated format.
+	db, err := bolt.Open(filepath.Join(dir, prysmDbFileName), 0600, nil)
+	require.NoError(t, err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		deprecated, err := tx.CreateBucket(prysmDeprecatedAttHistoryBucket)
+		require.NoError(t, err)
+		return deprecated.Put([]byte{0x1}, []byte{0x1})
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Expect the migration to be refused.
+	_, err = Open(dir, Config{})
+	require.ErrorContains(t, err, "deprecated Prysm format")
+
+	// Mark Prysm's migration as completed and expect the migration to succeed.
+	db, err = bolt.Open(filepath.Join(dir, prysmDbFileName), 0600, nil)
+	require.NoError(t, err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		migrations, err := tx.CreateBucket(prysmMigrationsBucket)
+		require.NoError(t, err)
+		return migrations.Put(prysmOptimalAttesterProtectionKey, prysmMigrationCompleted)
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store, err := Open(dir, Config{})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+}
+
+func TestWritePrysm(t *testing.T) {
+	dir := t.TempDir()
+	pubKey := phase0.BLSPubKey{0x1}
+	attestations := []*AttestationRecord{
+		{Source: 1, Target: 2, SigningRoot: phase0.Root{0x2}},
+		{Source: 1, Target: 3, SigningRoot: phase0.Root{0x3}},
+		{Source: 3, Target: 4},
+	}
+	proposals := []*Proposal{
+		{Slot: 100, SigningRoot: phase0.Root{0x4}},
+		{Slot: 101},
+	}
+	fileName := filepath.Join(dir, prysmDbFileName)
+	err := WritePrysm(fileName, phase0.Root{0x5}, []*PrysmHistory{
+		{PubKey: pubKey, Attestations: attestations, Proposals: proposals},
+	})
+	require.NoError(t, err)
+
+	// Expect an existing database not to be overwritten.
+	require.Error(t, WritePrysm(fileName, phase0.Root{}, nil))
+
+	// Expect the database to be indexed as Prysm does.
+	db, err := bolt.Open(fileName, 0600, &bolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	err = db.View(func(tx *bolt.Tx) error {
+		pkBucket := tx.Bucket(prysmPubKeysBucket).Bucket(pubKey[:])
+		require.Equal(t, append(uint64Bytes(2), uint64Bytes(3)...), pkBucket.Bucket(prysmAttSourceEpochsBucket).Get(uint64Bytes(1)))
+		require.Equal(t, uint64Bytes(3), pkBucket.Bucket(prysmAttTargetEpochsBucket).Get(uint64Bytes(4)))
+		require.Equal(t, uint64Bytes(2), tx.Bucket(prysmLowestSignedTargetBucket).Get(pubKey[:]))
+		require.Equal(t, uint64Bytes(101), tx.Bucket(prysmHighestSignedProposalsBkt).Get(pubKey[:]))
+		genesisValidatorsRoot := phase0.Root{0x5}
+		require.Equal(t, genesisValidatorsRoot[:], tx.Bucket(prysmGenesisInfoBucket).Get(prysmGenesisValidatorsRootKey))
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Expect the history to migrate back unchanged.
+	store, err := Open(dir, Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	migratedAttestations, err := store.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, attestations, migratedAttestations)
+	migratedProposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Equal(t, proposals, migratedProposals)
+}
@@ -0,0 +1,136 @@
+package kv
+
+import (
+	"encoding/binary"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/spans"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotLatest is returned when rolling back a record
+// which isn't the latest of it's kind.
+var ErrNotLatest = errors.New("record isn't the latest")
+
+// RollbackAttestation removes the attestation at the given target epoch, which
+// must be the latest one, and lowers the watermarks to the remaining history,
+// but never the lowest ones below it (see rollbackWatermarks).
+// It's only meant for attestations which were recorded but provably never
+// signed, since signing again at or below them would no longer be refused.
+func (s *Store) RollbackAttestation(target phase0.Epoch) (removed *AttestationRecord, err error) {
+	err = s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(attestationsBucket)
+		k, v := bucket.Cursor().Last()
+		if k == nil || binary.BigEndian.Uint64(k) != uint64(target) {
+			return errors.Wrapf(ErrNotLatest, "no attestation at target epoch %d is the latest", target)
+		}
+		removed = decodeAttestation(k, v)
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+		if err := addCount(tx, attestationCountKey, -1); err != nil {
+			return err
+		}
+
+		// Rebuild the spans and lower the highest watermarks to the remaining
+		// history.
+		var atts []spans.Attestation
+		var highestSource, highestTarget uint64
+		err := forEachAttestation(tx, func(r *AttestationRecord) error {
+			if uint64(r.Source) > highestSource {
+				highestSource = uint64(r.Source)
+			}
+			if uint64(r.Target) > highestTarget {
+				highestTarget = uint64(r.Target)
+			}
+			atts = append(atts, spans.Attestation{Source: r.Source, Target: r.Target})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = rollbackWatermarks(tx, lowestSourceKey, highestSourceKey, uint64(removed.Source), highestSource, len(atts) > 0, false)
+		if err != nil {
+			return err
+		}
+		err = rollbackWatermarks(tx, lowestTargetKey, highestTargetKey, uint64(removed.Target), highestTarget, len(atts) > 0, true)
+		if err != nil {
+			return err
+		}
+		return errors.Wrap(spans.Rebuild(tx, atts), "failed to rebuild spans")
+	})
+	return removed, err
+}
+
+// RollbackProposal removes the proposal at the given slot, which must be
+// the latest one, and lowers the watermarks to the remaining history,
+// but never the lowest one below it (see rollbackWatermarks).
+// It's only meant for proposals which were recorded but provably never
+// signed, since signing again at it would no longer be refused.
+func (s *Store) RollbackProposal(slot phase0.Slot) (removed *Proposal, err error) {
+	err = s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(proposalsBucket)
+		c := bucket.Cursor()
+		k, v := c.Last()
+		if k == nil || binary.BigEndian.Uint64(k) != uint64(slot) {
+			return errors.Wrapf(ErrNotLatest, "no proposal at slot %d is the latest", slot)
+		}
+		removed = &Proposal{Slot: slot}
+		copy(removed.SigningRoot[:], v)
+		if err := c.Delete(); err != nil {
+			return err
+		}
+		if err := addCount(tx, proposalCountKey, -1); err != nil {
+			return err
+		}
+
+		var highest uint64
+		k, _ = c.Last()
+		if k != nil {
+			highest = binary.BigEndian.Uint64(k)
+		}
+		return rollbackWatermarks(tx, lowestSlotKey, highestSlotKey, uint64(slot), highest, k != nil, true)
+	})
+	return removed, err
+}
+
+// rollbackWatermarks lowers the watermarks of a removed record's value to the
+// remaining history, whose highest value is remaining if any is left.
+//
+// The lowest watermark is never lowered below the removed value, since it may
+// also stand for records which are gone, such as pruned or imported ones, and
+// which must still be refused. If it's at the removed value and inclusive
+// (refusing values at or below it, like targets and slots), it's lowered by
+// one so that the removed value can be signed again. The highest watermark is
+// lowered to the remaining history, or to the lowest watermark if none is left.
+func rollbackWatermarks(tx *bolt.Tx, lowestKey, highestKey []byte, value, remaining uint64, anyRemaining, inclusive bool) error {
+	watermarks := tx.Bucket(watermarksBucket)
+	lowest, lowestOK := getWatermark(tx, lowestKey)
+	if lowestOK && lowest >= value && inclusive {
+		if value == 0 {
+			if err := watermarks.Delete(lowestKey); err != nil {
+				return err
+			}
+			lowestOK = false
+		} else {
+			lowest = value - 1
+			if err := watermarks.Put(lowestKey, uint64Bytes(lowest)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if highest, ok := getWatermark(tx, highestKey); ok && highest > value {
+		// Set by something other than the removed record.
+		return nil
+	}
+	switch {
+	case anyRemaining:
+		return watermarks.Put(highestKey, uint64Bytes(remaining))
+	case lowestOK:
+		return watermarks.Put(highestKey, uint64Bytes(lowest))
+	default:
+		return watermarks.Delete(highestKey)
+	}
+}
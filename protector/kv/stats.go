@@ -0,0 +1,96 @@
+package kv
+
+import (
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Stats are basic statistics of a store's history.
+type Stats struct {
+	Attestations int
+	Proposals    int
+
+	// Size is the size of the store's database in bytes.
+	Size int64
+
+	// FirstTargetEpoch and LastTargetEpoch are the lowest and highest signed
+	// target epochs, and FirstSlot and LastSlot are the lowest and highest
+	// signed proposal slots, or nil if nothing was signed.
+	FirstTargetEpoch *phase0.Epoch
+	LastTargetEpoch  *phase0.Epoch
+	FirstSlot        *phase0.Slot
+	LastSlot         *phase0.Slot
+
+	// LastActivity is when the store was last written to,
+	// or zero if it's never been written to.
+	LastActivity time.Time
+}
+
+// Stats returns the statistics of the store's history.
+func (s *Store) Stats() (*Stats, error) {
+	stats := &Stats{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stats.Attestations = tx.Bucket(attestationsBucket).Stats().KeyN
+		stats.Proposals = tx.Bucket(proposalsBucket).Stats().KeyN
+		stats.Size = tx.Size()
+		if v, ok := getWatermark(tx, lowestTargetKey); ok {
+			epoch := phase0.Epoch(v)
+			stats.FirstTargetEpoch = &epoch
+		}
+		if v, ok := getWatermark(tx, highestTargetKey); ok {
+			epoch := phase0.Epoch(v)
+			stats.LastTargetEpoch = &epoch
+		}
+		if v, ok := getWatermark(tx, lowestSlotKey); ok {
+			slot := phase0.Slot(v)
+			stats.FirstSlot = &slot
+		}
+		if v, ok := getWatermark(tx, highestSlotKey); ok {
+			slot := phase0.Slot(v)
+			stats.LastSlot = &slot
+		}
+		if v, ok := getUint64(tx.Bucket(metaBucket).Get(lastWriteKey)); ok {
+			stats.LastActivity = time.Unix(0, int64(v))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// LastSigned is the highest signed data of a store,
+// where each field is nil if nothing of its kind was signed.
+type LastSigned struct {
+	SourceEpoch *phase0.Epoch
+	TargetEpoch *phase0.Epoch
+	Slot        *phase0.Slot
+}
+
+// LastSigned returns the highest signed source epoch, target epoch and proposal
+// slot from the store's watermarks, without reading its history.
+func (s *Store) LastSigned() (*LastSigned, error) {
+	last := &LastSigned{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v, ok := getWatermark(tx, highestSourceKey); ok {
+			epoch := phase0.Epoch(v)
+			last.SourceEpoch = &epoch
+		}
+		if v, ok := getWatermark(tx, highestTargetKey); ok {
+			epoch := phase0.Epoch(v)
+			last.TargetEpoch = &epoch
+		}
+		if v, ok := getWatermark(tx, highestSlotKey); ok {
+			slot := phase0.Slot(v)
+			last.Slot = &slot
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return last, nil
+}
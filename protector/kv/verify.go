@@ -0,0 +1,120 @@
+package kv
+
+import (
+	"fmt"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Finding is a violation of the store's invariants found by Verify.
+type Finding struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// Verify checks the integrity of the bolt pages and the invariants of the
+// store within a single read-only transaction, so it's safe to run while
+// the store is in use. It returns the violations found, if any.
+func (s *Store) Verify() (findings []Finding, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		add := func(check, format string, args ...interface{}) {
+			findings = append(findings, Finding{Check: check, Message: fmt.Sprintf(format, args...)})
+		}
+
+		// Check the bolt pages.
+		for err := range tx.Check() {
+			add("pages", "%s", err)
+		}
+
+		// Check the attestations.
+		var attestations []*AttestationRecord
+		err := forEachAttestation(tx, func(r *AttestationRecord) error {
+			if r.Source > r.Target {
+				add("attestations", "source epoch %d is greater than target epoch %d", r.Source, r.Target)
+			}
+			attestations = append(attestations, r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		verifySurroundVotes(attestations, add)
+
+		// Check the watermarks against the records.
+		var (
+			sources = make([]uint64, len(attestations))
+			targets = make([]uint64, len(attestations))
+			slots   []uint64
+		)
+		for i, r := range attestations {
+			sources[i], targets[i] = uint64(r.Source), uint64(r.Target)
+		}
+		err = tx.Bucket(proposalsBucket).ForEach(func(k, _ []byte) error {
+			slot, _ := getUint64(k)
+			slots = append(slots, slot)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		verifyWatermarks(tx, lowestSourceKey, highestSourceKey, sources, add)
+		verifyWatermarks(tx, lowestTargetKey, highestTargetKey, targets, add)
+		verifyWatermarks(tx, lowestSlotKey, highestSlotKey, slots, add)
+		return nil
+	})
+	return
+}
+
+// verifySurroundVotes reports attestations which are surrounded by another.
+// Double votes can't be stored since attestations are keyed by target epoch.
+func verifySurroundVotes(attestations []*AttestationRecord, add func(check, format string, args ...interface{})) {
+	sorted := make([]*AttestationRecord, len(attestations))
+	copy(sorted, attestations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Source < sorted[j].Source
+	})
+
+	// Walk through the attestations by ascending source, keeping the one with the
+	// highest target among those with a strictly lower source than the current.
+	var widest, pending *AttestationRecord
+	for i, r := range sorted {
+		if i > 0 && sorted[i-1].Source < r.Source {
+			if widest == nil || pending.Target > widest.Target {
+				widest = pending
+			}
+			pending = nil
+		}
+		if widest != nil && widest.Target > r.Target {
+			add("attestations",
+				"attestation (source %d, target %d) is surrounded by (source %d, target %d)",
+				r.Source, r.Target, widest.Source, widest.Target,
+			)
+		}
+		if pending == nil || r.Target > pending.Target {
+			pending = r
+		}
+	}
+}
+
+// verifyWatermarks reports lowest and highest watermarks which
+// aren't consistent with each other or with the given values.
+func verifyWatermarks(tx *bolt.Tx, lowestKey, highestKey []byte, values []uint64, add func(check, format string, args ...interface{})) {
+	lowest, lowestOK := getWatermark(tx, lowestKey)
+	highest, highestOK := getWatermark(tx, highestKey)
+	if lowestOK && highestOK && lowest > highest {
+		add("watermarks", "%s %d is greater than %s %d", lowestKey, lowest, highestKey, highest)
+	}
+	for _, v := range values {
+		if !lowestOK || v < lowest {
+			add("watermarks", "%s is missing or above a record at %d", lowestKey, v)
+			break
+		}
+	}
+	for _, v := range values {
+		if !highestOK || v > highest {
+			add("watermarks", "%s is missing or below a record at %d", highestKey, v)
+			break
+		}
+	}
+}
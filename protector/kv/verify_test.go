@@ -0,0 +1,47 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStore_Verify(t *testing.T) {
+	store, err := Open(t.TempDir(), Config{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Save a consistent history and expect no findings.
+	require.NoError(t, store.SaveAttestations(
+		&AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}},
+		&AttestationRecord{Source: 2, Target: 3, SigningRoot: phase0.Root{0x2}},
+	))
+	require.NoError(t, store.SaveProposal(10, phase0.Root{0x3}))
+	findings, err := store.Verify()
+	require.NoError(t, err)
+	require.Empty(t, findings)
+
+	// Corrupt the history and expect the violations to be found.
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		// A surround vote, bypassing the spans.
+		r := &AttestationRecord{Source: 0, Target: 5, SigningRoot: phase0.Root{0x4}}
+		if err := tx.Bucket(attestationsBucket).Put(uint64Bytes(5), encodeAttestation(r)); err != nil {
+			return err
+		}
+		// A lowest slot above the lowest proposal.
+		return tx.Bucket(watermarksBucket).Put(lowestSlotKey, uint64Bytes(11))
+	})
+	require.NoError(t, err)
+	findings, err = store.Verify()
+	require.NoError(t, err)
+	require.Equal(t, []Finding{
+		{Check: "attestations", Message: "attestation (source 1, target 2) is surrounded by (source 0, target 5)"},
+		{Check: "attestations", Message: "attestation (source 2, target 3) is surrounded by (source 0, target 5)"},
+		{Check: "watermarks", Message: "lowest-source is missing or above a record at 0"},
+		{Check: "watermarks", Message: "highest-target is missing or below a record at 5"},
+		{Check: "watermarks", Message: "lowest-slot 11 is greater than highest-slot 10"},
+		{Check: "watermarks", Message: "lowest-slot is missing or above a record at 10"},
+	}, findings)
+}
@@ -0,0 +1,84 @@
+package kvpool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// minMmapSize and maxMmapStep are bolt's smallest memory map,
+	// and the step it grows by once it's doubled to that size.
+	minMmapSize = 1 << 15
+	maxMmapStep = 1 << 30
+)
+
+// memoryBudget bounds the memory maps of the stores open at once.
+type memoryBudget struct {
+	size      int64
+	semaphore *semaphore.Weighted
+	reserved  int64
+}
+
+// WithMemoryBudget bounds the memory maps of the stores open at once to
+// budget bytes, so that the pool's memory use stays predictable on small
+// machines. Acquiring a store whose memory map doesn't fit waits for others
+// to be released. Stores are closed when they're released, so only acquired
+// stores count towards the budget, and a store larger than the budget
+// takes all of it.
+func WithMemoryBudget(budget int64) Option {
+	return func(p *Pool) {
+		p.budget = &memoryBudget{
+			size:      budget,
+			semaphore: semaphore.NewWeighted(budget),
+		}
+	}
+}
+
+// reserve waits until cost fits in the budget and reserves it,
+// returning the reserved cost.
+func (b *memoryBudget) reserve(ctx context.Context, cost int64) (int64, error) {
+	if cost > b.size {
+		cost = b.size
+	}
+	if err := b.semaphore.Acquire(ctx, cost); err != nil {
+		return 0, errors.Wrap(err, "failed to reserve memory")
+	}
+	atomic.AddInt64(&b.reserved, cost)
+	return cost, nil
+}
+
+func (b *memoryBudget) release(cost int64) {
+	atomic.AddInt64(&b.reserved, -cost)
+	b.semaphore.Release(cost)
+}
+
+// storeCost estimates the memory map of a store, which bolt sizes to the
+// next power of two of it's file (or the initial size, if larger), and to
+// the next multiple of 1GB beyond 1GB.
+func storeCost(fileName string, initialMmapSize int) int64 {
+	size := int64(initialMmapSize)
+	if info, err := os.Stat(filepath.Join(fileName, kv.DbFileName)); err == nil && info.Size() > size {
+		size = info.Size()
+	}
+	for cost := int64(minMmapSize); cost <= maxMmapStep; cost *= 2 {
+		if size <= cost {
+			return cost
+		}
+	}
+	return (size + maxMmapStep - 1) / maxMmapStep * maxMmapStep
+}
+
+// ReservedMemory returns the bytes of the memory budget reserved by
+// acquired stores, or zero if there's no budget.
+func (p *Pool) ReservedMemory() int64 {
+	if p.budget == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&p.budget.reserved)
+}
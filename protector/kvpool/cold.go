@@ -0,0 +1,181 @@
+package kvpool
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// FrozenFileName is the name of the compressed database of a frozen store.
+const FrozenFileName = kv.DbFileName + ".zst"
+
+// Freezing is the result of freezing a store.
+type Freezing struct {
+	// Before is the size of the store's database file in bytes,
+	// and After is the size of it's compressed copy.
+	Before int64
+	After  int64
+}
+
+// Freeze compresses the database of a public key's store once it's not in
+// use, if it wasn't modified within the last coldFor, so that the stores of
+// keys which stopped signing take little space. Frozen stores are thawed
+// transparently when they're next acquired. It returns nil if the store
+// wasn't frozen.
+func (p *Pool) Freeze(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	coldFor time.Duration,
+) (*Freezing, error) {
+	var freezing *Freezing
+	err := p.withConn(connID{network, pubKey}, func(conn *Conn) (err error) {
+		freezing, err = conn.freeze(ctx, coldFor)
+		return err
+	})
+	return freezing, errors.Wrapf(err, "failed to freeze %#x", pubKey)
+}
+
+// Frozen returns whether the store of a public key is frozen, without thawing it.
+func (p *Pool) Frozen(network string, pubKey phase0.BLSPubKey) bool {
+	dir, path := p.storeLocation(connID{network, pubKey})
+	_, err := os.Stat(filepath.Join(dir, path, FrozenFileName))
+	return err == nil
+}
+
+// freeze compresses the database of the store once it's not in use, and
+// removes it once it's compressed copy is durable, unless it was modified
+// within the last coldFor. It returns nil if the store wasn't frozen.
+func (c *Conn) freeze(ctx context.Context, coldFor time.Duration) (*Freezing, error) {
+	if err := c.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer c.semaphore.Release(1)
+
+	if c.Store != nil {
+		return nil, nil
+	}
+	path := filepath.Join(c.fileName, kv.DbFileName)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) < coldFor {
+		return nil, nil
+	}
+
+	size, err := writeFile(filepath.Join(c.fileName, FrozenFileName), info.ModTime(), func(w io.Writer) error {
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(zw, src); err != nil {
+			return multierr.Append(err, zw.Close())
+		}
+		return zw.Close()
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compress database")
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, errors.Wrap(err, "failed to remove database")
+	}
+	return &Freezing{Before: info.Size(), After: size}, nil
+}
+
+// thaw decompresses the database of the store if it's frozen, keeping it's
+// modification time so that reading it doesn't make it look in use. Must be
+// called with the semaphore held.
+func (c *Conn) thaw() error {
+	frozenPath := filepath.Join(c.fileName, FrozenFileName)
+	_, err := os.Stat(frozenPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to stat frozen database")
+	}
+
+	// The database is authoritative if freezing or thawing was
+	// interrupted before the compressed copy was removed.
+	path := filepath.Join(c.fileName, kv.DbFileName)
+	if _, err := os.Stat(path); err == nil {
+		return errors.Wrap(os.Remove(frozenPath), "failed to remove frozen database")
+	}
+
+	if err := Decompress(frozenPath, path); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Remove(frozenPath), "failed to remove frozen database")
+}
+
+// Decompress replaces the database at path with the decompressed copy of a
+// frozen store's compressed database at frozenPath, keeping it's modification
+// time, without removing the compressed database.
+func Decompress(frozenPath, path string) error {
+	frozen, err := os.Stat(frozenPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to stat frozen database")
+	}
+	_, err = writeFile(path, frozen.ModTime(), func(w io.Writer) error {
+		src, err := os.Open(frozenPath)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		zr, err := zstd.NewReader(src)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		_, err = io.Copy(w, zr)
+		return err
+	})
+	return errors.Wrap(err, "failed to decompress database")
+}
+
+// writeFile writes a file with write, and replaces the file at path with it
+// once it's fsynced, with the given modification time. The rename is fsynced
+// to the directory of path. It returns the size of the file.
+func writeFile(path string, modTime time.Time, write func(io.Writer) error) (int64, error) {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	err = multierr.Append(err, f.Close())
+	if err == nil {
+		err = os.Chtimes(tmpPath, modTime, modTime)
+	}
+	if err != nil {
+		return 0, multierr.Append(err, os.Remove(tmpPath))
+	}
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, err
+	}
+	return info.Size(), syncFile(filepath.Dir(path))
+}
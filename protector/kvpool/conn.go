@@ -3,74 +3,375 @@ package kvpool
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/bloxapp/slashing-protector/protector/kv"
 	"github.com/pkg/errors"
-	"github.com/prysmaticlabs/prysm/v3/validator/db/kv"
+	"go.uber.org/multierr"
 	"golang.org/x/sync/semaphore"
 )
 
+// inactiveFileName is the name of the file which marks a store as inactive.
+const inactiveFileName = "inactive"
+
+const (
+	// openRetries is how many times opening a store is retried while it's
+	// locked, which is usually transient (such as during a backup elsewhere),
+	// and openBackoff is the wait before the first retry, which doubles
+	// with every retry.
+	openRetries = 3
+	openBackoff = 100 * time.Millisecond
+)
+
 // Conn is a connection acquired from the pool.
 type Conn struct {
 	*kv.Store
-	fileName       string
-	semaphore      *semaphore.Weighted
-	cancelStoreCtx func()
+	id       connID
+	fileName string
+
+	// semaphore serializes the use of the store, and guards the state of
+	// the connection below. It's per connection rather than striped across
+	// connections, even though leased stores stay open, since it's waits
+	// honor the context of the check and striping would make checks of
+	// unrelated keys wait for each other.
+	semaphore *semaphore.Weighted
+
+	// witness and sequence are used to detect rollbacks of the store.
+	witness  *Witness
+	sequence uint64
+
+	// config configures how the store is opened, and syncer fsyncs
+	// it's writes if they aren't fsynced when they're made.
+	config kv.Config
+	syncer *syncer
+
+	// compacted is the modification time of the database
+	// when it was last compacted, or zero if it wasn't.
+	compacted time.Time
+
+	// tombstoneFileName is the path of the store's tombstone, which
+	// is written when it's removed if keepTombstone is true.
+	tombstoneFileName string
+	keepTombstone     bool
+
+	// budget is the pool's memory budget, if any, and reserved
+	// is the part of it reserved while the store is open.
+	budget   *memoryBudget
+	reserved int64
+
+	// evicted is whether the connection was evicted from the pool by
+	// Pool.Collect, after which it's replaced by a new one.
+	evicted bool
+
+	// leasedUntil is when the lease of the connection expires, until
+	// which the store is kept open when it's released, and leaseTimer
+	// closes it then. See Pool.Lease.
+	leasedUntil time.Time
+	leaseTimer  *time.Timer
 }
 
-func newConn(fileName string) *Conn {
+func newConn(
+	id connID,
+	fileName string,
+	witness *Witness,
+	config kv.Config,
+	syncer *syncer,
+	tombstoneFileName string,
+	keepTombstone bool,
+) *Conn {
 	return &Conn{
-		fileName:  fileName,
-		semaphore: semaphore.NewWeighted(1),
+		id:                id,
+		fileName:          fileName,
+		semaphore:         semaphore.NewWeighted(1),
+		witness:           witness,
+		config:            config,
+		syncer:            syncer,
+		tombstoneFileName: tombstoneFileName,
+		keepTombstone:     keepTombstone,
 	}
 }
 
-func (c *Conn) acquire(ctx context.Context) (err error) {
+// lock acquires the semaphore, or fails with errEvicted if the
+// connection was evicted while waiting for it.
+func (c *Conn) lock(ctx context.Context) error {
 	if err := c.semaphore.Acquire(ctx, 1); err != nil {
 		return errors.Wrap(err, "failed to acquire semaphore")
 	}
-	defer func() {
+	if c.evicted {
+		c.semaphore.Release(1)
+		return errEvicted
+	}
+	return nil
+}
+
+func (c *Conn) acquire(ctx context.Context) error {
+	if err := c.lock(ctx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		c.semaphore.Release(1)
+		return errors.Wrap(err, "failed to acquire semaphore")
+	}
+	if c.Store != nil {
+		// The store was kept open by a lease.
+		return nil
+	}
+	if err := c.reserve(ctx); err != nil {
+		c.semaphore.Release(1)
+		return err
+	}
+
+	// Opening may wait for the database's lock or run migrations, so it's
+	// abandoned when ctx is done. The semaphore is then held until the
+	// store is opened and closed again, so that it's not opened twice.
+	opened := make(chan error, 1)
+	go func() {
+		opened <- c.openRetrying(ctx)
+	}()
+	select {
+	case err := <-opened:
 		if err != nil {
+			c.unreserve()
 			c.semaphore.Release(1)
 		}
-	}()
+		return err
+	case <-ctx.Done():
+		go func() {
+			if err := <-opened; err == nil {
+				_ = c.Release()
+			} else {
+				c.unreserve()
+				c.semaphore.Release(1)
+			}
+		}()
+		return errors.Wrap(ctx.Err(), "failed to open store")
+	}
+}
 
-	// kv.NewKVStore starts a background goroutine which only stops when the
-	// context is cancelled. However, cancelling the context before
-	// Store is closed causes some methods (such as SaveAttestationForPubKey)
-	// to hang forever.
-	// Therefore, we create a context and cancel it only after Store is closed.
-	ctxStore, cancelStore := context.WithCancel(context.Background())
-	c.cancelStoreCtx = cancelStore
-	store, err := kv.NewKVStore(
-		ctxStore,
-		c.fileName,
-		&kv.Config{},
-	)
+// reserve reserves the memory map of the store in the pool's
+// memory budget, if any. Must be called with the semaphore held.
+func (c *Conn) reserve(ctx context.Context) (err error) {
+	if c.budget == nil {
+		return nil
+	}
+	c.reserved, err = c.budget.reserve(ctx, storeCost(c.fileName, c.config.Tuning.InitialMmapSize))
+	return err
+}
+
+// unreserve reverts reserve. Must be called with the semaphore held.
+func (c *Conn) unreserve() {
+	if c.reserved > 0 {
+		c.budget.release(c.reserved)
+		c.reserved = 0
+	}
+}
+
+// openRetrying opens the store, retrying with backoff while it's locked
+// until openRetries are exhausted or ctx is done. Must be called with the
+// semaphore held.
+func (c *Conn) openRetrying(ctx context.Context) error {
+	backoff := openBackoff
+	for retry := 0; ; retry++ {
+		err := c.open()
+		if err == nil || !errors.Is(err, kv.ErrLocked) || retry == openRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// open opens the store, thawing it first if it's frozen.
+// Must be called with the semaphore held.
+func (c *Conn) open() error {
+	if err := c.thaw(); err != nil {
+		return err
+	}
+	store, err := kv.Open(c.fileName, c.config)
 	if err != nil {
-		// dirty hack alert: Ignore this prometheus error as we are opening two DB with same metric name
-		// if you want to avoid this then we should pass the metric name when opening the DB which touches
-		// too many places.
-		// Borrowed from Prysm at https://github.com/prysmaticlabs/prysm/blob/29513c804caad88cf4e93eefdde0d71ea9eb6e75/tools/exploredb/main.go#L390-L395
-		if err.Error() != "duplicate metrics collector registration attempted" {
-			return fmt.Errorf("kv.NewKVStore(%s): %w", c.fileName, err)
+		return fmt.Errorf("kv.Open(%s): %w", c.fileName, err)
+	}
+	if c.witness != nil {
+		if err := c.verify(store); err != nil {
+			return multierr.Append(err, store.Close())
 		}
 	}
+	if err := importTombstone(c.tombstoneFileName, store); err != nil {
+		return multierr.Append(err, store.Close())
+	}
 	c.Store = store
 	return nil
 }
 
-// Release returns the connection to the connection pool.
-func (c *Conn) Release() error {
-	if c.cancelStoreCtx != nil {
-		defer c.cancelStoreCtx()
+// verify verifies that the store wasn't rolled back.
+func (c *Conn) verify(store *kv.Store) (err error) {
+	c.sequence, err = store.Sequence()
+	if err != nil {
+		return errors.Wrap(err, "kv.Store.Sequence")
 	}
+	return c.witness.verify(c.id, c.sequence)
+}
+
+// Release returns the connection to the connection pool, closing
+// the store unless the connection is leased.
+func (c *Conn) Release() error {
 	if c.Store == nil {
 		return nil
 	}
 	defer c.semaphore.Release(1)
-	if err := c.Store.Close(); err != nil {
-		return errors.Wrap(err, "kv.Store.Close")
+	if c.leased() {
+		return c.witnessSequence()
+	}
+	return c.close()
+}
+
+// close closes the store. Must be called with the semaphore held.
+func (c *Conn) close() error {
+	defer c.unreserve()
+	err := c.witnessSequence()
+	if closeErr := c.Store.Close(); closeErr != nil {
+		return multierr.Append(err, errors.Wrap(closeErr, "kv.Store.Close"))
 	}
 	c.Store = nil
+	if c.syncer != nil {
+		c.syncer.add(filepath.Join(c.fileName, kv.DbFileName))
+	}
+	return err
+}
+
+// witnessSequence witnesses the sequence of the store if it was
+// written to. Must be called with the semaphore held.
+func (c *Conn) witnessSequence() error {
+	if c.witness == nil {
+		return nil
+	}
+	sequence, err := c.Store.Sequence()
+	if err == nil && sequence > c.sequence {
+		if err = c.witness.witness(c.id, sequence); err == nil {
+			c.sequence = sequence
+		}
+	}
+	return errors.Wrap(err, "failed to witness sequence")
+}
+
+// remove removes the store once it's not in use, moving it into
+// archiveDir instead if it's not empty.
+func (c *Conn) remove(ctx context.Context, archiveDir string) error {
+	if err := c.lock(ctx); err != nil {
+		return err
+	}
+	defer c.semaphore.Release(1)
+
+	if err := c.unlease(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(c.fileName); os.IsNotExist(err) {
+		return nil
+	}
+	if c.keepTombstone {
+		if err := c.bury(); err != nil {
+			return err
+		}
+	}
+	if archiveDir != "" {
+		if err := os.MkdirAll(archiveDir, 0700); err != nil {
+			return errors.Wrap(err, "failed to create archive directory")
+		}
+		if err := os.Rename(c.fileName, filepath.Join(archiveDir, filepath.Base(c.fileName))); err != nil {
+			return errors.Wrap(err, "failed to archive store")
+		}
+	} else if err := os.RemoveAll(c.fileName); err != nil {
+		return errors.Wrap(err, "failed to remove store")
+	}
+
+	// Forget the sequence of the store, since a new one starts over.
+	if c.witness != nil {
+		return errors.Wrap(c.witness.forget(c.id), "failed to forget sequence")
+	}
 	return nil
 }
+
+// bury writes the tombstone of the store. Must be called with the semaphore held.
+func (c *Conn) bury() error {
+	if err := c.open(); err != nil {
+		return err
+	}
+	last, err := c.Store.LastSigned()
+	err = multierr.Append(err, c.Store.Close())
+	c.Store = nil
+	if err != nil {
+		return errors.Wrap(err, "failed to read watermarks")
+	}
+	return writeTombstone(c.tombstoneFileName, last)
+}
+
+// Inactive returns whether the store is marked as inactive.
+func (c *Conn) Inactive() (bool, error) {
+	_, err := os.Stat(filepath.Join(c.fileName, inactiveFileName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// setInactive marks or unmarks the store as inactive once it's not in use,
+// creating the store's directory if necessary.
+func (c *Conn) setInactive(ctx context.Context, inactive bool) error {
+	if err := c.lock(ctx); err != nil {
+		return err
+	}
+	defer c.semaphore.Release(1)
+
+	fileName := filepath.Join(c.fileName, inactiveFileName)
+	if !inactive {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to remove marker")
+		}
+		return nil
+	}
+	if err := os.MkdirAll(c.fileName, 0700); err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+	return errors.Wrap(os.WriteFile(fileName, nil, 0600), "failed to write marker")
+}
+
+// compact compacts the store once it's not in use, unless it was modified
+// within the last coldFor or wasn't modified since it was last compacted.
+// It returns nil if the store wasn't compacted.
+func (c *Conn) compact(ctx context.Context, coldFor time.Duration) (*kv.Compaction, error) {
+	if err := c.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer c.semaphore.Release(1)
+
+	// Leased stores are open, and in use by definition.
+	if c.Store != nil {
+		return nil, nil
+	}
+	path := filepath.Join(c.fileName, kv.DbFileName)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) < coldFor || info.ModTime().Equal(c.compacted) {
+		return nil, nil
+	}
+	compaction, err := kv.Compact(c.fileName)
+	if err != nil {
+		return nil, err
+	}
+	if info, err = os.Stat(path); err != nil {
+		return nil, err
+	}
+	c.compacted = info.ModTime()
+	return compaction, nil
+}
@@ -0,0 +1,44 @@
+package kvpool
+
+import (
+	"github.com/pkg/errors"
+)
+
+// errEvicted is returned by the operations of a connection which
+// was evicted from the pool, which are then retried with a new one.
+var errEvicted = errors.New("connection was evicted")
+
+// Collection is the result of collecting a pool's garbage.
+type Collection struct {
+	// Evicted is the number of connections which weren't in use and were
+	// evicted, and InUse is the number of connections which were kept.
+	Evicted int
+	InUse   int
+}
+
+// Collect evicts the connections which aren't in use from the pool, which
+// otherwise keeps one for every key acquired since it was created (such as
+// after bulk imports or deletions), and fsyncs the writes which weren't
+// fsynced yet. Evicted connections are created again when they're next used.
+func (p *Pool) Collect() (*Collection, error) {
+	p.poolMu.Lock()
+	var collection Collection
+	for id, c := range p.conn {
+		if !c.semaphore.TryAcquire(1) {
+			collection.InUse++
+			continue
+		}
+		// Leased connections keep their store open.
+		if c.Store != nil {
+			c.semaphore.Release(1)
+			collection.InUse++
+			continue
+		}
+		c.evicted = true
+		delete(p.conn, id)
+		c.semaphore.Release(1)
+		collection.Evicted++
+	}
+	p.poolMu.Unlock()
+	return &collection, p.Sync()
+}
@@ -0,0 +1,44 @@
+package kvpool
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// ErrImporting is returned when a public key is being imported by another
+// import, and should be returned by checks of it until it's imported.
+var ErrImporting = errors.New("public key is being imported")
+
+// StartImport marks the given public keys as being imported until they're
+// passed to FinishImport, so that their checks can be refused rather than
+// interleaved with the imported history. It fails with ErrImporting,
+// marking none of them, if any of them is already being imported.
+func (p *Pool) StartImport(network string, pubKeys []phase0.BLSPubKey) error {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	for _, pubKey := range pubKeys {
+		if p.importing[connID{network, pubKey}] {
+			return errors.Wrapf(ErrImporting, "%#x", pubKey)
+		}
+	}
+	for _, pubKey := range pubKeys {
+		p.importing[connID{network, pubKey}] = true
+	}
+	return nil
+}
+
+// FinishImport unmarks the given public keys as being imported.
+func (p *Pool) FinishImport(network string, pubKeys []phase0.BLSPubKey) {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	for _, pubKey := range pubKeys {
+		delete(p.importing, connID{network, pubKey})
+	}
+}
+
+// Importing returns whether a public key is being imported.
+func (p *Pool) Importing(network string, pubKey phase0.BLSPubKey) bool {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	return p.importing[connID{network, pubKey}]
+}
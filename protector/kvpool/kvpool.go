@@ -3,13 +3,23 @@ package kvpool
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
 	"github.com/pkg/errors"
 )
 
+// archiveDirName is the directory which deleted stores are archived into.
+const archiveDirName = "archive"
+
+// storePrefix is the prefix of store directories.
+const storePrefix = "kvstore-"
+
 // connID is a unique identifier for a connection.
 type connID struct {
 	network string
@@ -18,21 +28,132 @@ type connID struct {
 
 // fileName returns the database filename of the connection.
 func (id connID) fileName() string {
-	return fmt.Sprintf("kvstore-%s-%x", id.network, id.pubKey)
+	return fmt.Sprintf("%s%x", fileNamePrefix(id.network), id.pubKey)
+}
+
+// StoreName returns the name of the store's directory within the pool's directory.
+func StoreName(network string, pubKey phase0.BLSPubKey) string {
+	return connID{network, pubKey}.fileName()
+}
+
+// fileNamePrefix returns the prefix of the database filenames in a network.
+func fileNamePrefix(network string) string {
+	return fmt.Sprintf("%s%s-", storePrefix, network)
+}
+
+// witnessKey returns the key of the connection in a Witness.
+func (id connID) witnessKey() []byte {
+	return []byte(id.fileName())
 }
 
 // Pool implements a kv.Store pool with a single connection per public key in a network.
 type Pool struct {
-	dir    string
-	conn   map[connID]*Conn
-	poolMu sync.Mutex
+	dir     string
+	witness *Witness
+	conn    map[connID]*Conn
+	poolMu  sync.Mutex
+
+	// config configures how stores are opened.
+	config kv.Config
+
+	// syncInterval is how often writes are fsynced with kv.DurabilityGroup,
+	// and syncer does so, or is nil with any other durability.
+	syncInterval time.Duration
+	syncer       *syncer
+
+	// tombstones keeps the watermarks of deleted stores.
+	tombstones bool
+
+	// layout is the layout which new stores are created in, and layoutErr
+	// is why the layout of the pool's directory couldn't be read, if so.
+	layout    *Layout
+	layoutErr error
+
+	// networkDirs are the directories of networks whose
+	// stores aren't in the pool's directory.
+	networkDirs map[string]string
+
+	// budget bounds the memory maps of open stores, or is nil if unbounded.
+	budget *memoryBudget
+
+	// limit bounds the public keys of each network, or is nil if unbounded.
+	limit *keyLimit
+
+	// importing are the stores which are being imported.
+	importing map[connID]bool
+}
+
+// Option configures a Pool.
+type Option func(*Pool)
+
+// WithWitness enables rollback detection: acquiring a connection fails
+// with ErrRolledBack if it's store is older than what the witness knows.
+func WithWitness(witness *Witness) Option {
+	return func(p *Pool) {
+		p.witness = witness
+	}
+}
+
+// WithDurability sets how writes to stores are made durable. With
+// kv.DurabilityGroup, writes are fsynced every syncInterval (which must be
+// positive) instead of when they're made, and syncInterval is ignored otherwise.
+func WithDurability(durability kv.Durability, syncInterval time.Duration) Option {
+	return func(p *Pool) {
+		p.config.Durability = durability
+		p.syncInterval = syncInterval
+	}
+}
+
+// WithTuning sets the bolt options of stores which only affect performance.
+func WithTuning(tuning kv.Tuning) Option {
+	return func(p *Pool) {
+		p.config.Tuning = tuning
+	}
+}
+
+// WithWatermarksOnly keeps only the latest attestation and proposal of every
+// store, for deployments which can't afford full histories. See
+// kv.Config.WatermarksOnly.
+func WithWatermarksOnly() Option {
+	return func(p *Pool) {
+		p.config.WatermarksOnly = true
+	}
 }
 
-func New(dir string) *Pool {
-	return &Pool{
-		dir:  dir,
-		conn: make(map[connID]*Conn),
+// WithNetworkDirs puts the stores of the given networks in their own
+// directories (such as mainnet on faster storage) instead of the pool's.
+// Stores which are still in the pool's directory are used in place.
+func WithNetworkDirs(dirs map[string]string) Option {
+	return func(p *Pool) {
+		p.networkDirs = make(map[string]string, len(dirs))
+		for network, dir := range dirs {
+			p.networkDirs[network] = filepath.Clean(dir)
+		}
+	}
+}
+
+func New(dir string, opts ...Option) *Pool {
+	p := &Pool{
+		dir:       filepath.Clean(dir),
+		conn:      make(map[connID]*Conn),
+		importing: make(map[connID]bool),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	if p.layout == nil {
+		layout, err := ReadLayout(dir)
+		p.layout, p.layoutErr = &layout, err
+	}
+	if p.config.Durability == kv.DurabilityGroup {
+		p.syncer = newSyncer(p.syncInterval)
+	}
+	return p
+}
+
+// Dir returns the pool's directory.
+func (p *Pool) Dir() string {
+	return p.dir
 }
 
 // Acquire returns a connection from the pool, creating one if necessary.
@@ -42,13 +163,38 @@ func (p *Pool) Acquire(
 	network string,
 	pubKey phase0.BLSPubKey,
 ) (*Conn, error) {
-	conn := p.getOrCreate(connID{network, pubKey})
-	if err := conn.acquire(ctx); err != nil {
+	if p.layoutErr != nil {
+		return nil, p.layoutErr
+	}
+	id := connID{network, pubKey}
+	if err := p.admit(id); err != nil {
+		return nil, err
+	}
+	var conn *Conn
+	err := p.withConn(id, func(c *Conn) error {
+		conn = c
+		return c.acquire(ctx)
+	})
+	if err != nil {
+		if !p.Exists(network, pubKey) {
+			p.forget(id)
+		}
 		return nil, err
 	}
 	return conn, nil
 }
 
+// withConn calls fn with the connection of id, and again with a new
+// connection for as long as fn fails because the connection was evicted.
+func (p *Pool) withConn(id connID, fn func(*Conn) error) error {
+	for {
+		err := fn(p.getOrCreate(id))
+		if !errors.Is(err, errEvicted) {
+			return err
+		}
+	}
+}
+
 // getOrCreate returns a connection from the pool, creating one if necessary.
 func (p *Pool) getOrCreate(id connID) *Conn {
 	p.poolMu.Lock()
@@ -60,26 +206,260 @@ func (p *Pool) getOrCreate(id connID) *Conn {
 	}
 
 	// Create the connection.
-	fileName := filepath.Join(p.dir, id.fileName())
-	conn := newConn(fileName)
+	dir, path := p.storeLocation(id)
+	fileName := filepath.Join(dir, path)
+	conn := newConn(id, fileName, p.witness, p.config, p.syncer, p.tombstoneFileName(id), p.tombstones)
+	conn.budget = p.budget
 	p.conn[id] = conn
 	return conn
 }
 
-// Close closes all connections in the pool.
+// networkDir returns the directory of a network's stores.
+func (p *Pool) networkDir(network string) string {
+	if dir, ok := p.networkDirs[network]; ok {
+		return dir
+	}
+	return p.dir
+}
+
+// dirs returns the pool's directory and the directories of networks.
+func (p *Pool) dirs() []string {
+	dirs := []string{p.dir}
+	seen := map[string]bool{p.dir: true}
+	for _, dir := range p.networkDirs {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs[1:])
+	return dirs
+}
+
+// storeLocation returns the directory of a store and it's path within it,
+// which is where it exists in any of the known layouts (in the network's
+// directory, or else in the pool's), or where it would be created in the
+// pool's layout otherwise.
+func (p *Pool) storeLocation(id connID) (dir, path string) {
+	dirs := []string{p.networkDir(id.network)}
+	if dirs[0] != p.dir {
+		dirs = append(dirs, p.dir)
+	}
+	for _, dir := range dirs {
+		for _, layout := range Layouts {
+			path := layout.path(id)
+			if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+				return dir, path
+			}
+		}
+	}
+	return dirs[0], p.layout.path(id)
+}
+
+// StorePath returns the slash-separated path of a store's directory
+// within the pool's directory, or within it's network's directory.
+func (p *Pool) StorePath(network string, pubKey phase0.BLSPubKey) string {
+	_, path := p.storeLocation(connID{network, pubKey})
+	return filepath.ToSlash(path)
+}
+
+// Exists returns whether a public key has a store in the given network,
+// without creating one.
+func (p *Pool) Exists(network string, pubKey phase0.BLSPubKey) bool {
+	dir, path := p.storeLocation(connID{network, pubKey})
+	_, err := os.Stat(filepath.Join(dir, path))
+	return err == nil
+}
+
+// storeIDs returns the stores in the pool's directories. Stores in the
+// directory of another network than their own are ignored, since
+// they aren't where they're acquired from.
+func (p *Pool) storeIDs() ([]connID, error) {
+	seen := map[connID]bool{}
+	var ids []connID
+	for _, dir := range p.dirs() {
+		paths, err := StorePaths(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			id, ok := parseStoreName(filepath.Base(path))
+			if !ok || seen[id] || (dir != p.dir && dir != p.networkDir(id.network)) {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Networks returns the networks which have stores.
+func (p *Pool) Networks() ([]string, error) {
+	ids, err := p.storeIDs()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var networks []string
+	for _, id := range ids {
+		if !seen[id.network] {
+			seen[id.network] = true
+			networks = append(networks, id.network)
+		}
+	}
+	return networks, nil
+}
+
+// PubKeys returns the public keys which have a store in the given network.
+func (p *Pool) PubKeys(network string) ([]phase0.BLSPubKey, error) {
+	ids, err := p.storeIDs()
+	if err != nil {
+		return nil, err
+	}
+	var pubKeys []phase0.BLSPubKey
+	for _, id := range ids {
+		// Networks with a longer name which starts with this
+		// one (such as "prater-2") are told apart by parsing.
+		if id.network == network {
+			pubKeys = append(pubKeys, id.pubKey)
+		}
+	}
+	return pubKeys, nil
+}
+
+// Delete removes the stores of the given public keys once they're not in use.
+// If archive is true, the stores are moved into a new directory under
+// "archive" in the network's directory instead, which is returned.
+// Stores which don't exist are ignored, and later acquires start new stores,
+// from the tombstones of the deleted ones if WithTombstones is enabled.
+func (p *Pool) Delete(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+	archive bool,
+) (archiveDir string, err error) {
+	if archive {
+		archiveDir = filepath.Join(p.networkDir(network), archiveDirName, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	}
+	for _, pubKey := range pubKeys {
+		id := connID{network, pubKey}
+		err := p.withConn(id, func(conn *Conn) error {
+			return conn.remove(ctx, archiveDir)
+		})
+		if err != nil {
+			return archiveDir, errors.Wrapf(err, "failed to delete %#x", pubKey)
+		}
+		p.forget(id)
+	}
+	return archiveDir, nil
+}
+
+// SetInactive marks or unmarks the stores of the given public keys as inactive
+// once they're not in use. Marks persist until they're unmarked, and are
+// checked with Conn.Inactive.
+func (p *Pool) SetInactive(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+	inactive bool,
+) error {
+	for _, pubKey := range pubKeys {
+		err := p.withConn(connID{network, pubKey}, func(conn *Conn) error {
+			return conn.setInactive(ctx, inactive)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to mark %#x", pubKey)
+		}
+	}
+	return nil
+}
+
+// Compact compacts the store of a public key once it's not in use, if it wasn't
+// modified within the last coldFor, so that keys which are in use aren't
+// delayed by it. It returns nil if the store wasn't compacted.
+func (p *Pool) Compact(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	coldFor time.Duration,
+) (*kv.Compaction, error) {
+	var compaction *kv.Compaction
+	err := p.withConn(connID{network, pubKey}, func(conn *Conn) (err error) {
+		compaction, err = conn.compact(ctx, coldFor)
+		return err
+	})
+	return compaction, errors.Wrapf(err, "failed to compact %#x", pubKey)
+}
+
+// DiskUsage returns the total size in bytes of the files in the pool's
+// directories, including archives.
+func (p *Pool) DiskUsage() (size int64, err error) {
+	for _, dir := range p.dirs() {
+		err = filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			size += info.Size()
+			return nil
+		})
+		if err != nil {
+			return size, errors.Wrap(err, "failed to walk directory")
+		}
+	}
+	return size, nil
+}
+
+// Close closes all connections in the pool, and fsyncs
+// the writes which weren't fsynced yet.
 func (p *Pool) Close() error {
 	p.poolMu.Lock()
 	defer p.poolMu.Unlock()
 	for _, c := range p.conn {
+		// Stores kept open by a lease are closed without waiting for it.
+		if c.semaphore.TryAcquire(1) {
+			err := c.unlease()
+			c.semaphore.Release(1)
+			if err != nil {
+				return errors.Wrap(err, "failed to end lease")
+			}
+			continue
+		}
 		if err := c.Release(); err != nil {
 			return errors.Wrap(err, "Conn.Release")
 		}
 	}
 	p.conn = make(map[connID]*Conn)
+	if p.syncer != nil {
+		return errors.Wrap(p.syncer.close(), "failed to sync")
+	}
 	return nil
 }
 
-// AcquiredConns returns the number of connections currently acquired.
+// Sync fsyncs the writes which weren't fsynced yet,
+// which is a no-op unless writes are fsynced with kv.DurabilityGroup.
+func (p *Pool) Sync() error {
+	if p.syncer == nil {
+		return nil
+	}
+	return errors.Wrap(p.syncer.sync(), "failed to sync")
+}
+
+// AcquiredConns returns the number of connections currently acquired,
+// including the ones whose store is kept open by a lease.
 func (p *Pool) AcquiredConns() int {
 	p.poolMu.Lock()
 	defer p.poolMu.Unlock()
@@ -0,0 +1,508 @@
+package kvpool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_PubKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	pool := New(dir)
+	defer pool.Close()
+
+	// Create stores in networks with overlapping names.
+	for _, id := range []connID{
+		{"prater", phase0.BLSPubKey{0x1}},
+		{"prater", phase0.BLSPubKey{0x2}},
+		{"prater-2", phase0.BLSPubKey{0x3}},
+	} {
+		conn, err := pool.Acquire(ctx, id.network, id.pubKey)
+		require.NoError(t, err)
+		require.NoError(t, conn.Release())
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kvstore-prater-notes"), nil, 0600))
+
+	pubKeys, err := pool.PubKeys("prater")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []phase0.BLSPubKey{{0x1}, {0x2}}, pubKeys)
+
+	pubKeys, err = pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Empty(t, pubKeys)
+}
+
+func TestPool_ShardedLayout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Create a store in the flat layout.
+	pool := New(dir)
+	conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0xab, 0xcd})
+	require.NoError(t, err)
+	require.NoError(t, conn.SaveProposal(1, phase0.Root{0x1}))
+	require.NoError(t, conn.Release())
+	require.NoError(t, pool.Close())
+
+	// Expect new stores to be sharded, and existing ones to be kept in place.
+	pool = New(dir, WithLayout(LayoutSharded))
+	for _, pubKey := range []phase0.BLSPubKey{{0xab, 0xcd}, {0x12, 0x34}} {
+		conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+		require.NoError(t, err)
+		require.NoError(t, conn.SaveProposal(2, phase0.Root{0x1}))
+		require.NoError(t, conn.Release())
+	}
+	require.NoError(t, pool.Close())
+	require.DirExists(t, filepath.Join(dir, "12", "34", StoreName("mainnet", phase0.BLSPubKey{0x12, 0x34})))
+	require.Equal(t, StoreName("mainnet", phase0.BLSPubKey{0xab, 0xcd}), pool.StorePath("mainnet", phase0.BLSPubKey{0xab, 0xcd}))
+
+	// Expect stores in either layout to be found without the option.
+	pool = New(dir)
+	defer pool.Close()
+	pubKeys, err := pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []phase0.BLSPubKey{{0xab, 0xcd}, {0x12, 0x34}}, pubKeys)
+	networks, err := pool.Networks()
+	require.NoError(t, err)
+	require.Equal(t, []string{"mainnet"}, networks)
+	conn, err = pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x12, 0x34})
+	require.NoError(t, err)
+	defer conn.Release()
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+}
+
+func TestPool_NetworkDirs(t *testing.T) {
+	ctx := context.Background()
+	dir, mainnetDir := t.TempDir(), t.TempDir()
+	saveProposal := func(pool *Pool, network string, pubKey phase0.BLSPubKey, slot phase0.Slot) {
+		conn, err := pool.Acquire(ctx, network, pubKey)
+		require.NoError(t, err)
+		require.NoError(t, conn.SaveProposal(slot, phase0.Root{0x1}))
+		require.NoError(t, conn.Release())
+	}
+
+	// Create a mainnet store before mapping the network.
+	pool := New(dir)
+	saveProposal(pool, "mainnet", phase0.BLSPubKey{0x1}, 1)
+	require.NoError(t, pool.Close())
+
+	// Expect new stores of mainnet to be in it's own directory,
+	// and the existing one to be used in place.
+	pool = New(dir, WithNetworkDirs(map[string]string{"mainnet": mainnetDir}))
+	defer pool.Close()
+	saveProposal(pool, "mainnet", phase0.BLSPubKey{0x1}, 2)
+	saveProposal(pool, "mainnet", phase0.BLSPubKey{0x2}, 1)
+	saveProposal(pool, "holesky", phase0.BLSPubKey{0x3}, 1)
+	require.DirExists(t, filepath.Join(mainnetDir, StoreName("mainnet", phase0.BLSPubKey{0x2})))
+	require.NoDirExists(t, filepath.Join(mainnetDir, StoreName("mainnet", phase0.BLSPubKey{0x1})))
+	require.DirExists(t, filepath.Join(dir, StoreName("holesky", phase0.BLSPubKey{0x3})))
+
+	// Expect stores of other networks in mainnet's directory to be ignored.
+	require.NoError(t, os.Mkdir(filepath.Join(mainnetDir, StoreName("holesky", phase0.BLSPubKey{0x4})), 0700))
+
+	pubKeys, err := pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []phase0.BLSPubKey{{0x1}, {0x2}}, pubKeys)
+	pubKeys, err = pool.PubKeys("holesky")
+	require.NoError(t, err)
+	require.Equal(t, []phase0.BLSPubKey{{0x3}}, pubKeys)
+	networks, err := pool.Networks()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"mainnet", "holesky"}, networks)
+
+	// Expect archives to be in the network's directory.
+	archiveDir, err := pool.Delete(ctx, "mainnet", []phase0.BLSPubKey{{0x2}}, true)
+	require.NoError(t, err)
+	require.Equal(t, mainnetDir, filepath.Dir(filepath.Dir(archiveDir)))
+}
+
+func TestPool_Compact(t *testing.T) {
+	ctx := context.Background()
+	pool := New(t.TempDir())
+	defer pool.Close()
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect missing stores to be skipped.
+	compaction, err := pool.Compact(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.Nil(t, compaction)
+
+	conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	for slot := phase0.Slot(1); slot <= 1000; slot++ {
+		require.NoError(t, conn.SaveProposal(slot, phase0.Root{0x1}))
+	}
+	require.NoError(t, conn.Release())
+
+	// Expect stores which were modified recently to be skipped.
+	compaction, err = pool.Compact(ctx, "mainnet", pubKey, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, compaction)
+
+	// Expect cold stores to be compacted once, with their history intact.
+	compaction, err = pool.Compact(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.NotNil(t, compaction)
+	require.Less(t, compaction.After, compaction.Before)
+	compaction, err = pool.Compact(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.Nil(t, compaction)
+
+	conn, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	defer conn.Release()
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1000)
+}
+
+func TestPool_DurabilityGroup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	pool := New(dir, WithDurability(kv.DurabilityGroup, time.Millisecond))
+	pubKey := phase0.BLSPubKey{0x1}
+
+	conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, conn.SaveProposal(1, phase0.Root{0x1}))
+	require.NoError(t, conn.Release())
+
+	// Expect the store to be synced in the background.
+	require.Eventually(t, func() bool {
+		pool.syncer.dirtyMu.Lock()
+		defer pool.syncer.dirtyMu.Unlock()
+		return len(pool.syncer.dirty) == 0
+	}, time.Second, time.Millisecond)
+	require.NoError(t, pool.Close())
+
+	// Expect the write to be kept.
+	pool = New(dir)
+	defer pool.Close()
+	conn, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	defer conn.Release()
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+}
+
+func TestPool_AcquireDeadline(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(dir)
+	defer pool.Close()
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect acquiring a key which is in use to abort at the deadline.
+	conn, err := pool.Acquire(context.Background(), "mainnet", pubKey)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, conn.Release())
+
+	// Expect opening a store which is locked by another process
+	// to be abandoned at the deadline, rather than bolt's timeout.
+	store, err := kv.Open(filepath.Join(dir, StoreName("mainnet", pubKey)), kv.Config{})
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Expect the key to be acquired once the abandoned open is done.
+	require.NoError(t, store.Close())
+	conn, err = pool.Acquire(context.Background(), "mainnet", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, conn.Release())
+}
+
+func TestPool_Tombstones(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	pool := New(dir, WithTombstones())
+	defer pool.Close()
+	pubKey := phase0.BLSPubKey{0x1}
+
+	conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, conn.SaveProposal(5, phase0.Root{0x1}))
+	require.NoError(t, conn.SaveAttestations(
+		&kv.AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x2}},
+		&kv.AttestationRecord{Source: 3, Target: 4, SigningRoot: phase0.Root{0x3}},
+	))
+	require.NoError(t, conn.Release())
+
+	// Expect the store to be removed, but not its watermarks.
+	_, err = pool.Delete(ctx, "mainnet", []phase0.BLSPubKey{pubKey}, false)
+	require.NoError(t, err)
+	pubKeys, err := pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Empty(t, pubKeys)
+
+	// Expect a new store to start from the watermarks.
+	conn, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*kv.Proposal{{Slot: 5}}, proposals)
+	attestations, err := conn.AttestationHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*kv.AttestationRecord{{Source: 3, Target: 4}}, attestations)
+	require.NoError(t, conn.Release())
+	_, err = os.Stat(pool.tombstoneFileName(connID{"mainnet", pubKey}))
+	require.True(t, os.IsNotExist(err), err)
+
+	// Expect stores deleted without tombstones to be forgotten,
+	// and stores without history not to leave one.
+	_, err = New(dir).Delete(ctx, "mainnet", []phase0.BLSPubKey{pubKey}, false)
+	require.NoError(t, err)
+	conn, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	proposals, err = conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Empty(t, proposals)
+	require.NoError(t, conn.Release())
+	_, err = pool.Delete(ctx, "mainnet", []phase0.BLSPubKey{pubKey}, false)
+	require.NoError(t, err)
+	_, err = os.Stat(pool.tombstoneFileName(connID{"mainnet", pubKey}))
+	require.True(t, os.IsNotExist(err), err)
+}
+
+func TestPool_MemoryBudget(t *testing.T) {
+	ctx := context.Background()
+	pool := New(t.TempDir(), WithMemoryBudget(2*minMmapSize))
+	defer pool.Close()
+
+	// Expect a store to wait for memory once the budget is reserved.
+	conn1, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x1})
+	require.NoError(t, err)
+	conn2, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x2})
+	require.NoError(t, err)
+	require.Equal(t, int64(2*minMmapSize), pool.ReservedMemory())
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(timeoutCtx, "mainnet", phase0.BLSPubKey{0x3})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Expect releasing a store to make room for it.
+	require.NoError(t, conn1.Release())
+	conn3, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x3})
+	require.NoError(t, err)
+	require.NoError(t, conn2.Release())
+	require.NoError(t, conn3.Release())
+	require.Zero(t, pool.ReservedMemory())
+}
+
+func TestStoreCost(t *testing.T) {
+	require.Equal(t, int64(minMmapSize), storeCost(t.TempDir(), 0))
+	require.Equal(t, int64(1<<20), storeCost(t.TempDir(), 1<<20))
+	require.Equal(t, int64(1<<21), storeCost(t.TempDir(), 1<<20+1))
+	require.Equal(t, int64(3<<30), storeCost(t.TempDir(), 2<<30+1))
+}
+
+func TestPool_AcquireLocked(t *testing.T) {
+	ctx := context.Background()
+	pool := New(t.TempDir(), WithTuning(kv.Tuning{Timeout: 10 * time.Millisecond}))
+	defer pool.Close()
+	conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{})
+	require.NoError(t, err)
+	dir := conn.DatabasePath()
+	require.NoError(t, conn.Release())
+
+	// Expect opening to be retried while another process holds the lock.
+	locker, err := kv.Open(dir, kv.Config{})
+	require.NoError(t, err)
+	time.AfterFunc(150*time.Millisecond, func() {
+		require.NoError(t, locker.Close())
+	})
+	conn, err = pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{})
+	require.NoError(t, err)
+	require.NoError(t, conn.Release())
+
+	// Expect the lock error once the retries are exhausted.
+	locker, err = kv.Open(dir, kv.Config{})
+	require.NoError(t, err)
+	defer locker.Close()
+	_, err = pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{})
+	require.ErrorIs(t, err, kv.ErrLocked)
+}
+
+func TestPool_Freeze(t *testing.T) {
+	ctx := context.Background()
+	pool := New(t.TempDir())
+	defer pool.Close()
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect missing stores to be skipped.
+	freezing, err := pool.Freeze(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.Nil(t, freezing)
+
+	conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	for slot := phase0.Slot(1); slot <= 1000; slot++ {
+		require.NoError(t, conn.SaveProposal(slot, phase0.Root{0x1}))
+	}
+	require.NoError(t, conn.Release())
+
+	// Expect stores which were modified recently to be skipped.
+	freezing, err = pool.Freeze(ctx, "mainnet", pubKey, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, freezing)
+
+	// Expect cold stores to be frozen once, and to keep being listed.
+	freezing, err = pool.Freeze(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.NotNil(t, freezing)
+	require.Less(t, freezing.After, freezing.Before)
+	freezing, err = pool.Freeze(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.Nil(t, freezing)
+	dir := filepath.Join(pool.Dir(), pool.StorePath("mainnet", pubKey))
+	_, err = os.Stat(filepath.Join(dir, kv.DbFileName))
+	require.True(t, os.IsNotExist(err))
+	pubKeys, err := pool.PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Equal(t, []phase0.BLSPubKey{pubKey}, pubKeys)
+
+	// Expect frozen stores to be thawed with their history intact.
+	conn, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1000)
+	require.NoError(t, conn.Release())
+	_, err = os.Stat(filepath.Join(dir, FrozenFileName))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestPool_Collect(t *testing.T) {
+	ctx := context.Background()
+	pool := New(t.TempDir())
+	defer pool.Close()
+
+	inUse, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x1})
+	require.NoError(t, err)
+	defer inUse.Release()
+	for _, pubKey := range []phase0.BLSPubKey{{0x2}, {0x3}} {
+		conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+		require.NoError(t, err)
+		require.NoError(t, conn.SaveProposal(1, phase0.Root{0x1}))
+		require.NoError(t, conn.Release())
+	}
+	stale := pool.getOrCreate(connID{"mainnet", phase0.BLSPubKey{0x2}})
+
+	// Expect only the connections which aren't in use to be evicted.
+	collection, err := pool.Collect()
+	require.NoError(t, err)
+	require.Equal(t, &Collection{Evicted: 2, InUse: 1}, collection)
+
+	// Expect evicted connections to be refused, and replaced by new ones.
+	require.ErrorIs(t, stale.lock(ctx), errEvicted)
+	conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x2})
+	require.NoError(t, err)
+	require.NotSame(t, stale, conn)
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+	require.NoError(t, conn.Release())
+}
+
+func TestPool_MaxKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Create a store before the limit is enforced.
+	pool := New(dir)
+	conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x1})
+	require.NoError(t, err)
+	require.NoError(t, conn.Release())
+	require.NoError(t, pool.Close())
+
+	pool = New(dir, WithMaxKeys(2))
+	defer pool.Close()
+	acquire := func(network string, pubKey phase0.BLSPubKey) error {
+		conn, err := pool.Acquire(ctx, network, pubKey)
+		if err != nil {
+			return err
+		}
+		return conn.Release()
+	}
+
+	// Expect existing stores to count towards the limit,
+	// and new keys to be refused beyond it.
+	require.NoError(t, acquire("mainnet", phase0.BLSPubKey{0x2}))
+	require.ErrorIs(t, acquire("mainnet", phase0.BLSPubKey{0x3}), ErrTooManyKeys)
+	require.False(t, pool.Exists("mainnet", phase0.BLSPubKey{0x3}))
+	require.Equal(t, int64(1), pool.RejectedKeys())
+
+	// Expect known keys and other networks to be unaffected.
+	require.NoError(t, acquire("mainnet", phase0.BLSPubKey{0x1}))
+	require.NoError(t, acquire("prater", phase0.BLSPubKey{0x3}))
+
+	// Expect deleting a key to make room for another.
+	_, err = pool.Delete(ctx, "mainnet", []phase0.BLSPubKey{{0x1}}, false)
+	require.NoError(t, err)
+	require.NoError(t, acquire("mainnet", phase0.BLSPubKey{0x3}))
+}
+
+func TestPool_Lease(t *testing.T) {
+	ctx := context.Background()
+	pool := New(t.TempDir())
+	defer pool.Close()
+	pubKey := phase0.BLSPubKey{0x1}
+
+	_, err := pool.Lease(ctx, "mainnet", pubKey, MaxLease+time.Second)
+	require.Error(t, err)
+
+	// Expect the store to be kept open between acquires while it's leased,
+	// and to be skipped by compaction and garbage collection.
+	expires, err := pool.Lease(ctx, "mainnet", pubKey, time.Minute)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Minute), expires, time.Second)
+	require.Equal(t, 1, pool.LeasedConns())
+	conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	store := conn.Store
+	require.NoError(t, conn.Release())
+	conn, err = pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Same(t, store, conn.Store)
+	require.NoError(t, conn.Release())
+	compaction, err := pool.Compact(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.Nil(t, compaction)
+	collection, err := pool.Collect()
+	require.NoError(t, err)
+	require.Equal(t, 1, collection.InUse)
+
+	// Expect deleting the store to end it's lease.
+	_, err = pool.Delete(ctx, "mainnet", []phase0.BLSPubKey{pubKey}, false)
+	require.NoError(t, err)
+	require.Zero(t, pool.LeasedConns())
+	require.False(t, pool.Exists("mainnet", pubKey))
+
+	// Expect the store to be closed once the lease expires,
+	// after it's extended.
+	_, err = pool.Lease(ctx, "mainnet", pubKey, 50*time.Millisecond)
+	require.NoError(t, err)
+	expires, err = pool.Lease(ctx, "mainnet", pubKey, 100*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(time.Until(expires) - 20*time.Millisecond)
+	require.Equal(t, 1, pool.LeasedConns())
+	require.Eventually(t, func() bool {
+		return pool.LeasedConns() == 0
+	}, time.Second, 10*time.Millisecond)
+}
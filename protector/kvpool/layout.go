@@ -0,0 +1,208 @@
+package kvpool
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// layoutFileName is the file which records the layout of a pool's directory.
+const layoutFileName = "layout.json"
+
+// Layout is a versioned scheme of where stores are within a pool's directory.
+type Layout struct {
+	// Version identifies the layout in the layout file of a directory.
+	Version int
+
+	// Name identifies the layout in configuration.
+	Name string
+
+	path func(id connID) string
+}
+
+var (
+	// LayoutFlat puts every store directly in the pool's directory,
+	// as "kvstore-{network}-{pubkey}".
+	LayoutFlat = Layout{Version: 1, Name: "flat", path: connID.fileName}
+
+	// LayoutSharded puts stores under two levels of directories named
+	// after the first two bytes of their public key (such as
+	// "ab/cd/kvstore-..."), since directories with many entries
+	// are slow on some filesystems.
+	LayoutSharded = Layout{Version: 2, Name: "sharded", path: connID.shardedFileName}
+)
+
+// Layouts are the known layouts, which stores are found in regardless of
+// the layout of the pool, so that changing it doesn't strand existing stores.
+var Layouts = []Layout{LayoutFlat, LayoutSharded}
+
+// LayoutByName returns the known layout with the given name.
+func LayoutByName(name string) (Layout, bool) {
+	for _, layout := range Layouts {
+		if layout.Name == name {
+			return layout, true
+		}
+	}
+	return Layout{}, false
+}
+
+// WithLayout sets the layout which new stores are created in, which is
+// otherwise the one recorded in the pool's directory by Migrate, or
+// LayoutFlat if none is.
+func WithLayout(layout Layout) Option {
+	return func(p *Pool) {
+		p.layout = &layout
+	}
+}
+
+// shardedFileName returns the database filename of the connection in the
+// sharded layout, under two levels of directories named after the first two
+// bytes of the public key.
+func (id connID) shardedFileName() string {
+	shard := hex.EncodeToString(id.pubKey[:2])
+	return filepath.Join(shard[:2], shard[2:], id.fileName())
+}
+
+// layoutFile is the content of the layout file.
+type layoutFile struct {
+	Version int `json:"version"`
+}
+
+// ReadLayout returns the layout recorded in dir, or LayoutFlat if none is.
+func ReadLayout(dir string) (Layout, error) {
+	b, err := os.ReadFile(filepath.Join(dir, layoutFileName))
+	if os.IsNotExist(err) {
+		return LayoutFlat, nil
+	}
+	if err != nil {
+		return Layout{}, errors.Wrap(err, "failed to read layout file")
+	}
+	var file layoutFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return Layout{}, errors.Wrap(err, "failed to decode layout file")
+	}
+	for _, layout := range Layouts {
+		if layout.Version == file.Version {
+			return layout, nil
+		}
+	}
+	return Layout{}, errors.Errorf("unknown layout version %d, which may have been written by a newer release", file.Version)
+}
+
+// writeLayout records the layout of dir atomically.
+func writeLayout(dir string, layout Layout) error {
+	b, err := json.Marshal(layoutFile{Version: layout.Version})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, layoutFileName+".tmp")
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "failed to write layout file")
+	}
+	return errors.Wrap(os.Rename(tmp, filepath.Join(dir, layoutFileName)), "failed to write layout file")
+}
+
+// Migrate moves the stores in dir, which must not be in use, into the given
+// layout, and records it as the layout of dir. It returns the number of stores
+// moved. Each store is moved atomically, so an interrupted migration leaves
+// stores in either layout, which are still found, and can be resumed.
+func Migrate(dir string, layout Layout) (moved int, err error) {
+	paths, err := StorePaths(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range paths {
+		id, ok := parseStoreName(filepath.Base(path))
+		if !ok {
+			continue
+		}
+		target := layout.path(id)
+		if target == path {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, target)); !os.IsNotExist(err) {
+			return moved, errors.Errorf("both %s and %s exist", path, target)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(target)), 0700); err != nil {
+			return moved, errors.Wrap(err, "failed to create directory")
+		}
+		if err := os.Rename(filepath.Join(dir, path), filepath.Join(dir, target)); err != nil {
+			return moved, errors.Wrapf(err, "failed to move %s", path)
+		}
+		moved++
+
+		// Remove the shards which were left empty, which fails if they aren't.
+		for parent := filepath.Dir(path); parent != "."; parent = filepath.Dir(parent) {
+			if os.Remove(filepath.Join(dir, parent)) != nil {
+				break
+			}
+		}
+	}
+	return moved, writeLayout(dir, layout)
+}
+
+// StorePaths returns the paths of the store directories within dir
+// in any of the known layouts.
+func StorePaths(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read directory")
+	}
+	var paths []string
+	for _, entry := range entries {
+		switch name := entry.Name(); {
+		case !entry.IsDir():
+		case strings.HasPrefix(name, storePrefix):
+			paths = append(paths, name)
+		case isShard(name):
+			shards, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read shard")
+			}
+			for _, shard := range shards {
+				if !shard.IsDir() || !isShard(shard.Name()) {
+					continue
+				}
+				stores, err := os.ReadDir(filepath.Join(dir, name, shard.Name()))
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to read shard")
+				}
+				for _, store := range stores {
+					if store.IsDir() && strings.HasPrefix(store.Name(), storePrefix) {
+						paths = append(paths, filepath.Join(name, shard.Name(), store.Name()))
+					}
+				}
+			}
+		}
+	}
+	return paths, nil
+}
+
+// isShard reports whether name is a shard directory of the sharded layout.
+func isShard(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == 1 && name == strings.ToLower(name)
+}
+
+// parseStoreName returns the network and public key of a store's directory name.
+func parseStoreName(name string) (id connID, ok bool) {
+	sep := strings.LastIndexByte(name, '-')
+	if !strings.HasPrefix(name, storePrefix) || sep < len(storePrefix) {
+		return id, false
+	}
+	b, err := hex.DecodeString(name[sep+1:])
+	if err != nil || len(b) != phase0.PublicKeyLength {
+		return id, false
+	}
+	id.network = name[len(storePrefix):sep]
+	copy(id.pubKey[:], b)
+	return id, true
+}
@@ -0,0 +1,68 @@
+package kvpool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	saveProposal := func(pool *Pool, pubKey phase0.BLSPubKey, slot phase0.Slot) {
+		conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+		require.NoError(t, err)
+		require.NoError(t, conn.SaveProposal(slot, phase0.Root{0x1}))
+		require.NoError(t, conn.Release())
+	}
+
+	pool := New(dir)
+	saveProposal(pool, phase0.BLSPubKey{0xab, 0xcd}, 1)
+	saveProposal(pool, phase0.BLSPubKey{0x12, 0x34}, 1)
+	require.NoError(t, pool.Close())
+
+	// Expect stores to be moved into the sharded layout,
+	// which new stores are then created in.
+	moved, err := Migrate(dir, LayoutSharded)
+	require.NoError(t, err)
+	require.Equal(t, 2, moved)
+	layout, err := ReadLayout(dir)
+	require.NoError(t, err)
+	require.Equal(t, LayoutSharded.Version, layout.Version)
+	require.NoDirExists(t, filepath.Join(dir, StoreName("mainnet", phase0.BLSPubKey{0xab, 0xcd})))
+
+	pool = New(dir)
+	saveProposal(pool, phase0.BLSPubKey{0xab, 0xcd}, 2)
+	saveProposal(pool, phase0.BLSPubKey{0x56, 0x78}, 1)
+	require.NoError(t, pool.Close())
+	require.DirExists(t, filepath.Join(dir, "56", "78", StoreName("mainnet", phase0.BLSPubKey{0x56, 0x78})))
+
+	// Expect migrating back to move every store and remove the emptied shards.
+	moved, err = Migrate(dir, LayoutFlat)
+	require.NoError(t, err)
+	require.Equal(t, 3, moved)
+	require.NoDirExists(t, filepath.Join(dir, "ab"))
+	pool = New(dir)
+	defer pool.Close()
+	conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0xab, 0xcd})
+	require.NoError(t, err)
+	defer conn.Release()
+	proposals, err := conn.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 2)
+}
+
+func TestPool_UnknownLayout(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, layoutFileName), []byte(`{"version": 100}`), 0600))
+
+	// Expect stores not to be created where a newer release wouldn't find them.
+	pool := New(dir)
+	defer pool.Close()
+	_, err := pool.Acquire(context.Background(), "mainnet", phase0.BLSPubKey{0x1})
+	require.ErrorContains(t, err, "unknown layout version 100")
+}
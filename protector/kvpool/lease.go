@@ -0,0 +1,102 @@
+package kvpool
+
+import (
+	"context"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// MaxLease is the longest a connection can be leased for at once.
+const MaxLease = 10 * time.Minute
+
+// Lease keeps the store of a public key open for d, so that acquiring it
+// meanwhile doesn't open it again (and thaw it, verify it's sequence and run
+// migrations), such as for a block proposer which makes several checks for
+// one key within a slot. Leasing a key which is already leased extends it's
+// lease, and returns when the lease expires.
+//
+// Maintenance which needs the store closed, such as compaction and freezing,
+// skips leased stores, while deleting a store ends it's lease.
+func (p *Pool) Lease(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	d time.Duration,
+) (time.Time, error) {
+	if d <= 0 || d > MaxLease {
+		return time.Time{}, errors.Errorf("lease must be positive and at most %s", MaxLease)
+	}
+	conn, err := p.Acquire(ctx, network, pubKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expires := conn.lease(d)
+	return expires, conn.Release()
+}
+
+// LeasedConns returns the number of connections which are currently leased.
+func (p *Pool) LeasedConns() int {
+	p.poolMu.Lock()
+	defer p.poolMu.Unlock()
+	var n int
+	for _, c := range p.conn {
+		if c.semaphore.TryAcquire(1) {
+			if c.Store != nil {
+				n++
+			}
+			c.semaphore.Release(1)
+		}
+	}
+	return n
+}
+
+// lease extends the lease of the connection to at least d from now,
+// and returns when it expires. Must be called with the store open.
+func (c *Conn) lease(d time.Duration) time.Time {
+	if expires := time.Now().Add(d); expires.After(c.leasedUntil) {
+		c.leasedUntil = expires
+	}
+	if c.leaseTimer == nil {
+		c.leaseTimer = time.AfterFunc(time.Until(c.leasedUntil), c.expire)
+	}
+	return c.leasedUntil
+}
+
+// leased returns whether the connection is leased.
+// Must be called with the semaphore held.
+func (c *Conn) leased() bool {
+	return time.Now().Before(c.leasedUntil)
+}
+
+// expire closes the store once it's lease expires, unless the lease was
+// extended, in which case it waits for it to expire again.
+func (c *Conn) expire() {
+	if err := c.lock(context.Background()); err != nil {
+		return
+	}
+	defer c.semaphore.Release(1)
+	if c.leased() {
+		c.leaseTimer = time.AfterFunc(time.Until(c.leasedUntil), c.expire)
+		return
+	}
+	c.leaseTimer = nil
+	if c.Store != nil {
+		_ = c.close()
+	}
+}
+
+// unlease ends the lease of the connection, closing the store if the
+// lease kept it open. Must be called with the semaphore held.
+func (c *Conn) unlease() error {
+	c.leasedUntil = time.Time{}
+	if c.leaseTimer != nil {
+		c.leaseTimer.Stop()
+		c.leaseTimer = nil
+	}
+	if c.Store == nil {
+		return nil
+	}
+	return c.close()
+}
@@ -0,0 +1,88 @@
+package kvpool
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// ErrTooManyKeys is returned when acquiring the store of a new public key
+// in a network which already has the maximum number of public keys.
+var ErrTooManyKeys = errors.New("too many public keys in network")
+
+// keyLimit bounds the number of public keys with a store in each network.
+type keyLimit struct {
+	max      int
+	rejected int64
+
+	// keys are the public keys of each network, which are listed from
+	// the pool's directories when a network's key is first acquired.
+	keys map[string]map[phase0.BLSPubKey]bool
+	mu   sync.Mutex
+}
+
+// WithMaxKeys bounds the number of public keys with a store in each network,
+// so that a caller checking random public keys can't fill the disk with empty
+// stores. Acquiring the store of a new public key beyond it fails with
+// ErrTooManyKeys, while the stores of known keys are acquired as usual.
+func WithMaxKeys(max int) Option {
+	return func(p *Pool) {
+		p.limit = &keyLimit{
+			max:  max,
+			keys: make(map[string]map[phase0.BLSPubKey]bool),
+		}
+	}
+}
+
+// admit counts the public key of id towards the limit of it's network,
+// unless it's already counted, or fails with ErrTooManyKeys if it's full.
+func (p *Pool) admit(id connID) error {
+	if p.limit == nil {
+		return nil
+	}
+	l := p.limit
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys, ok := l.keys[id.network]
+	if !ok {
+		pubKeys, err := p.PubKeys(id.network)
+		if err != nil {
+			return errors.Wrap(err, "failed to list public keys")
+		}
+		keys = make(map[phase0.BLSPubKey]bool, len(pubKeys))
+		for _, pubKey := range pubKeys {
+			keys[pubKey] = true
+		}
+		l.keys[id.network] = keys
+	}
+	if keys[id.pubKey] {
+		return nil
+	}
+	if len(keys) >= l.max {
+		atomic.AddInt64(&l.rejected, 1)
+		return errors.Wrapf(ErrTooManyKeys, "%s already has %d public keys", id.network, l.max)
+	}
+	keys[id.pubKey] = true
+	return nil
+}
+
+// forget stops counting the public key of id towards the limit
+// of it's network, such as once it's store is deleted.
+func (p *Pool) forget(id connID) {
+	if p.limit == nil {
+		return
+	}
+	p.limit.mu.Lock()
+	defer p.limit.mu.Unlock()
+	delete(p.limit.keys[id.network], id.pubKey)
+}
+
+// RejectedKeys returns the number of acquires refused with ErrTooManyKeys.
+func (p *Pool) RejectedKeys() int64 {
+	if p.limit == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&p.limit.rejected)
+}
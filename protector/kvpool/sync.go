@@ -0,0 +1,88 @@
+package kvpool
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// syncer fsyncs the database files of stores opened with kv.DurabilityGroup
+// every interval, since their writes aren't fsynced when they're made.
+type syncer struct {
+	interval time.Duration
+	dirty    map[string]struct{}
+	dirtyMu  sync.Mutex
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func newSyncer(interval time.Duration) *syncer {
+	s := &syncer{
+		interval: interval,
+		dirty:    make(map[string]struct{}),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// add schedules the file at path to be fsynced.
+func (s *syncer) add(path string) {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+	s.dirty[path] = struct{}{}
+}
+
+func (s *syncer) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			// Failed files are retried on the next tick.
+			_ = s.sync()
+		}
+	}
+}
+
+// sync fsyncs the scheduled files. Files which no longer exist (such as
+// deleted stores) are skipped, and files which fail are scheduled again.
+func (s *syncer) sync() error {
+	s.dirtyMu.Lock()
+	dirty := s.dirty
+	s.dirty = make(map[string]struct{})
+	s.dirtyMu.Unlock()
+
+	var err error
+	for path := range dirty {
+		if syncErr := syncFile(path); syncErr != nil && !os.IsNotExist(syncErr) {
+			err = multierr.Append(err, errors.Wrapf(syncErr, "failed to sync %s", path))
+			s.add(path)
+		}
+	}
+	return err
+}
+
+// close stops syncing in the background and syncs a final time.
+func (s *syncer) close() error {
+	close(s.stop)
+	<-s.stopped
+	return s.sync()
+}
+
+// syncFile fsyncs the file at path, which flushes the writes
+// made to it through any file descriptor.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return multierr.Append(f.Sync(), f.Close())
+}
@@ -0,0 +1,101 @@
+package kvpool
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+)
+
+// tombstonesDirName is the directory which the tombstones of deleted stores are kept in.
+const tombstonesDirName = "tombstones"
+
+// WithTombstones keeps a tombstone of every deleted store with its highest
+// signed source epoch, target epoch and slot. If the store of the same public
+// key is created again, the tombstone is imported into it (as a minimal
+// interchange would be), so that signing at or below them is still refused.
+//
+// Tombstones are imported whether or not this is enabled, so disabling it
+// only stops new ones from being kept.
+func WithTombstones() Option {
+	return func(p *Pool) {
+		p.tombstones = true
+	}
+}
+
+// tombstoneFileName returns the path of the tombstone of the connection.
+func (p *Pool) tombstoneFileName(id connID) string {
+	return filepath.Join(p.dir, tombstonesDirName, id.fileName()+".json")
+}
+
+// writeTombstone writes the watermarks of a store into its tombstone,
+// which is synced before it's renamed into place. It doesn't write
+// anything if nothing was signed.
+func writeTombstone(fileName string, last *kv.LastSigned) (err error) {
+	if last.SourceEpoch == nil && last.TargetEpoch == nil && last.Slot == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return errors.Wrap(err, "failed to create tombstones directory")
+	}
+	f, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create tombstone")
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err := json.NewEncoder(f).Encode(last); err != nil {
+		return errors.Wrap(err, "failed to write tombstone")
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync tombstone")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to close tombstone")
+	}
+	return errors.Wrap(os.Rename(f.Name(), fileName), "failed to rename tombstone")
+}
+
+// importTombstone imports the tombstone of a store, if there is one, and then
+// removes it. The watermarks are imported as records without signing roots,
+// which refuse anything at or below them.
+func importTombstone(fileName string, store *kv.Store) error {
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read tombstone")
+	}
+	var last kv.LastSigned
+	if err := json.Unmarshal(b, &last); err != nil {
+		return errors.Wrap(err, "failed to decode tombstone")
+	}
+
+	var (
+		attestations []*kv.AttestationRecord
+		proposals    []*kv.Proposal
+	)
+	if last.SourceEpoch != nil && last.TargetEpoch != nil {
+		attestations = append(attestations, &kv.AttestationRecord{
+			Source: *last.SourceEpoch,
+			Target: *last.TargetEpoch,
+		})
+	}
+	if last.Slot != nil {
+		proposals = append(proposals, &kv.Proposal{Slot: *last.Slot})
+	}
+	if err := store.Import(attestations, proposals); err != nil {
+		return errors.Wrap(err, "failed to import tombstone")
+	}
+
+	// Importing again after a crash is harmless, since the
+	// records are already without signing roots.
+	return errors.Wrap(os.Remove(fileName), "failed to remove tombstone")
+}
@@ -0,0 +1,93 @@
+package kvpool
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrRolledBack is returned when a store is older than its last known state,
+// such as when the data directory is restored from an old backup.
+var ErrRolledBack = errors.New("database was rolled back")
+
+var witnessBucket = []byte("sequences")
+
+// Witness keeps the last known sequence of every store in a database
+// outside of the data directory, so that restoring an old copy of a store
+// (which would let it sign below its real watermarks) can be detected.
+//
+// A Witness must be stored on a different volume than the data directory,
+// since otherwise it would likely be restored along with it.
+type Witness struct {
+	db *bolt.DB
+}
+
+// OpenWitness opens or creates the witness database at the given path.
+func OpenWitness(path string) (*Witness, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "bolt.Open")
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(witnessBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Witness{db: db}, nil
+}
+
+// Close closes the witness database.
+func (w *Witness) Close() error {
+	return w.db.Close()
+}
+
+// verify returns ErrRolledBack if the given sequence is lower
+// than the last known sequence of the store.
+func (w *Witness) verify(id connID, sequence uint64) error {
+	var known uint64
+	err := w.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(witnessBucket).Get(id.witnessKey()); len(v) == 8 {
+			known = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to read witness")
+	}
+	if sequence < known {
+		return errors.Wrapf(
+			ErrRolledBack,
+			"sequence %d is lower than the last known sequence %d",
+			sequence,
+			known,
+		)
+	}
+	return nil
+}
+
+// witness records the sequence of the store. Concurrent calls are
+// batched into a single transaction to reduce the number of fsyncs.
+func (w *Witness) witness(id connID, sequence uint64) error {
+	return w.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(witnessBucket)
+		key := id.witnessKey()
+		if v := b.Get(key); len(v) == 8 && binary.BigEndian.Uint64(v) >= sequence {
+			return nil
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, sequence)
+		return b.Put(key, v)
+	})
+}
+
+// forget removes the sequence of the store, such as when it's deleted.
+func (w *Witness) forget(id connID) error {
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(witnessBucket).Delete(id.witnessKey())
+	})
+}
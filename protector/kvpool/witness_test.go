@@ -0,0 +1,58 @@
+package kvpool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWitness_DetectsRollback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	witness, err := OpenWitness(filepath.Join(t.TempDir(), "witness.db"))
+	require.NoError(t, err)
+	defer witness.Close()
+	pool := New(dir, WithWitness(witness))
+	defer pool.Close()
+
+	save := func(slot phase0.Slot) {
+		conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{})
+		require.NoError(t, err)
+		require.NoError(t, conn.SaveProposal(slot, phase0.Root{0x1}))
+		require.NoError(t, conn.Release())
+	}
+
+	// Save a proposal and take a backup of the store.
+	save(1)
+	dbPath := filepath.Join(dir, connID{"mainnet", phase0.BLSPubKey{}}.fileName(), kv.DbFileName)
+	backup, err := os.ReadFile(dbPath)
+	require.NoError(t, err)
+
+	// Save another proposal and then restore the backup.
+	save(2)
+	require.NoError(t, os.WriteFile(dbPath, backup, 0600))
+
+	// Expect the rollback to be detected.
+	_, err = pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{})
+	require.ErrorIs(t, err, ErrRolledBack)
+
+	// Expect a lost store to be detected as well.
+	require.NoError(t, os.Remove(dbPath))
+	_, err = pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{})
+	require.ErrorIs(t, err, ErrRolledBack)
+
+	// Expect other keys to be unaffected.
+	conn, err := pool.Acquire(ctx, "mainnet", phase0.BLSPubKey{0x1})
+	require.NoError(t, err)
+	require.NoError(t, conn.Release())
+
+	// Expect a deleted store to start over.
+	_, err = pool.Delete(ctx, "mainnet", []phase0.BLSPubKey{{}}, false)
+	require.NoError(t, err)
+	save(1)
+}
@@ -0,0 +1,38 @@
+package protector
+
+import (
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// ProtectorLivenessReporter is a protector that reports when each public key
+// was last checked, without acquiring their stores.
+type ProtectorLivenessReporter interface {
+	Protector
+
+	// Started returns when the protector started, before which no checks
+	// are known.
+	Started() time.Time
+
+	// LastChecks returns when each public key of the network was last
+	// successfully checked. Keys which weren't checked since the protector
+	// started are omitted.
+	LastChecks(network string) map[phase0.BLSPubKey]time.Time
+}
+
+func (p *protector) Started() time.Time {
+	return p.started
+}
+
+func (p *protector) LastChecks(network string) map[phase0.BLSPubKey]time.Time {
+	p.lastChecksMu.Lock()
+	defer p.lastChecksMu.Unlock()
+	lastChecks := make(map[phase0.BLSPubKey]time.Time)
+	for id, lastCheck := range p.lastChecks {
+		if id.network == network {
+			lastChecks[id.pubKey] = lastCheck
+		}
+	}
+	return lastChecks
+}
@@ -0,0 +1,102 @@
+package protector
+
+import (
+	"context"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+)
+
+// ProtectorFinder is a protector that can look up a single record of a public
+// key, which is cheaper than reading it's history, such as to monitor whether
+// a duty was already signed.
+type ProtectorFinder interface {
+	Protector
+
+	// FindAttestation returns the attestation signed at the given
+	// target epoch, or nil if there's none.
+	FindAttestation(ctx context.Context, network string, pubKey phase0.BLSPubKey, target phase0.Epoch) (*AttestationRecord, error)
+
+	// FindProposal returns the proposal signed at the given slot, or nil if there's none.
+	FindProposal(ctx context.Context, network string, pubKey phase0.BLSPubKey, slot phase0.Slot) (*ProposalRecord, error)
+}
+
+func (p *protector) FindAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	target phase0.Epoch,
+) (record *AttestationRecord, err error) {
+	// Keys without a store have nothing to find, and their store isn't created.
+	if !p.pool.Exists(network, pubKey) {
+		return nil, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	a, err := conn.AttestationAtTargetEpoch(target)
+	if err != nil || a == nil {
+		return nil, err
+	}
+	return &AttestationRecord{
+		Source:          a.Source,
+		Target:          a.Target,
+		SigningRoot:     a.SigningRoot,
+		SourceRoot:      a.SourceRoot,
+		TargetRoot:      a.TargetRoot,
+		BeaconBlockRoot: a.BeaconBlockRoot,
+	}, nil
+}
+
+func (p *protector) FindProposal(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	slot phase0.Slot,
+) (record *ProposalRecord, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return nil, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	signingRoot, exists, err := conn.ProposalHistoryForSlot(slot)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return &ProposalRecord{Slot: slot, SigningRoot: signingRoot}, nil
+}
+
+// ProtectorCounter is a protector that reports the number of records of a
+// public key, which is cheaper than it's full statistics, such as to decide
+// which keys need maintenance first.
+type ProtectorCounter interface {
+	Protector
+
+	// Counts returns the number of records of a public key, which are zero
+	// for keys without a store.
+	Counts(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Counts, error)
+}
+
+func (p *protector) Counts(ctx context.Context, network string, pubKey phase0.BLSPubKey) (counts *kv.Counts, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return &kv.Counts{}, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return conn.Counts()
+}
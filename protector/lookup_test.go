@@ -0,0 +1,43 @@
+package protector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadsOfUnknownKeys(t *testing.T) {
+	ctx := context.Background()
+	prtc := New(t.TempDir())
+	defer prtc.Close()
+	pubKey := phase0.BLSPubKey{0x1}
+
+	// Expect empty results or ErrUnknownKey, without creating a store.
+	history, err := prtc.HistorySince(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.Empty(t, history.Attestations)
+	require.Empty(t, history.Proposals)
+	stats, err := prtc.Stats(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Zero(t, stats.Attestations)
+	last, err := prtc.LastSigned(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Nil(t, last.TargetEpoch)
+	_, err = prtc.Digest(ctx, "mainnet", pubKey)
+	require.ErrorIs(t, err, ErrUnknownKey)
+	_, err = prtc.Verify(ctx, "mainnet", pubKey)
+	require.ErrorIs(t, err, ErrUnknownKey)
+	pubKeys, err := prtc.(ProtectorPooler).Pool().PubKeys("mainnet")
+	require.NoError(t, err)
+	require.Empty(t, pubKeys)
+
+	// Expect keys with a history to be read as before.
+	check, err := prtc.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	digest, err := prtc.Digest(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.Equal(t, 1, digest.Proposals)
+}
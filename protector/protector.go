@@ -3,18 +3,39 @@ package protector
 import (
 	"context"
 	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/forensics"
+	"github.com/bloxapp/slashing-protector/protector/kv"
 	"github.com/bloxapp/slashing-protector/protector/kvpool"
 	"github.com/pkg/errors"
-	"github.com/prysmaticlabs/prysm/v3/config/params"
-	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
-	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
-	"github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1/slashings"
-	"github.com/prysmaticlabs/prysm/v3/validator/db/kv"
 	"go.uber.org/multierr"
 )
 
+// SlotsPerEpoch is the number of slots in an epoch, which is
+// the same in every network.
+const SlotsPerEpoch = 32
+
+// ErrReadOnly is returned when checking an attestation or a proposal
+// with a read-only Protector, such as a read replica.
+var ErrReadOnly = errors.New("protector is read-only")
+
+// ErrOverloaded is returned when too many checks are waiting to run.
+var ErrOverloaded = errors.New("too many checks are waiting, try again later")
+
+// ErrInactive is returned when checking an attestation or a proposal
+// of a public key which was deactivated.
+var ErrInactive = errors.New("public key is inactive")
+
+// ErrUnknownKey is returned when reading what only exists for a public key
+// with a history, such as it's digest, for a key which has none. Reading
+// doesn't create a history for a key.
+var ErrUnknownKey = errors.New("public key has no history")
+
 // Check is the result of an attestation check or a proposal check.
 type Check struct {
 	Slashable bool   `json:"slashable"`
@@ -36,8 +57,37 @@ func notSlashable() *Check {
 
 // History is the slashing protection history for a public key.
 type History struct {
-	Attestations []*kv.AttestationRecord
-	Proposals    []*kv.Proposal
+	Attestations []*AttestationRecord
+	Proposals    []*ProposalRecord
+}
+
+// AttestationRecord is a signed attestation in a History.
+type AttestationRecord struct {
+	Source      phase0.Epoch
+	Target      phase0.Epoch
+	SigningRoot phase0.Root
+
+	// SourceRoot, TargetRoot and BeaconBlockRoot are the roots the
+	// attestation voted for, which are zero if they aren't known, such as
+	// for imported attestations.
+	SourceRoot      phase0.Root
+	TargetRoot      phase0.Root
+	BeaconBlockRoot phase0.Root
+}
+
+// ProposalRecord is a signed proposal in a History.
+type ProposalRecord struct {
+	Slot        phase0.Slot
+	SigningRoot phase0.Root
+}
+
+// Stats are statistics of the slashing protection history for a public key.
+type Stats struct {
+	kv.Stats
+
+	// LastCheck is when an attestation or a proposal was last checked,
+	// or zero if none was checked since the Protector was created.
+	LastCheck time.Time
 }
 
 // Protector is the interface for slashing protection.
@@ -62,6 +112,44 @@ type Protector interface {
 
 	// History returns the slashing protection history for a public key.
 	History(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*History, error)
+
+	// HistorySince returns the slashing protection history for a public key
+	// from the given epoch onwards: attestations with a target epoch at or
+	// after it, and proposals at or after its first slot.
+	HistorySince(ctx context.Context, network string, pubKey phase0.BLSPubKey, epoch phase0.Epoch) (*History, error)
+
+	// Digest returns a digest of the slashing protection history for a public key,
+	// which allows comparing histories without transferring them. It returns
+	// ErrUnknownKey if the public key has no history.
+	Digest(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Digest, error)
+
+	// Stats returns statistics of the slashing protection history for a public key.
+	Stats(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*Stats, error)
+
+	// LastSigned returns the highest signed source epoch, target epoch
+	// and proposal slot of a public key.
+	LastSigned(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.LastSigned, error)
+
+	// Backup writes a consistent copy of the database of a public key to the
+	// writer returned by open, which is given the size of the copy.
+	Backup(ctx context.Context, network string, pubKey phase0.BLSPubKey, open func(size int64) (io.Writer, error)) error
+
+	// Delete removes the slashing protection history of the given public keys,
+	// archiving it instead if archive is true. It returns the archive's directory.
+	Delete(ctx context.Context, network string, pubKeys []phase0.BLSPubKey, archive bool) (archiveDir string, err error)
+
+	// Verify checks the database of a public key for integrity
+	// and returns the violations found, if any. It returns ErrUnknownKey
+	// if the public key has no history.
+	Verify(ctx context.Context, network string, pubKey phase0.BLSPubKey) ([]kv.Finding, error)
+
+	// Deactivate marks the given public keys as inactive, so that their checks
+	// fail with ErrInactive until they're activated again. Their histories
+	// are kept and can still be read.
+	Deactivate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error
+
+	// Activate reverts Deactivate.
+	Activate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error
 }
 
 // ProtectorCloser is a Protector that must be closed.
@@ -80,22 +168,156 @@ type ProtectorPooler interface {
 	Pool() *kvpool.Pool
 }
 
+// ProtectorQueuer is a protector that exposes it's queue of asynchronous writes.
+type ProtectorQueuer interface {
+	Protector
+
+	// AsyncQueue returns the number of acknowledged attestations which are
+	// yet to be saved, and how long ago the oldest of them was acknowledged.
+	AsyncQueue() (depth int, lag time.Duration)
+}
+
+// ProtectorFlusher is a protector that can save it's pending writes on demand.
+type ProtectorFlusher interface {
+	Protector
+
+	// Flush saves the acknowledged attestations which are yet to be saved,
+	// and fsyncs the writes which weren't fsynced yet.
+	Flush() error
+}
+
 type protector struct {
-	pool *kvpool.Pool
+	pool        *kvpool.Pool
+	poolOptions []kvpool.Option
+
+	// commitInterval is how long attestation saves are batched for
+	// before being committed. Zero disables group commits.
+	commitInterval time.Duration
+	queues         map[keyID]*attestationQueue
+	queuesMu       sync.Mutex
+
+	// commits tracks the scheduled group commits, which Close flushes
+	// and waits for, after which closed rejects further ones.
+	commits sync.WaitGroup
+	closed  bool
+
+	// readOnly rejects checks, since they record the signed data.
+	readOnly bool
+
+	// async is the state of asynchronous writes, or nil if disabled.
+	async *asyncState
+
+	// scheduler limits concurrent checks, or is nil if unlimited.
+	scheduler           *scheduler
+	maxConcurrentChecks int
+	maxQueuedChecks     int
+
+	// started is when the protector started.
+	started time.Time
+
+	// lastChecks is when each public key was last checked.
+	lastChecks   map[keyID]time.Time
+	lastChecksMu sync.Mutex
+
+	// forensics records the evidence of slashable checks, or is nil if disabled.
+	forensics *forensics.Recorder
+
+	// duplicates detects keys checked on more than one network, or is nil if disabled.
+	duplicates *duplicateDetector
+
+	// slow reports slow operations, or is nil if disabled.
+	slow *slowReporter
+}
+
+// Option configures a Protector.
+type Option func(*protector)
+
+// WithCommitInterval enables group commits: attestation records for the
+// same public key which arrive within the given interval are saved
+// together in a single transaction, reducing the number of fsyncs
+// under heavy load at the cost of up to interval added latency.
+func WithCommitInterval(interval time.Duration) Option {
+	return func(p *protector) {
+		p.commitInterval = interval
+	}
+}
+
+// WithReadOnly rejects attestation and proposal checks with ErrReadOnly,
+// while still serving reads such as History.
+func WithReadOnly() Option {
+	return func(p *protector) {
+		p.readOnly = true
+	}
+}
+
+// WithMaxConcurrentChecks limits the number of checks which run concurrently.
+// When the limit is reached, waiting proposal checks are admitted before
+// waiting attestation checks, since a missed proposal is far more costly
+// than a late attestation. Zero means no limit.
+func WithMaxConcurrentChecks(n int) Option {
+	return func(p *protector) {
+		p.maxConcurrentChecks = n
+	}
 }
 
-// New returns a concurrent-safe Protector that leverages Prysm's KVStore
-// to store slashing protection data with validator-level isolation,
+// WithMaxQueuedChecks rejects checks with ErrOverloaded when the limit of
+// WithMaxConcurrentChecks is reached and n checks are already waiting, so that
+// they fail fast instead of piling up until after their duty's deadline.
+// Zero means no limit.
+func WithMaxQueuedChecks(n int) Option {
+	return func(p *protector) {
+		p.maxQueuedChecks = n
+	}
+}
+
+// WithPoolOptions configures the underlying connection pool.
+func WithPoolOptions(opts ...kvpool.Option) Option {
+	return func(p *protector) {
+		p.poolOptions = append(p.poolOptions, opts...)
+	}
+}
+
+// New returns a concurrent-safe Protector that stores slashing protection
+// data in bolt databases with validator-level isolation,
 // so that each public key has it's own separate database for every network.
-func New(dir string) ProtectorCloser {
-	return &protector{
-		pool: kvpool.New(dir),
+func New(dir string, opts ...Option) ProtectorCloser {
+	p := &protector{
+		started:    time.Now(),
+		queues:     make(map[keyID]*attestationQueue),
+		lastChecks: make(map[keyID]time.Time),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.pool = kvpool.New(dir, p.poolOptions...)
+	if p.maxConcurrentChecks > 0 {
+		p.scheduler = newScheduler(p.maxConcurrentChecks, p.maxQueuedChecks)
 	}
+	if p.async != nil {
+		p.startAsync()
+	}
+	return p
 }
 
 // Close closes the database.
 func (p *protector) Close() error {
-	return p.pool.Close()
+	p.flushCommits()
+	var err error
+	if p.async != nil {
+		err = errors.Wrap(p.stopAsync(), "failed to save pending attestations")
+	}
+	return multierr.Append(err, p.pool.Close())
+}
+
+// Flush saves the acknowledged attestations which are yet to be saved,
+// and fsyncs the writes which weren't fsynced yet.
+func (p *protector) Flush() error {
+	if p.async != nil {
+		if err := p.checkpoint(); err != nil {
+			return errors.Wrap(err, "failed to save pending attestations")
+		}
+	}
+	return p.pool.Sync()
 }
 
 // Pool returns the underlying connection pool.
@@ -110,44 +332,113 @@ func (p *protector) CheckAttestation(
 	signingRoot phase0.Root,
 	data *phase0.AttestationData,
 ) (check *Check, err error) {
-	conn, err := p.pool.Acquire(ctx, network, pubKey)
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
+	defer p.timed(keyID{network, pubKey}, "CheckAttestation")()
+	defer func() {
+		if err == nil {
+			p.checked(network, pubKey)
+		}
+	}()
+	if p.async == nil && p.commitInterval > 0 {
+		return p.commitAttestation(ctx, network, pubKey, signingRoot, data)
+	}
+	done, err := p.schedule(ctx, lowPriority)
 	if err != nil {
-		return nil, errors.Wrap(err, "kvpool.Acquire")
+		return nil, err
+	}
+	defer done()
+	if p.async != nil {
+		return p.asyncAttestation(ctx, network, pubKey, signingRoot, data)
+	}
+
+	conn, err := p.acquireActive(ctx, network, pubKey)
+	if err != nil {
+		return nil, err
 	}
 	defer func() {
 		err = p.release(err, conn)
 	}()
 
+	check, err = p.checkAttestation(conn, keyID{network, pubKey}, signingRoot, data)
+	if err != nil || check.Slashable {
+		return check, err
+	}
+	defer p.timed(keyID{network, pubKey}, "SaveAttestations")()
+	if err := conn.SaveAttestations(newAttestationRecord(signingRoot, data)); err != nil {
+		return nil, errors.Wrap(err, "could not save attestation history for validator public key")
+	}
+	return notSlashable(), nil
+}
+
+// checkAttestation checks an attestation against the history in conn without
+// recording it, other than as forensics evidence and a refused attempt if
+// it's slashable.
+func (p *protector) checkAttestation(
+	conn *kvpool.Conn,
+	id keyID,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+) (check *Check, err error) {
+	defer func() {
+		if err == nil && check.Slashable {
+			p.attestationForensics(conn, id, signingRoot, data, check)
+			err = saveAttempt(conn, &kv.Attempt{
+				Kind:        kv.AttemptAttestation,
+				SigningRoot: signingRoot,
+				Source:      data.Source.Epoch,
+				Target:      data.Target.Epoch,
+			}, check)
+			if err != nil {
+				check = nil
+			}
+		}
+	}()
+	if data.Source.Epoch > data.Target.Epoch {
+		return slashable(
+			"could not sign attestation with source epoch greater than target epoch, %d > %d",
+			data.Source.Epoch,
+			data.Target.Epoch,
+		), nil
+	}
+
 	// Based on EIP3076, validator should refuse to sign any attestation with source epoch less
 	// than the minimum source epoch present in that signer’s attestations.
-	lowestSourceEpoch, exists, err := conn.LowestSignedSourceEpoch(ctx, pubKey)
+	done := p.timed(id, "LowestSignedSourceEpoch")
+	lowestSourceEpoch, exists, err := conn.LowestSignedSourceEpoch()
+	done()
 	if err != nil {
 		return nil, err
 	}
-	if exists && types.Epoch(data.Source.Epoch) < lowestSourceEpoch {
+	if exists && data.Source.Epoch < lowestSourceEpoch {
 		return slashable(
 			"could not sign attestation lower than lowest source epoch in db, %d < %d",
 			data.Source.Epoch,
 			lowestSourceEpoch,
 		), nil
 	}
-	existingSigningRoot, err := conn.SigningRootAtTargetEpoch(
-		ctx,
-		pubKey,
-		types.Epoch(data.Target.Epoch),
-	)
+	done = p.timed(id, "SigningRootAtTargetEpoch")
+	existingSigningRoot, existingExists, err := conn.SigningRootAtTargetEpoch(data.Target.Epoch)
+	done()
 	if err != nil {
 		return nil, err
 	}
-	signingRootsDiffer := slashings.SigningRootsDiffer(existingSigningRoot, signingRoot)
+	// If the existing signing root is empty, we always consider
+	// the incoming attestation as different to be safe.
+	signingRootsDiffer := !existingExists ||
+		existingSigningRoot == (phase0.Root{}) ||
+		existingSigningRoot != signingRoot
 
 	// Based on EIP3076, validator should refuse to sign any attestation with target epoch less
 	// than or equal to the minimum target epoch present in that signer’s attestations.
-	lowestTargetEpoch, exists, err := conn.LowestSignedTargetEpoch(ctx, pubKey)
+	done = p.timed(id, "LowestSignedTargetEpoch")
+	lowestTargetEpoch, exists, err := conn.LowestSignedTargetEpoch()
+	done()
 	if err != nil {
 		return nil, err
 	}
-	if signingRootsDiffer && exists && types.Epoch(data.Target.Epoch) <= lowestTargetEpoch {
+	if signingRootsDiffer && exists && data.Target.Epoch <= lowestTargetEpoch {
 		return slashable(
 			"could not sign attestation lower than or equal to lowest target epoch in db, %d <= %d",
 			data.Target.Epoch,
@@ -155,52 +446,44 @@ func (p *protector) CheckAttestation(
 		), nil
 	}
 
-	// Convert the attestation to a type compatible with Prysm's kv.
-	prysmAtt := &ethpb.IndexedAttestation{
-		// TODO: AttestingIndices and Signatures are currently not used in
-		// Prysm's attestation check, but this might change and break the
-		// CheckSlashableAttestation call.
-		AttestingIndices: []uint64{},
-		Signature:        nil,
-
-		Data: &ethpb.AttestationData{
-			Slot:            types.Slot(data.Slot),
-			CommitteeIndex:  types.CommitteeIndex(data.Index),
-			BeaconBlockRoot: data.BeaconBlockRoot[:],
-			Source: &ethpb.Checkpoint{
-				Epoch: types.Epoch(data.Source.Epoch),
-				Root:  data.Source.Root[:],
-			},
-			Target: &ethpb.Checkpoint{
-				Epoch: types.Epoch(data.Target.Epoch),
-				Root:  data.Target.Root[:],
-			},
-		},
-	}
-	slashingKind, err := conn.CheckSlashableAttestation(ctx, pubKey, signingRoot, prysmAtt)
+	done = p.timed(id, "CheckSlashableAttestation")
+	conflict, err := conn.CheckSlashableAttestation(data.Source.Epoch, data.Target.Epoch, signingRoot)
+	done()
 	if err != nil {
-		switch slashingKind {
+		return nil, err
+	}
+	if conflict != nil {
+		switch conflict.Kind {
 		case kv.DoubleVote:
-			return slashable("Attestation is slashable as it is a double vote: %v", err), nil
+			return slashable("Attestation is slashable as it is a double vote: %v", conflict), nil
 		case kv.SurroundingVote:
 			return slashable(
 				"Attestation is slashable as it is surrounding a previous attestation: %v",
-				err,
+				conflict,
 			), nil
 		case kv.SurroundedVote:
 			return slashable(
 				"Attestation is slashable as it is surrounded by a previous attestation: %v",
-				err,
+				conflict,
 			), nil
 		}
-		return nil, err
-	}
-	if err := conn.SaveAttestationForPubKey(ctx, pubKey, signingRoot, prysmAtt); err != nil {
-		return nil, errors.Wrap(err, "could not save attestation history for validator public key")
+		return nil, conflict
 	}
 	return notSlashable(), nil
 }
 
+// newAttestationRecord returns the record of a signed attestation.
+func newAttestationRecord(signingRoot phase0.Root, data *phase0.AttestationData) *kv.AttestationRecord {
+	return &kv.AttestationRecord{
+		Source:          data.Source.Epoch,
+		Target:          data.Target.Epoch,
+		SigningRoot:     signingRoot,
+		SourceRoot:      data.Source.Root,
+		TargetRoot:      data.Target.Root,
+		BeaconBlockRoot: data.BeaconBlockRoot,
+	}
+}
+
 func (p *protector) CheckProposal(
 	ctx context.Context,
 	network string,
@@ -208,24 +491,73 @@ func (p *protector) CheckProposal(
 	signingRoot phase0.Root,
 	slot phase0.Slot,
 ) (check *Check, err error) {
-	conn, err := p.pool.Acquire(ctx, network, pubKey)
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
+	defer p.timed(keyID{network, pubKey}, "CheckProposal")()
+	defer func() {
+		if err == nil {
+			p.checked(network, pubKey)
+		}
+	}()
+	done, err := p.schedule(ctx, highPriority)
 	if err != nil {
-		return nil, errors.Wrap(err, "kvpool.Acquire")
+		return nil, err
+	}
+	defer done()
+
+	conn, err := p.acquireActive(ctx, network, pubKey)
+	if err != nil {
+		return nil, err
 	}
 	defer func() {
 		err = p.release(err, conn)
 	}()
 
-	prevSigningRoot, proposalAtSlotExists, err := conn.ProposalHistoryForSlot(
-		ctx,
-		pubKey,
-		types.Slot(slot),
-	)
+	check, err = p.checkProposal(conn, keyID{network, pubKey}, signingRoot, slot)
+	if err != nil || check.Slashable {
+		return check, err
+	}
+	defer p.timed(keyID{network, pubKey}, "SaveProposal")()
+	if err := conn.SaveProposal(slot, signingRoot); err != nil {
+		return nil, errors.Wrap(err, "failed to save updated proposal history")
+	}
+	return notSlashable(), nil
+}
+
+// checkProposal checks a proposal against the history in conn without
+// recording it, other than as forensics evidence and a refused attempt if
+// it's slashable.
+func (p *protector) checkProposal(
+	conn *kvpool.Conn,
+	id keyID,
+	signingRoot phase0.Root,
+	slot phase0.Slot,
+) (check *Check, err error) {
+	defer func() {
+		if err == nil && check.Slashable {
+			p.proposalForensics(conn, id, signingRoot, slot, check)
+			err = saveAttempt(conn, &kv.Attempt{
+				Kind:        kv.AttemptProposal,
+				SigningRoot: signingRoot,
+				Slot:        slot,
+			}, check)
+			if err != nil {
+				check = nil
+			}
+		}
+	}()
+
+	done := p.timed(id, "ProposalHistoryForSlot")
+	prevSigningRoot, proposalAtSlotExists, err := conn.ProposalHistoryForSlot(slot)
+	done()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get proposal history")
 	}
 
-	lowestSignedProposalSlot, lowestProposalExists, err := conn.LowestSignedProposal(ctx, pubKey)
+	done = p.timed(id, "LowestSignedProposal")
+	lowestSignedProposalSlot, lowestProposalExists, err := conn.LowestSignedProposal()
+	done()
 	if err != nil {
 		return nil, err
 	}
@@ -234,7 +566,7 @@ func (p *protector) CheckProposal(
 	// If the signing root is empty (zero hash), then we consider it slashable. If signing root is not empty,
 	// we check if it is different than the incoming block's signing root. If that is the case,
 	// we consider that proposal slashable.
-	signingRootIsDifferent := prevSigningRoot == params.BeaconConfig().ZeroHash ||
+	signingRootIsDifferent := prevSigningRoot == (phase0.Root{}) ||
 		prevSigningRoot != signingRoot
 	if proposalAtSlotExists && signingRootIsDifferent {
 		return slashable(
@@ -247,22 +579,33 @@ func (p *protector) CheckProposal(
 	// In the case the slot of the incoming block is equal to the minimum signed proposal, we
 	// then also check the signing root is different.
 	if lowestProposalExists && signingRootIsDifferent &&
-		lowestSignedProposalSlot >= types.Slot(slot) {
+		lowestSignedProposalSlot >= slot {
 		return slashable(
 			"could not sign block with slot <= lowest signed slot in db, lowest signed slot: %d >= block slot: %d",
 			lowestSignedProposalSlot,
 			slot,
 		), nil
 	}
-
-	if err := conn.SaveProposalHistoryForSlot(ctx, pubKey, types.Slot(slot), signingRoot[:]); err != nil {
-		return nil, errors.Wrap(err, "failed to save updated proposal history")
-	}
 	return notSlashable(), nil
 }
 
-func (p *protector) History(ctx context.Context, network string, pubKey phase0.BLSPubKey) (history *History, err error) {
-	conn, err := p.pool.Acquire(ctx, network, pubKey)
+func (p *protector) History(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*History, error) {
+	return p.HistorySince(ctx, network, pubKey, 0)
+}
+
+func (p *protector) HistorySince(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	epoch phase0.Epoch,
+) (history *History, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return &History{
+			Attestations: []*AttestationRecord{},
+			Proposals:    []*ProposalRecord{},
+		}, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "kvpool.Acquire")
 	}
@@ -270,18 +613,253 @@ func (p *protector) History(ctx context.Context, network string, pubKey phase0.B
 		err = p.release(err, conn)
 	}()
 
-	history = &History{}
-	history.Proposals, err = conn.ProposalHistoryForPubKey(ctx, pubKey)
+	// The epoch's first slot saturates rather than overflows.
+	slot := phase0.Slot(math.MaxUint64)
+	if epoch <= math.MaxUint64/SlotsPerEpoch {
+		slot = phase0.Slot(epoch) * SlotsPerEpoch
+	}
+	proposals, err := conn.ProposalHistorySince(slot)
 	if err != nil {
 		return nil, err
 	}
-	history.Attestations, err = conn.AttestationHistoryForPubKey(ctx, pubKey)
+	attestations, err := conn.AttestationHistorySince(epoch)
 	if err != nil {
 		return nil, err
 	}
+	history = &History{
+		Attestations: make([]*AttestationRecord, len(attestations)),
+		Proposals:    make([]*ProposalRecord, len(proposals)),
+	}
+	for i, a := range attestations {
+		history.Attestations[i] = &AttestationRecord{
+			Source:          a.Source,
+			Target:          a.Target,
+			SigningRoot:     a.SigningRoot,
+			SourceRoot:      a.SourceRoot,
+			TargetRoot:      a.TargetRoot,
+			BeaconBlockRoot: a.BeaconBlockRoot,
+		}
+	}
+	for i, p := range proposals {
+		history.Proposals[i] = &ProposalRecord{
+			Slot:        p.Slot,
+			SigningRoot: p.SigningRoot,
+		}
+	}
 	return history, nil
 }
 
+func (p *protector) Digest(ctx context.Context, network string, pubKey phase0.BLSPubKey) (digest *kv.Digest, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return nil, ErrUnknownKey
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+
+	digest, err = conn.Digest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to digest")
+	}
+	return digest, nil
+}
+
+// checked records that a public key was checked.
+func (p *protector) checked(network string, pubKey phase0.BLSPubKey) {
+	now := time.Now()
+	p.lastChecksMu.Lock()
+	p.lastChecks[keyID{network, pubKey}] = now
+	p.lastChecksMu.Unlock()
+
+	if p.duplicates != nil {
+		if duplicate := p.duplicates.observe(network, pubKey, now); duplicate != nil {
+			p.duplicates.report(duplicate)
+		}
+	}
+}
+
+func (p *protector) Stats(ctx context.Context, network string, pubKey phase0.BLSPubKey) (stats *Stats, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return &Stats{}, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+
+	kvStats, err := conn.Stats()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get stats")
+	}
+	p.lastChecksMu.Lock()
+	lastCheck := p.lastChecks[keyID{network, pubKey}]
+	p.lastChecksMu.Unlock()
+	return &Stats{Stats: *kvStats, LastCheck: lastCheck}, nil
+}
+
+func (p *protector) LastSigned(ctx context.Context, network string, pubKey phase0.BLSPubKey) (last *kv.LastSigned, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return &kv.LastSigned{}, nil
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+
+	last, err = conn.LastSigned()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get last signed")
+	}
+	return last, nil
+}
+
+func (p *protector) Backup(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	open func(size int64) (io.Writer, error),
+) (err error) {
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return conn.Backup(open)
+}
+
+func (p *protector) Delete(
+	ctx context.Context,
+	network string,
+	pubKeys []phase0.BLSPubKey,
+	archive bool,
+) (string, error) {
+	// Save pending attestations first, so that they're archived as well.
+	if p.async != nil {
+		for _, pubKey := range pubKeys {
+			conn, err := p.acquireFlushed(ctx, network, pubKey)
+			if err != nil {
+				return "", errors.Wrap(err, "kvpool.Acquire")
+			}
+			if err := p.release(nil, conn); err != nil {
+				return "", err
+			}
+		}
+	}
+	archiveDir, err := p.pool.Delete(ctx, network, pubKeys, archive)
+	if err != nil {
+		return archiveDir, err
+	}
+	p.lastChecksMu.Lock()
+	defer p.lastChecksMu.Unlock()
+	for _, pubKey := range pubKeys {
+		delete(p.lastChecks, keyID{network, pubKey})
+	}
+	return archiveDir, nil
+}
+
+func (p *protector) Verify(ctx context.Context, network string, pubKey phase0.BLSPubKey) (findings []kv.Finding, err error) {
+	if !p.pool.Exists(network, pubKey) {
+		return nil, ErrUnknownKey
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+
+	findings, err = conn.Verify()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify")
+	}
+	return findings, nil
+}
+
+func (p *protector) Deactivate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error {
+	// Save pending attestations first, so that no state of the keys is left in memory.
+	if p.async != nil {
+		for _, pubKey := range pubKeys {
+			conn, err := p.acquireFlushed(ctx, network, pubKey)
+			if err != nil {
+				return errors.Wrap(err, "kvpool.Acquire")
+			}
+			if err := p.release(nil, conn); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.pool.SetInactive(ctx, network, pubKeys, true); err != nil {
+		return err
+	}
+	if p.async != nil {
+		p.async.keysMu.Lock()
+		for _, pubKey := range pubKeys {
+			delete(p.async.keys, keyID{network, pubKey})
+		}
+		p.async.keysMu.Unlock()
+	}
+	p.lastChecksMu.Lock()
+	defer p.lastChecksMu.Unlock()
+	for _, pubKey := range pubKeys {
+		delete(p.lastChecks, keyID{network, pubKey})
+	}
+	return nil
+}
+
+func (p *protector) Activate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error {
+	return p.pool.SetInactive(ctx, network, pubKeys, false)
+}
+
+// acquireActive acquires a connection to check with, failing with
+// ErrInactive if the public key is inactive, or with kvpool.ErrImporting
+// if it's being imported.
+func (p *protector) acquireActive(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kvpool.Conn, error) {
+	done := p.timed(keyID{network, pubKey}, "Acquire")
+	conn, err := p.pool.Acquire(ctx, network, pubKey)
+	done()
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	// Imports mark keys before acquiring them, so checks of a key which
+	// acquire it after it's marked are refused until it's imported.
+	if p.pool.Importing(network, pubKey) {
+		return nil, p.release(kvpool.ErrImporting, conn)
+	}
+	inactive, err := conn.Inactive()
+	if err == nil && inactive {
+		err = ErrInactive
+	}
+	if err != nil {
+		return nil, p.release(err, conn)
+	}
+	return conn, nil
+}
+
+// schedule waits for the scheduler to admit a check,
+// and returns a function to call once the check is done.
+func (p *protector) schedule(ctx context.Context, prio priority) (func(), error) {
+	if p.scheduler == nil {
+		return func() {}, nil
+	}
+	if err := p.scheduler.acquire(ctx, prio); err != nil {
+		return nil, errors.Wrap(err, "failed to schedule check")
+	}
+	return p.scheduler.release, nil
+}
+
 // release releases conn and returns an error combined with the given error.
 func (p *protector) release(err error, conn *kvpool.Conn) error {
 	return multierr.Append(
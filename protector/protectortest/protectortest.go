@@ -0,0 +1,202 @@
+// Package protectortest provides an in-process mock of protector.Protector,
+// so that integrations can be tested without running a server.
+package protectortest
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+)
+
+var _ protector.Protector = (*Mock)(nil)
+
+// Call is a recorded call to a Mock. Only the fields
+// which are arguments of the method are set.
+type Call struct {
+	Method      string
+	Network     string
+	PubKey      phase0.BLSPubKey
+	PubKeys     []phase0.BLSPubKey
+	SigningRoot phase0.Root
+	Attestation *phase0.AttestationData
+	Slot        phase0.Slot
+	Epoch       phase0.Epoch
+	Archive     bool
+}
+
+// Mock is a protector.Protector which records its calls and responds with
+// the functions set on it. Methods whose function isn't set allow every
+// check and return empty results. A Mock is safe for concurrent use,
+// but its functions must be set before it's used.
+type Mock struct {
+	CheckAttestationFunc func(ctx context.Context, network string, pubKey phase0.BLSPubKey, signingRoot phase0.Root, data *phase0.AttestationData) (*protector.Check, error)
+	CheckProposalFunc    func(ctx context.Context, network string, pubKey phase0.BLSPubKey, signingRoot phase0.Root, slot phase0.Slot) (*protector.Check, error)
+	HistorySinceFunc     func(ctx context.Context, network string, pubKey phase0.BLSPubKey, epoch phase0.Epoch) (*protector.History, error)
+	DigestFunc           func(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Digest, error)
+	StatsFunc            func(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*protector.Stats, error)
+	LastSignedFunc       func(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.LastSigned, error)
+	BackupFunc           func(ctx context.Context, network string, pubKey phase0.BLSPubKey, open func(size int64) (io.Writer, error)) error
+	DeleteFunc           func(ctx context.Context, network string, pubKeys []phase0.BLSPubKey, archive bool) (string, error)
+	VerifyFunc           func(ctx context.Context, network string, pubKey phase0.BLSPubKey) ([]kv.Finding, error)
+	DeactivateFunc       func(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error
+	ActivateFunc         func(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error
+
+	calls   []Call
+	callsMu sync.Mutex
+}
+
+// Calls returns the calls made so far, in order.
+func (m *Mock) Calls() []Call {
+	m.callsMu.Lock()
+	defer m.callsMu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsOf returns the calls made so far to the given method, in order.
+func (m *Mock) CallsOf(method string) []Call {
+	var calls []Call
+	for _, call := range m.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls made so far.
+func (m *Mock) Reset() {
+	m.callsMu.Lock()
+	defer m.callsMu.Unlock()
+	m.calls = nil
+}
+
+func (m *Mock) record(call Call) {
+	m.callsMu.Lock()
+	defer m.callsMu.Unlock()
+	m.calls = append(m.calls, call)
+}
+
+func (m *Mock) CheckAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	data *phase0.AttestationData,
+) (*protector.Check, error) {
+	m.record(Call{
+		Method:      "CheckAttestation",
+		Network:     network,
+		PubKey:      pubKey,
+		SigningRoot: signingRoot,
+		Attestation: data,
+	})
+	if m.CheckAttestationFunc == nil {
+		return &protector.Check{}, nil
+	}
+	return m.CheckAttestationFunc(ctx, network, pubKey, signingRoot, data)
+}
+
+func (m *Mock) CheckProposal(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	signingRoot phase0.Root,
+	slot phase0.Slot,
+) (*protector.Check, error) {
+	m.record(Call{
+		Method:      "CheckProposal",
+		Network:     network,
+		PubKey:      pubKey,
+		SigningRoot: signingRoot,
+		Slot:        slot,
+	})
+	if m.CheckProposalFunc == nil {
+		return &protector.Check{}, nil
+	}
+	return m.CheckProposalFunc(ctx, network, pubKey, signingRoot, slot)
+}
+
+func (m *Mock) History(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*protector.History, error) {
+	return m.HistorySince(ctx, network, pubKey, 0)
+}
+
+func (m *Mock) HistorySince(ctx context.Context, network string, pubKey phase0.BLSPubKey, epoch phase0.Epoch) (*protector.History, error) {
+	m.record(Call{Method: "HistorySince", Network: network, PubKey: pubKey, Epoch: epoch})
+	if m.HistorySinceFunc == nil {
+		return &protector.History{}, nil
+	}
+	return m.HistorySinceFunc(ctx, network, pubKey, epoch)
+}
+
+func (m *Mock) Digest(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.Digest, error) {
+	m.record(Call{Method: "Digest", Network: network, PubKey: pubKey})
+	if m.DigestFunc == nil {
+		return &kv.Digest{}, nil
+	}
+	return m.DigestFunc(ctx, network, pubKey)
+}
+
+func (m *Mock) Stats(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*protector.Stats, error) {
+	m.record(Call{Method: "Stats", Network: network, PubKey: pubKey})
+	if m.StatsFunc == nil {
+		return &protector.Stats{}, nil
+	}
+	return m.StatsFunc(ctx, network, pubKey)
+}
+
+func (m *Mock) LastSigned(ctx context.Context, network string, pubKey phase0.BLSPubKey) (*kv.LastSigned, error) {
+	m.record(Call{Method: "LastSigned", Network: network, PubKey: pubKey})
+	if m.LastSignedFunc == nil {
+		return &kv.LastSigned{}, nil
+	}
+	return m.LastSignedFunc(ctx, network, pubKey)
+}
+
+func (m *Mock) Backup(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	open func(size int64) (io.Writer, error),
+) error {
+	m.record(Call{Method: "Backup", Network: network, PubKey: pubKey})
+	if m.BackupFunc == nil {
+		return nil
+	}
+	return m.BackupFunc(ctx, network, pubKey, open)
+}
+
+func (m *Mock) Delete(ctx context.Context, network string, pubKeys []phase0.BLSPubKey, archive bool) (string, error) {
+	m.record(Call{Method: "Delete", Network: network, PubKeys: pubKeys, Archive: archive})
+	if m.DeleteFunc == nil {
+		return "", nil
+	}
+	return m.DeleteFunc(ctx, network, pubKeys, archive)
+}
+
+func (m *Mock) Verify(ctx context.Context, network string, pubKey phase0.BLSPubKey) ([]kv.Finding, error) {
+	m.record(Call{Method: "Verify", Network: network, PubKey: pubKey})
+	if m.VerifyFunc == nil {
+		return nil, nil
+	}
+	return m.VerifyFunc(ctx, network, pubKey)
+}
+
+func (m *Mock) Deactivate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error {
+	m.record(Call{Method: "Deactivate", Network: network, PubKeys: pubKeys})
+	if m.DeactivateFunc == nil {
+		return nil
+	}
+	return m.DeactivateFunc(ctx, network, pubKeys)
+}
+
+func (m *Mock) Activate(ctx context.Context, network string, pubKeys []phase0.BLSPubKey) error {
+	m.record(Call{Method: "Activate", Network: network, PubKeys: pubKeys})
+	if m.ActivateFunc == nil {
+		return nil
+	}
+	return m.ActivateFunc(ctx, network, pubKeys)
+}
@@ -0,0 +1,46 @@
+package protectortest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMock(t *testing.T) {
+	ctx := context.Background()
+	m := &Mock{
+		CheckProposalFunc: func(_ context.Context, _ string, _ phase0.BLSPubKey, _ phase0.Root, slot phase0.Slot) (*protector.Check, error) {
+			return &protector.Check{Slashable: slot < 10, Reason: "too low"}, nil
+		},
+	}
+
+	// Expect scripted responses, and defaults otherwise.
+	check, err := m.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x2}, 5)
+	require.NoError(t, err)
+	require.Equal(t, &protector.Check{Slashable: true, Reason: "too low"}, check)
+	check, err = m.CheckAttestation(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x3}, &phase0.AttestationData{})
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+	history, err := m.History(ctx, "prater", phase0.BLSPubKey{0x4})
+	require.NoError(t, err)
+	require.Empty(t, history.Attestations)
+
+	// Expect the calls to be recorded in order.
+	require.Equal(t, []Call{{
+		Method:      "CheckProposal",
+		Network:     "mainnet",
+		PubKey:      phase0.BLSPubKey{0x1},
+		SigningRoot: phase0.Root{0x2},
+		Slot:        5,
+	}}, m.CallsOf("CheckProposal"))
+	calls := m.Calls()
+	require.Len(t, calls, 3)
+	require.Equal(t, "HistorySince", calls[2].Method)
+	require.Equal(t, phase0.BLSPubKey{0x4}, calls[2].PubKey)
+
+	m.Reset()
+	require.Empty(t, m.Calls())
+}
@@ -0,0 +1,58 @@
+package protector
+
+import (
+	"context"
+	"math"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+)
+
+// ProtectorPruner is a protector that can prune the histories of it's keys.
+type ProtectorPruner interface {
+	ProtectorPooler
+
+	// Prune removes the records of a public key which are more than keep
+	// epochs older than it's latest ones, refusing to sign anything at or
+	// below them from then on. See kv.Store.Prune.
+	Prune(ctx context.Context, network string, pubKey phase0.BLSPubKey, keep phase0.Epoch) (*kv.Pruning, error)
+}
+
+func (p *protector) Prune(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	keep phase0.Epoch,
+) (pruning *kv.Pruning, err error) {
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+
+	last, err := conn.LastSigned()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read watermarks")
+	}
+	var target phase0.Epoch
+	if last.TargetEpoch != nil && *last.TargetEpoch > keep {
+		target = *last.TargetEpoch - keep
+	}
+	// The kept slots saturate rather than overflow.
+	keepSlots := phase0.Slot(math.MaxUint64)
+	if keep <= math.MaxUint64/SlotsPerEpoch {
+		keepSlots = phase0.Slot(keep) * SlotsPerEpoch
+	}
+	var slot phase0.Slot
+	if last.Slot != nil && *last.Slot > keepSlots {
+		slot = *last.Slot - keepSlots
+	}
+	pruning, err = conn.Prune(target, slot)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prune")
+	}
+	return pruning, nil
+}
@@ -0,0 +1,185 @@
+// Package pruner prunes the histories of a protector's keys in the background
+// according to a retention policy, so that databases stay small without an
+// operator ever pruning them.
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultBatchSize is the default number of keys pruned every interval.
+	defaultBatchSize = 100
+
+	// busyTimeout is how long to wait for a key which is in use before
+	// skipping it until it comes around again, so that pruning never holds
+	// up checks for long.
+	busyTimeout = 100 * time.Millisecond
+)
+
+type keyID struct {
+	network string
+	pubKey  phase0.BLSPubKey
+}
+
+// Pruner periodically prunes a rolling batch of the keys of a protector.
+type Pruner struct {
+	logger    *zap.Logger
+	protector protector.ProtectorPruner
+	keep      phase0.Epoch
+	batchSize int
+
+	// deferred reports whether pruning should wait for the next interval.
+	deferred func() bool
+
+	// next is the position in the keys to resume pruning from.
+	next int
+}
+
+// Option configures a Pruner.
+type Option func(*Pruner)
+
+// WithDeferral defers pruning to the next interval while deferred
+// returns true, such as while checks are slower than their objective.
+func WithDeferral(deferred func() bool) Option {
+	return func(p *Pruner) {
+		p.deferred = deferred
+	}
+}
+
+// WithBatchSize sets the number of keys pruned every interval, which is
+// 100 by default. Every key is pruned once every n/batchSize intervals.
+func WithBatchSize(batchSize int) Option {
+	return func(p *Pruner) {
+		p.batchSize = batchSize
+	}
+}
+
+// New returns a Pruner which keeps the records of every key within keep
+// epochs of it's latest ones. See protector.ProtectorPruner.
+func New(logger *zap.Logger, protector protector.ProtectorPruner, keep phase0.Epoch, opts ...Option) *Pruner {
+	p := &Pruner{
+		logger:    logger,
+		protector: protector,
+		keep:      keep,
+		batchSize: defaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run prunes the next batch of keys every interval until ctx is done. Each
+// batch starts after a random delay of up to a quarter of the interval, so
+// that instances sharing storage don't prune at the same time every epoch.
+func (p *Pruner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(rand.Int63n(int64(interval)/4 + 1))):
+		}
+		if err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+			p.logger.Error("failed to prune", zap.Error(err))
+		}
+	}
+}
+
+// Prune prunes the next batch of keys one at a time, resuming where the last
+// batch ended. Keys which are in use are skipped until they come around again,
+// and frozen stores are skipped since they aren't written to.
+func (p *Pruner) Prune(ctx context.Context) error {
+	ids, err := p.keys()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	pool := p.protector.Pool()
+	batchSize := p.batchSize
+	if batchSize > len(ids) {
+		batchSize = len(ids)
+	}
+	var pruned, skipped, attestations, proposals int
+	defer func() {
+		if pruned > 0 || skipped > 0 {
+			p.logger.Info("pruned stores",
+				zap.Int("count", pruned),
+				zap.Int("skipped", skipped),
+				zap.Int("attestations", attestations),
+				zap.Int("proposals", proposals),
+			)
+		}
+	}()
+	for i := 0; i < batchSize; i++ {
+		if p.deferred != nil && p.deferred() {
+			p.logger.Info("deferred pruning", zap.Int("pruned", pruned))
+			return nil
+		}
+		id := ids[p.next%len(ids)]
+		p.next = (p.next + 1) % len(ids)
+		if pool.Frozen(id.network, id.pubKey) {
+			continue
+		}
+		keyCtx, cancel := context.WithTimeout(ctx, busyTimeout)
+		pruning, err := p.protector.Prune(keyCtx, id.network, id.pubKey, p.keep)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to prune %#x", id.pubKey)
+		}
+		if pruning.Attestations == 0 && pruning.Proposals == 0 {
+			continue
+		}
+		pruned++
+		attestations += pruning.Attestations
+		proposals += pruning.Proposals
+		p.logger.Debug("pruned store",
+			zap.String("network", id.network),
+			zap.String("pub_key", fmt.Sprintf("%#x", id.pubKey)),
+			zap.Int("attestations", pruning.Attestations),
+			zap.Int("proposals", pruning.Proposals),
+		)
+	}
+	return nil
+}
+
+// keys returns the keys of every network, in a stable order.
+func (p *Pruner) keys() ([]keyID, error) {
+	pool := p.protector.Pool()
+	networks, err := pool.Networks()
+	if err != nil {
+		return nil, err
+	}
+	var ids []keyID
+	for _, network := range networks {
+		pubKeys, err := pool.PubKeys(network)
+		if err != nil {
+			return nil, err
+		}
+		for _, pubKey := range pubKeys {
+			ids = append(ids, keyID{network, pubKey})
+		}
+	}
+	return ids, nil
+}
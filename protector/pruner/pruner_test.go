@@ -0,0 +1,69 @@
+package pruner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPruner_Prune(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	pruner := prtc.(protector.ProtectorPruner)
+
+	// Sign 10 epochs of attestations and blocks with two keys.
+	pubKeys := []phase0.BLSPubKey{{0x1}, {0x2}}
+	for _, pubKey := range pubKeys {
+		for epoch := phase0.Epoch(1); epoch <= 10; epoch++ {
+			check, err := prtc.CheckAttestation(ctx, "mainnet", pubKey, phase0.Root{byte(epoch)}, &phase0.AttestationData{
+				Source: &phase0.Checkpoint{Epoch: epoch - 1},
+				Target: &phase0.Checkpoint{Epoch: epoch},
+			})
+			require.NoError(t, err)
+			require.False(t, check.Slashable)
+			slot := phase0.Slot(epoch) * protector.SlotsPerEpoch
+			check, err = prtc.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{byte(epoch)}, slot)
+			require.NoError(t, err)
+			require.False(t, check.Slashable)
+		}
+	}
+	historyLen := func(pubKey phase0.BLSPubKey) (int, int) {
+		history, err := prtc.History(ctx, "mainnet", pubKey)
+		require.NoError(t, err)
+		return len(history.Attestations), len(history.Proposals)
+	}
+
+	// Expect one key to be pruned at a time, keeping 3 epochs of records.
+	p := New(zap.NewNop(), pruner, 3, WithBatchSize(1))
+	require.NoError(t, p.Prune(ctx))
+	attestations, proposals := historyLen(pubKeys[0])
+	require.Equal(t, 4, attestations)
+	require.Equal(t, 4, proposals)
+	attestations, _ = historyLen(pubKeys[1])
+	require.Equal(t, 10, attestations)
+	require.NoError(t, p.Prune(ctx))
+	attestations, _ = historyLen(pubKeys[1])
+	require.Equal(t, 4, attestations)
+
+	// Expect pruned records to be refused.
+	check, err := prtc.CheckAttestation(ctx, "mainnet", pubKeys[0], phase0.Root{0xff}, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 5},
+		Target: &phase0.Checkpoint{Epoch: 6},
+	})
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+	check, err = prtc.CheckProposal(ctx, "mainnet", pubKeys[0], phase0.Root{0xff}, 6*protector.SlotsPerEpoch)
+	require.NoError(t, err)
+	require.True(t, check.Slashable)
+
+	// Expect pruning to wait while it's deferred.
+	p = New(zap.NewNop(), pruner, 0, WithDeferral(func() bool { return true }))
+	require.NoError(t, p.Prune(ctx))
+	attestations, _ = historyLen(pubKeys[0])
+	require.Equal(t, 4, attestations)
+}
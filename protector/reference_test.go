@@ -0,0 +1,189 @@
+package protector
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/stretchr/testify/require"
+)
+
+// reference is a simple implementation of the EIP-3076 rules over a list of
+// signed messages, which the protector's decisions are compared against. Like
+// the protector, it refuses to sign over messages with an empty signing root.
+type reference struct {
+	attestations []*AttestationRecord
+	proposals    []*ProposalRecord
+}
+
+func (r *reference) checkAttestation(source, target phase0.Epoch, signingRoot phase0.Root) (slashable bool) {
+	if source > target {
+		return true
+	}
+	for _, a := range r.attestations {
+		if a.Target == target {
+			// Signing the same attestation again is allowed.
+			return a.SigningRoot == (phase0.Root{}) || a.SigningRoot != signingRoot
+		}
+	}
+	if len(r.attestations) > 0 && (source < r.lowestSource() || target <= r.lowestTarget()) {
+		return true
+	}
+	for _, a := range r.attestations {
+		surrounding := source < a.Source && target > a.Target
+		surrounded := source > a.Source && target < a.Target
+		if surrounding || surrounded {
+			return true
+		}
+	}
+	r.attestations = append(r.attestations, &AttestationRecord{
+		Source:      source,
+		Target:      target,
+		SigningRoot: signingRoot,
+	})
+	return false
+}
+
+func (r *reference) lowestSource() phase0.Epoch {
+	lowest := r.attestations[0].Source
+	for _, a := range r.attestations {
+		if a.Source < lowest {
+			lowest = a.Source
+		}
+	}
+	return lowest
+}
+
+func (r *reference) lowestTarget() phase0.Epoch {
+	lowest := r.attestations[0].Target
+	for _, a := range r.attestations {
+		if a.Target < lowest {
+			lowest = a.Target
+		}
+	}
+	return lowest
+}
+
+func (r *reference) checkProposal(slot phase0.Slot, signingRoot phase0.Root) (slashable bool) {
+	for _, p := range r.proposals {
+		if p.Slot == slot {
+			// Signing the same proposal again is allowed.
+			return p.SigningRoot == (phase0.Root{}) || p.SigningRoot != signingRoot
+		}
+	}
+	if len(r.proposals) > 0 && slot <= r.lowestSlot() {
+		return true
+	}
+	r.proposals = append(r.proposals, &ProposalRecord{Slot: slot, SigningRoot: signingRoot})
+	return false
+}
+
+func (r *reference) lowestSlot() phase0.Slot {
+	lowest := r.proposals[0].Slot
+	for _, p := range r.proposals {
+		if p.Slot < lowest {
+			lowest = p.Slot
+		}
+	}
+	return lowest
+}
+
+// history returns the signed messages, ordered as in History.
+func (r *reference) history() *History {
+	history := &History{
+		Attestations: append([]*AttestationRecord{}, r.attestations...),
+		Proposals:    append([]*ProposalRecord{}, r.proposals...),
+	}
+	sort.Slice(history.Attestations, func(i, j int) bool {
+		return history.Attestations[i].Target < history.Attestations[j].Target
+	})
+	sort.Slice(history.Proposals, func(i, j int) bool {
+		return history.Proposals[i].Slot < history.Proposals[j].Slot
+	})
+	return history
+}
+
+// opSize is the number of bytes which encode an operation: its kind,
+// two epochs (or a slot) and the variant of its signing root.
+const opSize = 4
+
+// checkAgainstReference decodes operations from ops, checks each with both
+// a protector and the reference, and fails if their decisions or the
+// resulting histories differ. Epochs and slots are kept small so that
+// operations often conflict.
+func checkAgainstReference(t *testing.T, ops []byte) {
+	ctx := context.Background()
+	prtc := New(t.TempDir(), WithPoolOptions(kvpool.WithDurability(kv.DurabilityNone, 0)))
+	defer func() {
+		require.NoError(t, prtc.Close())
+	}()
+
+	const network = "mainnet"
+	pubKey := phase0.BLSPubKey{0x1}
+	ref := &reference{}
+	var log []string
+	for i := 0; i+opSize <= len(ops); i += opSize {
+		op := ops[i : i+opSize]
+		a, b := op[1]%16, op[2]%16
+
+		// Signing roots are derived from the message, so that the
+		// same root is never signed over different messages.
+		var root phase0.Root
+		if variant := op[3] % 4; variant > 0 {
+			root = phase0.Root{op[0] % 2, a, b, variant}
+		}
+
+		var check *Check
+		var want bool
+		var err error
+		if op[0]%2 == 0 {
+			source, target := phase0.Epoch(a), phase0.Epoch(b)
+			log = append(log, fmt.Sprintf("attestation (%d, %d) %#x", source, target, root[:4]))
+			want = ref.checkAttestation(source, target, root)
+			check, err = prtc.CheckAttestation(ctx, network, pubKey, root, &phase0.AttestationData{
+				Source: &phase0.Checkpoint{Epoch: source},
+				Target: &phase0.Checkpoint{Epoch: target},
+			})
+		} else {
+			slot := phase0.Slot(a)
+			log = append(log, fmt.Sprintf("proposal %d %#x", slot, root[:4]))
+			want = ref.checkProposal(slot, root)
+			check, err = prtc.CheckProposal(ctx, network, pubKey, root, slot)
+		}
+		require.NoError(t, err)
+		require.Equal(t, want, check.Slashable, "decisions differ (reason: %q) after:\n%v", check.Reason, log)
+	}
+
+	history, err := prtc.History(ctx, network, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, ref.history(), history, "histories differ after:\n%v", log)
+}
+
+func FuzzReference(f *testing.F) {
+	f.Add([]byte{})
+	// Surrounding and surrounded votes.
+	f.Add([]byte{0, 3, 5, 1, 0, 2, 6, 1, 0, 4, 4, 1})
+	// Double votes, and the same vote again.
+	f.Add([]byte{0, 1, 2, 1, 0, 1, 2, 2, 0, 1, 2, 1, 0, 1, 2, 0, 0, 1, 2, 0})
+	// Lower than the lowest source and target.
+	f.Add([]byte{0, 5, 6, 1, 0, 4, 7, 1, 0, 6, 5, 1, 0, 5, 5, 1})
+	// Double proposals, and proposals below the lowest slot.
+	f.Add([]byte{1, 3, 0, 1, 1, 3, 0, 2, 1, 3, 0, 1, 1, 2, 0, 1, 1, 4, 0, 0, 1, 4, 0, 0})
+	f.Fuzz(checkAgainstReference)
+}
+
+func TestReference(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		ops := make([]byte, 40*opSize)
+		r.Read(ops)
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			checkAgainstReference(t, ops)
+		})
+	}
+}
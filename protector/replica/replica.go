@@ -0,0 +1,327 @@
+// Package replica keeps a copy of a primary's data directory up to date,
+// so that it can be served by a read-only Protector.
+package replica
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// storePrefix is the prefix of store directories in a data directory.
+const storePrefix = "kvstore-"
+
+// Replica periodically snapshots the stores of a primary's data directory
+// into it's own data directory.
+type Replica struct {
+	logger  *zap.Logger
+	primary string
+	dir     string
+
+	// networkDirs are the directories of the primary's networks
+	// which are kept outside of it's data directory.
+	networkDirs map[string]string
+
+	// copied is the modification time of every store when it was last copied,
+	// so that unchanged stores aren't copied again.
+	copied map[string]time.Time
+
+	// applied is the state of every store's copy, and skipped are the stores
+	// which weren't copied by the last refresh, which ended at refreshed.
+	applied   map[string]appliedStore
+	skipped   map[string]bool
+	refreshed time.Time
+	statusMu  sync.Mutex
+}
+
+// appliedStore is the state of a store's copy.
+type appliedStore struct {
+	network   string
+	sequence  uint64
+	lastWrite time.Time
+}
+
+// Status is how far a replica is behind its primary.
+type Status struct {
+	// LastRefresh is when the last refresh ended, or zero if none did.
+	LastRefresh time.Time `json:"last_refresh"`
+
+	Networks map[string]*NetworkStatus `json:"networks"`
+}
+
+// NetworkStatus is the state of the copies of a network's stores.
+type NetworkStatus struct {
+	Stores int `json:"stores"`
+
+	// Sequence is the sum of the sequences of the copies, which are the
+	// numbers of write transactions committed to their stores.
+	Sequence uint64 `json:"sequence"`
+
+	// LastWrite is the time of the latest write in the copies.
+	LastWrite time.Time `json:"last_write"`
+
+	// Skipped is the number of stores which changed but weren't copied by the
+	// last refresh (such as when the primary held their lock), whose copies
+	// are therefore behind.
+	Skipped int `json:"skipped"`
+}
+
+// Option configures a Replica.
+type Option func(*Replica)
+
+// WithNetworkDirs copies the stores of the given networks from the primary's
+// directories of those networks (see kvpool.WithNetworkDirs) as well. Their
+// copies are kept in the replica's data directory.
+func WithNetworkDirs(dirs map[string]string) Option {
+	return func(r *Replica) {
+		r.networkDirs = dirs
+	}
+}
+
+// New returns a Replica of the primary data directory in dir.
+func New(logger *zap.Logger, primary, dir string, opts ...Option) *Replica {
+	r := &Replica{
+		logger:  logger,
+		primary: primary,
+		dir:     dir,
+		copied:  make(map[string]time.Time),
+		applied: make(map[string]appliedStore),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run refreshes the replica every interval until ctx is done.
+func (r *Replica) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(); err != nil {
+				r.logger.Error("failed to refresh replica", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Refresh copies the stores which changed since the last refresh.
+// Stores which are locked by the primary are skipped until the next refresh.
+func (r *Replica) Refresh() error {
+	stores, err := r.primaryStores()
+	if err != nil {
+		return err
+	}
+	var copied int
+	skipped := make(map[string]bool)
+	for _, path := range stores.paths {
+		primary := stores.dirs[path]
+		// Frozen stores only have their compressed database, which keeps the
+		// modification time of the database, so a store isn't copied again
+		// when it's frozen.
+		copyStore := r.copy
+		src := filepath.Join(primary, path, kv.DbFileName)
+		info, err := os.Stat(src)
+		if os.IsNotExist(err) {
+			copyStore = r.copyFrozen
+			src = filepath.Join(primary, path, kvpool.FrozenFileName)
+			info, err = os.Stat(src)
+			if os.IsNotExist(err) {
+				continue
+			}
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to stat store")
+		}
+		if modTime, ok := r.copied[path]; ok && modTime.Equal(info.ModTime()) {
+			continue
+		}
+		applied, err := copyStore(src, filepath.Join(r.dir, path))
+		if err != nil {
+			r.logger.Warn("skipped store", zap.String("store", path), zap.Error(err))
+			skipped[path] = true
+			continue
+		}
+		r.copied[path] = info.ModTime()
+		r.statusMu.Lock()
+		r.applied[path] = applied
+		r.statusMu.Unlock()
+		copied++
+	}
+	r.statusMu.Lock()
+	r.skipped = skipped
+	r.refreshed = time.Now()
+	r.statusMu.Unlock()
+	r.logger.Debug("refreshed replica", zap.Int("copied", copied), zap.Int("skipped", len(skipped)))
+	return nil
+}
+
+// primaryStores are the paths of the primary's stores, and the
+// directory every one of them is in.
+type primaryStores struct {
+	paths []string
+	dirs  map[string]string
+}
+
+// primaryStores lists the primary's stores in it's network directories and
+// then in it's data directory, where a store of a network which is in both is
+// taken from the network's directory, like the primary's pool does.
+func (r *Replica) primaryStores() (*primaryStores, error) {
+	stores := &primaryStores{dirs: make(map[string]string)}
+	list := func(dir string, network string) error {
+		paths, err := kvpool.StorePaths(dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list primary stores in %s", dir)
+		}
+		for _, path := range paths {
+			if _, ok := stores.dirs[path]; ok {
+				continue
+			}
+			if network != "" && storeNetwork(path) != network {
+				continue
+			}
+			stores.paths = append(stores.paths, path)
+			stores.dirs[path] = dir
+		}
+		return nil
+	}
+	networks := make([]string, 0, len(r.networkDirs))
+	for network := range r.networkDirs {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	for _, network := range networks {
+		if err := list(r.networkDirs[network], network); err != nil {
+			return nil, err
+		}
+	}
+	if err := list(r.primary, ""); err != nil {
+		return nil, err
+	}
+	return stores, nil
+}
+
+// Status returns the state of the copies by network,
+// as of the last refresh.
+func (r *Replica) Status() *Status {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	status := &Status{
+		LastRefresh: r.refreshed,
+		Networks:    make(map[string]*NetworkStatus),
+	}
+	network := func(name string) *NetworkStatus {
+		n, ok := status.Networks[name]
+		if !ok {
+			n = &NetworkStatus{}
+			status.Networks[name] = n
+		}
+		return n
+	}
+	for _, applied := range r.applied {
+		n := network(applied.network)
+		n.Stores++
+		n.Sequence += applied.sequence
+		if applied.lastWrite.After(n.LastWrite) {
+			n.LastWrite = applied.lastWrite
+		}
+	}
+	for path := range r.skipped {
+		network(storeNetwork(path)).Skipped++
+	}
+	return status
+}
+
+// storeNetwork returns the network of a store's directory path, whose
+// name is the store prefix, the network and the public key.
+func storeNetwork(path string) string {
+	name := strings.TrimPrefix(filepath.Base(path), storePrefix)
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// copy copies a consistent snapshot of the store at src into dir,
+// replacing it's previous snapshot atomically, and returns its state.
+func (r *Replica) copy(src, dir string) (applied appliedStore, err error) {
+	db, err := bolt.Open(src, 0600, &bolt.Options{
+		Timeout:  time.Second,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return applied, errors.Wrap(err, "failed to open store")
+	}
+	defer func() {
+		err = multierr.Append(err, db.Close())
+	}()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return applied, errors.Wrap(err, "failed to create directory")
+	}
+	dst := filepath.Join(dir, kv.DbFileName)
+	tmp := dst + ".tmp"
+	err = db.View(func(tx *bolt.Tx) error {
+		applied.sequence, applied.lastWrite = kv.ReadMeta(tx)
+		return tx.CopyFile(tmp, 0600)
+	})
+	if err != nil {
+		if rmErr := os.Remove(tmp); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = multierr.Append(err, rmErr)
+		}
+		return applied, errors.Wrap(err, "failed to copy store")
+	}
+	applied.network = storeNetwork(dir)
+	return applied, errors.Wrap(os.Rename(tmp, dst), "failed to replace store")
+}
+
+// copyFrozen decompresses the compressed database of a frozen store at src
+// into dir, replacing it's previous snapshot atomically, and returns its state.
+func (r *Replica) copyFrozen(src, dir string) (applied appliedStore, err error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return applied, errors.Wrap(err, "failed to create directory")
+	}
+	dst := filepath.Join(dir, kv.DbFileName)
+	tmp := dst + ".tmp"
+	defer func() {
+		if err != nil {
+			if rmErr := os.Remove(tmp); rmErr != nil && !os.IsNotExist(rmErr) {
+				err = multierr.Append(err, rmErr)
+			}
+		}
+	}()
+	if err := kvpool.Decompress(src, tmp); err != nil {
+		return applied, err
+	}
+	db, err := bolt.Open(tmp, 0600, &bolt.Options{
+		Timeout:  time.Second,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return applied, errors.Wrap(err, "failed to open store")
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		applied.sequence, applied.lastWrite = kv.ReadMeta(tx)
+		return nil
+	})
+	if err = multierr.Append(err, db.Close()); err != nil {
+		return applied, errors.Wrap(err, "failed to read store")
+	}
+	applied.network = storeNetwork(dir)
+	return applied, errors.Wrap(os.Rename(tmp, dst), "failed to replace store")
+}
@@ -0,0 +1,143 @@
+package replica
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/bloxapp/slashing-protector/protector/kvpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplica_Refresh(t *testing.T) {
+	primary, dir := t.TempDir(), t.TempDir()
+	const storeName = "kvstore-mainnet-01"
+	rep := New(zap.NewNop(), primary, dir)
+
+	saveProposal := func(slot phase0.Slot) {
+		store, err := kv.Open(filepath.Join(primary, storeName), kv.Config{})
+		require.NoError(t, err)
+		require.NoError(t, store.SaveProposal(slot, phase0.Root{0x1}))
+		require.NoError(t, store.Close())
+	}
+	requireProposals := func(expected ...*kv.Proposal) {
+		store, err := kv.Open(filepath.Join(dir, storeName), kv.Config{})
+		require.NoError(t, err)
+		defer store.Close()
+		proposals, err := store.ProposalHistory()
+		require.NoError(t, err)
+		require.Equal(t, expected, proposals)
+	}
+
+	// Expect the store to be copied.
+	saveProposal(1)
+	require.NoError(t, rep.Refresh())
+	requireProposals(&kv.Proposal{Slot: 1, SigningRoot: phase0.Root{0x1}})
+
+	// Expect changes to be copied on the next refresh.
+	saveProposal(2)
+	require.NoError(t, rep.Refresh())
+	requireProposals(
+		&kv.Proposal{Slot: 1, SigningRoot: phase0.Root{0x1}},
+		&kv.Proposal{Slot: 2, SigningRoot: phase0.Root{0x1}},
+	)
+}
+
+func TestReplica_Status(t *testing.T) {
+	primary, dir := t.TempDir(), t.TempDir()
+	rep := New(zap.NewNop(), primary, dir)
+	require.True(t, rep.Status().LastRefresh.IsZero())
+
+	open := func(name string) *kv.Store {
+		store, err := kv.Open(filepath.Join(primary, name), kv.Config{})
+		require.NoError(t, err)
+		return store
+	}
+	var sequence uint64
+	for _, name := range []string{"kvstore-mainnet-01", "kvstore-mainnet-02", "kvstore-prater-2-03"} {
+		store := open(name)
+		require.NoError(t, store.SaveProposal(1, phase0.Root{0x1}))
+		if name != "kvstore-prater-2-03" {
+			s, err := store.Sequence()
+			require.NoError(t, err)
+			sequence += s
+		}
+		require.NoError(t, store.Close())
+	}
+	require.NoError(t, rep.Refresh())
+	status := rep.Status()
+	require.False(t, status.LastRefresh.IsZero())
+	require.Len(t, status.Networks, 2)
+	require.Equal(t, 2, status.Networks["mainnet"].Stores)
+	require.Equal(t, sequence, status.Networks["mainnet"].Sequence)
+	require.False(t, status.Networks["mainnet"].LastWrite.IsZero())
+	require.Equal(t, 1, status.Networks["prater-2"].Stores)
+
+	// Expect stores which are locked by the primary to be reported as skipped.
+	store := open("kvstore-mainnet-01")
+	defer store.Close()
+	require.NoError(t, store.SaveProposal(2, phase0.Root{0x1}))
+	require.NoError(t, rep.Refresh())
+	status = rep.Status()
+	require.Equal(t, 1, status.Networks["mainnet"].Skipped)
+	require.Equal(t, sequence, status.Networks["mainnet"].Sequence)
+}
+
+func TestReplica_RefreshFrozen(t *testing.T) {
+	ctx := context.Background()
+	primary, dir := t.TempDir(), t.TempDir()
+	pool := kvpool.New(primary)
+	defer pool.Close()
+	rep := New(zap.NewNop(), primary, dir)
+
+	pubKey := phase0.BLSPubKey{0x1}
+	conn, err := pool.Acquire(ctx, "mainnet", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, conn.SaveProposal(1, phase0.Root{0x1}))
+	require.NoError(t, conn.Release())
+	freezing, err := pool.Freeze(ctx, "mainnet", pubKey, 0)
+	require.NoError(t, err)
+	require.NotNil(t, freezing)
+
+	// Expect frozen stores to be copied decompressed, without thawing them.
+	require.NoError(t, rep.Refresh())
+	require.True(t, pool.Frozen("mainnet", pubKey))
+	store, err := kv.Open(filepath.Join(dir, pool.StorePath("mainnet", pubKey)), kv.Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	proposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Equal(t, []*kv.Proposal{{Slot: 1, SigningRoot: phase0.Root{0x1}}}, proposals)
+	require.Equal(t, 1, rep.Status().Networks["mainnet"].Stores)
+}
+
+func TestReplica_RefreshNetworkDirs(t *testing.T) {
+	ctx := context.Background()
+	primary, mainnetDir, dir := t.TempDir(), t.TempDir(), t.TempDir()
+	pool := kvpool.New(primary, kvpool.WithNetworkDirs(map[string]string{"mainnet": mainnetDir}))
+	defer pool.Close()
+	rep := New(zap.NewNop(), primary, dir, WithNetworkDirs(map[string]string{"mainnet": mainnetDir}))
+
+	for _, network := range []string{"mainnet", "prater"} {
+		conn, err := pool.Acquire(ctx, network, phase0.BLSPubKey{0x1})
+		require.NoError(t, err)
+		require.NoError(t, conn.SaveProposal(1, phase0.Root{0x1}))
+		require.NoError(t, conn.Release())
+	}
+
+	// Expect the stores in the network's directory to be copied
+	// into the replica's data directory.
+	require.NoError(t, rep.Refresh())
+	status := rep.Status()
+	require.Equal(t, 1, status.Networks["mainnet"].Stores)
+	require.Equal(t, 1, status.Networks["prater"].Stores)
+	store, err := kv.Open(filepath.Join(dir, pool.StorePath("mainnet", phase0.BLSPubKey{0x1})), kv.Config{})
+	require.NoError(t, err)
+	defer store.Close()
+	proposals, err := store.ProposalHistory()
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+}
@@ -0,0 +1,61 @@
+package protector
+
+import (
+	"context"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+)
+
+// ProtectorRollbacker is a protector that can remove the latest record of a
+// public key, for when a check passed but the data was provably never signed.
+type ProtectorRollbacker interface {
+	Protector
+
+	// RollbackAttestation removes the attestation at the given target epoch,
+	// failing with kv.ErrNotLatest if it isn't the latest one.
+	RollbackAttestation(ctx context.Context, network string, pubKey phase0.BLSPubKey, target phase0.Epoch) (*kv.AttestationRecord, error)
+
+	// RollbackProposal removes the proposal at the given slot,
+	// failing with kv.ErrNotLatest if it isn't the latest one.
+	RollbackProposal(ctx context.Context, network string, pubKey phase0.BLSPubKey, slot phase0.Slot) (*kv.Proposal, error)
+}
+
+func (p *protector) RollbackAttestation(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	target phase0.Epoch,
+) (removed *kv.AttestationRecord, err error) {
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return conn.RollbackAttestation(target)
+}
+
+func (p *protector) RollbackProposal(
+	ctx context.Context,
+	network string,
+	pubKey phase0.BLSPubKey,
+	slot phase0.Slot,
+) (removed *kv.Proposal, err error) {
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
+	conn, err := p.acquireFlushed(ctx, network, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "kvpool.Acquire")
+	}
+	defer func() {
+		err = p.release(err, conn)
+	}()
+	return conn.RollbackProposal(slot)
+}
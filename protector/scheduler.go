@@ -0,0 +1,99 @@
+package protector
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// priority is the priority of a check in the scheduler.
+type priority int
+
+const (
+	lowPriority priority = iota
+	highPriority
+)
+
+// scheduler limits the number of concurrent checks, and admits waiting
+// checks of high priority before any waiting checks of low priority.
+type scheduler struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	waiting [2]*list.List
+
+	// maxWaiting is the number of waiting checks above which new checks
+	// are rejected with ErrOverloaded, or zero if unlimited.
+	maxWaiting int
+}
+
+func newScheduler(limit, maxWaiting int) *scheduler {
+	return &scheduler{
+		limit:      limit,
+		waiting:    [2]*list.List{list.New(), list.New()},
+		maxWaiting: maxWaiting,
+	}
+}
+
+// acquire waits until the check is admitted or ctx is done, or returns
+// ErrOverloaded if too many checks are waiting already.
+// The caller must call release once the check is done.
+func (s *scheduler) acquire(ctx context.Context, prio priority) error {
+	s.mu.Lock()
+	if s.running < s.limit && s.waiting[highPriority].Len() == 0 &&
+		(prio == highPriority || s.waiting[lowPriority].Len() == 0) {
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+	if s.maxWaiting > 0 && s.waiting[lowPriority].Len()+s.waiting[highPriority].Len() >= s.maxWaiting {
+		s.mu.Unlock()
+		return ErrOverloaded
+	}
+	ready := make(chan struct{})
+	elem := s.waiting[prio].PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-ready:
+			// Admitted meanwhile, so pass the slot on.
+			s.running--
+			s.admit()
+		default:
+			s.waiting[prio].Remove(elem)
+		}
+		return ctx.Err()
+	}
+}
+
+// release frees the slot of a check and admits the next waiting one.
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	s.admit()
+}
+
+// admit admits waiting checks by priority while there are free slots.
+// Must be called with mu held.
+func (s *scheduler) admit() {
+	for s.running < s.limit {
+		queue := s.waiting[highPriority]
+		if queue.Len() == 0 {
+			queue = s.waiting[lowPriority]
+		}
+		front := queue.Front()
+		if front == nil {
+			return
+		}
+		queue.Remove(front)
+		s.running++
+		close(front.Value.(chan struct{}))
+	}
+}
@@ -0,0 +1,73 @@
+package protector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Priority(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(1, 0)
+	require.NoError(t, s.acquire(ctx, lowPriority))
+
+	// Queue an attestation check before a proposal check.
+	admitted := make(chan priority, 2)
+	for _, prio := range []priority{lowPriority, highPriority} {
+		prio := prio
+		go func() {
+			if s.acquire(ctx, prio) == nil {
+				admitted <- prio
+				s.release()
+			}
+		}()
+		require.Eventually(t, func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.waiting[prio].Len() == 1
+		}, time.Second, time.Millisecond)
+	}
+
+	// Expect the proposal check to be admitted first.
+	s.release()
+	require.Equal(t, highPriority, <-admitted)
+	require.Equal(t, lowPriority, <-admitted)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	s := newScheduler(1, 0)
+	require.NoError(t, s.acquire(context.Background(), highPriority))
+
+	// Expect a waiting check to give up when it's context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.acquire(ctx, lowPriority), context.DeadlineExceeded)
+
+	// Expect the slot to be available once released.
+	s.release()
+	require.NoError(t, s.acquire(context.Background(), lowPriority))
+}
+
+func TestScheduler_Overloaded(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(1, 1)
+	require.NoError(t, s.acquire(ctx, lowPriority))
+
+	// Fill the queue.
+	go func() {
+		if s.acquire(ctx, lowPriority) == nil {
+			s.release()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.waiting[lowPriority].Len() == 1
+	}, time.Second, time.Millisecond)
+
+	// Expect further checks to be rejected immediately.
+	require.ErrorIs(t, s.acquire(ctx, highPriority), ErrOverloaded)
+	s.release()
+}
@@ -0,0 +1,84 @@
+// Package shard assigns public keys to the shards of a sharded deployment by
+// consistent hashing, so that every instance owns a deterministic slice of the
+// keys, and adding or removing a shard only moves the keys of its own slice.
+package shard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// pointsPerShard is the number of points of every shard on the ring,
+// which spreads the keys evenly across shards.
+const pointsPerShard = 256
+
+type point struct {
+	hash  uint64
+	shard int
+}
+
+// Ring is a consistent hash ring of shards, which are named
+// by the URL they're served at.
+type Ring struct {
+	shards []string
+	points []point
+}
+
+// New returns a Ring of the given shards. Rings of the same shards
+// assign keys equally regardless of the order they're given in.
+func New(shards []string) (*Ring, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("at least one shard is required")
+	}
+	r := &Ring{shards: append([]string(nil), shards...)}
+	sort.Strings(r.shards)
+	for i, shard := range r.shards {
+		if i > 0 && shard == r.shards[i-1] {
+			return nil, errors.Errorf("duplicate shard %s", shard)
+		}
+		for j := 0; j < pointsPerShard; j++ {
+			r.points = append(r.points, point{hash: hash([]byte(shard + "#" + strconv.Itoa(j))), shard: i})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		return r.points[i].shard < r.points[j].shard
+	})
+	return r, nil
+}
+
+// Shards returns the shards of the ring in order.
+func (r *Ring) Shards() []string {
+	return append([]string(nil), r.shards...)
+}
+
+// Has returns whether shard is one of the shards of the ring.
+func (r *Ring) Has(shard string) bool {
+	i := sort.SearchStrings(r.shards, shard)
+	return i < len(r.shards) && r.shards[i] == shard
+}
+
+// Owner returns the shard which owns a public key, which is the first
+// point on the ring at or after the key's hash.
+func (r *Ring) Owner(pubKey phase0.BLSPubKey) string {
+	h := hash(pubKey[:])
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.shards[r.points[i].shard]
+}
+
+func hash(b []byte) uint64 {
+	sum := sha256.Sum256(b)
+	return binary.BigEndian.Uint64(sum[:8])
+}
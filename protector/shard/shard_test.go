@@ -0,0 +1,50 @@
+package shard
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing_Owner(t *testing.T) {
+	_, err := New(nil)
+	require.Error(t, err)
+	_, err = New([]string{"http://a", "http://a"})
+	require.ErrorContains(t, err, "duplicate shard")
+
+	ring, err := New([]string{"http://a", "http://b", "http://c"})
+	require.NoError(t, err)
+	require.True(t, ring.Has("http://b"))
+	require.False(t, ring.Has("http://d"))
+	reordered, err := New([]string{"http://c", "http://a", "http://b"})
+	require.NoError(t, err)
+
+	// Expect keys to be spread across shards, and assigned
+	// regardless of the order of the shards.
+	const keys = 3000
+	owned := map[string]int{}
+	for i := 0; i < keys; i++ {
+		pubKey := phase0.BLSPubKey{byte(i), byte(i >> 8)}
+		owner := ring.Owner(pubKey)
+		require.Equal(t, owner, reordered.Owner(pubKey))
+		owned[owner]++
+	}
+	require.Len(t, owned, 3)
+	for _, n := range owned {
+		require.InDelta(t, keys/3, n, keys/10)
+	}
+
+	// Expect adding a shard to only move keys onto it.
+	grown, err := New([]string{"http://a", "http://b", "http://c", "http://d"})
+	require.NoError(t, err)
+	var moved int
+	for i := 0; i < keys; i++ {
+		pubKey := phase0.BLSPubKey{byte(i), byte(i >> 8)}
+		if owner := grown.Owner(pubKey); owner != ring.Owner(pubKey) {
+			require.Equal(t, "http://d", owner)
+			moved++
+		}
+	}
+	require.InDelta(t, keys/4, moved, keys/10)
+}
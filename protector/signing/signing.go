@@ -0,0 +1,59 @@
+// Package signing computes the signing roots of signed beacon chain objects,
+// as defined by the consensus specs, so that they needn't be trusted from callers.
+package signing
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+var (
+	// DomainBeaconProposer is the domain type of block proposals.
+	DomainBeaconProposer = phase0.DomainType{0x00, 0x00, 0x00, 0x00}
+
+	// DomainBeaconAttester is the domain type of attestations.
+	DomainBeaconAttester = phase0.DomainType{0x01, 0x00, 0x00, 0x00}
+)
+
+// ComputeDomain returns the domain of a domain type in the fork with the
+// given version, of the chain with the given genesis validators root.
+func ComputeDomain(
+	domainType phase0.DomainType,
+	forkVersion phase0.Version,
+	genesisValidatorsRoot phase0.Root,
+) (phase0.Domain, error) {
+	forkData := &phase0.ForkData{
+		CurrentVersion:        forkVersion,
+		GenesisValidatorsRoot: genesisValidatorsRoot,
+	}
+	forkDataRoot, err := forkData.HashTreeRoot()
+	if err != nil {
+		return phase0.Domain{}, errors.Wrap(err, "failed to hash fork data")
+	}
+	var domain phase0.Domain
+	copy(domain[:], domainType[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain, nil
+}
+
+// hashTreeRooter is an SSZ object.
+type hashTreeRooter interface {
+	HashTreeRoot() ([32]byte, error)
+}
+
+// SigningRoot returns the signing root of an object in the given domain.
+func SigningRoot(obj hashTreeRooter, domain phase0.Domain) (phase0.Root, error) {
+	objectRoot, err := obj.HashTreeRoot()
+	if err != nil {
+		return phase0.Root{}, errors.Wrap(err, "failed to hash object")
+	}
+	signingData := &phase0.SigningData{
+		ObjectRoot: objectRoot,
+		Domain:     domain,
+	}
+	root, err := signingData.HashTreeRoot()
+	if err != nil {
+		return phase0.Root{}, errors.Wrap(err, "failed to hash signing data")
+	}
+	return root, nil
+}
@@ -0,0 +1,52 @@
+package signing
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeDomain(t *testing.T) {
+	var mainnetGenesisValidatorsRoot phase0.Root
+	_, err := hex.Decode(mainnetGenesisValidatorsRoot[:], []byte("4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"))
+	require.NoError(t, err)
+
+	// Expect the domains to embed mainnet's fork digests.
+	for _, tt := range []struct {
+		forkVersion phase0.Version
+		forkDigest  string
+	}{
+		{phase0.Version{0x00, 0x00, 0x00, 0x00}, "b5303f2a"},
+		{phase0.Version{0x01, 0x00, 0x00, 0x00}, "afcaaba0"},
+		{phase0.Version{0x02, 0x00, 0x00, 0x00}, "4a26c58b"},
+	} {
+		domain, err := ComputeDomain(DomainBeaconAttester, tt.forkVersion, mainnetGenesisValidatorsRoot)
+		require.NoError(t, err)
+		require.Equal(t, DomainBeaconAttester[:], domain[:4])
+		require.Equal(t, tt.forkDigest, hex.EncodeToString(domain[4:8]))
+	}
+}
+
+func TestSigningRoot(t *testing.T) {
+	data := &phase0.AttestationData{
+		Slot:            1,
+		BeaconBlockRoot: phase0.Root{0x1},
+		Source:          &phase0.Checkpoint{Epoch: 1, Root: phase0.Root{0x2}},
+		Target:          &phase0.Checkpoint{Epoch: 2, Root: phase0.Root{0x3}},
+	}
+	objectRoot, err := data.HashTreeRoot()
+	require.NoError(t, err)
+
+	// Expect the signing root to commit to both the object and the domain.
+	rootA, err := SigningRoot(data, phase0.Domain{0x1})
+	require.NoError(t, err)
+	expected, err := (&phase0.SigningData{ObjectRoot: objectRoot, Domain: phase0.Domain{0x1}}).HashTreeRoot()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Root(expected), rootA)
+
+	rootB, err := SigningRoot(data, phase0.Domain{0x2})
+	require.NoError(t, err)
+	require.NotEqual(t, rootA, rootB)
+}
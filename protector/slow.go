@@ -0,0 +1,77 @@
+package protector
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// SlowOperation is a check, or a store operation of one, which took
+// longer than the threshold given to WithSlowOperations.
+type SlowOperation struct {
+	Network string
+	PubKey  phase0.BLSPubKey
+
+	// Operation is the check (such as "CheckAttestation") or the store
+	// operation (such as "SaveAttestations" or "Acquire") which was slow.
+	Operation string
+	Took      time.Duration
+}
+
+// ProtectorSlowReporter is a protector that reports slow operations.
+type ProtectorSlowReporter interface {
+	Protector
+
+	// SlowOperations returns the number of slow operations reported so far.
+	SlowOperations() int64
+}
+
+type slowReporter struct {
+	threshold time.Duration
+	report    func(*SlowOperation)
+	count     int64
+}
+
+// WithSlowOperations reports checks, and the store operations they're made
+// of, which take longer than threshold, so that latency spikes can be
+// attributed to the store call which caused them. report is called as soon
+// as the operation completes, and should return quickly.
+func WithSlowOperations(threshold time.Duration, report func(*SlowOperation)) Option {
+	return func(p *protector) {
+		p.slow = &slowReporter{
+			threshold: threshold,
+			report:    report,
+		}
+	}
+}
+
+// timed returns a function to call once operation of a key completes,
+// which reports it if it took longer than the threshold.
+func (p *protector) timed(id keyID, operation string) func() {
+	if p.slow == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		took := time.Since(start)
+		if took <= p.slow.threshold {
+			return
+		}
+		atomic.AddInt64(&p.slow.count, 1)
+		p.slow.report(&SlowOperation{
+			Network:   id.network,
+			PubKey:    id.pubKey,
+			Operation: operation,
+			Took:      took,
+		})
+	}
+}
+
+// SlowOperations returns the number of slow operations reported so far.
+func (p *protector) SlowOperations() int64 {
+	if p.slow == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&p.slow.count)
+}
@@ -0,0 +1,34 @@
+package protector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowOperations(t *testing.T) {
+	ctx := context.Background()
+	var reported []string
+	prtc := New(t.TempDir(), WithSlowOperations(0, func(op *SlowOperation) {
+		require.Equal(t, "mainnet", op.Network)
+		require.Equal(t, phase0.BLSPubKey{0x1}, op.PubKey)
+		reported = append(reported, op.Operation)
+	}))
+	defer prtc.Close()
+
+	// Expect every store operation of the check to be reported
+	// before the check itself, with no threshold.
+	check, err := prtc.CheckProposal(ctx, "mainnet", phase0.BLSPubKey{0x1}, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable, "unexpected slashing: %s", check.Reason)
+	require.Equal(t, []string{
+		"Acquire",
+		"ProposalHistoryForSlot",
+		"LowestSignedProposal",
+		"SaveProposal",
+		"CheckProposal",
+	}, reported)
+	require.Equal(t, int64(len(reported)), prtc.(ProtectorSlowReporter).SlowOperations())
+}
@@ -0,0 +1,318 @@
+// Package snapshotter takes backups of a protector on a schedule, and
+// verifies every backup by restoring it and comparing the histories in it
+// against the live ones, so that a backup which can't be restored surfaces
+// when it's taken rather than when it's needed.
+package snapshotter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/backup"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	filePrefix = "snapshot-"
+	fileSuffix = ".tar.zst"
+	timeFormat = "20060102T150405.000Z"
+)
+
+type keyID struct {
+	network string
+	pubKey  phase0.BLSPubKey
+}
+
+// Snapshot is a backup which was taken by a Snapshotter.
+type Snapshot struct {
+	Path    string
+	TakenAt time.Time
+
+	// Stores is how many stores were backed up, and Skipped is how many of
+	// them were written to while they were backed up, so their history can't
+	// be verified.
+	Stores  int
+	Skipped int
+
+	// digests are the live digests of the stores which weren't written to
+	// while they were backed up.
+	digests map[keyID]*kv.Digest
+}
+
+// Status is the state of the scheduled backups.
+type Status struct {
+	// LastBackup is when the last backup was taken, and LastVerifiedBackup
+	// is when the last backup which was verified was taken, or zero if none were.
+	LastBackup         time.Time
+	LastVerifiedBackup time.Time
+
+	// LastError is why the last backup failed or couldn't be verified,
+	// or empty if it was verified.
+	LastError string
+}
+
+// Snapshotter periodically backs up the stores of a protector into a directory,
+// keeping the most recent backups.
+type Snapshotter struct {
+	logger    *zap.Logger
+	protector protector.ProtectorPooler
+	dir       string
+	keep      int
+
+	// deferred reports whether the backup should wait for the next interval.
+	deferred func() bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Option configures a Snapshotter.
+type Option func(*Snapshotter)
+
+// WithDeferral defers backups to the next interval while deferred
+// returns true, such as while checks are slower than their objective.
+func WithDeferral(deferred func() bool) Option {
+	return func(s *Snapshotter) {
+		s.deferred = deferred
+	}
+}
+
+// New returns a Snapshotter which writes backups into dir,
+// and removes all but the keep most recent ones.
+func New(logger *zap.Logger, protector protector.ProtectorPooler, dir string, keep int, opts ...Option) *Snapshotter {
+	s := &Snapshotter{
+		logger:    logger,
+		protector: protector,
+		dir:       dir,
+		keep:      keep,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run backs up the stores and verifies the backup every interval until ctx is done.
+func (s *Snapshotter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.deferred != nil && s.deferred() {
+				s.logger.Info("deferred backup")
+				continue
+			}
+			snapshot, err := s.Snapshot(ctx)
+			if err == nil {
+				err = s.Verify(snapshot)
+			}
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("failed to back up stores", zap.Error(err))
+				s.mu.Lock()
+				s.status.LastError = err.Error()
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Status returns the state of the scheduled backups.
+func (s *Snapshotter) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Snapshot writes a compressed backup of every store into the directory,
+// and removes the backups beyond the most recent ones. The live digest of
+// every store is taken before and after it's backed up, so that its backup
+// can be verified unless it was written to in between.
+func (s *Snapshotter) Snapshot(ctx context.Context) (snapshot *Snapshot, err error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	start := time.Now().UTC()
+	snapshot = &Snapshot{
+		Path:    filepath.Join(s.dir, filePrefix+start.Format(timeFormat)+fileSuffix),
+		TakenAt: start,
+		digests: make(map[keyID]*kv.Digest),
+	}
+
+	// Write into a temporary file, which is only moved into place once
+	// the archive is complete.
+	f, err := os.CreateTemp(s.dir, ".snapshot-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create file")
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Append(err, f.Close())
+			os.Remove(f.Name())
+		}
+	}()
+	bw, err := backup.NewWriter(f, true)
+	if err != nil {
+		return nil, err
+	}
+	pool := s.protector.Pool()
+	networks, err := pool.Networks()
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		pubKeys, err := pool.PubKeys(network)
+		if err != nil {
+			return nil, err
+		}
+		for _, pubKey := range pubKeys {
+			before, err := s.protector.Digest(ctx, network, pubKey)
+			if errors.Is(err, protector.ErrUnknownKey) {
+				// Deleted since it was listed.
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to digest %#x", pubKey)
+			}
+			err = s.protector.Backup(ctx, network, pubKey, func(size int64) (io.Writer, error) {
+				return bw.Create(path.Join(pool.StorePath(network, pubKey), kv.DbFileName), size)
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to back up %#x", pubKey)
+			}
+			after, err := s.protector.Digest(ctx, network, pubKey)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to digest %#x", pubKey)
+			}
+			snapshot.Stores++
+			if *before != *after {
+				snapshot.Skipped++
+				continue
+			}
+			snapshot.digests[keyID{network, pubKey}] = after
+		}
+	}
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, errors.Wrap(err, "failed to sync file")
+	}
+	if err := f.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close file")
+	}
+	if err := os.Rename(f.Name(), snapshot.Path); err != nil {
+		return nil, errors.Wrap(err, "failed to move file")
+	}
+
+	s.mu.Lock()
+	s.status.LastBackup = snapshot.TakenAt
+	s.mu.Unlock()
+	s.logger.Info("backed up stores",
+		zap.String("path", snapshot.Path),
+		zap.Int("stores", snapshot.Stores),
+		zap.Duration("took", time.Since(start)),
+	)
+	if err := s.removeOld(); err != nil {
+		s.logger.Error("failed to remove old backups", zap.Error(err))
+	}
+	return snapshot, nil
+}
+
+// Verify restores a backup into a temporary directory, and compares the
+// digest of every store in it against the live digest taken when it was
+// backed up.
+func (s *Snapshotter) Verify(snapshot *Snapshot) (err error) {
+	dir, err := os.MkdirTemp("", "slashing-protector-verify-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer func() {
+		err = multierr.Append(err, os.RemoveAll(dir))
+	}()
+
+	f, err := os.Open(snapshot.Path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open backup")
+	}
+	_, err = backup.Restore(f, dir)
+	err = multierr.Append(err, f.Close())
+	if err != nil {
+		return errors.Wrap(err, "failed to restore backup")
+	}
+
+	pool := s.protector.Pool()
+	var mismatches []string
+	for id, expected := range snapshot.digests {
+		storeDir := filepath.Join(dir, filepath.FromSlash(pool.StorePath(id.network, id.pubKey)))
+		store, err := kv.Open(storeDir, kv.Config{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to open restored store of %#x", id.pubKey)
+		}
+		digest, err := store.Digest()
+		err = multierr.Append(err, store.Close())
+		if err != nil {
+			return errors.Wrapf(err, "failed to digest restored store of %#x", id.pubKey)
+		}
+		if *digest != *expected {
+			mismatches = append(mismatches, fmt.Sprintf("%s/%#x", id.network, id.pubKey))
+		}
+	}
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return errors.Errorf("backup %s doesn't match the live history of %s",
+			snapshot.Path, strings.Join(mismatches, ", "))
+	}
+
+	s.mu.Lock()
+	if snapshot.TakenAt.After(s.status.LastVerifiedBackup) {
+		s.status.LastVerifiedBackup = snapshot.TakenAt
+	}
+	s.status.LastError = ""
+	s.mu.Unlock()
+	s.logger.Info("verified backup",
+		zap.String("path", snapshot.Path),
+		zap.Int("verified", len(snapshot.digests)),
+		zap.Int("skipped", snapshot.Skipped),
+	)
+	return nil
+}
+
+// removeOld removes all but the most recent backups from the directory.
+func (s *Snapshotter) removeOld() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			names = append(names, name)
+		}
+	}
+	// Names sort by the time they were taken at.
+	sort.Strings(names)
+	for len(names) > s.keep {
+		if err := os.Remove(filepath.Join(s.dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
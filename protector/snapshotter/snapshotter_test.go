@@ -0,0 +1,53 @@
+package snapshotter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSnapshotter_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	for _, pubKey := range []phase0.BLSPubKey{{0x1}, {0x2}} {
+		check, err := prtc.CheckProposal(ctx, "mainnet", pubKey, phase0.Root{0x1}, 1)
+		require.NoError(t, err)
+		require.False(t, check.Slashable)
+	}
+
+	// Expect a backup of every store to be taken and verified.
+	dir := t.TempDir()
+	s := New(zap.NewNop(), prtc.(protector.ProtectorPooler), dir, 2)
+	snapshot, err := s.Snapshot(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, snapshot.Stores)
+	require.Zero(t, snapshot.Skipped)
+	require.FileExists(t, snapshot.Path)
+	require.Equal(t, snapshot.TakenAt, s.Status().LastBackup)
+	require.True(t, s.Status().LastVerifiedBackup.IsZero())
+	require.NoError(t, s.Verify(snapshot))
+	require.Equal(t, snapshot.TakenAt, s.Status().LastVerifiedBackup)
+
+	// Expect a backup which doesn't match the live history to fail verification.
+	mismatched, err := s.Snapshot(ctx)
+	require.NoError(t, err)
+	mismatched.digests[keyID{"mainnet", phase0.BLSPubKey{0x1}}].Proposals++
+	require.ErrorContains(t, s.Verify(mismatched), "doesn't match the live history of mainnet/0x01")
+	require.Equal(t, snapshot.TakenAt, s.Status().LastVerifiedBackup)
+
+	// Expect only the most recent backups to be kept.
+	latest, err := s.Snapshot(ctx)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoFileExists(t, snapshot.Path)
+	require.FileExists(t, filepath.Join(dir, filepath.Base(latest.Path)))
+}
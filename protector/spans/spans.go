@@ -0,0 +1,212 @@
+// Package spans implements surround vote detection with min-max spans,
+// similarly to Prysm's slasher, so that checking an attestation takes
+// constant time regardless of the length of the attestation history.
+//
+// For every epoch e, the min span is the minimum distance between e and
+// the target of any attestation with a source greater than e, and the max
+// span is the maximum distance between e and the target of any attestation
+// which spans over e. An attestation (s, t) therefore surrounds a previous
+// attestation if minSpan(s) < t-s, and is surrounded by one if maxSpan(s) > t-s.
+package spans
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket     = []byte("spans-meta")
+	minSpansBucket = []byte("min-spans")
+	maxSpansBucket = []byte("max-spans")
+
+	// floorKey is the lowest source epoch which spans are maintained for.
+	floorKey = []byte("floor")
+)
+
+// Surround is the kind of surround vote an attestation is.
+type Surround int
+
+const (
+	NotSurround Surround = iota
+	Surrounding
+	Surrounded
+)
+
+// Attestation is the source and target epochs of an attestation.
+type Attestation struct {
+	Source phase0.Epoch
+	Target phase0.Epoch
+}
+
+// CreateBuckets creates the buckets used by this package.
+func CreateBuckets(tx *bolt.Tx) error {
+	for _, name := range [][]byte{metaBucket, minSpansBucket, maxSpansBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return errors.Wrapf(err, "failed to create bucket %s", name)
+		}
+	}
+	return nil
+}
+
+// Check returns whether an attestation surrounds or is surrounded by any
+// attestation in the spans, and if so, the target epoch of that attestation.
+func Check(tx *bolt.Tx, att Attestation) (Surround, phase0.Epoch, error) {
+	if att.Target < att.Source {
+		return NotSurround, 0, errors.Errorf(
+			"source epoch %d is greater than target epoch %d",
+			att.Source,
+			att.Target,
+		)
+	}
+	distance := uint64(att.Target - att.Source)
+	if minSpan, ok := getSpan(tx.Bucket(minSpansBucket), att.Source); ok && minSpan < distance {
+		return Surrounding, att.Source + phase0.Epoch(minSpan), nil
+	}
+	if maxSpan, ok := getSpan(tx.Bucket(maxSpansBucket), att.Source); ok && maxSpan > distance {
+		return Surrounded, att.Source + phase0.Epoch(maxSpan), nil
+	}
+	return NotSurround, 0, nil
+}
+
+// Update updates the spans with the given attestations.
+//
+// Spans are only maintained from the lowest source epoch and upwards,
+// because attestations below it are always refused. Updating with a lower
+// source epoch therefore requires rebuilding the spans from all attestations,
+// which are retrieved with the given function.
+func Update(tx *bolt.Tx, atts []Attestation, all func() ([]Attestation, error)) error {
+	if len(atts) == 0 {
+		return nil
+	}
+	meta := tx.Bucket(metaBucket)
+	floor, hasFloor := getEpoch(meta.Get(floorKey))
+	rebuild := false
+	for _, att := range atts {
+		if att.Target < att.Source {
+			return errors.Errorf("source epoch %d is greater than target epoch %d", att.Source, att.Target)
+		}
+		if !hasFloor || att.Source < floor {
+			rebuild = rebuild || hasFloor
+			floor, hasFloor = att.Source, true
+		}
+	}
+	if err := meta.Put(floorKey, epochBytes(floor)); err != nil {
+		return err
+	}
+	if rebuild {
+		var err error
+		if atts, err = all(); err != nil {
+			return errors.Wrap(err, "failed to get attestations")
+		}
+		if err := clear(tx); err != nil {
+			return err
+		}
+		sort.Slice(atts, func(i, j int) bool {
+			return atts[i].Source < atts[j].Source
+		})
+	}
+	for _, att := range atts {
+		if err := update(tx, att, floor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild rebuilds the spans from all attestations, such as after
+// removing one, which the spans can't otherwise forget.
+func Rebuild(tx *bolt.Tx, atts []Attestation) error {
+	if err := clear(tx); err != nil {
+		return err
+	}
+	meta := tx.Bucket(metaBucket)
+	if len(atts) == 0 {
+		return meta.Delete(floorKey)
+	}
+	atts = append([]Attestation(nil), atts...)
+	sort.Slice(atts, func(i, j int) bool {
+		return atts[i].Source < atts[j].Source
+	})
+	floor := atts[0].Source
+	if err := meta.Put(floorKey, epochBytes(floor)); err != nil {
+		return err
+	}
+	for _, att := range atts {
+		if err := update(tx, att, floor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear deletes all spans.
+func clear(tx *bolt.Tx) error {
+	for _, name := range [][]byte{minSpansBucket, maxSpansBucket} {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update updates the min and max spans with the given attestation,
+// stopping early once a span is already tighter than what the attestation
+// would set, since all further spans must then be too.
+func update(tx *bolt.Tx, att Attestation, floor phase0.Epoch) error {
+	minSpans := tx.Bucket(minSpansBucket)
+	for e := att.Source; e > floor; {
+		e--
+		distance := uint64(att.Target - e)
+		if existing, ok := getSpan(minSpans, e); ok && existing <= distance {
+			break
+		}
+		if err := putSpan(minSpans, e, distance); err != nil {
+			return err
+		}
+	}
+	maxSpans := tx.Bucket(maxSpansBucket)
+	for e := att.Source + 1; e < att.Target; e++ {
+		distance := uint64(att.Target - e)
+		if existing, ok := getSpan(maxSpans, e); ok && existing >= distance {
+			break
+		}
+		if err := putSpan(maxSpans, e, distance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getSpan(b *bolt.Bucket, epoch phase0.Epoch) (uint64, bool) {
+	v := b.Get(epochBytes(epoch))
+	if len(v) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(v), true
+}
+
+func putSpan(b *bolt.Bucket, epoch phase0.Epoch, distance uint64) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, distance)
+	return b.Put(epochBytes(epoch), v)
+}
+
+func getEpoch(v []byte) (phase0.Epoch, bool) {
+	if len(v) != 8 {
+		return 0, false
+	}
+	return phase0.Epoch(binary.BigEndian.Uint64(v)), true
+}
+
+func epochBytes(epoch phase0.Epoch) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(epoch))
+	return b
+}
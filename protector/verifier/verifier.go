@@ -0,0 +1,197 @@
+// Package verifier verifies the stores of a protector in the background, so
+// that violations of their invariants surface before they cause a false
+// negative, rather than only when someone thinks to verify them.
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Violation is a store which was found violating its invariants.
+type Violation struct {
+	Network    string
+	PubKey     phase0.BLSPubKey
+	Findings   []kv.Finding
+	VerifiedAt time.Time
+}
+
+// Status is the state of the verification of the stores.
+type Status struct {
+	// LastPass is when the last pass over every store ended,
+	// or zero if none did, and Verified is how many stores it verified.
+	LastPass time.Time
+	Verified int
+
+	// Violations are the stores which violated their invariants when
+	// they were last verified, ordered by network and public key.
+	Violations []*Violation
+}
+
+type keyID struct {
+	network string
+	pubKey  phase0.BLSPubKey
+}
+
+// Verifier periodically verifies the stores of a protector one at a time.
+type Verifier struct {
+	logger    *zap.Logger
+	protector protector.ProtectorPooler
+
+	// deferred reports whether verification should wait for the next interval.
+	deferred func() bool
+
+	mu         sync.Mutex
+	lastPass   time.Time
+	verified   int
+	violations map[keyID]*Violation
+}
+
+// Option configures a Verifier.
+type Option func(*Verifier)
+
+// WithDeferral defers verification to the next interval while deferred
+// returns true, such as while checks are slower than their objective.
+func WithDeferral(deferred func() bool) Option {
+	return func(v *Verifier) {
+		v.deferred = deferred
+	}
+}
+
+// New returns a Verifier of the stores of protector.
+func New(logger *zap.Logger, protector protector.ProtectorPooler, opts ...Option) *Verifier {
+	v := &Verifier{
+		logger:     logger,
+		protector:  protector,
+		violations: make(map[keyID]*Violation),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Run verifies the stores every interval until ctx is done.
+func (v *Verifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.Verify(ctx); err != nil && ctx.Err() == nil {
+				v.logger.Error("failed to verify stores", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Verify verifies the stores of every network one at a time, so that at most
+// one key waits for verification at any time. Frozen stores are skipped,
+// since they can't change until they're thawed by a check.
+func (v *Verifier) Verify(ctx context.Context) error {
+	pool := v.protector.Pool()
+	networks, err := pool.Networks()
+	if err != nil {
+		return err
+	}
+	var verified, violations int
+	seen := make(map[keyID]bool)
+	for _, network := range networks {
+		pubKeys, err := pool.PubKeys(network)
+		if err != nil {
+			return err
+		}
+		for _, pubKey := range pubKeys {
+			if v.deferred != nil && v.deferred() {
+				v.logger.Info("deferred verification", zap.Int("verified", verified))
+				return nil
+			}
+			seen[keyID{network, pubKey}] = true
+			if pool.Frozen(network, pubKey) {
+				continue
+			}
+			findings, err := v.protector.Verify(ctx, network, pubKey)
+			if errors.Is(err, protector.ErrUnknownKey) {
+				// Deleted since it was listed.
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			verified++
+			if len(findings) > 0 {
+				violations++
+			}
+			v.record(network, pubKey, findings)
+		}
+	}
+
+	// Forget the violations of stores which were deleted since.
+	v.mu.Lock()
+	for id := range v.violations {
+		if !seen[id] {
+			delete(v.violations, id)
+		}
+	}
+	v.lastPass, v.verified = time.Now(), verified
+	v.mu.Unlock()
+	v.logger.Info("verified stores", zap.Int("verified", verified), zap.Int("violations", violations))
+	return nil
+}
+
+// record records the findings of a store, and logs them if they're new.
+func (v *Verifier) record(network string, pubKey phase0.BLSPubKey, findings []kv.Finding) {
+	id := keyID{network, pubKey}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(findings) == 0 {
+		delete(v.violations, id)
+		return
+	}
+	if _, ok := v.violations[id]; !ok {
+		v.logger.Error("store violates its invariants",
+			zap.String("network", network),
+			zap.String("pub_key", fmt.Sprintf("%#x", pubKey)),
+			zap.Any("findings", findings),
+		)
+	}
+	v.violations[id] = &Violation{
+		Network:    network,
+		PubKey:     pubKey,
+		Findings:   findings,
+		VerifiedAt: time.Now(),
+	}
+}
+
+// Status returns the state of the verification of the stores.
+func (v *Verifier) Status() *Status {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	status := &Status{
+		LastPass:   v.lastPass,
+		Verified:   v.verified,
+		Violations: make([]*Violation, 0, len(v.violations)),
+	}
+	for _, violation := range v.violations {
+		status.Violations = append(status.Violations, violation)
+	}
+	sort.Slice(status.Violations, func(i, j int) bool {
+		a, b := status.Violations[i], status.Violations[j]
+		if a.Network != b.Network {
+			return a.Network < b.Network
+		}
+		return string(a.PubKey[:]) < string(b.PubKey[:])
+	})
+	return status
+}
@@ -0,0 +1,54 @@
+package verifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	ctx := context.Background()
+	prtc := protector.New(t.TempDir())
+	defer prtc.Close()
+	pooler := prtc.(protector.ProtectorPooler)
+
+	// Import a surround vote into one key, which imports allow,
+	// and sign consistently with another.
+	surrounded, consistent := phase0.BLSPubKey{0x1}, phase0.BLSPubKey{0x2}
+	conn, err := pooler.Pool().Acquire(ctx, "mainnet", surrounded)
+	require.NoError(t, err)
+	require.NoError(t, conn.Import([]*kv.AttestationRecord{
+		{Source: 1, Target: 10},
+		{Source: 2, Target: 5},
+	}, nil))
+	require.NoError(t, conn.Release())
+	check, err := prtc.CheckProposal(ctx, "mainnet", consistent, phase0.Root{0x1}, 1)
+	require.NoError(t, err)
+	require.False(t, check.Slashable)
+
+	// Expect only the key with the surround vote to be reported.
+	v := New(zap.NewNop(), pooler)
+	require.NoError(t, v.Verify(ctx))
+	status := v.Status()
+	require.False(t, status.LastPass.IsZero())
+	require.Equal(t, 2, status.Verified)
+	require.Len(t, status.Violations, 1)
+	require.Equal(t, surrounded, status.Violations[0].PubKey)
+	require.Equal(t, "attestations", status.Violations[0].Findings[0].Check)
+
+	// Expect violations of deleted keys to be forgotten.
+	_, err = prtc.Delete(ctx, "mainnet", []phase0.BLSPubKey{surrounded}, false)
+	require.NoError(t, err)
+	require.NoError(t, v.Verify(ctx))
+	require.Empty(t, v.Status().Violations)
+
+	// Expect verification to wait while it's deferred.
+	v = New(zap.NewNop(), pooler, WithDeferral(func() bool { return true }))
+	require.NoError(t, v.Verify(ctx))
+	require.True(t, v.Status().LastPass.IsZero())
+}
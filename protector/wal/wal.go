@@ -0,0 +1,265 @@
+// Package wal implements a write-ahead log of attestation records which
+// are acknowledged before they're saved to their stores.
+//
+// Entries are fsynced before they're acknowledged, so they survive a crash of
+// the machine too, and concurrent appends share their fsyncs. Segments are
+// removed once their entries are saved.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// Entry is an acknowledged attestation record of a public key.
+type Entry struct {
+	Network string
+	PubKey  phase0.BLSPubKey
+	Record  kv.AttestationRecord
+}
+
+// WAL is a write-ahead log made of segment files in a directory.
+type WAL struct {
+	dir     string
+	entries []Entry
+
+	mu      sync.Mutex
+	file    *os.File
+	segment uint64
+	sealed  []string
+
+	// written is the number of entries appended, and synced is the number
+	// of them which are fsynced, which syncMu serializes the fsyncs of.
+	// syncMu is always locked before mu.
+	written uint64
+	syncMu  sync.Mutex
+	synced  uint64
+}
+
+// Open opens the WAL in dir and reads the entries left in it,
+// which must be saved before their segments are removed.
+func Open(dir string) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	segments, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(segments)
+
+	w := &WAL{dir: dir}
+	for _, path := range segments {
+		entries, err := readSegment(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read segment %s", path)
+		}
+		w.entries = append(w.entries, entries...)
+		w.sealed = append(w.sealed, path)
+		if _, err := fmt.Sscanf(filepath.Base(path), "%016x.wal", &w.segment); err != nil {
+			return nil, errors.Wrapf(err, "invalid segment name %s", path)
+		}
+	}
+	if err := w.create(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Entries returns the entries which were left in the WAL when it was opened.
+func (w *WAL) Entries() []Entry {
+	return w.entries
+}
+
+// Append writes an entry to the current segment, and returns once it's fsynced.
+func (w *WAL) Append(e Entry) error {
+	if len(e.Network) > 255 {
+		return errors.New("network name is too long")
+	}
+	b := encodeEntry(e)
+	w.mu.Lock()
+	if _, err := w.file.Write(b); err != nil {
+		w.mu.Unlock()
+		return errors.Wrap(err, "failed to write entry")
+	}
+	w.written++
+	written := w.written
+	w.mu.Unlock()
+	return w.sync(written)
+}
+
+// sync fsyncs the current segment unless the first n entries are fsynced
+// already, such as by the fsync of a concurrent append.
+func (w *WAL) sync(n uint64) error {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	if w.synced >= n {
+		return nil
+	}
+	w.mu.Lock()
+	file, written := w.file, w.written
+	w.mu.Unlock()
+	if err := file.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync segment")
+	}
+	w.synced = written
+	return nil
+}
+
+// Rotate starts a new segment and returns the segments before it, which
+// can be removed once all entries appended before the rotation are saved.
+func (w *WAL) Rotate() ([]string, error) {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sealed := w.file.Name()
+	if err := w.file.Sync(); err != nil {
+		return nil, errors.Wrap(err, "failed to sync segment")
+	}
+	w.synced = w.written
+	if err := w.file.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close segment")
+	}
+	w.sealed = append(w.sealed, sealed)
+	if err := w.create(); err != nil {
+		return nil, err
+	}
+	return append([]string(nil), w.sealed...), nil
+}
+
+// Remove removes segments returned by Rotate.
+func (w *WAL) Remove(segments []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	removed := make(map[string]bool, len(segments))
+	var err error
+	for _, path := range segments {
+		if rmErr := os.Remove(path); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = multierr.Append(err, rmErr)
+			continue
+		}
+		removed[path] = true
+	}
+	sealed := w.sealed[:0]
+	for _, path := range w.sealed {
+		if !removed[path] {
+			sealed = append(sealed, path)
+		}
+	}
+	w.sealed = sealed
+	return errors.Wrap(err, "failed to remove segments")
+}
+
+// Close closes the current segment.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// create creates the next segment.
+func (w *WAL) create() error {
+	w.segment++
+	path := filepath.Join(w.dir, fmt.Sprintf("%016x.wal", w.segment))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create segment")
+	}
+	w.file = file
+	return nil
+}
+
+// encodeEntry encodes an entry as it's length, payload and checksum.
+func encodeEntry(e Entry) []byte {
+	n := len(e.Network)
+	b := make([]byte, 4+entrySize(n)+4)
+	payload := b[4 : len(b)-4]
+	payload[0] = byte(n)
+	copy(payload[1:], e.Network)
+	copy(payload[1+n:], e.PubKey[:])
+	binary.BigEndian.PutUint64(payload[1+n+len(e.PubKey):], uint64(e.Record.Source))
+	binary.BigEndian.PutUint64(payload[1+n+len(e.PubKey)+8:], uint64(e.Record.Target))
+	roots := payload[1+n+len(e.PubKey)+16:]
+	copy(roots, e.Record.SigningRoot[:])
+	copy(roots[phase0.RootLength:], e.Record.SourceRoot[:])
+	copy(roots[2*phase0.RootLength:], e.Record.TargetRoot[:])
+	copy(roots[3*phase0.RootLength:], e.Record.BeaconBlockRoot[:])
+
+	binary.BigEndian.PutUint32(b, uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[len(b)-4:], crc32.ChecksumIEEE(payload))
+	return b
+}
+
+// entrySize returns the size of the payload of an entry in a network
+// with a name of the given length.
+func entrySize(networkLen int) int {
+	return 1 + networkLen + phase0.PublicKeyLength + 8 + 8 + 4*phase0.RootLength
+}
+
+// readSegment reads the entries of a segment up to the first incomplete or
+// corrupt one, which can only be the last one written before a crash.
+func readSegment(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	r := bufio.NewReader(f)
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			break
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size > uint32(entrySize(255)) {
+			break
+		}
+		b := make([]byte, size+4)
+		if _, err := io.ReadFull(r, b); err != nil {
+			break
+		}
+		payload, checksum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+		if crc32.ChecksumIEEE(payload) != checksum {
+			break
+		}
+		e, ok := decodeEntry(payload)
+		if !ok {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func decodeEntry(b []byte) (e Entry, ok bool) {
+	if len(b) < 1 || len(b) != entrySize(int(b[0])) {
+		return e, false
+	}
+	n := int(b[0])
+	e.Network, b = string(b[1:1+n]), b[1+n:]
+	copy(e.PubKey[:], b)
+	b = b[len(e.PubKey):]
+	e.Record.Source = phase0.Epoch(binary.BigEndian.Uint64(b))
+	e.Record.Target = phase0.Epoch(binary.BigEndian.Uint64(b[8:]))
+	roots := b[16:]
+	copy(e.Record.SigningRoot[:], roots)
+	copy(e.Record.SourceRoot[:], roots[phase0.RootLength:])
+	copy(e.Record.TargetRoot[:], roots[2*phase0.RootLength:])
+	copy(e.Record.BeaconBlockRoot[:], roots[3*phase0.RootLength:])
+	return e, true
+}
@@ -0,0 +1,92 @@
+package wal
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/slashing-protector/protector/kv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL(t *testing.T) {
+	dir := t.TempDir()
+	entries := []Entry{
+		{Network: "mainnet", PubKey: phase0.BLSPubKey{0x1}, Record: kv.AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}, SourceRoot: phase0.Root{0x3}, TargetRoot: phase0.Root{0x4}, BeaconBlockRoot: phase0.Root{0x5}}},
+		{Network: "prater", PubKey: phase0.BLSPubKey{0x2}, Record: kv.AttestationRecord{Source: 3, Target: 4, SigningRoot: phase0.Root{0x2}}},
+	}
+
+	// Append an entry to a sealed segment and another to the current one.
+	w, err := Open(dir)
+	require.NoError(t, err)
+	require.Empty(t, w.Entries())
+	require.NoError(t, w.Append(entries[0]))
+	_, err = w.Rotate()
+	require.NoError(t, err)
+	require.NoError(t, w.Append(entries[1]))
+
+	// Simulate a torn write of a third entry.
+	torn := encodeEntry(entries[0])
+	_, err = w.file.Write(torn[:len(torn)-1])
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// Expect the complete entries to be read.
+	w, err = Open(dir)
+	require.NoError(t, err)
+	require.Equal(t, entries, w.Entries())
+
+	// Expect removed segments not to be read again.
+	segments, err := w.Rotate()
+	require.NoError(t, err)
+	require.NoError(t, w.Remove(segments))
+	require.NoError(t, w.Close())
+	w, err = Open(dir)
+	require.NoError(t, err)
+	require.Empty(t, w.Entries())
+	require.NoError(t, w.Close())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2, "expected the empty segments of the last two opens")
+}
+
+func TestDecodeEntry_Size(t *testing.T) {
+	// Expect only payloads of the exact size of an entry to be decoded.
+	e := Entry{Network: "mainnet", PubKey: phase0.BLSPubKey{0x1}, Record: kv.AttestationRecord{Source: 1, Target: 2, SigningRoot: phase0.Root{0x1}}}
+	payload := encodeEntry(e)[4:]
+	payload = payload[:len(payload)-4]
+	decoded, ok := decodeEntry(payload)
+	require.True(t, ok)
+	require.Equal(t, e, decoded)
+	_, ok = decodeEntry(payload[:len(payload)-phase0.RootLength])
+	require.False(t, ok)
+	_, ok = decodeEntry(append(payload, 0))
+	require.False(t, ok)
+}
+
+func TestWAL_Sync(t *testing.T) {
+	w, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer w.Close()
+
+	// Expect concurrent appends to return once they're fsynced.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e := Entry{Network: "mainnet", PubKey: phase0.BLSPubKey{byte(i)}, Record: kv.AttestationRecord{Source: 1, Target: 2}}
+			require.NoError(t, w.Append(e))
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, uint64(10), w.written)
+	require.Equal(t, w.written, w.synced)
+
+	// Expect rotating to fsync the sealed segment.
+	_, err = w.Rotate()
+	require.NoError(t, err)
+	require.Equal(t, w.written, w.synced)
+}